@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"io"
+	"net/netip"
+	"os"
+
+	"github.com/spf13/cobra"
+	addr "github.com/thatmattlove/addr/pkg"
+)
+
+var aggregateExclude []string
+
+var AggregateCmd *cobra.Command = &cobra.Command{
+	Use:   "aggregate [file...]",
+	Short: "Aggregate prefixes from files or stdin into the minimal covering set",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			args = []string{"-"}
+		}
+		prefixes := []netip.Prefix{}
+		for _, arg := range args {
+			p, err := readPrefixList(cmd, arg)
+			if err != nil {
+				cmd.PrintErr(err.Error() + "\n")
+				os.Exit(1)
+			}
+			prefixes = append(prefixes, p...)
+		}
+		exclude := []netip.Prefix{}
+		for _, x := range aggregateExclude {
+			if p, err := addr.NewPrefixInfo(x); err == nil {
+				exclude = append(exclude, p.Prefix)
+				continue
+			}
+			p, err := readPrefixList(cmd, x)
+			if err != nil {
+				cmd.PrintErr(err.Error() + "\n")
+				os.Exit(1)
+			}
+			exclude = append(exclude, p...)
+		}
+		for _, p := range addr.Exclude(prefixes, exclude) {
+			cmd.Println(p.String())
+		}
+	},
+}
+
+// readPrefixList reads a prefix list from a file, or from stdin if name is '-'.
+func readPrefixList(cmd *cobra.Command, name string) ([]netip.Prefix, error) {
+	var r io.Reader = cmd.InOrStdin()
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	return addr.ParsePrefixList(r)
+}
+
+func init() {
+	AggregateCmd.Flags().StringSliceVar(&aggregateExclude, "exclude", nil, "prefix, or file of prefixes, to remove from the result")
+}
@@ -1,37 +1,87 @@
 package cmd
 
 import (
+	"errors"
+	"fmt"
 	"os"
 
+	"github.com/biter777/countries"
+	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
-	"github.com/thatmattlove/addr/cmd/style"
-	"github.com/thatmattlove/addr/internal/util"
 	addr "github.com/thatmattlove/addr/pkg"
 )
 
+var showNeighbors bool
+var asRelFiles []string
+
 var ASNCmd *cobra.Command = &cobra.Command{
 	Use:   "asn",
-	Short: "Look up an ASN",
+	Short: "Look up an ASN or range of ASNs",
+	Long: `Look up an ASN or range of ASNs.
+
+With --neighbors, the upstreams, downstreams & peers of each ASN are shown too. They're found in the
+AS paths of --rib dumps, and in CAIDA AS relationship files (--as-rel), whose relationships are
+used for the links they have. Otherwise, relationships are inferred from the AS paths.`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if !showNeighbors {
+			return nil
+		}
+		err := loadASRelationships()
+		if err != nil {
+			return err
+		}
+		if (queryOptions.RIB == nil || queryOptions.RIB.Len() == 0) && asRelationships.Len() == 0 {
+			return fmt.Errorf("%w, use --rib or --as-rel", addr.ErrNoNeighborData)
+		}
+		return nil
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		if len(args) == 0 {
 			cmd.Help()
 			os.Exit(0)
 		}
-		s := style.NewSpinner(cmd)
-		for _, arg := range args {
-			if util.IsASN(arg) {
-				p, _ := s.Start()
-				r, err := addr.QueryASN(arg)
-				p.Stop()
-				if err != nil {
-					cmd.PrintErr(err.Error() + "\n")
-					os.Exit(1)
-				}
-				cmd.Println(style.ASNBox(r))
-			} else {
-				cmd.PrintErrf("invalid argument '%s'\n", arg)
-				os.Exit(1)
-			}
-		}
+		lookupASNs(cmd, newSpinner(cmd), args)
+		flushOutput(cmd)
 	},
 }
+
+// lookupASNs looks up each ASN or range of ASNs in args.
+func lookupASNs(cmd *cobra.Command, s pterm.SpinnerPrinter, args []string) {
+	for _, arg := range joinRangeArgs(args) {
+		r, err := addr.ParseASNRange(arg)
+		if err != nil {
+			printError(cmd, arg, addr.RESULT_ASN, err)
+			continue
+		}
+		lookupASNRange(cmd, s, r)
+	}
+}
+
+// lookupASNRange looks up each ASN in a range, which may be a single ASN.
+func lookupASNRange(cmd *cobra.Command, s pterm.SpinnerPrinter, r addr.ASNRange) {
+	for _, asn := range r.ASNs() {
+		p, _ := s.Start()
+		res, err := queryASN(asn.ASPlain())
+		p.Stop()
+		if showNeighbors && errors.Is(err, addr.ErrOfflineASN) {
+			// Neighbors are still worth showing without anything else known about the ASN.
+			res, err = &addr.Response{ASN: asn, Country: countries.Unknown}, nil
+		}
+		if err != nil {
+			printError(cmd, "AS"+asn.ASPlain(), addr.RESULT_ASN, err)
+			continue
+		}
+		if showNeighbors {
+			// Copied, as the shell caches responses.
+			withNeighbors := *res
+			withNeighbors.Neighbors = addr.LookupNeighbors(asn.Uint32(), queryOptions.RIB, asRelationships)
+			res = &withNeighbors
+		}
+		printASN(cmd, "AS"+asn.ASPlain(), res)
+	}
+}
+
+func init() {
+	ASNCmd.Flags().BoolVar(&showNeighbors, "neighbors", false, "show upstreams, downstreams & peers from --rib AS paths & --as-rel")
+	ASNCmd.Flags().StringSliceVar(&asRelFiles, "as-rel", nil, "CAIDA AS relationship files, e.g. 20231001.as-rel2.txt.bz2 (default *as-rel* in --data-dir)")
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/thatmattlove/addr/cmd/style"
+	addr "github.com/thatmattlove/addr/pkg"
+)
+
+var calcSubnet int
+var calcSupernet int
+
+var CalcCmd *cobra.Command = &cobra.Command{
+	Use:   "calc",
+	Short: "Calculate prefix details, subnets & supernets",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			cmd.Help()
+			os.Exit(0)
+		}
+		for _, arg := range args {
+			info, err := addr.NewPrefixInfo(arg)
+			if err != nil {
+				cmd.PrintErr(err.Error() + "\n")
+				os.Exit(1)
+			}
+			cmd.Println(style.CalcBox(info))
+			if cmd.Flags().Changed("supernet") {
+				super, err := addr.Supernet(info.Prefix, calcSupernet)
+				if err != nil {
+					cmd.PrintErr(err.Error() + "\n")
+					os.Exit(1)
+				}
+				cmd.Println(super.String())
+			}
+			if cmd.Flags().Changed("subnet") {
+				subnets, err := addr.Subnets(info.Prefix, calcSubnet)
+				if err != nil {
+					cmd.PrintErr(err.Error() + "\n")
+					os.Exit(1)
+				}
+				for _, s := range subnets {
+					cmd.Println(s.String())
+				}
+			}
+		}
+	},
+}
+
+func init() {
+	CalcCmd.Flags().IntVar(&calcSubnet, "subnet", 0, "split the prefix into subnets of this length")
+	CalcCmd.Flags().IntVar(&calcSupernet, "supernet", 0, "show the supernet of this length containing the prefix")
+}
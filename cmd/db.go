@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/thatmattlove/addr/cmd/style"
+	"github.com/thatmattlove/addr/internal/util"
+	addr "github.com/thatmattlove/addr/pkg"
+)
+
+var DBCmd *cobra.Command = &cobra.Command{
+	Use:   "db",
+	Short: "Manage local databases",
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+		os.Exit(0)
+	},
+}
+
+var DBUpdateSpecialCmd *cobra.Command = &cobra.Command{
+	Use:   "update-special",
+	Short: "Download the latest IANA special-purpose address & AS number registries",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		s := style.NewSpinner(cmd)
+		sources := []struct {
+			url      string
+			file     string
+			download func(url, dest string) (int, error)
+		}{
+			{addr.IANA_SPECIAL_V4_URL, addr.SPECIAL_V4_FILE, addr.DownloadSpecialRegistry},
+			{addr.IANA_SPECIAL_V6_URL, addr.SPECIAL_V6_FILE, addr.DownloadSpecialRegistry},
+			{addr.IANA_SPECIAL_ASN_URL, addr.SPECIAL_ASN_FILE, addr.DownloadSpecialASNRegistry},
+		}
+		for _, src := range sources {
+			dest := filepath.Join(dataDir, src.file)
+			p, _ := s.Start()
+			n, err := src.download(src.url, dest)
+			p.Stop()
+			if err != nil {
+				cmd.PrintErr(err.Error() + "\n")
+				os.Exit(1)
+			}
+			cmd.Printf("updated %s (%d entries)\n", dest, n)
+		}
+	},
+}
+
+var DBImportDelegatedCmd *cobra.Command = &cobra.Command{
+	Use:   "import-delegated [file or URL...]",
+	Short: "Import RIR delegated-extended statistics for offline registry & allocation lookups",
+	Long: "Import RIR delegated-extended statistics for offline registry & allocation lookups.\n\n" +
+		"Without arguments, the latest statistics of all five RIRs are downloaded.",
+	Run: func(cmd *cobra.Command, args []string) {
+		sources := args
+		if len(sources) == 0 {
+			sources = addr.DELEGATED_URLS
+		}
+		s := style.NewSpinner(cmd)
+		dest := filepath.Join(dataDir, addr.DELEGATED_FILE)
+		p, _ := s.Start()
+		counts, err := addr.ImportDelegated(dest, sources...)
+		p.Stop()
+		if err != nil {
+			cmd.PrintErr(err.Error() + "\n")
+			os.Exit(1)
+		}
+		total := 0
+		for i, src := range sources {
+			cmd.Printf("imported %s (%d entries)\n", src, counts[i])
+			total += counts[i]
+		}
+		cmd.Printf("updated %s (%d entries)\n", dest, total)
+	},
+}
+
+// loadSpecialTables replaces the embedded special-purpose registries with those given with
+// --special-v4, --special-v6 & --special-asn, or downloaded by 'addr db update-special'. Downloaded
+// registries that can't be read are skipped with a warning, so 'addr db update-special' can still
+// replace them.
+func loadSpecialTables(cmd *cobra.Command) error {
+	v4 := specialTablePath(specialV4File, addr.SPECIAL_V4_FILE)
+	v6 := specialTablePath(specialV6File, addr.SPECIAL_V6_FILE)
+	asn := specialTablePath(specialASNFile, addr.SPECIAL_ASN_FILE)
+	if v4 != "" || v6 != "" {
+		table, err := addr.LoadSpecialTable(v4, v6)
+		if err != nil {
+			if specialV4File != "" || specialV6File != "" {
+				return err
+			}
+			cmd.PrintErrf("warning: %s, using the embedded special-purpose address registries\n", err)
+		} else {
+			addr.SPECIAL_TABLE = table
+		}
+	}
+	if asn != "" {
+		table, err := addr.LoadSpecialASNTable(asn)
+		if err != nil {
+			if specialASNFile != "" {
+				return err
+			}
+			cmd.PrintErrf("warning: %s, using the embedded special-purpose AS number registry\n", err)
+		} else {
+			addr.SPECIAL_ASN_TABLE = table
+		}
+	}
+	return nil
+}
+
+// specialTablePath returns flag if set, or the path of a registry downloaded by
+// 'addr db update-special' if there is one.
+func specialTablePath(flag, name string) string {
+	if flag != "" {
+		return flag
+	}
+	path := filepath.Join(dataDir, name)
+	if !util.PathExists(path) {
+		return ""
+	}
+	return path
+}
+
+// loadMMDB opens the MMDB databases given with --mmdb, or any in the data directory.
+func loadMMDB() error {
+	paths := mmdbFiles
+	if len(paths) == 0 {
+		found, err := filepath.Glob(filepath.Join(dataDir, "*.mmdb"))
+		if err != nil {
+			return err
+		}
+		paths = found
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+	m, err := addr.OpenMMDB(paths...)
+	if err != nil {
+		return err
+	}
+	queryOptions.MMDB = m
+	return nil
+}
+
+// loadRIB reads the MRT RIB dumps given with --rib.
+func loadRIB() error {
+	if len(ribFiles) == 0 {
+		return nil
+	}
+	rib, err := addr.LoadRIB(ribFiles...)
+	if err != nil {
+		return err
+	}
+	queryOptions.RIB = rib
+	return nil
+}
+
+// loadPeeringDB uses the PeeringDB dumps given with --peeringdb, or the one in the data directory,
+// if present. They're loaded on first use.
+func loadPeeringDB() error {
+	paths := peeringDBFiles
+	for _, p := range paths {
+		if _, err := os.Stat(p); err != nil {
+			return err
+		}
+	}
+	if len(paths) == 0 {
+		path := filepath.Join(dataDir, addr.PEERINGDB_FILE)
+		if !util.PathExists(path) {
+			return nil
+		}
+		paths = []string{path}
+	}
+	queryOptions.PeeringDB = addr.OpenPeeringDB(paths...)
+	return nil
+}
+
+// asRelationships are read from --as-rel by loadASRelationships.
+var asRelationships = addr.NewASRelationships()
+
+// loadASRelationships reads the CAIDA AS relationship files given with --as-rel, or any in the data
+// directory.
+func loadASRelationships() error {
+	paths := asRelFiles
+	if len(paths) == 0 {
+		found, err := filepath.Glob(filepath.Join(dataDir, "*as-rel*"))
+		if err != nil {
+			return err
+		}
+		paths = found
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+	rels, err := addr.LoadASRelationships(paths...)
+	if err != nil {
+		return err
+	}
+	asRelationships = rels
+	return nil
+}
+
+// loadDelegated uses the delegations imported by 'addr db import-delegated', if present. They're
+// loaded on first use.
+func loadDelegated() {
+	path := filepath.Join(dataDir, addr.DELEGATED_FILE)
+	if util.PathExists(path) {
+		queryOptions.Delegated = addr.OpenDelegatedTable(path)
+	}
+}
+
+func init() {
+	DBCmd.AddCommand(DBUpdateSpecialCmd, DBImportDelegatedCmd)
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/thatmattlove/addr/cmd/style"
+	addr "github.com/thatmattlove/addr/pkg"
+)
+
+var DiffCmd *cobra.Command = &cobra.Command{
+	Use:   "diff <old.json> <new.json>",
+	Short: "Compare two snapshots of lookup results",
+	Long: `Compare two snapshots saved with 'addr snapshot save' or '--output json', reporting targets
+that were added or removed and changes to origin ASNs, prefixes, advertised state, names,
+countries, registries & PTRs. Exits 1 if the snapshots differ.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		before, err := readSnapshot(args[0])
+		if err != nil {
+			cmd.PrintErr(err.Error() + "\n")
+			os.Exit(2)
+		}
+		after, err := readSnapshot(args[1])
+		if err != nil {
+			cmd.PrintErr(err.Error() + "\n")
+			os.Exit(2)
+		}
+		diff := addr.DiffSnapshots(before, after)
+		for _, r := range diff.Added {
+			cmd.Println(style.Highlight1("+ "+r.Query), style.Subtle(snapshotSummary(r)))
+		}
+		for _, r := range diff.Removed {
+			cmd.Println(style.Title("- "+r.Query), style.Subtle(snapshotSummary(r)))
+		}
+		for _, c := range diff.Changed {
+			cmd.Println(style.Highlight2("~ " + c.Target))
+			for _, f := range c.Changes {
+				cmd.Printf("    %s: %s → %s\n", f.Field, snapshotValue(f.Old), snapshotValue(f.New))
+			}
+		}
+		if !diff.Empty() {
+			os.Exit(1)
+		}
+	},
+}
+
+func readSnapshot(name string) ([]*addr.Result, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	results, err := addr.ReadSnapshot(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return results, nil
+}
+
+// snapshotSummary describes an added or removed result in one line.
+func snapshotSummary(r *addr.Result) string {
+	if r.Error != "" {
+		return r.Error
+	}
+	parts := []string{}
+	if asn := r.Column("asn"); asn != "" && r.Type != addr.RESULT_ASN {
+		parts = append(parts, "AS"+asn)
+	}
+	for _, p := range []string{r.Prefix, r.Name} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func snapshotValue(v string) string {
+	if v == "" {
+		return "(none)"
+	}
+	return v
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/thatmattlove/addr/cmd/style"
+	addr "github.com/thatmattlove/addr/pkg"
+)
+
+// FLOWS_TOP is the number of ASNs in the flow summary, unless --top is set.
+const FLOWS_TOP int = 10
+
+var flowsTable string
+var flowsBy string
+var flowsSummary bool
+
+var FlowsCmd *cobra.Command = &cobra.Command{
+	Use:   "flows",
+	Short: "Enrich flow records with source & destination origin ASNs",
+	Long: `Enrich NetFlow, IPFIX & sFlow records exported as CSV or JSON by nfdump, pmacct or goflow2, read
+from stdin, with the origin ASN, prefix & AS name of each source & destination address.
+
+Enriched records are written to stdout in the format they were read, and a summary of the top
+talkers by source ASN (--by) is written to stderr. With --summary, only the summary is written, to
+stdout. Addresses are looked up in bulk with bgp.tools, or offline with --table, a file of prefixes &
+origin ASNs in bgp.tools' table.txt or table.jsonl format.
+
+Example: nfdump -r nfcapd.202610191000 -o csv | addr flows > enriched.csv`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if flowsBy != addr.FLOW_SRC && flowsBy != addr.FLOW_DST {
+			cmd.PrintErr(fmt.Sprintf("--by must be %s or %s\n", addr.FLOW_SRC, addr.FLOW_DST))
+			os.Exit(1)
+		}
+		f, err := addr.ReadFlows(cmd.InOrStdin())
+		if err != nil {
+			cmd.PrintErr(err.Error() + "\n")
+			os.Exit(1)
+		}
+		if flowsTable != "" {
+			table, err := addr.LoadPrefixTable(flowsTable)
+			if err != nil {
+				cmd.PrintErr(err.Error() + "\n")
+				os.Exit(1)
+			}
+			f.Enrich(func(a netip.Addr) addr.FlowOrigin {
+				p, asn, _ := table.Lookup(a)
+				return addr.FlowOrigin{ASN: asn, Prefix: p}
+			})
+		} else {
+			addrs := f.Addrs()
+			s := newSpinner(cmd)
+			p, _ := s.Start(fmt.Sprintf("Looking up %d addresses", len(addrs)))
+			origins := addr.QueryFlowOrigins(addrs, queryOptions)
+			p.Stop()
+			f.Enrich(func(a netip.Addr) addr.FlowOrigin {
+				return origins[a]
+			})
+		}
+		top := summaryTop
+		if !cmd.Flags().Changed("top") {
+			top = FLOWS_TOP
+		}
+		summary, err := addr.SummarizeFlows(f, flowsBy, top)
+		if err != nil {
+			cmd.PrintErr(err.Error() + "\n")
+			os.Exit(1)
+		}
+		format := outputMode
+		if format == "" {
+			format = style.OUTPUT_TABLE
+		}
+		out, err := style.FlowTable(format, summary)
+		if err != nil {
+			cmd.PrintErr(err.Error() + "\n")
+			os.Exit(1)
+		}
+		if flowsSummary {
+			cmd.Println(out)
+			return
+		}
+		err = addr.WriteFlows(cmd.OutOrStdout(), f)
+		if err != nil {
+			cmd.PrintErr(err.Error() + "\n")
+			os.Exit(1)
+		}
+		cmd.PrintErrln(out)
+	},
+}
+
+func init() {
+	FlowsCmd.Flags().StringVar(&flowsTable, "table", "", "look up origins offline in a table of prefixes & ASNs (bgp.tools table.txt or table.jsonl)")
+	FlowsCmd.Flags().StringVar(&flowsBy, "by", addr.FLOW_SRC, "summarize top talkers by src or dst ASN")
+	FlowsCmd.Flags().BoolVar(&flowsSummary, "summary", false, "only print the summary, to stdout")
+}
@@ -1,39 +1,120 @@
 package cmd
 
 import (
+	"fmt"
+	"net"
 	"os"
 
+	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
-	"github.com/thatmattlove/addr/cmd/style"
 	"github.com/thatmattlove/addr/internal/util"
 	addr "github.com/thatmattlove/addr/pkg"
 )
 
+// queryOptions are set from the root command's flags.
+var queryOptions addr.QueryOptions
+
+// queryIP, queryASN & reverseLookup are replaced by the shell, which reuses a whois client and
+// caches results between commands.
+var (
+	queryIP       func(string) (*addr.Response, error) = queryIPPrefix
+	queryASN      func(string) (*addr.Response, error) = queryASNWith
+	reverseLookup func(*net.IP) ([]string, error)      = dnsReverseLookup
+)
+
 var IPCmd *cobra.Command = &cobra.Command{
 	Use:   "ip",
-	Short: "Look up an IP address or prefix",
+	Short: "Look up an IP address, prefix or range",
 	Run: func(cmd *cobra.Command, args []string) {
 		if len(args) == 0 {
 			cmd.Help()
 			os.Exit(0)
 		}
-		s := style.NewSpinner(cmd)
-		for _, arg := range args {
-			if util.IsIP(arg) {
-				p, _ := s.Start()
-				r, err := addr.QueryIPPrefix(arg)
-				if err != nil {
-					p.Stop()
-					cmd.PrintErr(err.Error() + "\n")
-					os.Exit(1)
-				}
-				ptrs, _ := addr.DNSReverseLookup(r.IP)
-				p.Stop()
-				cmd.Println(style.IPBox(r, ptrs))
-			} else {
-				cmd.PrintErrf("invalid argument '%s'\n", arg)
-				os.Exit(1)
-			}
-		}
+		lookupIPs(cmd, newSpinner(cmd), args)
+		flushOutput(cmd)
 	},
 }
+
+func queryIPPrefix(q string) (*addr.Response, error) {
+	return addr.QueryIPPrefixWith(nil, q, queryOptions)
+}
+
+func queryASNWith(q string) (*addr.Response, error) {
+	return addr.QueryASNWith(nil, q, queryOptions)
+}
+
+// dnsReverseLookup & dnsForwardLookup fail with addr.ErrOfflineDNS if --offline is set.
+func dnsReverseLookup(ip *net.IP) ([]string, error) {
+	if queryOptions.Offline {
+		return nil, addr.ErrOfflineDNS
+	}
+	return addr.DNSReverseLookup(ip)
+}
+
+func dnsForwardLookup(host string) ([]net.IP, []net.IP, error) {
+	if queryOptions.Offline {
+		return nil, nil, addr.ErrOfflineDNS
+	}
+	return addr.DNSForwardLookup(host)
+}
+
+// lookupIPs looks up each IP address, prefix or range in args.
+func lookupIPs(cmd *cobra.Command, s pterm.SpinnerPrinter, args []string) {
+	for _, arg := range joinRangeArgs(args) {
+		if util.IsIP(arg) {
+			lookupIP(cmd, s, arg)
+		} else if addr.IsIPRange(arg) {
+			lookupIPRange(cmd, s, arg)
+		} else {
+			printError(cmd, arg, addr.RESULT_IP, fmt.Errorf("invalid argument '%s'", arg))
+		}
+	}
+}
+
+// lookupIP looks up the origin of an IP address or prefix.
+func lookupIP(cmd *cobra.Command, s pterm.SpinnerPrinter, arg string) {
+	p, _ := s.Start()
+	r, err := queryIP(arg)
+	if err != nil {
+		p.Stop()
+		printError(cmd, arg, addr.RESULT_IP, err)
+		return
+	}
+	ptrs, _ := reverseLookup(r.IP)
+	p.Stop()
+	printIP(cmd, arg, r, ptrs)
+}
+
+// lookupIPRange looks up the origin of each prefix covering an IP range.
+func lookupIPRange(cmd *cobra.Command, s pterm.SpinnerPrinter, arg string) {
+	rng, err := addr.ParseIPRange(arg)
+	if err != nil {
+		printError(cmd, arg, addr.RESULT_IP, err)
+		return
+	}
+	for _, pfx := range rng.Prefixes() {
+		p, _ := s.Start()
+		r, err := queryIP(pfx.String())
+		p.Stop()
+		if err != nil {
+			printError(cmd, pfx.String(), addr.RESULT_IP, err)
+			continue
+		}
+		printIP(cmd, pfx.String(), r, nil)
+	}
+}
+
+// joinRangeArgs rejoins RIR style ranges, e.g. '192.0.2.0 - 192.0.2.255', that the shell split
+// into separate arguments.
+func joinRangeArgs(args []string) []string {
+	joined := []string{}
+	for i := 0; i < len(args); i++ {
+		if i+2 < len(args) && args[i+1] == "-" {
+			joined = append(joined, args[i]+"-"+args[i+2])
+			i += 2
+			continue
+		}
+		joined = append(joined, args[i])
+	}
+	return joined
+}
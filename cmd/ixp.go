@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/thatmattlove/addr/cmd/style"
+	addr "github.com/thatmattlove/addr/pkg"
+)
+
+var IXPCmd *cobra.Command = &cobra.Command{
+	Use:   "ixp <name|id>",
+	Short: "List an IXP's participants & their peering LAN addresses from PeeringDB",
+	Long: `List an IXP's participants & their peering LAN addresses from a PeeringDB dump (--peeringdb).
+
+The IXP is found by its PeeringDB ID, or by its name or long name, ignoring case. A partial name
+matches if only one IXP's name contains it.
+
+Example: addr ixp DE-CIX Frankfurt`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		db := queryOptions.PeeringDB
+		if db != nil && db.Err() != nil {
+			cmd.PrintErr(db.Err().Error() + "\n")
+			os.Exit(1)
+		}
+		if db == nil || db.Len() == 0 {
+			cmd.PrintErr(fmt.Sprintf("no PeeringDB dump loaded, use --peeringdb or place %s in %s\n", addr.PEERINGDB_FILE, dataDir))
+			os.Exit(1)
+		}
+		ix, err := db.LookupIX(strings.Join(args, " "))
+		if err != nil {
+			cmd.PrintErr(err.Error() + "\n")
+			os.Exit(1)
+		}
+		format := outputMode
+		if format == "" {
+			format = style.OUTPUT_TABLE
+		}
+		out, err := style.IXPTable(format, ix)
+		if err != nil {
+			cmd.PrintErr(err.Error() + "\n")
+			os.Exit(1)
+		}
+		cmd.Println(out)
+	},
+}
@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/thatmattlove/addr/pkg/metrics"
+	"github.com/thatmattlove/addr/pkg/metrics/prom"
+)
+
+var metricsListen string
+
+// serveMetrics records metrics and serves them for Prometheus on /metrics, if --metrics-listen is
+// set. The listener is opened before returning so address errors are reported immediately.
+func serveMetrics() error {
+	if metricsListen == "" {
+		return nil
+	}
+	ln, err := net.Listen("tcp", metricsListen)
+	if err != nil {
+		return err
+	}
+	m, h := prom.Handler()
+	metrics.HOOK = m
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", h)
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: time.Second * 10}
+	go srv.Serve(ln)
+	return nil
+}
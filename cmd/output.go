@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+	"github.com/thatmattlove/addr/cmd/style"
+	addr "github.com/thatmattlove/addr/pkg"
+)
+
+var outputFormat string
+var outputTemplate *template.Template
+var outputMode string
+var outputColumns []string
+var summaryBy string
+var summaryTop int
+var summaryTargets bool
+
+// tableResults are collected until all lookups are done, so tabular output can be aligned.
+var tableResults []*addr.Result
+var tableFailed bool
+
+// interactive is set by the shell, so failed lookups don't exit.
+var interactive bool
+
+// parseOutputFlags validates output flags before any lookups are made.
+func parseOutputFlags() error {
+	if outputFormat != "" && outputMode != "" {
+		return errors.New("--format and --output can't be used together")
+	}
+	if summaryBy != "" {
+		if outputFormat != "" {
+			return errors.New("--format and --summary-by can't be used together")
+		}
+		summaryBy = strings.ToLower(strings.TrimSpace(summaryBy))
+		if err := addr.ValidateSummaryKey(summaryBy); err != nil {
+			return err
+		}
+	}
+	if outputMode != "" {
+		if _, err := style.Table(outputMode, nil, nil); err != nil {
+			return err
+		}
+		for i, c := range outputColumns {
+			outputColumns[i] = strings.ToLower(strings.TrimSpace(c))
+		}
+		if err := addr.ValidateColumns(outputColumns); err != nil {
+			return err
+		}
+	}
+	if outputFormat == "" {
+		return nil
+	}
+	t, err := addr.ParseTemplate(outputFormat)
+	if err != nil {
+		return err
+	}
+	outputTemplate = t
+	return nil
+}
+
+// newSpinner returns a spinner that is hidden when results are machine-readable.
+func newSpinner(cmd *cobra.Command) pterm.SpinnerPrinter {
+	s := style.NewSpinner(cmd)
+	if outputTemplate != nil || outputMode != "" {
+		s.Writer = io.Discard
+	}
+	return s
+}
+
+// collecting returns true if results are collected and printed together by flushOutput.
+func collecting() bool {
+	return outputMode != "" || summaryBy != ""
+}
+
+func printIP(cmd *cobra.Command, query string, r *addr.Response, ptrs []string) {
+	if collecting() {
+		tableResults = append(tableResults, addr.NewIPResult(query, r, ptrs))
+		return
+	}
+	if outputTemplate != nil {
+		printResult(cmd, addr.NewIPResult(query, r, ptrs))
+		return
+	}
+	cmd.Println(style.IPBox(r, ptrs))
+}
+
+func printASN(cmd *cobra.Command, query string, r *addr.Response) {
+	if collecting() {
+		tableResults = append(tableResults, addr.NewASNResult(query, r))
+		return
+	}
+	if outputTemplate != nil {
+		printResult(cmd, addr.NewASNResult(query, r))
+		return
+	}
+	cmd.Println(style.ASNBox(r))
+}
+
+// printError prints a failed lookup and exits, unless running interactively. If an output
+// template is set, the error is also rendered with it. For tabular output, the error is added as
+// a row and the remaining lookups continue; flushOutput exits once the table is printed.
+func printError(cmd *cobra.Command, query, resultType string, err error) {
+	cmd.PrintErr(err.Error() + "\n")
+	if collecting() {
+		tableResults = append(tableResults, addr.NewErrorResult(query, resultType, err))
+		tableFailed = true
+		return
+	}
+	if outputTemplate != nil {
+		printResult(cmd, addr.NewErrorResult(query, resultType, err))
+	}
+	if !interactive {
+		os.Exit(1)
+	}
+}
+
+// flushOutput prints any collected tabular results or summary. It must be called once all lookups
+// are done.
+func flushOutput(cmd *cobra.Command) {
+	if !collecting() {
+		return
+	}
+	var out string
+	var err error
+	if summaryBy != "" {
+		out, err = summarize()
+	} else {
+		columns := outputColumns
+		if len(columns) == 0 {
+			columns = addr.DEFAULT_COLUMNS
+		}
+		out, err = style.Table(outputMode, columns, tableResults)
+	}
+	if err != nil {
+		cmd.PrintErr(err.Error() + "\n")
+		os.Exit(1)
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), out)
+	if tableFailed && !interactive {
+		os.Exit(1)
+	}
+	tableResults = nil
+	tableFailed = false
+}
+
+// summarize renders a summary of the collected results, as a table unless --output is set.
+func summarize() (string, error) {
+	s, err := addr.Summarize(summaryBy, tableResults, summaryTop)
+	if err != nil {
+		return "", err
+	}
+	format := outputMode
+	if format == "" {
+		format = style.OUTPUT_TABLE
+	}
+	return style.SummaryTable(format, s, summaryTargets)
+}
+
+func printResult(cmd *cobra.Command, r *addr.Result) {
+	out, err := addr.RenderTemplate(outputTemplate, r)
+	if err != nil {
+		cmd.PrintErr(err.Error() + "\n")
+		os.Exit(1)
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), out)
+}
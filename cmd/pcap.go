@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/thatmattlove/addr/cmd/style"
+	addr "github.com/thatmattlove/addr/pkg"
+)
+
+var pcapBy []string
+var pcapHosts bool
+
+var PcapCmd *cobra.Command = &cobra.Command{
+	Use:   "pcap",
+	Short: "Analyze packet captures",
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+		os.Exit(0)
+	},
+}
+
+var PcapSummarizeCmd *cobra.Command = &cobra.Command{
+	Use:   "summarize <capture>",
+	Short: "Summarize a pcap or pcapng file's traffic by origin ASN & country",
+	Long: `Summarize a pcap or pcapng file's traffic by origin ASN & country. Every source & destination
+address in the capture is looked up in bulk, and the packets & bytes each address sent & received are
+grouped by --by. Percentages are each group's share of all bytes sent & received.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		for i, by := range pcapBy {
+			pcapBy[i] = strings.ToLower(strings.TrimSpace(by))
+			if err := addr.ValidateSummaryKey(pcapBy[i]); err != nil {
+				cmd.PrintErr(err.Error() + "\n")
+				os.Exit(1)
+			}
+		}
+		c, err := readCapture(args[0])
+		if err != nil {
+			cmd.PrintErr(err.Error() + "\n")
+			os.Exit(1)
+		}
+		s := newSpinner(cmd)
+		p, _ := s.Start(fmt.Sprintf("Looking up %d addresses", len(c.Hosts)))
+		results := addr.QueryAddrs(c.Addrs(), queryOptions)
+		p.Stop()
+		summaries := make([]*addr.TrafficSummary, 0, len(pcapBy))
+		for _, by := range pcapBy {
+			ts, err := addr.SummarizeTraffic(by, c, results, summaryTop)
+			if err != nil {
+				cmd.PrintErr(err.Error() + "\n")
+				os.Exit(1)
+			}
+			summaries = append(summaries, ts)
+		}
+		format := outputMode
+		if format == "" {
+			format = style.OUTPUT_TABLE
+		}
+		if format == style.OUTPUT_JSON {
+			printCaptureJSON(cmd, c, results, summaries)
+			return
+		}
+		if pcapHosts {
+			out, err := style.CaptureHostsTable(format, c, results)
+			if err != nil {
+				cmd.PrintErr(err.Error() + "\n")
+				os.Exit(1)
+			}
+			cmd.Println(out)
+		}
+		for _, ts := range summaries {
+			out, err := style.TrafficTable(format, ts)
+			if err != nil {
+				cmd.PrintErr(err.Error() + "\n")
+				os.Exit(1)
+			}
+			cmd.Println(out)
+		}
+	},
+}
+
+func readCapture(name string) (*addr.Capture, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	c, err := addr.ReadCapture(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return c, nil
+}
+
+type captureHostJSON struct {
+	IP       string            `json:"ip"`
+	Sent     addr.TrafficCount `json:"sent"`
+	Received addr.TrafficCount `json:"received"`
+	Result   *addr.Result      `json:"result"`
+}
+
+func printCaptureJSON(cmd *cobra.Command, c *addr.Capture, results []*addr.Result, summaries []*addr.TrafficSummary) {
+	out := struct {
+		Total     addr.TrafficCount      `json:"total"`
+		Skipped   uint64                 `json:"skipped"`
+		Hosts     []captureHostJSON      `json:"hosts,omitempty"`
+		Summaries []*addr.TrafficSummary `json:"summaries"`
+	}{Total: c.Total, Skipped: c.Skipped, Summaries: summaries}
+	if pcapHosts {
+		for i, h := range c.Hosts {
+			out.Hosts = append(out.Hosts, captureHostJSON{IP: h.Addr.String(), Sent: h.Sent, Received: h.Received, Result: results[i]})
+		}
+	}
+	b, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		cmd.PrintErr(err.Error() + "\n")
+		os.Exit(1)
+	}
+	cmd.Println(string(b))
+}
+
+func init() {
+	PcapSummarizeCmd.Flags().StringSliceVar(&pcapBy, "by", []string{addr.SUMMARY_ASN, addr.SUMMARY_COUNTRY}, "group traffic by "+strings.Join(addr.SUMMARY_KEYS, ", "))
+	PcapSummarizeCmd.Flags().BoolVar(&pcapHosts, "hosts", false, "also list each address with its traffic & origin")
+	PcapCmd.AddCommand(PcapSummarizeCmd)
+}
@@ -1,55 +1,144 @@
 package cmd
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
+	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
-	"github.com/thatmattlove/addr/cmd/style"
 	"github.com/thatmattlove/addr/internal/util"
 	addr "github.com/thatmattlove/addr/pkg"
 )
 
+var dataDir string
+var nat64Prefixes []string
+var definitionsFile string
+var mmdbFiles []string
+var offline bool
+var ribFiles []string
+var peeringDBFiles []string
+var specialV4File string
+var specialV6File string
+var specialASNFile string
+
 func Init(version string) *cobra.Command {
 	root := &cobra.Command{
 		Use:     "addr",
 		Short:   "addr is a tool to look up IP & ASN ownership and routing information.",
 		Args:    cobra.ArbitraryArgs,
 		Version: version,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			queryOptions = addr.QueryOptions{Offline: offline}
+			for _, p := range nat64Prefixes {
+				pfx, err := addr.ParseNAT64Prefix(p)
+				if err != nil {
+					return err
+				}
+				queryOptions.NAT64Prefixes = append(queryOptions.NAT64Prefixes, pfx)
+			}
+			err := parseOutputFlags()
+			if err != nil {
+				return err
+			}
+			err = loadLocalDefinitions()
+			if err != nil {
+				return err
+			}
+			err = serveMetrics()
+			if err != nil {
+				return err
+			}
+			err = loadMMDB()
+			if err != nil {
+				return err
+			}
+			loadDelegated()
+			err = loadPeeringDB()
+			if err != nil {
+				return err
+			}
+			err = loadRIB()
+			if err != nil {
+				return err
+			}
+			return loadSpecialTables(cmd)
+		},
 		Run: func(cmd *cobra.Command, args []string) {
 			if len(args) == 0 {
 				cmd.Help()
 				os.Exit(0)
 			}
-			s := style.NewSpinner(cmd)
-			for _, arg := range args {
-				if util.IsIP(arg) {
-					p, _ := s.Start()
-					r, err := addr.QueryIPPrefix(arg)
-					if err != nil {
-						p.Stop()
-						cmd.PrintErr(err.Error() + "\n")
-						os.Exit(1)
-					}
-					ptrs, _ := addr.DNSReverseLookup(r.IP)
-					p.Stop()
-					cmd.Println(style.IPBox(r, ptrs))
-				} else if util.IsASN(arg) {
-					p, _ := s.Start()
-					r, err := addr.QueryASN(arg)
-					p.Stop()
-					if err != nil {
-						cmd.PrintErr(err.Error() + "\n")
-						os.Exit(1)
-					}
-					cmd.Println(style.ASNBox(r))
-				} else {
-					cmd.PrintErrf("invalid argument '%s'\n", arg)
-					os.Exit(1)
-				}
-			}
+			lookupTargets(cmd, newSpinner(cmd), args)
+			flushOutput(cmd)
 			os.Exit(0)
 		},
 	}
-	root.AddCommand(ASNCmd, IPCmd)
+	root.PersistentFlags().StringVar(&dataDir, "data-dir", util.DataDir(), "directory for downloaded databases")
+	root.PersistentFlags().StringSliceVar(&nat64Prefixes, "nat64-prefix", nil, "network-specific NAT64 prefix to extract IPv4 addresses from")
+	root.PersistentFlags().StringVar(&definitionsFile, "definitions", "", "local prefix & ASN definitions file (YAML, JSON or CSV)")
+	root.PersistentFlags().StringVar(&outputFormat, "format", "", "render each result with a Go template, e.g. '{{.IP}} {{.ASN}} {{.Name}}'")
+	root.PersistentFlags().StringVarP(&outputMode, "output", "o", "", "print results as csv, tsv, markdown, table or json")
+	root.PersistentFlags().StringSliceVar(&outputColumns, "columns", nil, "columns for --output, from "+strings.Join(addr.RESULT_COLUMNS, ", "))
+	root.PersistentFlags().StringVar(&summaryBy, "summary-by", "", "summarize results by "+strings.Join(addr.SUMMARY_KEYS, ", ")+" instead of printing each one")
+	root.PersistentFlags().IntVar(&summaryTop, "top", 0, "only show the largest groups with --summary-by or pcap summarize, 0 for all")
+	root.PersistentFlags().BoolVar(&summaryTargets, "summary-targets", false, "list the targets in each group with --summary-by")
+	root.PersistentFlags().StringSliceVar(&mmdbFiles, "mmdb", nil, "MMDB databases for locations & ASNs, e.g. GeoLite2-City.mmdb (default *.mmdb in --data-dir)")
+	root.PersistentFlags().BoolVar(&offline, "offline", false, "don't query bgp.tools or DNS, answering lookups from --rib, MMDB databases, imported delegations & PeeringDB only")
+	root.PersistentFlags().StringSliceVar(&ribFiles, "rib", nil, "MRT TABLE_DUMP_V2 RIB dumps to answer IP lookups from, e.g. rib.20231018.0000.bz2")
+	root.PersistentFlags().StringSliceVar(&peeringDBFiles, "peeringdb", nil, "PeeringDB dumps for ASN results & 'addr ixp' (default "+addr.PEERINGDB_FILE+" in --data-dir)")
+	root.PersistentFlags().StringVar(&specialV4File, "special-v4", "", "IANA IPv4 special-purpose address registry CSV (default "+addr.SPECIAL_V4_FILE+" in --data-dir, if downloaded)")
+	root.PersistentFlags().StringVar(&specialV6File, "special-v6", "", "IANA IPv6 special-purpose address registry CSV (default "+addr.SPECIAL_V6_FILE+" in --data-dir, if downloaded)")
+	root.PersistentFlags().StringVar(&specialASNFile, "special-asn", "", "IANA special-purpose AS numbers registry CSV (default "+addr.SPECIAL_ASN_FILE+" in --data-dir, if downloaded)")
+	root.PersistentFlags().StringVar(&metricsListen, "metrics-listen", "", "serve Prometheus metrics on this address, e.g. ':9100'")
+	root.AddCommand(ASNCmd, IPCmd, AggregateCmd, CalcCmd, DBCmd, ServeCmd, ShellCmd, WatchCmd, SnapshotCmd, DiffCmd, TraceAnnotateCmd, PcapCmd, FlowsCmd, IXPCmd)
 	return root
 }
+
+// lookupTargets looks up each IP address, prefix, range, ASN or range of ASNs in args.
+func lookupTargets(cmd *cobra.Command, s pterm.SpinnerPrinter, args []string) {
+	for _, arg := range joinRangeArgs(args) {
+		if util.IsIP(arg) {
+			lookupIP(cmd, s, arg)
+		} else if addr.IsIPRange(arg) {
+			lookupIPRange(cmd, s, arg)
+		} else if r, err := addr.ParseASNRange(arg); err == nil {
+			lookupASNRange(cmd, s, r)
+		} else if looksLikeASN(arg) {
+			printError(cmd, arg, addr.RESULT_ASN, err)
+		} else {
+			printError(cmd, arg, "", fmt.Errorf("invalid argument '%s'", arg))
+		}
+	}
+}
+
+// loadLocalDefinitions loads the file passed with --definitions or, if not set, the first
+// definitions file found in the config directory.
+func loadLocalDefinitions() error {
+	path := definitionsFile
+	if path == "" {
+		for _, ext := range []string{"yaml", "yml", "json", "csv"} {
+			p := filepath.Join(util.ConfigDir(), "definitions."+ext)
+			if util.PathExists(p) {
+				path = p
+				break
+			}
+		}
+	}
+	if path == "" {
+		return nil
+	}
+	defs, err := addr.LoadLocalDefinitions(path)
+	if err != nil {
+		return err
+	}
+	addr.LOCAL_DEFINITIONS = defs
+	return nil
+}
+
+// looksLikeASN returns true if an argument that failed to parse was probably meant to be an ASN,
+// so the reason it's invalid can be shown.
+func looksLikeASN(arg string) bool {
+	return strings.HasPrefix(strings.ToLower(arg), "as") || (arg != "" && arg[0] >= '0' && arg[0] <= '9' && !strings.ContainsAny(arg, ":/"))
+}
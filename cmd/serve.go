@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/thatmattlove/addr/pkg/server"
+)
+
+var serveListen string
+var serveCacheTTL time.Duration
+var serveRateLimit float64
+var serveBurst int
+var serveMaxBulk int
+
+var ServeCmd *cobra.Command = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve lookups over an HTTP API",
+	Long: `Serve lookups over an HTTP API. Results use the same JSON schema as '--output json'.
+
+  GET  /ip/{ip or prefix}
+  GET  /asn/{asn}
+  POST /bulk     {"targets": ["192.0.2.1", "AS14525"]}
+  GET  /healthz`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		s := server.New(server.Options{
+			CacheTTL:      serveCacheTTL,
+			RateLimit:     serveRateLimit,
+			Burst:         serveBurst,
+			MaxBulk:       serveMaxBulk,
+			QueryIP:       queryIP,
+			QueryASN:      queryASN,
+			ReverseLookup: reverseLookup,
+		})
+		cmd.Printf("listening on %s\n", serveListen)
+		err := s.ListenAndServe(serveListen)
+		if err != nil {
+			cmd.PrintErr(err.Error() + "\n")
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	ServeCmd.Flags().StringVar(&serveListen, "listen", ":8080", "address to listen on")
+	ServeCmd.Flags().DurationVar(&serveCacheTTL, "cache-ttl", time.Minute*5, "how long to cache results, 0 to disable")
+	ServeCmd.Flags().Float64Var(&serveRateLimit, "rate-limit", 10, "requests per second allowed per client, 0 to disable")
+	ServeCmd.Flags().IntVar(&serveBurst, "burst", 20, "requests a client may burst above --rate-limit")
+	ServeCmd.Flags().IntVar(&serveMaxBulk, "max-bulk", server.DEFAULT_MAX_BULK, "maximum targets per /bulk request or range")
+}
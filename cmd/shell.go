@@ -0,0 +1,227 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/chzyer/readline"
+	"github.com/miekg/dns"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+	"github.com/thatmattlove/addr/internal/util"
+	addr "github.com/thatmattlove/addr/pkg"
+	"github.com/thatmattlove/addr/pkg/metrics"
+	"github.com/thatmattlove/addr/pkg/whois"
+)
+
+// SHELL_HISTORY_FILE is the name of the shell history file in the data directory.
+const SHELL_HISTORY_FILE string = "shell_history"
+
+// SHELL_CACHE_NAME identifies the shell's cache in metrics.
+const SHELL_CACHE_NAME string = "shell"
+
+const shellHelp string = `Enter IP addresses, prefixes, ranges, ASNs or hostnames to look them up, or:
+
+  ip <ip, prefix or range>...   look up IP addresses, prefixes & ranges
+  asn <asn or range>...         look up ASNs & ranges of ASNs
+  host <hostname>...            look up the addresses a hostname resolves to
+  help                          show this help
+  exit                          exit the shell`
+
+var shellCommands = []string{"ip", "asn", "host", "help", "exit", "quit"}
+
+var shellCacheTTL time.Duration
+
+var ShellCmd *cobra.Command = &cobra.Command{
+	Use:   "shell",
+	Short: "Look up targets interactively",
+	Long: `Look up targets interactively, with history & tab completion. Results are cached, and a
+single whois client is reused for every lookup.
+
+` + shellHelp,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := os.MkdirAll(dataDir, 0o755)
+		if err != nil {
+			cmd.PrintErr(err.Error() + "\n")
+			os.Exit(1)
+		}
+		completions := make([]readline.PrefixCompleterInterface, 0, len(shellCommands))
+		for _, c := range shellCommands {
+			completions = append(completions, readline.PcItem(c))
+		}
+		rl, err := readline.NewEx(&readline.Config{
+			Prompt:            "addr> ",
+			HistoryFile:       filepath.Join(dataDir, SHELL_HISTORY_FILE),
+			HistorySearchFold: true,
+			AutoComplete:      readline.NewPrefixCompleter(completions...),
+			InterruptPrompt:   "^C",
+			EOFPrompt:         "exit",
+		})
+		if err != nil {
+			cmd.PrintErr(err.Error() + "\n")
+			os.Exit(1)
+		}
+		defer rl.Close()
+
+		sh := newShell(shellCacheTTL)
+		interactive = true
+		queryIP, queryASN, reverseLookup = sh.queryIP, sh.queryASN, sh.reverseLookup
+		s := newSpinner(cmd)
+		for {
+			line, err := rl.Readline()
+			if errors.Is(err, readline.ErrInterrupt) {
+				continue
+			}
+			if err != nil {
+				return
+			}
+			if !sh.run(cmd, s, line) {
+				return
+			}
+		}
+	},
+}
+
+type shellEntry[T any] struct {
+	value   T
+	expires time.Time
+}
+
+// shell caches lookups for the life of an interactive session.
+type shell struct {
+	ttl       time.Duration
+	whois     *whois.Whois
+	responses map[string]shellEntry[*addr.Response]
+	ptrs      map[string]shellEntry[[]string]
+}
+
+func newShell(ttl time.Duration) *shell {
+	sh := &shell{
+		ttl:       ttl,
+		responses: map[string]shellEntry[*addr.Response]{},
+		ptrs:      map[string]shellEntry[[]string]{},
+	}
+	// Connect up front so the first lookup isn't slower than the rest. If this fails, client()
+	// tries again on the next lookup.
+	sh.client()
+	return sh
+}
+
+// run runs a line of input, returning false if the shell should exit.
+func (sh *shell) run(cmd *cobra.Command, s pterm.SpinnerPrinter, line string) bool {
+	args := strings.Fields(line)
+	if len(args) == 0 {
+		return true
+	}
+	switch strings.ToLower(args[0]) {
+	case "exit", "quit":
+		return false
+	case "help", "?":
+		cmd.Println(shellHelp)
+		return true
+	case "ip":
+		lookupIPs(cmd, s, args[1:])
+	case "asn":
+		lookupASNs(cmd, s, args[1:])
+	case "host":
+		for _, host := range args[1:] {
+			lookupHost(cmd, s, host)
+		}
+	default:
+		for _, arg := range joinRangeArgs(args) {
+			if isHostname(arg) {
+				lookupHost(cmd, s, arg)
+				continue
+			}
+			lookupTargets(cmd, s, []string{arg})
+		}
+	}
+	flushOutput(cmd)
+	return true
+}
+
+// client returns the shell's whois client, connecting if a previous attempt failed. If it can't
+// connect, nil is returned and the lookup creates its own client, returning the error.
+func (sh *shell) client() *whois.Whois {
+	if sh.whois == nil {
+		w, err := whois.New(addr.WHOIS_HOST, addr.WHOIS_PORT)
+		if err == nil {
+			sh.whois = w
+		}
+	}
+	return sh.whois
+}
+
+func (sh *shell) queryIP(q string) (*addr.Response, error) {
+	return shellCached(sh, sh.responses, "ip:"+q, func() (*addr.Response, error) {
+		return addr.QueryIPPrefixWith(sh.client(), q, queryOptions)
+	})
+}
+
+func (sh *shell) queryASN(q string) (*addr.Response, error) {
+	return shellCached(sh, sh.responses, "asn:"+q, func() (*addr.Response, error) {
+		return addr.QueryASNWith(sh.client(), q, queryOptions)
+	})
+}
+
+func (sh *shell) reverseLookup(ip *net.IP) ([]string, error) {
+	if ip == nil {
+		return nil, nil
+	}
+	return shellCached(sh, sh.ptrs, ip.String(), func() ([]string, error) {
+		return dnsReverseLookup(ip)
+	})
+}
+
+// shellCached returns the cached value for key, or calls fn and caches the result if successful.
+func shellCached[T any](sh *shell, entries map[string]shellEntry[T], key string, fn func() (T, error)) (T, error) {
+	e, ok := entries[key]
+	hit := ok && time.Now().Before(e.expires)
+	metrics.HOOK.Cache(SHELL_CACHE_NAME, hit)
+	if hit {
+		return e.value, nil
+	}
+	v, err := fn()
+	if err == nil && sh.ttl > 0 {
+		entries[key] = shellEntry[T]{value: v, expires: time.Now().Add(sh.ttl)}
+	}
+	return v, err
+}
+
+// lookupHost looks up the origin of each address a hostname resolves to.
+func lookupHost(cmd *cobra.Command, s pterm.SpinnerPrinter, host string) {
+	p, _ := s.Start()
+	a, aaaa, err := dnsForwardLookup(host)
+	p.Stop()
+	if err != nil {
+		printError(cmd, host, addr.RESULT_IP, err)
+		return
+	}
+	ips := append(a, aaaa...)
+	if len(ips) == 0 {
+		printError(cmd, host, addr.RESULT_IP, fmt.Errorf("no addresses found for '%s'", host))
+		return
+	}
+	for _, ip := range ips {
+		lookupIP(cmd, s, ip.String())
+	}
+}
+
+// isHostname returns true if v looks like a hostname rather than any other target.
+func isHostname(v string) bool {
+	if util.IsIP(v) || addr.IsIPRange(v) || !strings.Contains(v, ".") {
+		return false
+	}
+	_, ok := dns.IsDomainName(v)
+	return ok
+}
+
+func init() {
+	ShellCmd.Flags().DurationVar(&shellCacheTTL, "cache-ttl", time.Minute*5, "how long to cache results, 0 to disable")
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/thatmattlove/addr/cmd/style"
+	addr "github.com/thatmattlove/addr/pkg"
+)
+
+var snapshotTargets string
+
+var SnapshotCmd *cobra.Command = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Save lookup results for later comparison with 'addr diff'",
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+		os.Exit(0)
+	},
+}
+
+var SnapshotSaveCmd *cobra.Command = &cobra.Command{
+	Use:   "save <snapshot.json> [target...]",
+	Short: "Look up targets and save the results to a JSON file",
+	Long: `Look up targets and save the results to a JSON file, in the same format as '--output json'.
+Targets are read from arguments, from a file with --targets, or from stdin.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dest, targets := args[0], args[1:]
+		if snapshotTargets != "" || len(targets) == 0 {
+			name := snapshotTargets
+			if name == "" {
+				name = "-"
+			}
+			t, err := readTargetList(cmd, name)
+			if err != nil {
+				cmd.PrintErr(err.Error() + "\n")
+				os.Exit(1)
+			}
+			targets = append(targets, t...)
+		}
+		// Collect results as for '--output json', continuing past failed lookups so they're
+		// recorded in the snapshot.
+		outputMode, outputTemplate, interactive = style.OUTPUT_JSON, nil, true
+		lookupTargets(cmd, style.NewSpinner(cmd), targets)
+		f, err := os.Create(dest)
+		if err != nil {
+			cmd.PrintErr(err.Error() + "\n")
+			os.Exit(1)
+		}
+		defer f.Close()
+		err = addr.WriteSnapshot(f, tableResults)
+		if err != nil {
+			cmd.PrintErr(err.Error() + "\n")
+			os.Exit(1)
+		}
+		failed := 0
+		for _, r := range tableResults {
+			if r.Error != "" {
+				failed++
+			}
+		}
+		cmd.Printf("saved %d results to %s (%d failed)\n", len(tableResults), dest, failed)
+	},
+}
+
+// readTargetList reads whitespace-separated targets from a file, or from stdin if name is '-'.
+// Text after '#' is ignored.
+func readTargetList(cmd *cobra.Command, name string) ([]string, error) {
+	var r io.Reader = cmd.InOrStdin()
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	targets := []string{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		targets = append(targets, joinRangeArgs(strings.Fields(line))...)
+	}
+	return targets, scanner.Err()
+}
+
+func init() {
+	SnapshotSaveCmd.Flags().StringVar(&snapshotTargets, "targets", "", "file of targets to look up, or '-' for stdin")
+	SnapshotCmd.AddCommand(SnapshotSaveCmd)
+}
@@ -3,24 +3,18 @@ package style
 import (
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/biter777/countries"
 	addr "github.com/thatmattlove/addr/pkg"
 )
 
 func IPBox(r *addr.Response, ptrs []string) string {
-	netPrefix := "from "
-	var asn string
-	if r.FromQuery {
-		netPrefix = "advertised as "
-		asn = Plain("AS") + Highlight2(fmt.Sprint(r.ASN))
-	} else {
-		asn = Subtle("Never Advertised")
+	body := strings.Join(originLines(r), "\n")
+	if r.Embedded != nil {
+		body = fmt.Sprintf("%s\n\n%s", body, embeddedLines(r.Embedded))
 	}
-	net := Subtle(netPrefix) + Highlight1(r.Prefix.String())
-	org := Country(r)
-	reg := Subtle("Registry: ") + Plain(r.Registry)
-	body := strings.Join([]string{net, asn, org, reg}, "\n")
-	title := Title(r.IP.String())
+	title := Title(r.Addr.String())
 	box := Box.WithTitle(title)
 	if len(ptrs) > 0 {
 		for _, p := range ptrs {
@@ -33,8 +27,260 @@ func IPBox(r *addr.Response, ptrs []string) string {
 
 func ASNBox(r *addr.Response) string {
 	asn := Plain("AS") + Title(fmt.Sprint(r.ASN))
-	org := Country(r)
+	var lines []string
+	switch {
+	case r.Local != nil:
+		lines = append([]string{Highlight2(r.Name)}, localLines(r.Local)...)
+	case !r.FromQuery:
+		if r.Name != "" {
+			lines = append(lines, Highlight2(r.Name))
+		}
+		if r.Registry != "" {
+			lines = append(lines, Subtle("Registry: ")+Plain(r.Registry))
+		}
+		lines = append(lines, delegationLines(r.Delegation)...)
+	default:
+		lines = append([]string{Country(r)}, delegationLines(r.Delegation)...)
+	}
+	lines = append(lines, peeringDBLines(r.PeeringDB)...)
+	lines = append(lines, neighborLines(r.Neighbors)...)
 	return Wrapper.Sprint(
-		Box.WithTitle(asn).Sprint(org),
+		Box.WithTitle(asn).Sprint(strings.Join(lines, "\n")),
+	)
+}
+
+func originLines(r *addr.Response) []string {
+	if r.Local != nil {
+		lines := []string{Subtle("local ") + Highlight1(r.Network.String())}
+		if r.ASN.Uint32() != 0 {
+			lines = append(lines, Plain("AS")+Highlight2(fmt.Sprint(r.ASN)))
+		}
+		lines = append(lines, Plain(r.Name))
+		return append(lines, localLines(r.Local)...)
+	}
+	netPrefix := "from "
+	var asn string
+	if r.FromQuery {
+		netPrefix = "advertised as "
+		asn = Plain("AS") + Highlight2(fmt.Sprint(r.ASN))
+	} else if r.Registry == addr.REGISTRY_MMDB && r.ASN.Uint32() != 0 {
+		asn = Plain("AS") + Highlight2(fmt.Sprint(r.ASN))
+	} else {
+		asn = Subtle("Never Advertised")
+	}
+	lines := []string{Subtle(netPrefix) + Highlight1(r.Network.String()), asn}
+	// Routes from an MRT RIB have no name, and no country or registry without a delegation.
+	if r.Name != "" || r.Country != countries.Unknown {
+		lines = append(lines, Country(r))
+	}
+	if r.Registry != "" {
+		lines = append(lines, Subtle("Registry: ")+Plain(r.Registry))
+	}
+	return append(lines, geoLines(r)...)
+}
+
+func geoLines(r *addr.Response) []string {
+	lines := []string{}
+	if g := r.Geo; g != nil {
+		place := []string{}
+		for _, p := range []string{g.City, g.Region} {
+			if p != "" {
+				place = append(place, p)
+			}
+		}
+		if g.Country != countries.Unknown {
+			place = append(place, g.Country.Alpha2())
+		}
+		if len(place) > 0 {
+			lines = append(lines, Subtle("Location: ")+Plain(strings.Join(place, ", ")))
+		}
+		if g.Latitude != 0 || g.Longitude != 0 {
+			coords := fmt.Sprintf("%g, %g", g.Latitude, g.Longitude)
+			if g.AccuracyRadius != 0 {
+				coords += Subtle(fmt.Sprintf(" ±%dkm", g.AccuracyRadius))
+			}
+			lines = append(lines, Subtle("Coordinates: ")+Plain(coords))
+		}
+	}
+	if a := r.GeoASN; a != nil && r.Registry != addr.REGISTRY_MMDB {
+		lines = append(lines, Subtle("MMDB: ")+Plain("AS"+fmt.Sprint(a.ASN)+" "+a.Name))
+	}
+	lines = append(lines, routeLines(r.Route)...)
+	return append(lines, delegationLines(r.Delegation)...)
+}
+
+// ROUTE_UPSTREAMS is the number of upstream ASNs shown for a prefix from an MRT RIB.
+const ROUTE_UPSTREAMS int = 8
+
+// PEERINGDB_SHOWN is the number of IXPs & facilities each shown for a PeeringDB network.
+const PEERINGDB_SHOWN int = 6
+
+// NEIGHBORS_SHOWN is the number of upstreams, downstreams & peers each shown for an ASN.
+const NEIGHBORS_SHOWN int = 8
+
+func routeLines(e *addr.RIBEntry) []string {
+	if e == nil {
+		return []string{}
+	}
+	lines := []string{Subtle("Peers: ") + Plain(fmt.Sprint(e.Peers))}
+	if e.MOAS() {
+		origins := []string{}
+		for _, o := range e.Origins {
+			origins = append(origins, fmt.Sprintf("AS%d", o.ASN)+Subtle(fmt.Sprintf(" (%d)", o.Peers)))
+		}
+		lines = append(lines, Highlight2("MOAS ")+Subtle("origins: ")+Plain(strings.Join(origins, ", ")))
+	}
+	if len(e.Upstreams) > 0 {
+		upstreams := []string{}
+		for _, u := range e.Upstreams {
+			upstreams = append(upstreams, fmt.Sprintf("AS%d", u))
+		}
+		lines = append(lines, Subtle("Upstreams: ")+shortList(upstreams, ROUTE_UPSTREAMS))
+	}
+	return lines
+}
+
+func neighborLines(n *addr.ASNeighbors) []string {
+	if n == nil {
+		return []string{}
+	}
+	if n.Len() == 0 {
+		return []string{Subtle("No neighbors found")}
+	}
+	lines := []string{}
+	for _, group := range []struct {
+		label     string
+		neighbors []addr.ASNeighbor
+	}{
+		{"Upstreams: ", n.Upstreams},
+		{"Downstreams: ", n.Downstreams},
+		{"Peers: ", n.Peers},
+	} {
+		if len(group.neighbors) == 0 {
+			continue
+		}
+		asns := []string{}
+		for _, nb := range group.neighbors {
+			asn := fmt.Sprintf("AS%d", nb.ASN)
+			if nb.Paths > 0 {
+				asn += Subtle(fmt.Sprintf(" (%d)", nb.Paths))
+			}
+			asns = append(asns, asn)
+		}
+		lines = append(lines, Subtle(group.label)+shortList(asns, NEIGHBORS_SHOWN))
+	}
+	return lines
+}
+
+func peeringDBLines(n *addr.PeeringDBNetwork) []string {
+	if n == nil {
+		return []string{}
+	}
+	lines := []string{}
+	for _, field := range []struct{ label, value string }{
+		{"AS-SET: ", n.IRRASSet},
+		{"Policy: ", n.Policy},
+		{"Traffic: ", n.Traffic},
+	} {
+		if field.value != "" {
+			lines = append(lines, Subtle(field.label)+Plain(field.value))
+		}
+	}
+	if ixps := n.IXPs(); len(ixps) > 0 {
+		lines = append(lines, Subtle("IXPs: ")+shortList(ixps, PEERINGDB_SHOWN))
+	}
+	if len(n.Facilities) > 0 {
+		facilities := []string{}
+		for _, f := range n.Facilities {
+			facilities = append(facilities, f.Name)
+		}
+		lines = append(lines, Subtle("Facilities: ")+shortList(facilities, PEERINGDB_SHOWN))
+	}
+	return lines
+}
+
+// shortList joins formatted items, showing at most limit and the number left out.
+func shortList(items []string, limit int) string {
+	if len(items) > limit {
+		items = append(items[:limit:limit], Subtle(fmt.Sprintf("+%d more", len(items)-limit)))
+	}
+	return Plain(strings.Join(items, ", "))
+}
+
+func delegationLines(d *addr.Delegation) []string {
+	if d == nil {
+		return []string{}
+	}
+	status := d.Status
+	if !d.Date.IsZero() {
+		status += Subtle(" on ") + Plain(d.Date.Format(time.DateOnly))
+	}
+	lines := []string{Subtle("Status: ") + Plain(status)}
+	if d.OpaqueID != "" {
+		lines = append(lines, Subtle("Holder ID: ")+Plain(d.OpaqueID))
+	}
+	return lines
+}
+
+func embeddedLines(e *addr.Embedded) string {
+	ip := Subtle("embeds ") + Highlight1(e.IPv4.String())
+	if e.Type == addr.TXT_TEREDO {
+		ip += Subtle(fmt.Sprintf(" port %d via server ", e.Port)) + Plain(e.Server.String())
+	}
+	lines := []string{ip}
+	if e.Origin != nil {
+		lines = append(lines, originLines(e.Origin)...)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func localLines(l *addr.LocalDefinition) []string {
+	lines := []string{}
+	if l.Site != "" {
+		lines = append(lines, Subtle("Site: ")+Plain(l.Site))
+	}
+	if l.Owner != "" {
+		lines = append(lines, Subtle("Owner: ")+Plain(l.Owner))
+	}
+	if len(l.Tags) > 0 {
+		lines = append(lines, Subtle("Tags: ")+Plain(strings.Join(l.Tags, ", ")))
+	}
+	return lines
+}
+
+func CalcBox(i *addr.PrefixInfo) string {
+	lines := []string{}
+	if i.HostBitsSet {
+		lines = append(lines, Highlight2("host bits set, using "+i.Prefix.String()), "")
+	}
+	lines = append(lines,
+		Subtle("Network:   ")+Highlight1(i.Network.String()),
+	)
+	if i.Broadcast.IsValid() {
+		lines = append(lines, Subtle("Broadcast: ")+Plain(i.Broadcast.String()))
+	} else {
+		lines = append(lines, Subtle("Last:      ")+Plain(i.Last.String()))
+	}
+	lines = append(lines,
+		Subtle("Usable:    ")+Plain(i.FirstUsable.String()+" - "+i.LastUsable.String()),
+		Subtle("Size:      ")+Plain(i.Size.String())+Subtle(" ("+i.Usable.String()+" usable)"),
+		Subtle("Netmask:   ")+Plain(i.Netmask.String()),
+		Subtle("Wildcard:  ")+Plain(i.Wildcard.String()),
 	)
+	if i.Prefix.Addr().Is6() {
+		nibble := "yes"
+		if !i.NibbleAligned {
+			nibble = "no, nearest boundary is " + i.NibbleSupernet.String()
+		}
+		lines = append(lines, Subtle("Nibble:    ")+Plain(nibble))
+	}
+	for n, z := range i.ReverseZones {
+		label := "           "
+		if n == 0 {
+			label = "Reverse:   "
+		}
+		lines = append(lines, Subtle(label)+Plain(z))
+	}
+	title := Title(i.Prefix.String())
+	return Wrapper.Sprint(Box.WithTitle(title).Sprint(strings.Join(lines, "\n")))
 }
@@ -0,0 +1,369 @@
+package style
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pterm/pterm"
+	addr "github.com/thatmattlove/addr/pkg"
+)
+
+const (
+	OUTPUT_CSV      string = "csv"
+	OUTPUT_TSV      string = "tsv"
+	OUTPUT_MARKDOWN string = "markdown"
+	OUTPUT_TABLE    string = "table"
+	OUTPUT_JSON     string = "json"
+)
+
+var OUTPUT_FORMATS = []string{OUTPUT_CSV, OUTPUT_TSV, OUTPUT_MARKDOWN, OUTPUT_TABLE, OUTPUT_JSON}
+
+// Table renders results with one row per result, in one of OUTPUT_FORMATS. JSON output is an
+// array of results and ignores columns.
+func Table(format string, columns []string, results []*addr.Result) (string, error) {
+	if format == OUTPUT_JSON {
+		if results == nil {
+			results = []*addr.Result{}
+		}
+		b, err := json.MarshalIndent(results, "", "  ")
+		return string(b), err
+	}
+	rows := [][]string{columns}
+	for _, r := range results {
+		row := make([]string, 0, len(columns))
+		for _, c := range columns {
+			row = append(row, r.Column(c))
+		}
+		rows = append(rows, row)
+	}
+	return renderRows(format, rows)
+}
+
+// SummaryTable renders a summary with one row per group, in one of OUTPUT_FORMATS. Targets are
+// included if withTargets is true.
+func SummaryTable(format string, s *addr.Summary, withTargets bool) (string, error) {
+	if format == OUTPUT_JSON {
+		out := s
+		if !withTargets {
+			// Copied, so that the caller's groups keep their targets.
+			c := *s
+			c.Groups = make([]*addr.SummaryGroup, 0, len(s.Groups))
+			for _, g := range s.Groups {
+				without := *g
+				without.Targets = nil
+				c.Groups = append(c.Groups, &without)
+			}
+			out = &c
+		}
+		b, err := json.MarshalIndent(out, "", "  ")
+		return string(b), err
+	}
+	header := []string{s.By, "name", "count", "percent"}
+	if withTargets {
+		header = append(header, "targets")
+	}
+	rows := [][]string{header}
+	for _, g := range s.Groups {
+		key := g.Key
+		if key == "" {
+			key = "unknown"
+		}
+		row := []string{key, g.Name, fmt.Sprint(g.Count), fmt.Sprintf("%.2f%%", g.Percent)}
+		if withTargets {
+			row = append(row, strings.Join(g.Targets, " "))
+		}
+		rows = append(rows, row)
+	}
+	if s.Other > 0 {
+		row := []string{fmt.Sprintf("other (%d)", s.OtherGroups), "", fmt.Sprint(s.Other), fmt.Sprintf("%.2f%%", float64(s.Other)/float64(s.Total-s.Failed)*100)}
+		if withTargets {
+			row = append(row, "")
+		}
+		rows = append(rows, row)
+	}
+	out, err := renderRows(format, rows)
+	if err != nil || format != OUTPUT_TABLE {
+		return out, err
+	}
+	return fmt.Sprintf("%s\n%s", Subtle(fmt.Sprintf("%d results, %d failed", s.Total, s.Failed)), out), nil
+}
+
+// TrafficTable renders a traffic summary with one row per group, in one of OUTPUT_FORMATS.
+func TrafficTable(format string, s *addr.TrafficSummary) (string, error) {
+	if format == OUTPUT_JSON {
+		b, err := json.MarshalIndent(s, "", "  ")
+		return string(b), err
+	}
+	rows := [][]string{{s.By, "name", "hosts", "packets", "sent", "received", "percent"}}
+	row := func(key string, g *addr.TrafficGroup) []string {
+		return []string{
+			key,
+			g.Name,
+			fmt.Sprint(g.Hosts),
+			fmt.Sprint(g.Sent.Packets + g.Received.Packets),
+			fmt.Sprint(g.Sent.Bytes),
+			fmt.Sprint(g.Received.Bytes),
+			fmt.Sprintf("%.2f%%", g.Percent),
+		}
+	}
+	for _, g := range s.Groups {
+		key := g.Key
+		if key == "" {
+			key = "unknown"
+		}
+		rows = append(rows, row(key, g))
+	}
+	if s.OtherGroups > 0 {
+		rows = append(rows, row(fmt.Sprintf("other (%d)", s.OtherGroups), &s.Other))
+	}
+	out, err := renderRows(format, rows)
+	if err != nil || format != OUTPUT_TABLE {
+		return out, err
+	}
+	header := fmt.Sprintf("%d packets, %d bytes", s.Total.Packets, s.Total.Bytes)
+	if s.Failed.Packets != 0 {
+		header += fmt.Sprintf(", %.2f%% from addresses that failed to look up", float64(s.Failed.Bytes)/float64(s.Total.Bytes*2)*100)
+	}
+	return fmt.Sprintf("%s\n%s", Subtle(header), out), nil
+}
+
+// FlowTable renders a flow summary with one row per ASN, in one of OUTPUT_FORMATS.
+func FlowTable(format string, s *addr.FlowSummary) (string, error) {
+	if format == OUTPUT_JSON {
+		b, err := json.MarshalIndent(s, "", "  ")
+		return string(b), err
+	}
+	rows := [][]string{{s.By + "_asn", "name", "flows", "packets", "bytes", "percent"}}
+	row := func(key string, g *addr.FlowGroup) []string {
+		return []string{key, g.Name, fmt.Sprint(g.Flows), fmt.Sprint(g.Packets), fmt.Sprint(g.Bytes), fmt.Sprintf("%.2f%%", g.Percent)}
+	}
+	for _, g := range s.Groups {
+		key := "unknown"
+		if g.ASN != 0 {
+			key = fmt.Sprint(g.ASN)
+		}
+		rows = append(rows, row(key, g))
+	}
+	if s.OtherGroups > 0 {
+		rows = append(rows, row(fmt.Sprintf("other (%d)", s.OtherGroups), &s.Other))
+	}
+	out, err := renderRows(format, rows)
+	if err != nil || format != OUTPUT_TABLE {
+		return out, err
+	}
+	return fmt.Sprintf("%s\n%s", Subtle(fmt.Sprintf("%d flows, %d packets, %d bytes", s.Flows, s.Total.Packets, s.Total.Bytes)), out), nil
+}
+
+// IXPTable renders an IXP's participants with one row per connection, in one of OUTPUT_FORMATS.
+func IXPTable(format string, ix *addr.PeeringDBIX) (string, error) {
+	if format == OUTPUT_JSON {
+		b, err := json.MarshalIndent(ix, "", "  ")
+		return string(b), err
+	}
+	rows := [][]string{{"asn", "name", "speed", "ipv4", "ipv6", "route_server"}}
+	addrString := func(a netip.Addr) string {
+		if a.IsValid() {
+			return a.String()
+		}
+		return ""
+	}
+	for _, p := range ix.Participants {
+		rows = append(rows, []string{
+			fmt.Sprint(p.ASN),
+			p.NetName,
+			formatSpeed(p.Speed),
+			addrString(p.IPv4),
+			addrString(p.IPv6),
+			fmt.Sprint(p.RouteServer),
+		})
+	}
+	out, err := renderRows(format, rows)
+	if err != nil || format != OUTPUT_TABLE {
+		return out, err
+	}
+	header := fmt.Sprintf("%s (ID %d)", ix.Name, ix.ID)
+	if ix.City != "" {
+		header += ", " + ix.City
+	}
+	header += fmt.Sprintf(": %d networks, %d connections", ix.Networks(), len(ix.Participants))
+	return fmt.Sprintf("%s\n%s", Subtle(header), out), nil
+}
+
+// formatSpeed formats a port speed in Mbps, e.g. '100G' or '500M'.
+func formatSpeed(mbps int) string {
+	switch {
+	case mbps == 0:
+		return ""
+	case mbps%1000000 == 0:
+		return fmt.Sprintf("%dT", mbps/1000000)
+	case mbps%1000 == 0:
+		return fmt.Sprintf("%dG", mbps/1000)
+	}
+	return fmt.Sprintf("%dM", mbps)
+}
+
+// CaptureHostsTable renders the hosts in a capture with their lookup results, in one of
+// OUTPUT_FORMATS other than JSON.
+func CaptureHostsTable(format string, c *addr.Capture, results []*addr.Result) (string, error) {
+	rows := [][]string{{"ip", "asn", "name", "country", "sent", "received"}}
+	for i, h := range c.Hosts {
+		r := results[i]
+		name := r.Name
+		if r.Error != "" {
+			name = r.Error
+		}
+		rows = append(rows, []string{
+			h.Addr.String(),
+			r.Column("asn"),
+			name,
+			r.Column("country"),
+			fmt.Sprint(h.Sent.Bytes),
+			fmt.Sprint(h.Received.Bytes),
+		})
+	}
+	return renderRows(format, rows)
+}
+
+// TRACE_BOUNDARY marks hops where the origin ASN changes.
+const TRACE_BOUNDARY string = "»"
+
+// TraceTable renders annotated trace hops with one row per hop address, in one of OUTPUT_FORMATS.
+// Loss is included if any hop has mtr statistics.
+func TraceTable(format string, hops []*addr.AnnotatedHop) (string, error) {
+	if format == OUTPUT_JSON {
+		if hops == nil {
+			hops = []*addr.AnnotatedHop{}
+		}
+		b, err := json.MarshalIndent(hops, "", "  ")
+		return string(b), err
+	}
+	withLoss := false
+	for _, h := range hops {
+		if h.Sent != 0 {
+			withLoss = true
+		}
+	}
+	header := []string{"", "hop", "ip", "ptr", "asn", "name", "rtt"}
+	if withLoss {
+		header = append(header, "loss")
+	}
+	rows := [][]string{header}
+	for _, h := range hops {
+		row := []string{"", fmt.Sprint(h.Hop), "*", h.Host, "", "", ""}
+		if h.Boundary {
+			row[0] = TRACE_BOUNDARY
+		}
+		if h.IP.IsValid() {
+			row[2] = h.IP.String()
+		}
+		if r := h.Result; r != nil {
+			row[3], row[4], row[5] = r.Column("ptr"), r.Column("asn"), r.Column("name")
+			if r.Error != "" {
+				row[5] = r.Error
+			}
+		}
+		if h.RTT != 0 {
+			row[6] = fmt.Sprintf("%.1fms", float64(h.RTT)/float64(time.Millisecond))
+		}
+		if withLoss {
+			row = append(row, fmt.Sprintf("%.1f%%", h.Loss))
+		}
+		rows = append(rows, row)
+	}
+	return renderRows(format, rows)
+}
+
+// renderRows renders rows, the first of which is the header, in one of OUTPUT_FORMATS other than
+// JSON.
+func renderRows(format string, rows [][]string) (string, error) {
+	switch format {
+	case OUTPUT_CSV:
+		return csvTable(rows)
+	case OUTPUT_TSV:
+		return tsvTable(rows), nil
+	case OUTPUT_MARKDOWN:
+		return markdownTable(rows), nil
+	case OUTPUT_TABLE:
+		return pterm.DefaultTable.WithHasHeader().WithData(untab(rows)).Srender()
+	default:
+		return "", fmt.Errorf("unknown output format '%s', must be one of %s", format, strings.Join(OUTPUT_FORMATS, ", "))
+	}
+}
+
+// untab replaces tabs in cells with spaces, since they break the alignment of table columns.
+func untab(rows [][]string) [][]string {
+	out := make([][]string, 0, len(rows))
+	for _, row := range rows {
+		cells := make([]string, 0, len(row))
+		for _, cell := range row {
+			cells = append(cells, strings.ReplaceAll(cell, "\t", " "))
+		}
+		out = append(out, cells)
+	}
+	return out
+}
+
+func csvTable(rows [][]string) (string, error) {
+	b := &bytes.Buffer{}
+	w := csv.NewWriter(b)
+	err := w.WriteAll(rows)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+func tsvTable(rows [][]string) string {
+	replacer := strings.NewReplacer("\t", " ", "\n", " ", "\r", " ")
+	lines := make([]string, 0, len(rows))
+	for _, row := range rows {
+		cells := make([]string, 0, len(row))
+		for _, cell := range row {
+			cells = append(cells, replacer.Replace(cell))
+		}
+		lines = append(lines, strings.Join(cells, "\t"))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func markdownTable(rows [][]string) string {
+	replacer := strings.NewReplacer("|", `\|`, "\t", " ", "\n", " ", "\r", " ")
+	widths := make([]int, len(rows[0]))
+	for i := range widths {
+		widths[i] = 3
+	}
+	escaped := make([][]string, 0, len(rows))
+	for _, row := range rows {
+		cells := make([]string, 0, len(row))
+		for i, cell := range row {
+			cell = replacer.Replace(cell)
+			if w := utf8.RuneCountInString(cell); w > widths[i] {
+				widths[i] = w
+			}
+			cells = append(cells, cell)
+		}
+		escaped = append(escaped, cells)
+	}
+	line := func(cells []string) string {
+		padded := make([]string, 0, len(cells))
+		for i, cell := range cells {
+			padded = append(padded, cell+strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell)))
+		}
+		return "| " + strings.Join(padded, " | ") + " |"
+	}
+	separator := make([]string, 0, len(widths))
+	for _, w := range widths {
+		separator = append(separator, strings.Repeat("-", w))
+	}
+	lines := []string{line(escaped[0]), "| " + strings.Join(separator, " | ") + " |"}
+	for _, row := range escaped[1:] {
+		lines = append(lines, line(row))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,78 @@
+package style_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thatmattlove/addr/cmd/style"
+	addr "github.com/thatmattlove/addr/pkg"
+	goasn "github.com/thatmattlove/go-asn"
+)
+
+func Test_Table(t *testing.T) {
+	name := "Foo, \"Bar\"\tBaz\nQux|Quux"
+	results := []*addr.Result{addr.NewIPResult("192.0.2.1", &addr.Response{ASN: goasn.MustParse("64500"), Name: name}, nil)}
+	columns := []string{"target", "name"}
+	t.Run("csv", func(t *testing.T) {
+		t.Parallel()
+		out, err := style.Table(style.OUTPUT_CSV, columns, results)
+		assert.NoError(t, err)
+		assert.Equal(t, "target,name\n192.0.2.1,\"Foo, \"\"Bar\"\"\tBaz\nQux|Quux\"", out)
+	})
+	t.Run("tsv", func(t *testing.T) {
+		t.Parallel()
+		out, err := style.Table(style.OUTPUT_TSV, columns, results)
+		assert.NoError(t, err)
+		assert.Equal(t, "target\tname\n192.0.2.1\tFoo, \"Bar\" Baz Qux|Quux", out)
+	})
+	t.Run("markdown", func(t *testing.T) {
+		t.Parallel()
+		out, err := style.Table(style.OUTPUT_MARKDOWN, columns, results)
+		assert.NoError(t, err)
+		assert.Equal(t, `| target    | name                     |
+| --------- | ------------------------ |
+| 192.0.2.1 | Foo, "Bar" Baz Qux\|Quux |`, out)
+	})
+	t.Run("table", func(t *testing.T) {
+		t.Parallel()
+		out, err := style.Table(style.OUTPUT_TABLE, columns, results)
+		assert.NoError(t, err)
+		assert.NotContains(t, out, "\t")
+		assert.Contains(t, out, `Foo, "Bar" Baz`)
+	})
+	t.Run("json", func(t *testing.T) {
+		t.Parallel()
+		out, err := style.Table(style.OUTPUT_JSON, columns, results)
+		assert.NoError(t, err)
+		assert.Contains(t, out, `"name": "Foo, \"Bar\"\tBaz\nQux|Quux"`)
+	})
+	t.Run("unknown format", func(t *testing.T) {
+		t.Parallel()
+		_, err := style.Table("xml", columns, results)
+		assert.Error(t, err)
+	})
+}
+
+func Test_SummaryTable(t *testing.T) {
+	newSummary := func() *addr.Summary {
+		return &addr.Summary{By: addr.SUMMARY_ASN, Total: 2, Groups: []*addr.SummaryGroup{
+			{Key: "13335", Name: "Cloudflare, Inc.", Count: 2, Percent: 100, Targets: []string{"1.1.1.1", "1.0.0.1"}},
+		}}
+	}
+	t.Run("without targets", func(t *testing.T) {
+		t.Parallel()
+		for _, format := range style.OUTPUT_FORMATS {
+			s := newSummary()
+			out, err := style.SummaryTable(format, s, false)
+			assert.NoError(t, err, format)
+			assert.NotContains(t, out, "1.0.0.1", format)
+			assert.Equal(t, []string{"1.1.1.1", "1.0.0.1"}, s.Groups[0].Targets, "the summary isn't changed")
+		}
+	})
+	t.Run("with targets", func(t *testing.T) {
+		t.Parallel()
+		out, err := style.SummaryTable(style.OUTPUT_CSV, newSummary(), true)
+		assert.NoError(t, err)
+		assert.Equal(t, "asn,name,count,percent,targets\n13335,\"Cloudflare, Inc.\",2,100.00%,1.1.1.1 1.0.0.1", out)
+	})
+}
@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/thatmattlove/addr/cmd/style"
+	addr "github.com/thatmattlove/addr/pkg"
+)
+
+var TraceAnnotateCmd *cobra.Command = &cobra.Command{
+	Use:   "trace-annotate",
+	Short: "Annotate traceroute or mtr output with origin ASNs",
+	Long: `Annotate traceroute, traceroute6, 'mtr --report' or 'mtr --json' output read from stdin with
+each hop's origin ASN, AS name & PTR. Private & other non-global hops aren't looked up. Hops where
+the origin ASN changes are marked with ` + style.TRACE_BOUNDARY + `.
+
+Example: traceroute -n one.one.one.one | addr trace-annotate`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		hops, err := addr.ParseTrace(cmd.InOrStdin())
+		if err != nil {
+			cmd.PrintErr(err.Error() + "\n")
+			os.Exit(1)
+		}
+		s := newSpinner(cmd)
+		p, _ := s.Start("Looking up hops")
+		annotated := addr.AnnotateTrace(hops, addr.AnnotateOptions{QueryIP: queryIP, ReverseLookup: reverseLookup, ForwardLookup: dnsForwardLookup})
+		p.Stop()
+		format := outputMode
+		if format == "" {
+			format = style.OUTPUT_TABLE
+		}
+		out, err := style.TraceTable(format, annotated)
+		if err != nil {
+			cmd.PrintErr(err.Error() + "\n")
+			os.Exit(1)
+		}
+		cmd.Println(out)
+	},
+}
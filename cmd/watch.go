@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/thatmattlove/addr/cmd/style"
+	"github.com/thatmattlove/addr/internal/util"
+	addr "github.com/thatmattlove/addr/pkg"
+)
+
+var watchInterval time.Duration
+var watchCount int
+var watchHook string
+var watchWebhook string
+
+var WatchCmd *cobra.Command = &cobra.Command{
+	Use:   "watch <prefix|ip>...",
+	Short: "Watch IP addresses & prefixes for origin changes",
+	Long: `Watch IP addresses & prefixes for origin changes. Targets are looked up every --interval, and any
+change in origin ASN, covering prefix or advertised state is printed.
+
+--hook runs a command for each change, with the change as JSON on stdin and in the ADDR_TARGET,
+ADDR_OLD_ASN, ADDR_OLD_PREFIX, ADDR_OLD_ADVERTISED, ADDR_NEW_ASN, ADDR_NEW_PREFIX &
+ADDR_NEW_ADVERTISED environment variables. --webhook POSTs the same JSON to a URL.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		for _, arg := range args {
+			if !util.IsIP(arg) {
+				cmd.PrintErr(fmt.Sprintf("invalid IP address or prefix '%s'\n", arg))
+				os.Exit(1)
+			}
+		}
+		if watchInterval <= 0 {
+			cmd.PrintErr("--interval must be greater than zero\n")
+			os.Exit(1)
+		}
+		w := addr.NewWatcher(queryIP)
+		for i := 0; watchCount == 0 || i < watchCount; i++ {
+			if i > 0 {
+				time.Sleep(watchInterval)
+			}
+			for _, arg := range args {
+				watchTarget(cmd, w, arg)
+			}
+		}
+	},
+}
+
+// watchTarget checks a target once, printing its origin on the first successful check and any
+// changes after.
+func watchTarget(cmd *cobra.Command, w *addr.Watcher, target string) {
+	now := time.Now().Format(time.RFC3339)
+	first := !w.Seen(target)
+	origin, change, err := w.Check(target)
+	if err != nil {
+		cmd.PrintErr(fmt.Sprintf("%s %s: %s\n", now, target, err))
+		return
+	}
+	if first {
+		cmd.Println(style.Subtle(now), style.Highlight1(target), origin.String())
+		return
+	}
+	if change == nil {
+		return
+	}
+	cmd.Println(style.Subtle(now), style.Highlight1(target), style.Title(strings.Join(change.Diff(), ", ")))
+	if watchHook != "" {
+		if err := addr.RunHook(watchHook, change); err != nil {
+			cmd.PrintErr(err.Error() + "\n")
+		}
+	}
+	if watchWebhook != "" {
+		if err := addr.PostWebhook(watchWebhook, change); err != nil {
+			cmd.PrintErr(err.Error() + "\n")
+		}
+	}
+}
+
+func init() {
+	WatchCmd.Flags().DurationVar(&watchInterval, "interval", time.Minute*5, "how often to look up each target")
+	WatchCmd.Flags().IntVar(&watchCount, "count", 0, "stop after this many checks, 0 to watch until interrupted")
+	WatchCmd.Flags().StringVar(&watchHook, "hook", "", "command to run when an origin changes")
+	WatchCmd.Flags().StringVar(&watchWebhook, "webhook", "", "URL to POST origin changes to as JSON")
+}
@@ -4,11 +4,7 @@ import (
 	"errors"
 	"net"
 	"os"
-	"regexp"
-)
-
-const (
-	ASN_PATTERN string = `(AS|as)?([0-9]+)`
+	"path/filepath"
 )
 
 func PathExists(n string) bool {
@@ -18,13 +14,20 @@ func PathExists(n string) bool {
 	return true
 }
 
-func IsASN(v string) bool {
-	ip := net.ParseIP(v)
-	if ip != nil {
-		return false
+func DataDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "addr")
+}
+
+func ConfigDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = os.TempDir()
 	}
-	p := regexp.MustCompile(ASN_PATTERN)
-	return p.MatchString(v)
+	return filepath.Join(dir, "addr")
 }
 
 func IsIP(v string) bool {
@@ -25,40 +25,16 @@ func Test_PathExists(t *testing.T) {
 	})
 }
 
-func Test_IsASN(t *testing.T) {
-	type CaseT struct {
-		bool
-		string
-	}
-	cases := []CaseT{
-		{
-			true,
-			"14525",
-		},
-		{
-			false,
-			"192.0.2.1",
-		},
-		{
-			true,
-			"AS14525",
-		},
-		{
-			true,
-			"as14525",
-		},
-		{
-			false,
-			"test",
-		},
-	}
-	for _, c := range cases {
-		c := c
-		t.Run(c.string, func(t *testing.T) {
-			t.Parallel()
-			assert.Equal(t, c.bool, util.IsASN(c.string), c.string)
-		})
-	}
+func Test_DataDir(t *testing.T) {
+	dir := util.DataDir()
+	assert.Equal(t, "addr", filepath.Base(dir))
+	assert.True(t, filepath.IsAbs(dir))
+}
+
+func Test_ConfigDir(t *testing.T) {
+	dir := util.ConfigDir()
+	assert.Equal(t, "addr", filepath.Base(dir))
+	assert.True(t, filepath.IsAbs(dir))
 }
 
 func Test_IsIP(t *testing.T) {
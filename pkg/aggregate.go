@@ -0,0 +1,117 @@
+package addr
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/netip"
+	"sort"
+	"strings"
+)
+
+// Aggregate returns the minimal set of prefixes covering prefixes. Duplicates & covered
+// more-specifics are removed, and adjacent prefixes are merged. IPv4 prefixes are returned before
+// IPv6 prefixes.
+func Aggregate(prefixes []netip.Prefix) []netip.Prefix {
+	sorted := make([]netip.Prefix, 0, len(prefixes))
+	for _, p := range prefixes {
+		if p.IsValid() {
+			sorted = append(sorted, p.Masked())
+		}
+	}
+	sortPrefixes(sorted)
+	result := []netip.Prefix{}
+	for _, p := range sorted {
+		if n := len(result); n > 0 && result[n-1].Bits() <= p.Bits() && result[n-1].Contains(p.Addr()) {
+			continue
+		}
+		result = append(result, p)
+		for len(result) > 1 {
+			a, b := result[len(result)-2], result[len(result)-1]
+			if a.Bits() != b.Bits() || a.Bits() == 0 || a.Addr().Is4() != b.Addr().Is4() {
+				break
+			}
+			parent := netip.PrefixFrom(a.Addr(), a.Bits()-1).Masked()
+			if parent.Addr() != a.Addr() || !parent.Contains(b.Addr()) {
+				break
+			}
+			result = append(result[:len(result)-2], parent)
+		}
+	}
+	return result
+}
+
+// Exclude returns the minimal set of prefixes covering prefixes, minus any space covered by
+// exclude.
+func Exclude(prefixes, exclude []netip.Prefix) []netip.Prefix {
+	result := Aggregate(prefixes)
+	for _, x := range Aggregate(exclude) {
+		next := []netip.Prefix{}
+		for _, p := range result {
+			next = append(next, subtractPrefix(p, x)...)
+		}
+		result = next
+	}
+	return Aggregate(result)
+}
+
+// ParsePrefixList parses prefixes, IP addresses & IP ranges separated by whitespace, commas or
+// newlines. Ranges are converted to the prefixes covering them. Lines starting with '#' are
+// ignored.
+func ParsePrefixList(r io.Reader) ([]netip.Prefix, error) {
+	prefixes := []netip.Prefix{}
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		text = rangeSeparator.ReplaceAllString(text, "-")
+		fields := strings.FieldsFunc(text, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		})
+		for _, f := range fields {
+			if strings.Contains(f, "-") {
+				r, err := ParseIPRange(f)
+				if err != nil {
+					return nil, fmt.Errorf("%w on line %d", err, line)
+				}
+				prefixes = append(prefixes, r.Prefixes()...)
+				continue
+			}
+			p, err := parsePrefixOrAddr(f)
+			if err != nil {
+				return nil, fmt.Errorf("invalid prefix '%s' on line %d", f, line)
+			}
+			prefixes = append(prefixes, p)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return prefixes, nil
+}
+
+// subtractPrefix returns the prefixes covering p, minus any space covered by x.
+func subtractPrefix(p, x netip.Prefix) []netip.Prefix {
+	if !p.Overlaps(x) {
+		return []netip.Prefix{p}
+	}
+	if x.Bits() <= p.Bits() {
+		return []netip.Prefix{}
+	}
+	lo := netip.PrefixFrom(p.Addr(), p.Bits()+1)
+	hi := netip.PrefixFrom(LastAddr(lo).Next(), p.Bits()+1)
+	return append(subtractPrefix(lo, x), subtractPrefix(hi, x)...)
+}
+
+func sortPrefixes(prefixes []netip.Prefix) {
+	sort.Slice(prefixes, func(i, j int) bool {
+		if c := prefixes[i].Addr().Compare(prefixes[j].Addr()); c != 0 {
+			return c < 0
+		}
+		return prefixes[i].Bits() < prefixes[j].Bits()
+	})
+}
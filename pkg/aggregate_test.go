@@ -0,0 +1,93 @@
+package addr_test
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	addr "github.com/thatmattlove/addr/pkg"
+)
+
+func prefixes(in ...string) []netip.Prefix {
+	out := make([]netip.Prefix, 0, len(in))
+	for _, p := range in {
+		out = append(out, netip.MustParsePrefix(p))
+	}
+	return out
+}
+
+func Test_Aggregate(t *testing.T) {
+	t.Run("adjacent", func(t *testing.T) {
+		t.Parallel()
+		result := addr.Aggregate(prefixes("192.0.2.0/25", "192.0.2.128/25", "198.51.100.0/24"))
+		assert.Equal(t, prefixes("192.0.2.0/24", "198.51.100.0/24"), result)
+	})
+	t.Run("cascading", func(t *testing.T) {
+		t.Parallel()
+		result := addr.Aggregate(prefixes("10.0.3.0/24", "10.0.0.0/24", "10.0.2.0/24", "10.0.1.0/24"))
+		assert.Equal(t, prefixes("10.0.0.0/22"), result)
+	})
+	t.Run("covered & duplicates", func(t *testing.T) {
+		t.Parallel()
+		result := addr.Aggregate(prefixes("10.0.0.0/8", "10.1.0.0/16", "10.0.0.0/8", "10.255.255.0/24"))
+		assert.Equal(t, prefixes("10.0.0.0/8"), result)
+	})
+	t.Run("non-aligned neighbours are not merged", func(t *testing.T) {
+		t.Parallel()
+		result := addr.Aggregate(prefixes("10.0.1.0/24", "10.0.2.0/24"))
+		assert.Equal(t, prefixes("10.0.1.0/24", "10.0.2.0/24"), result)
+	})
+	t.Run("host bits", func(t *testing.T) {
+		t.Parallel()
+		result := addr.Aggregate(prefixes("192.0.2.1/24", "192.0.3.0/24"))
+		assert.Equal(t, prefixes("192.0.2.0/23"), result)
+	})
+	t.Run("mixed families", func(t *testing.T) {
+		t.Parallel()
+		result := addr.Aggregate(prefixes("2001:db8:1::/48", "192.0.2.0/24", "2001:db8::/48"))
+		assert.Equal(t, prefixes("192.0.2.0/24", "2001:db8::/47"), result)
+	})
+	t.Run("empty", func(t *testing.T) {
+		t.Parallel()
+		assert.Empty(t, addr.Aggregate(nil))
+	})
+}
+
+func Test_Exclude(t *testing.T) {
+	t.Run("hole", func(t *testing.T) {
+		t.Parallel()
+		result := addr.Exclude(prefixes("192.0.2.0/24"), prefixes("192.0.2.64/26"))
+		assert.Equal(t, prefixes("192.0.2.0/26", "192.0.2.128/25"), result)
+	})
+	t.Run("covering exclusion", func(t *testing.T) {
+		t.Parallel()
+		result := addr.Exclude(prefixes("10.1.0.0/16", "192.0.2.0/24"), prefixes("10.0.0.0/8"))
+		assert.Equal(t, prefixes("192.0.2.0/24"), result)
+	})
+	t.Run("ipv6", func(t *testing.T) {
+		t.Parallel()
+		result := addr.Exclude(prefixes("2001:db8::/32"), prefixes("2001:db8::/34", "2001:db8:4000::/34"))
+		assert.Equal(t, prefixes("2001:db8:8000::/33"), result)
+	})
+	t.Run("no overlap", func(t *testing.T) {
+		t.Parallel()
+		result := addr.Exclude(prefixes("192.0.2.0/24"), prefixes("2001:db8::/32"))
+		assert.Equal(t, prefixes("192.0.2.0/24"), result)
+	})
+}
+
+func Test_ParsePrefixList(t *testing.T) {
+	t.Run("basic", func(t *testing.T) {
+		t.Parallel()
+		in := "# comment\n192.0.2.0/24, 198.51.100.1\n\n  2001:db8::/32\t2001:db8::1\n"
+		result, err := addr.ParsePrefixList(strings.NewReader(in))
+		assert.NoError(t, err)
+		assert.Equal(t, prefixes("192.0.2.0/24", "198.51.100.1/32", "2001:db8::/32", "2001:db8::1/128"), result)
+	})
+	t.Run("invalid", func(t *testing.T) {
+		t.Parallel()
+		_, err := addr.ParsePrefixList(strings.NewReader("192.0.2.0/24\nnope\n"))
+		assert.ErrorContains(t, err, "line 2")
+	})
+}
@@ -0,0 +1,110 @@
+package addr
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	goasn "github.com/thatmattlove/go-asn"
+)
+
+// MAX_ASN_RANGE is the maximum number of ASNs a range may contain.
+var MAX_ASN_RANGE int = 1024
+
+type ErrInvalidASN error
+
+func NewErrInvalidASN(in string, reason error) ErrInvalidASN {
+	return fmt.Errorf("invalid ASN '%s': %w", in, reason)
+}
+
+// ASNRange is an inclusive range of ASNs.
+type ASNRange struct {
+	Low  goasn.ASN
+	High goasn.ASN
+}
+
+// ParseASN strictly parses an ASN in asplain (AS65546), asdot (AS1.10) or asdot+ (AS0.64512)
+// format. The 'AS' prefix is optional and may be any case.
+func ParseASN(in string) (goasn.ASN, error) {
+	s := strings.TrimSpace(in)
+	if len(s) >= 2 && strings.EqualFold(s[:2], "as") {
+		s = s[2:]
+	}
+	if s == "" {
+		return nil, NewErrInvalidASN(in, errors.New("no number"))
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && c != '.' {
+			return nil, NewErrInvalidASN(in, fmt.Errorf("unexpected character '%c'", c))
+		}
+	}
+	parts := strings.Split(s, ".")
+	switch len(parts) {
+	case 1:
+		if _, err := strconv.ParseUint(s, 10, 32); err != nil {
+			return nil, NewErrInvalidASN(in, goasn.ErrOutOf4ByteRange)
+		}
+	case 2:
+		for _, p := range parts {
+			if p == "" {
+				return nil, NewErrInvalidASN(in, errors.New("asdot notation requires a number on both sides of '.'"))
+			}
+			if _, err := strconv.ParseUint(p, 10, 16); err != nil {
+				return nil, NewErrInvalidASN(in, goasn.ErrOutOf2ByteRange)
+			}
+		}
+	default:
+		return nil, NewErrInvalidASN(in, errors.New("asdot notation must have exactly two parts"))
+	}
+	asn, err := goasn.Parse(s)
+	if err != nil {
+		return nil, NewErrInvalidASN(in, err)
+	}
+	return asn, nil
+}
+
+func IsASN(in string) bool {
+	_, err := ParseASN(in)
+	return err == nil
+}
+
+// ParseASNRange parses either a single ASN or a range of ASNs, such as AS64512-AS64520. Each end
+// of the range is parsed with ParseASN.
+func ParseASNRange(in string) (ASNRange, error) {
+	lowStr, highStr, isRange := strings.Cut(in, "-")
+	low, err := ParseASN(lowStr)
+	if err != nil {
+		return ASNRange{}, err
+	}
+	if !isRange {
+		return ASNRange{Low: low, High: low}, nil
+	}
+	high, err := ParseASN(highStr)
+	if err != nil {
+		return ASNRange{}, err
+	}
+	if high.LessThan(low) {
+		return ASNRange{}, fmt.Errorf("invalid ASN range '%s': AS%s is lower than AS%s", in, high, low)
+	}
+	if size := uint64(high.Uint32()) - uint64(low.Uint32()) + 1; size > uint64(MAX_ASN_RANGE) {
+		return ASNRange{}, fmt.Errorf("invalid ASN range '%s': %d ASNs exceeds the limit of %d", in, size, MAX_ASN_RANGE)
+	}
+	return ASNRange{Low: low, High: high}, nil
+}
+
+func (r ASNRange) String() string {
+	if r.Low.Equal(r.High) {
+		return "AS" + r.Low.ASPlain()
+	}
+	return fmt.Sprintf("AS%s-AS%s", r.Low.ASPlain(), r.High.ASPlain())
+}
+
+// ASNs returns every ASN in the range.
+func (r ASNRange) ASNs() []goasn.ASN {
+	asns := []goasn.ASN{}
+	for n := uint64(r.Low.Uint32()); n <= uint64(r.High.Uint32()); n++ {
+		asns = append(asns, goasn.FromUint32(uint32(n)))
+	}
+	return asns
+}
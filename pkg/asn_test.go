@@ -0,0 +1,105 @@
+package addr_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	addr "github.com/thatmattlove/addr/pkg"
+	goasn "github.com/thatmattlove/go-asn"
+)
+
+func Test_ParseASN(t *testing.T) {
+	type CaseT struct {
+		in       string
+		expected uint32
+	}
+	valid := []CaseT{
+		{"14525", 14525},
+		{"AS14525", 14525},
+		{"as14525", 14525},
+		{"As14525", 14525},
+		{"aS14525", 14525},
+		{" AS14525 ", 14525},
+		{"AS1.10", 65546},
+		{"1.10", 65546},
+		{"AS0.64512", 64512},
+		{"4294967295", 4294967295},
+		{"AS65535.65535", 4294967295},
+	}
+	for _, c := range valid {
+		c := c
+		t.Run(c.in, func(t *testing.T) {
+			t.Parallel()
+			asn, err := addr.ParseASN(c.in)
+			assert.NoError(t, err)
+			assert.Equal(t, c.expected, asn.Uint32())
+			assert.True(t, addr.IsASN(c.in))
+		})
+	}
+	type ErrCaseT struct {
+		in       string
+		contains string
+	}
+	invalid := []ErrCaseT{
+		{"", "no number"},
+		{"AS", "no number"},
+		{"test", "unexpected character 't'"},
+		{"foo1", "unexpected character 'f'"},
+		{"AS-1", "unexpected character '-'"},
+		{"192.0.2.1", "exactly two parts"},
+		{"1.2.3", "exactly two parts"},
+		{"1.", "both sides"},
+		{"4294967296", goasn.ErrOutOf4ByteRange.Error()},
+		{"AS65536.1", goasn.ErrOutOf2ByteRange.Error()},
+	}
+	for _, c := range invalid {
+		c := c
+		t.Run("invalid "+c.in, func(t *testing.T) {
+			t.Parallel()
+			_, err := addr.ParseASN(c.in)
+			assert.ErrorContains(t, err, c.contains)
+			assert.ErrorContains(t, err, "invalid ASN '"+c.in+"'")
+			assert.False(t, addr.IsASN(c.in))
+		})
+	}
+}
+
+func Test_ParseASNRange(t *testing.T) {
+	t.Run("range", func(t *testing.T) {
+		t.Parallel()
+		r, err := addr.ParseASNRange("AS64512-AS64520")
+		assert.NoError(t, err)
+		assert.Equal(t, uint32(64512), r.Low.Uint32())
+		assert.Equal(t, uint32(64520), r.High.Uint32())
+		assert.Len(t, r.ASNs(), 9)
+		assert.Equal(t, "AS64512-AS64520", r.String())
+	})
+	t.Run("mixed notation", func(t *testing.T) {
+		t.Parallel()
+		r, err := addr.ParseASNRange("as1.0-65537")
+		assert.NoError(t, err)
+		assert.Equal(t, []uint32{65536, 65537}, []uint32{r.ASNs()[0].Uint32(), r.ASNs()[1].Uint32()})
+	})
+	t.Run("single", func(t *testing.T) {
+		t.Parallel()
+		r, err := addr.ParseASNRange("AS14525")
+		assert.NoError(t, err)
+		assert.Len(t, r.ASNs(), 1)
+		assert.Equal(t, "AS14525", r.String())
+	})
+	t.Run("reversed", func(t *testing.T) {
+		t.Parallel()
+		_, err := addr.ParseASNRange("AS64520-AS64512")
+		assert.ErrorContains(t, err, "AS64512 is lower than AS64520")
+	})
+	t.Run("too large", func(t *testing.T) {
+		t.Parallel()
+		_, err := addr.ParseASNRange("AS1-AS4294967295")
+		assert.ErrorContains(t, err, "exceeds the limit")
+	})
+	t.Run("invalid end", func(t *testing.T) {
+		t.Parallel()
+		_, err := addr.ParseASNRange("AS64512-ASx")
+		assert.ErrorContains(t, err, "invalid ASN 'ASx'")
+	})
+}
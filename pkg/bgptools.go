@@ -5,10 +5,12 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"net/netip"
 	"strings"
 	"time"
 
 	"github.com/biter777/countries"
+	"github.com/thatmattlove/addr/pkg/metrics"
 	"github.com/thatmattlove/addr/pkg/whois"
 	goasn "github.com/thatmattlove/go-asn"
 )
@@ -17,11 +19,26 @@ type Response struct {
 	ASN       goasn.ASN
 	IP        *net.IP
 	Prefix    *net.IPNet
+	Addr      netip.Addr
+	Network   netip.Prefix
 	Country   countries.CountryCode
 	Registry  string
 	Allocated time.Time
 	Name      string
 	FromQuery bool
+	Embedded  *Embedded
+	Local     *LocalDefinition
+	// Geo & GeoASN are from QueryOptions.MMDB, if any of its databases have the address.
+	Geo    *GeoLocation
+	GeoASN *MMDBASN
+	// Delegation is from QueryOptions.Delegated, if it has the address or ASN.
+	Delegation *Delegation
+	// Route is from QueryOptions.RIB, if it has a route to the address.
+	Route *RIBEntry
+	// Neighbors are from LookupNeighbors, if requested for an ASN.
+	Neighbors *ASNeighbors
+	// PeeringDB is from QueryOptions.PeeringDB, if it has the ASN.
+	PeeringDB *PeeringDBNetwork
 }
 
 var (
@@ -32,6 +49,14 @@ var (
 var ErrEmptyResponse = errors.New("empty response")
 
 func ParseResponse(res string) (*Response, error) {
+	r, err := parseResponse(res)
+	if err != nil {
+		metrics.HOOK.ParseFailure(metrics.PROVIDER_WHOIS, err)
+	}
+	return r, err
+}
+
+func parseResponse(res string) (*Response, error) {
 	scanner := bufio.NewScanner(strings.NewReader(res))
 	scanner.Scan()
 
@@ -79,6 +104,11 @@ func ParseResponse(res string) (*Response, error) {
 		}
 	}
 
+	return responseFromRow(values)
+}
+
+// responseFromRow creates a response from the columns of a verbose whois row.
+func responseFromRow(values []string) (*Response, error) {
 	asnStr := values[0]       // 'AS' column.
 	ipStr := values[1]        // 'IP' column.
 	pfxStr := values[2]       // 'BGP Prefix' column.
@@ -124,50 +154,291 @@ func ParseResponse(res string) (*Response, error) {
 			return nil, err
 		}
 		response.Prefix = pfx
+		response.Addr = AddrFromIP(ip)
+		response.Network = PrefixFromIPNet(pfx)
 	}
 	return response, nil
 }
 
+// bulkRow is the response to one address in a bulk whois response, or the error parsing it.
+type bulkRow struct {
+	res *Response
+	err error
+}
+
+// parseBulkResponse parses a verbose bulk whois response, keyed by each row's 'IP' column. Warnings,
+// the header row & rows without a valid address are skipped.
+func parseBulkResponse(res string) map[netip.Addr]bulkRow {
+	rows := map[netip.Addr]bulkRow{}
+	scanner := bufio.NewScanner(strings.NewReader(res))
+	for scanner.Scan() {
+		parts := strings.Split(scanner.Text(), "|")
+		if len(parts) != 7 {
+			continue
+		}
+		values := make([]string, len(parts))
+		for i, p := range parts {
+			values[i] = strings.TrimSpace(p)
+		}
+		a, err := netip.ParseAddr(values[1])
+		if err != nil {
+			continue
+		}
+		r, err := responseFromRow(values)
+		if err != nil {
+			metrics.HOOK.ParseFailure(metrics.PROVIDER_WHOIS, err)
+		}
+		rows[a.Unmap()] = bulkRow{res: r, err: err}
+	}
+	return rows
+}
+
 func QueryASN(asnStr string) (*Response, error) {
-	asn, err := goasn.Parse(asnStr)
+	return QueryASNWith(nil, asnStr, QueryOptions{})
+}
+
+// QueryASNWith is QueryASN using an existing whois client, which must not be used concurrently,
+// and opts. If w is nil, a client is created when a query is needed.
+func QueryASNWith(w *whois.Whois, asnStr string, opts QueryOptions) (*Response, error) {
+	validator, err := NewASNValidator(asnStr)
 	if err != nil {
 		return nil, err
 	}
-	w, err := whois.New(WHOIS_HOST, WHOIS_PORT)
-	if err != nil {
-		return nil, err
+	shouldQuery, res := validator.Validate()
+	if !shouldQuery && res != nil {
+		return res, nil
 	}
-	result, err := w.Query(fmt.Sprintf("as%s", asn.ASPlain()))
+	// Don't silently leave out details from data sources that were given but couldn't be read.
+	if err := opts.delegated().Err(); err != nil {
+		return nil, fmt.Errorf("failed to load delegations: %w", err)
+	}
+	if err := opts.peeringDB().Err(); err != nil {
+		return nil, fmt.Errorf("failed to load PeeringDB: %w", err)
+	}
+	if opts.Offline {
+		return offlineASNResponse(validator.ASN, opts)
+	}
+	if w == nil {
+		w, err = whois.New(WHOIS_HOST, WHOIS_PORT)
+		if err != nil {
+			return nil, err
+		}
+	}
+	result, err := w.Query(fmt.Sprintf("as%s", validator.ASN.ASPlain()))
 	if err != nil {
 		return nil, err
 	}
-	res, err := ParseResponse(result)
+	res, err = ParseResponse(result)
 	if err != nil {
 		return nil, err
 	}
+	res.delegate(opts.delegated().LookupASN(res.ASN))
+	res.network(opts.peeringDB().Network(res.ASN.Uint32()))
 	return res, nil
 }
 
+// QueryOptions change how QueryIPPrefixWith & QueryASNWith look up a target. Data sources that
+// aren't set are empty.
+type QueryOptions struct {
+	// NAT64Prefixes are network-specific NAT64 prefixes to extract IPv4 addresses from, in
+	// addition to NAT64_PREFIXES.
+	NAT64Prefixes []netip.Prefix
+	// Offline disables bgp.tools queries. IP lookups are answered from local definitions, the
+	// special-purpose tables, RIB, MMDB & Delegated only, and ASN lookups from Delegated &
+	// PeeringDB.
+	Offline bool
+	// MMDB is consulted for the location & MMDB ASN of every IP lookup.
+	MMDB *MMDB
+	// Delegated is consulted for the registry, country, allocation date & status of every IP &
+	// ASN lookup.
+	Delegated *DelegatedTable
+	// RIB answers IP lookups when it has a route to the address, instead of bgp.tools.
+	RIB *RIB
+	// PeeringDB adds PeeringDB details to ASN results.
+	PeeringDB *PeeringDB
+}
+
+// Empty data sources, used in place of QueryOptions' unset ones.
+var (
+	emptyMMDB      = NewMMDB()
+	emptyDelegated = NewDelegatedTable()
+	emptyRIB       = NewRIB()
+	emptyPeeringDB = NewPeeringDB()
+)
+
+func (opts QueryOptions) mmdb() *MMDB {
+	if opts.MMDB == nil {
+		return emptyMMDB
+	}
+	return opts.MMDB
+}
+
+func (opts QueryOptions) delegated() *DelegatedTable {
+	if opts.Delegated == nil {
+		return emptyDelegated
+	}
+	return opts.Delegated
+}
+
+func (opts QueryOptions) rib() *RIB {
+	if opts.RIB == nil {
+		return emptyRIB
+	}
+	return opts.RIB
+}
+
+func (opts QueryOptions) peeringDB() *PeeringDB {
+	if opts.PeeringDB == nil {
+		return emptyPeeringDB
+	}
+	return opts.PeeringDB
+}
+
 func QueryIPPrefix(q string) (*Response, error) {
+	return QueryIPPrefixWith(nil, q, QueryOptions{})
+}
+
+// QueryIPPrefixWith is QueryIPPrefix using an existing whois client, which must not be used
+// concurrently, and opts. If w is nil, a client is created when a query is needed.
+func QueryIPPrefixWith(w *whois.Whois, q string, opts QueryOptions) (*Response, error) {
+	return queryIPPrefix(q, opts, func(q string) (*Response, error) {
+		var err error
+		if w == nil {
+			w, err = whois.New(WHOIS_HOST, WHOIS_PORT)
+			if err != nil {
+				return nil, err
+			}
+		}
+		result, err := w.Query(q)
+		if err != nil {
+			return nil, err
+		}
+		return ParseResponse(result)
+	})
+}
+
+// QueryIPPrefixes looks up each address like QueryIPPrefixWith, but makes every whois query in bulk
+// over one connection. Responses & errors are in the same order as addrs.
+func QueryIPPrefixes(addrs []netip.Addr, opts QueryOptions) ([]*Response, []error) {
+	queries := []string{}
+	queued := map[netip.Addr]bool{}
+	var queue = func(a netip.Addr) {
+		if !queued[a] && needsWhoisQuery(a, opts) {
+			queued[a] = true
+			queries = append(queries, a.String())
+		}
+	}
+	for _, a := range addrs {
+		a = a.Unmap()
+		queue(a)
+		if embedded := ExtractEmbeddedIPv4(a, opts.NAT64Prefixes...); embedded != nil {
+			queue(embedded.IPv4)
+		}
+	}
+	var rows map[netip.Addr]bulkRow
+	var bulkErr error
+	if len(queries) != 0 {
+		var w *whois.Whois
+		w, bulkErr = whois.New(WHOIS_HOST, WHOIS_PORT)
+		if bulkErr == nil {
+			var result string
+			result, bulkErr = w.BulkQuery(queries)
+			rows = parseBulkResponse(result)
+		}
+	}
+	lookup := func(q string) (*Response, error) {
+		if bulkErr != nil {
+			return nil, bulkErr
+		}
+		a, err := netip.ParseAddr(q)
+		if err != nil {
+			return nil, err
+		}
+		row, ok := rows[a.Unmap()]
+		if !ok {
+			return nil, ErrEmptyResponse
+		}
+		if row.err != nil {
+			return nil, row.err
+		}
+		// Copy the response, since an address can also be another's embedded IPv4 address.
+		res := *row.res
+		return &res, nil
+	}
+	responses := make([]*Response, len(addrs))
+	errs := make([]error, len(addrs))
+	for i, a := range addrs {
+		responses[i], errs[i] = queryIPPrefix(a.Unmap().String(), opts, lookup)
+	}
+	return responses, errs
+}
+
+// needsWhoisQuery reports whether looking up a needs a whois query, which it doesn't if it's a
+// special-purpose or locally defined address, it's in opts.RIB, or opts.Offline is set.
+func needsWhoisQuery(a netip.Addr, opts QueryOptions) bool {
+	if opts.Offline {
+		return false
+	}
+	validator, err := NewIPValidator(a.String())
+	if err != nil {
+		return false
+	}
+	shouldQuery, _ := validator.Validate()
+	return shouldQuery && opts.rib().Response(validator) == nil
+}
+
+// queryIPPrefix looks up q, using whoisLookup for addresses that need a whois query.
+func queryIPPrefix(q string, opts QueryOptions, whoisLookup func(string) (*Response, error)) (*Response, error) {
 	validator, err := NewIPValidator(q)
 	if err != nil {
 		return nil, err
 	}
+	embedded := validator.Embedded(opts.NAT64Prefixes...)
 	shouldQuery, res := validator.Validate()
-	if !shouldQuery && res != nil {
-		return res, nil
+	if shouldQuery {
+		if err := opts.delegated().Err(); err != nil {
+			return nil, fmt.Errorf("failed to load delegations: %w", err)
+		}
 	}
-	w, err := whois.New(WHOIS_HOST, WHOIS_PORT)
-	if err != nil {
-		return nil, err
+	var route *Response
+	if shouldQuery {
+		route = opts.rib().Response(validator)
 	}
-	result, err := w.Query(q)
-	if err != nil {
-		return nil, err
+	if route != nil {
+		res = route
+	} else if shouldQuery && opts.Offline {
+		res, err = offlineResponse(validator, opts)
+		if err != nil {
+			return nil, err
+		}
+	} else if shouldQuery {
+		res, err = whoisLookup(q)
+		if err != nil {
+			return nil, err
+		}
 	}
-	res, err = ParseResponse(result)
-	if err != nil {
-		return nil, err
+	if embedded != nil {
+		// Look up the embedded IPv4 address as well, since its origin is usually more useful
+		// than that of the translation or tunnel prefix. If that fails, the IPv6 result is still
+		// returned, without the origin.
+		origin, err := queryIPPrefix(embedded.IPv4.String(), opts, whoisLookup)
+		if err == nil {
+			embedded.Origin = origin
+		}
+		res.Embedded = embedded
+	}
+	if shouldQuery {
+		res.delegate(opts.delegated().LookupAddr(validator.Addr))
+	}
+	if res.Geo == nil && res.GeoASN == nil {
+		res.Geo, res.GeoASN = opts.mmdb().Lookup(validator.Addr)
+	}
+	if route != nil {
+		// RIBs don't have AS names, so name the origin from the other data sources.
+		if res.GeoASN != nil && res.GeoASN.ASN.Uint32() == res.ASN.Uint32() && res.Name == "" {
+			res.Name = res.GeoASN.Name
+		}
+		res.network(opts.peeringDB().Network(res.ASN.Uint32()))
 	}
 	return res, nil
 }
@@ -2,11 +2,13 @@ package addr_test
 
 import (
 	"net"
+	"net/netip"
 	"testing"
 
 	"github.com/biter777/countries"
 	"github.com/stretchr/testify/assert"
 	addr "github.com/thatmattlove/addr/pkg"
+	"github.com/thatmattlove/addr/pkg/metrics"
 	goasn "github.com/thatmattlove/go-asn"
 )
 
@@ -47,6 +49,13 @@ func Test_QueryASN(t *testing.T) {
 		assert.Equal(t, countries.USA, asn.Country, q)
 		assert.Equal(t, "Stellar Technologies Inc.", asn.Name, q)
 	})
+	t.Run("special-purpose", func(t *testing.T) {
+		t.Parallel()
+		res, err := addr.QueryASN("AS64512")
+		assert.NoError(t, err)
+		assert.False(t, res.FromQuery)
+		assert.Equal(t, "Private Use ASN (RFC6996)", res.Name)
+	})
 	t.Run("invalid asn", func(t *testing.T) {
 		t.Parallel()
 		_, err := addr.QueryASN("this will fail")
@@ -122,8 +131,10 @@ func Test_QueryIPPrefix(t *testing.T) {
 func Test_ParseResponse(t *testing.T) {
 	t.Run("valid", func(t *testing.T) {
 		t.Parallel()
-		_, err := addr.ParseResponse(RES_VALID)
+		res, err := addr.ParseResponse(RES_VALID)
 		assert.NoError(t, err)
+		assert.Equal(t, netip.MustParseAddr("1.1.1.0"), res.Addr)
+		assert.Equal(t, netip.MustParsePrefix("1.1.1.0/24"), res.Network)
 	})
 	t.Run("with warning", func(t *testing.T) {
 		t.Parallel()
@@ -157,3 +168,78 @@ func Test_ParseResponse(t *testing.T) {
 		assert.Error(t, err)
 	})
 }
+
+type parseFailures struct {
+	metrics.Noop
+	count int
+}
+
+func (p *parseFailures) ParseFailure(provider string, err error) {
+	p.count++
+}
+
+func Test_ParseResponse_Metrics(t *testing.T) {
+	m := &parseFailures{}
+	prev := metrics.HOOK
+	metrics.HOOK = m
+	t.Cleanup(func() { metrics.HOOK = prev })
+	_, err := addr.ParseResponse(RES_VALID)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, m.count)
+	_, err = addr.ParseResponse(RES_INVALID_TIME)
+	assert.Error(t, err)
+	_, err = addr.ParseResponse(RES_EMPTY)
+	assert.Error(t, err)
+	assert.Equal(t, 2, m.count)
+}
+
+func Test_QueryIPPrefixes(t *testing.T) {
+	requests := serveBulkWhois(t, `AS      | IP               | BGP Prefix          | CC | Registry | Allocated  | AS Name
+13335   | 1.1.1.1          | 1.1.1.0/24          | US | ARIN     | 2010-07-14 | Cloudflare, Inc.
+15169   | 8.8.8.8          | 8.8.8.0/24          | US | ARIN     | 1992-12-01 | Google LLC
+invalid | 8.8.4.4          | 8.8.4.0/24          | US | ARIN     | 1992-12-01 | Google LLC
+`)
+	addrs := []netip.Addr{
+		netip.MustParseAddr("1.1.1.1"),
+		netip.MustParseAddr("192.168.1.1"),
+		netip.MustParseAddr("8.8.8.8"),
+		netip.MustParseAddr("9.9.9.9"),
+		netip.MustParseAddr("8.8.4.4"),
+		netip.MustParseAddr("64:ff9b::808:808"),
+	}
+	responses, errs := addr.QueryIPPrefixes(addrs, addr.QueryOptions{})
+	assert.Equal(t, "begin\nverbose\n1.1.1.1\n8.8.8.8\n9.9.9.9\n8.8.4.4\nend\n", <-requests)
+	assert.Len(t, requests, 0, "queries are made over one connection")
+
+	assert.NoError(t, errs[0])
+	assert.Equal(t, "13335", responses[0].ASN.ASPlain())
+	assert.Equal(t, "1.1.1.0/24", responses[0].Network.String())
+	assert.NoError(t, errs[1])
+	assert.False(t, responses[1].FromQuery, "special-purpose addresses aren't queried")
+	assert.Equal(t, "Google LLC", responses[2].Name)
+	assert.ErrorIs(t, errs[3], addr.ErrEmptyResponse)
+	assert.Nil(t, responses[3])
+	assert.Error(t, errs[4])
+	assert.NoError(t, errs[5])
+	if assert.NotNil(t, responses[5].Embedded) && assert.NotNil(t, responses[5].Embedded.Origin) {
+		assert.Equal(t, "15169", responses[5].Embedded.Origin.ASN.ASPlain())
+	}
+
+	t.Run("connection failure", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+		port := addr.WHOIS_PORT
+		addr.WHOIS_PORT = uint(ln.Addr().(*net.TCPAddr).Port)
+		ln.Close()
+		t.Cleanup(func() { addr.WHOIS_PORT = port })
+		responses, errs := addr.QueryIPPrefixes(addrs[:2], addr.QueryOptions{})
+		assert.Error(t, errs[0])
+		assert.NoError(t, errs[1])
+		assert.Equal(t, addr.TXT_PRIVATE, responses[1].Name)
+	})
+	t.Run("nothing to query", func(t *testing.T) {
+		_, errs := addr.QueryIPPrefixes([]netip.Addr{netip.MustParseAddr("10.0.0.1")}, addr.QueryOptions{})
+		assert.NoError(t, errs[0])
+		assert.Len(t, requests, 0)
+	})
+}
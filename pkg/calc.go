@@ -0,0 +1,185 @@
+package addr
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// MAX_SUBNETS is the maximum number of subnets Subnets will return.
+var MAX_SUBNETS int = 65536
+
+type ErrTooManySubnets error
+
+func NewErrTooManySubnets(p netip.Prefix, bits int) ErrTooManySubnets {
+	return fmt.Errorf("splitting %s into /%d subnets would exceed the limit of %d", p, bits, MAX_SUBNETS)
+}
+
+// PrefixInfo is the result of a prefix calculation.
+type PrefixInfo struct {
+	// Input is the prefix as given, which may have host bits set.
+	Input netip.Prefix
+	// Prefix is Input with host bits cleared.
+	Prefix      netip.Prefix
+	HostBitsSet bool
+	Network     netip.Addr
+	// Broadcast is only set for IPv4 prefixes shorter than /31.
+	Broadcast netip.Addr
+	// Last is the last address in the prefix.
+	Last netip.Addr
+	// FirstUsable & LastUsable exclude the network & broadcast addresses for IPv4 prefixes
+	// shorter than /31. Every IPv6 address is usable.
+	FirstUsable netip.Addr
+	LastUsable  netip.Addr
+	Size        *big.Int
+	Usable      *big.Int
+	Netmask     netip.Addr
+	Wildcard    netip.Addr
+	// ReverseZones are the reverse DNS zones covering the prefix.
+	ReverseZones []string
+	// NibbleAligned is true if an IPv6 prefix length falls on a nibble (4 bit) boundary.
+	NibbleAligned bool
+	// NibbleSupernet is the closest nibble-aligned prefix containing an IPv6 prefix.
+	NibbleSupernet netip.Prefix
+}
+
+// NewPrefixInfo parses a prefix or IP address and calculates its details. Host bits may be set.
+func NewPrefixInfo(in string) (*PrefixInfo, error) {
+	in = strings.TrimSpace(in)
+	if a, err := netip.ParseAddr(in); err == nil {
+		return PrefixInfoFromPrefix(netip.PrefixFrom(a, a.BitLen())), nil
+	}
+	p, err := netip.ParsePrefix(in)
+	if err != nil {
+		return nil, fmt.Errorf("invalid prefix '%s'", in)
+	}
+	return PrefixInfoFromPrefix(p), nil
+}
+
+// PrefixInfoFromPrefix calculates the details of p.
+func PrefixInfoFromPrefix(p netip.Prefix) *PrefixInfo {
+	masked := p.Masked()
+	bits := masked.Bits()
+	bitLen := masked.Addr().BitLen()
+	hostBits := bitLen - bits
+	info := &PrefixInfo{
+		Input:       p,
+		Prefix:      masked,
+		HostBitsSet: p.Addr() != masked.Addr(),
+		Network:     masked.Addr(),
+		Last:        LastAddr(masked),
+		Size:        new(big.Int).Lsh(big.NewInt(1), uint(hostBits)),
+		Netmask:     maskAddr(bits, bitLen, false),
+		Wildcard:    maskAddr(bits, bitLen, true),
+	}
+	info.FirstUsable = info.Network
+	info.LastUsable = info.Last
+	info.Usable = new(big.Int).Set(info.Size)
+	if bitLen == IPv4Bits && hostBits > 1 {
+		info.Broadcast = info.Last
+		info.FirstUsable = info.Network.Next()
+		info.LastUsable = info.Last.Prev()
+		info.Usable.Sub(info.Usable, big.NewInt(2))
+	}
+	info.ReverseZones = ReverseZones(masked)
+	if bitLen == IPv6Bits {
+		info.NibbleAligned = bits%4 == 0
+		info.NibbleSupernet = netip.PrefixFrom(masked.Addr(), bits-bits%4).Masked()
+	}
+	return info
+}
+
+// LastAddr returns the last address in p.
+func LastAddr(p netip.Prefix) netip.Addr {
+	p = p.Masked()
+	b := p.Addr().AsSlice()
+	for i := p.Bits(); i < len(b)*8; i++ {
+		b[i/8] |= 1 << (7 - i%8)
+	}
+	a, _ := netip.AddrFromSlice(b)
+	return a
+}
+
+// Subnets splits p into subnets of length bits.
+func Subnets(p netip.Prefix, bits int) ([]netip.Prefix, error) {
+	p = p.Masked()
+	if bits < p.Bits() || bits > p.Addr().BitLen() {
+		return nil, fmt.Errorf("invalid subnet length /%d for %s", bits, p)
+	}
+	if bits-p.Bits() >= 31 || 1<<(bits-p.Bits()) > MAX_SUBNETS {
+		return nil, NewErrTooManySubnets(p, bits)
+	}
+	count := 1 << (bits - p.Bits())
+	subnets := make([]netip.Prefix, 0, count)
+	a := p.Addr()
+	for i := 0; i < count; i++ {
+		sub := netip.PrefixFrom(a, bits)
+		subnets = append(subnets, sub)
+		a = LastAddr(sub).Next()
+	}
+	return subnets, nil
+}
+
+// Supernet returns the prefix of length bits containing p.
+func Supernet(p netip.Prefix, bits int) (netip.Prefix, error) {
+	if bits < 0 || bits > p.Bits() {
+		return netip.Prefix{}, fmt.Errorf("invalid supernet length /%d for %s", bits, p.Masked())
+	}
+	return netip.PrefixFrom(p.Addr(), bits).Masked(), nil
+}
+
+// ReverseZones returns the in-addr.arpa or ip6.arpa zones covering p. Prefixes that don't fall on
+// an octet (IPv4) or nibble (IPv6) boundary are covered by multiple zones. IPv4 prefixes longer
+// than /24 return the enclosing /24 zone, which RFC2317 delegations are made from.
+func ReverseZones(p netip.Prefix) []string {
+	p = p.Masked()
+	unit := 4
+	if p.Addr().Is4() {
+		unit = 8
+	}
+	zoneBits := (p.Bits() + unit - 1) / unit * unit
+	if p.Addr().Is4() && zoneBits > 24 {
+		zoneBits = 24
+	}
+	if zoneBits < p.Bits() {
+		return []string{reverseZone(netip.PrefixFrom(p.Addr(), zoneBits).Masked())}
+	}
+	subnets, _ := Subnets(p, zoneBits)
+	zones := make([]string, 0, len(subnets))
+	for _, s := range subnets {
+		zones = append(zones, reverseZone(s))
+	}
+	return zones
+}
+
+func reverseZone(p netip.Prefix) string {
+	b := p.Addr().AsSlice()
+	labels := []string{}
+	if p.Addr().Is4() {
+		for i := p.Bits()/8 - 1; i >= 0; i-- {
+			labels = append(labels, fmt.Sprint(b[i]))
+		}
+		return strings.Join(append(labels, "in-addr.arpa"), ".")
+	}
+	for i := p.Bits()/4 - 1; i >= 0; i-- {
+		n := b[i/2]
+		if i%2 == 0 {
+			n >>= 4
+		}
+		labels = append(labels, fmt.Sprintf("%x", n&0xf))
+	}
+	return strings.Join(append(labels, "ip6.arpa"), ".")
+}
+
+func maskAddr(bits, bitLen int, invert bool) netip.Addr {
+	mask := net.CIDRMask(bits, bitLen)
+	if invert {
+		for i := range mask {
+			mask[i] = ^mask[i]
+		}
+	}
+	a, _ := netip.AddrFromSlice(mask)
+	return a
+}
@@ -0,0 +1,147 @@
+package addr_test
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	addr "github.com/thatmattlove/addr/pkg"
+)
+
+func Test_NewPrefixInfo(t *testing.T) {
+	t.Run("ipv4", func(t *testing.T) {
+		t.Parallel()
+		info, err := addr.NewPrefixInfo("192.0.2.0/24")
+		assert.NoError(t, err)
+		assert.False(t, info.HostBitsSet)
+		assert.Equal(t, "192.0.2.0", info.Network.String())
+		assert.Equal(t, "192.0.2.255", info.Broadcast.String())
+		assert.Equal(t, "192.0.2.1", info.FirstUsable.String())
+		assert.Equal(t, "192.0.2.254", info.LastUsable.String())
+		assert.Equal(t, "256", info.Size.String())
+		assert.Equal(t, "254", info.Usable.String())
+		assert.Equal(t, "255.255.255.0", info.Netmask.String())
+		assert.Equal(t, "0.0.0.255", info.Wildcard.String())
+		assert.Equal(t, []string{"2.0.192.in-addr.arpa"}, info.ReverseZones)
+	})
+	t.Run("host bits set", func(t *testing.T) {
+		t.Parallel()
+		info, err := addr.NewPrefixInfo("192.0.2.77/26")
+		assert.NoError(t, err)
+		assert.True(t, info.HostBitsSet)
+		assert.Equal(t, "192.0.2.64/26", info.Prefix.String())
+		assert.Equal(t, "192.0.2.127", info.Broadcast.String())
+		assert.Equal(t, []string{"2.0.192.in-addr.arpa"}, info.ReverseZones)
+	})
+	t.Run("ipv4 /31", func(t *testing.T) {
+		t.Parallel()
+		info, _ := addr.NewPrefixInfo("198.51.100.0/31")
+		assert.False(t, info.Broadcast.IsValid())
+		assert.Equal(t, "198.51.100.0", info.FirstUsable.String())
+		assert.Equal(t, "198.51.100.1", info.LastUsable.String())
+		assert.Equal(t, "2", info.Usable.String())
+	})
+	t.Run("ipv4 address", func(t *testing.T) {
+		t.Parallel()
+		info, err := addr.NewPrefixInfo("198.51.100.1")
+		assert.NoError(t, err)
+		assert.Equal(t, "198.51.100.1/32", info.Prefix.String())
+		assert.Equal(t, "1", info.Usable.String())
+	})
+	t.Run("ipv6", func(t *testing.T) {
+		t.Parallel()
+		info, err := addr.NewPrefixInfo("2001:db8::/46")
+		assert.NoError(t, err)
+		assert.Equal(t, "2001:db8:3:ffff:ffff:ffff:ffff:ffff", info.Last.String())
+		assert.Equal(t, info.Last, info.LastUsable)
+		assert.False(t, info.Broadcast.IsValid())
+		assert.Equal(t, "ffff:ffff:fffc::", info.Netmask.String())
+		assert.Equal(t, "4835703278458516698824704", info.Size.String())
+		assert.False(t, info.NibbleAligned)
+		assert.Equal(t, "2001:db8::/44", info.NibbleSupernet.String())
+		assert.Equal(t, []string{
+			"0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa",
+			"1.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa",
+			"2.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa",
+			"3.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa",
+		}, info.ReverseZones)
+	})
+	t.Run("ipv6 nibble aligned", func(t *testing.T) {
+		t.Parallel()
+		info, _ := addr.NewPrefixInfo("2001:db8:abc::/48")
+		assert.True(t, info.NibbleAligned)
+		assert.Equal(t, info.Prefix, info.NibbleSupernet)
+		assert.Equal(t, []string{"c.b.a.0.8.b.d.0.1.0.0.2.ip6.arpa"}, info.ReverseZones)
+	})
+	t.Run("invalid", func(t *testing.T) {
+		t.Parallel()
+		_, err := addr.NewPrefixInfo("192.0.2.0/33")
+		assert.Error(t, err)
+	})
+}
+
+func Test_Subnets(t *testing.T) {
+	t.Run("basic", func(t *testing.T) {
+		t.Parallel()
+		subnets, err := addr.Subnets(netip.MustParsePrefix("192.0.2.0/24"), 26)
+		assert.NoError(t, err)
+		assert.Equal(t, []netip.Prefix{
+			netip.MustParsePrefix("192.0.2.0/26"),
+			netip.MustParsePrefix("192.0.2.64/26"),
+			netip.MustParsePrefix("192.0.2.128/26"),
+			netip.MustParsePrefix("192.0.2.192/26"),
+		}, subnets)
+	})
+	t.Run("last subnets of address space", func(t *testing.T) {
+		t.Parallel()
+		subnets, err := addr.Subnets(netip.MustParsePrefix("ffff::/16"), 17)
+		assert.NoError(t, err)
+		assert.Equal(t, "ffff:8000::/17", subnets[1].String())
+	})
+	t.Run("too many", func(t *testing.T) {
+		t.Parallel()
+		_, err := addr.Subnets(netip.MustParsePrefix("2001:db8::/32"), 64)
+		assert.Error(t, err)
+	})
+	t.Run("shorter than prefix", func(t *testing.T) {
+		t.Parallel()
+		_, err := addr.Subnets(netip.MustParsePrefix("192.0.2.0/24"), 23)
+		assert.Error(t, err)
+	})
+}
+
+func Test_Supernet(t *testing.T) {
+	t.Run("basic", func(t *testing.T) {
+		t.Parallel()
+		p, err := addr.Supernet(netip.MustParsePrefix("192.0.3.0/24"), 22)
+		assert.NoError(t, err)
+		assert.Equal(t, "192.0.0.0/22", p.String())
+	})
+	t.Run("longer than prefix", func(t *testing.T) {
+		t.Parallel()
+		_, err := addr.Supernet(netip.MustParsePrefix("192.0.2.0/24"), 25)
+		assert.Error(t, err)
+	})
+}
+
+func Test_ReverseZones(t *testing.T) {
+	t.Run("ipv4 /22", func(t *testing.T) {
+		t.Parallel()
+		zones := addr.ReverseZones(netip.MustParsePrefix("198.51.100.0/22"))
+		assert.Equal(t, []string{
+			"100.51.198.in-addr.arpa",
+			"101.51.198.in-addr.arpa",
+			"102.51.198.in-addr.arpa",
+			"103.51.198.in-addr.arpa",
+		}, zones)
+	})
+	t.Run("ipv4 /8", func(t *testing.T) {
+		t.Parallel()
+		assert.Equal(t, []string{"10.in-addr.arpa"}, addr.ReverseZones(netip.MustParsePrefix("10.0.0.0/8")))
+	})
+	t.Run("ipv6 /128", func(t *testing.T) {
+		t.Parallel()
+		zones := addr.ReverseZones(netip.MustParsePrefix("2001:db8::1/128"))
+		assert.Equal(t, []string{"1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa"}, zones)
+	})
+}
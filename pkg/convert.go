@@ -0,0 +1,52 @@
+package addr
+
+import (
+	"net"
+	"net/netip"
+)
+
+// AddrFromIP converts a net.IP to a netip.Addr. Since net.IP doesn't distinguish IPv4 addresses
+// from IPv4-mapped IPv6 addresses, the result is always unmapped.
+func AddrFromIP(ip net.IP) netip.Addr {
+	a, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return netip.Addr{}
+	}
+	return a.Unmap()
+}
+
+// IPFromAddr converts a netip.Addr to a net.IP.
+func IPFromAddr(a netip.Addr) net.IP {
+	if !a.IsValid() {
+		return nil
+	}
+	return net.IP(a.AsSlice())
+}
+
+// PrefixFromIPNet converts a net.IPNet to a netip.Prefix.
+func PrefixFromIPNet(n *net.IPNet) netip.Prefix {
+	if n == nil {
+		return netip.Prefix{}
+	}
+	ones, bits := n.Mask.Size()
+	ip := n.IP.To16()
+	if bits == IPv4Bits {
+		ip = n.IP.To4()
+	}
+	a, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return netip.Prefix{}
+	}
+	return netip.PrefixFrom(a, ones).Masked()
+}
+
+// IPNetFromPrefix converts a netip.Prefix to a net.IPNet.
+func IPNetFromPrefix(p netip.Prefix) *net.IPNet {
+	if !p.IsValid() {
+		return nil
+	}
+	return &net.IPNet{
+		IP:   IPFromAddr(p.Masked().Addr()),
+		Mask: net.CIDRMask(p.Bits(), p.Addr().BitLen()),
+	}
+}
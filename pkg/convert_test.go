@@ -0,0 +1,77 @@
+package addr_test
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	addr "github.com/thatmattlove/addr/pkg"
+)
+
+func Test_AddrFromIP(t *testing.T) {
+	t.Run("ipv4", func(t *testing.T) {
+		t.Parallel()
+		a := addr.AddrFromIP(net.ParseIP("192.0.2.1"))
+		assert.True(t, a.Is4())
+		assert.Equal(t, "192.0.2.1", a.String())
+	})
+	t.Run("ipv6", func(t *testing.T) {
+		t.Parallel()
+		a := addr.AddrFromIP(net.ParseIP("2001:db8::1"))
+		assert.True(t, a.Is6())
+		assert.Equal(t, "2001:db8::1", a.String())
+	})
+	t.Run("invalid", func(t *testing.T) {
+		t.Parallel()
+		assert.False(t, addr.AddrFromIP(nil).IsValid())
+	})
+}
+
+func Test_IPFromAddr(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		t.Parallel()
+		ip := addr.IPFromAddr(netip.MustParseAddr("192.0.2.1"))
+		assert.True(t, ip.Equal(net.ParseIP("192.0.2.1")))
+	})
+	t.Run("invalid", func(t *testing.T) {
+		t.Parallel()
+		assert.Nil(t, addr.IPFromAddr(netip.Addr{}))
+	})
+}
+
+func Test_PrefixFromIPNet(t *testing.T) {
+	t.Run("ipv4 16-byte form", func(t *testing.T) {
+		t.Parallel()
+		n := &net.IPNet{IP: net.IPv4(192, 0, 2, 0), Mask: net.CIDRMask(24, 32)}
+		assert.Equal(t, netip.MustParsePrefix("192.0.2.0/24"), addr.PrefixFromIPNet(n))
+	})
+	t.Run("ipv4-mapped", func(t *testing.T) {
+		t.Parallel()
+		_, n, _ := net.ParseCIDR("::ffff:0:0/96")
+		assert.Equal(t, addr.PFX_IPv4_MAPPED, addr.PrefixFromIPNet(n))
+	})
+	t.Run("nil", func(t *testing.T) {
+		t.Parallel()
+		assert.False(t, addr.PrefixFromIPNet(nil).IsValid())
+	})
+}
+
+func Test_IPNetFromPrefix(t *testing.T) {
+	t.Run("round trip", func(t *testing.T) {
+		t.Parallel()
+		for _, p := range []string{"192.0.2.0/24", "2001:db8::/32", "::ffff:0:0/96"} {
+			pfx := netip.MustParsePrefix(p)
+			assert.Equal(t, pfx, addr.PrefixFromIPNet(addr.IPNetFromPrefix(pfx)), p)
+		}
+	})
+	t.Run("masks host bits", func(t *testing.T) {
+		t.Parallel()
+		n := addr.IPNetFromPrefix(netip.MustParsePrefix("192.0.2.1/24"))
+		assert.Equal(t, "192.0.2.0/24", n.String())
+	})
+	t.Run("invalid", func(t *testing.T) {
+		t.Parallel()
+		assert.Nil(t, addr.IPNetFromPrefix(netip.Prefix{}))
+	})
+}
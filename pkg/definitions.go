@@ -2,6 +2,7 @@ package addr
 
 import (
 	"net"
+	"net/netip"
 	"time"
 )
 
@@ -13,6 +14,7 @@ const (
 	TXT_LOOPBACK   string = "Loopback"
 	TXT_DEFAULT    string = "Unspecified/Default"
 	TXT_EMBEDDED   string = "Embedded IPv4-IPv6 Translation (RFC6052)"
+	TXT_LOCAL_XLAT string = "Local-Use IPv4-IPv6 Translation (RFC8215)"
 	TXT_ORCHIDv1   string = "ORCHIDv1 (Deprecated)"
 	TXT_ORCHIDv2   string = "ORCHIDv2 (RFC7343)"
 	TXT_DOC        string = "Documentation"
@@ -26,6 +28,14 @@ const (
 	TXT_BENCHMARK  string = "Benchmarking (RFC5180)"
 	TXT_AMT        string = "Automatic Multicast Tunneling (RFC7450)"
 	TXT_CGNAT      string = "Shared Address Space/Carrier-Grade NAT"
+	TXT_MAPPED     string = "IPv4-mapped Address"
+)
+
+const (
+	TXT_ASN_PRIVATE  string = "Private Use ASN"
+	TXT_ASN_DOC      string = "Documentation ASN"
+	TXT_ASN_RESERVED string = "Reserved ASN"
+	TXT_ASN_TRANS    string = "AS_TRANS"
 )
 
 const (
@@ -39,184 +49,131 @@ var (
 )
 
 var (
-	// 169.254.0.0/16
-	LINK_LOCAL_v4 = &net.IPNet{
-		IP:   net.IPv4(169, 254, 0, 0),
-		Mask: net.CIDRMask(16, IPv4Bits),
-	}
-	// fe80::/10
-	LINK_LOCAL_v6 = &net.IPNet{
-		IP:   net.IP{0xfe, 0x80, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
-		Mask: net.CIDRMask(10, net.IPv6len*8),
-	}
-	// 10.0.0.0/8
-	RFC1918_10 = &net.IPNet{
-		IP:   net.IPv4(10, 0, 0, 0),
-		Mask: net.CIDRMask(8, IPv4Bits),
-	}
-	// 172.16.0.0/12
-	RFC1918_172 = &net.IPNet{
-		IP:   net.IPv4(172, 16, 0, 0),
-		Mask: net.CIDRMask(12, IPv4Bits),
-	}
-	// 192.168.0.0/16
-	RFC1918_192 = &net.IPNet{
-		IP:   net.IPv4(192, 168, 0, 0),
-		Mask: net.CIDRMask(16, IPv4Bits),
-	}
-	// 100.64.0.0/10
-	CGNAT = &net.IPNet{
-		IP:   net.IPv4(100, 64, 0, 0),
-		Mask: net.CIDRMask(10, IPv4Bits),
-	}
-	// 192.0.0.0/24
-	RFC6890_192 = &net.IPNet{
-		IP:   net.IPv4(192, 0, 0, 0),
-		Mask: net.CIDRMask(24, IPv4Bits),
-	}
-	// 192.31.196.0/24
-	AS112_v4 = &net.IPNet{
-		IP:   net.IPv4(192, 31, 196, 0),
-		Mask: net.CIDRMask(24, IPv4Bits),
-	}
-	// 192.175.48.0/24
-	AS112_v4_Direct = &net.IPNet{
-		IP:   net.IPv4(192, 175, 48, 0),
-		Mask: net.CIDRMask(24, IPv4Bits),
-	}
-	// 2001:4:112::/48
-	AS112_v6 = &net.IPNet{
-		IP:   net.IP{0x20, 0x01, 0x00, 0x04, 0x01, 0x12, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
-		Mask: net.CIDRMask(48, IPv6Bits),
-	}
-	// 2620:4f:8000::/48
-	AS112_v6_Direct = &net.IPNet{
-		IP:   net.IP{0x26, 0x20, 0x00, 0x4f, 0x80, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
-		Mask: net.CIDRMask(48, IPv6Bits),
-	}
-	// 192.52.193.0/24
-	AMT_v4 = &net.IPNet{
-		IP:   net.IPv4(192, 52, 193, 0),
-		Mask: net.CIDRMask(24, IPv4Bits),
-	}
-	// 2001:3::/32
-	AMT_v6 = &net.IPNet{
-		IP:   net.IP{0x20, 0x01, 0x00, 0x03, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
-		Mask: net.CIDRMask(32, IPv6Bits),
-	}
-	// 192.88.99.0/24
-	SIX_TO_FOUR_v4 = &net.IPNet{
-		IP:   net.IPv4(192, 88, 99, 0),
-		Mask: net.CIDRMask(24, IPv4Bits),
-	}
-	// 2002::/16
-	SIX_TO_FOUR_v6 = &net.IPNet{
-		IP:   net.IP{0x20, 0x02, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
-		Mask: net.CIDRMask(16, IPv6Bits),
-	}
-	// 198.18.0.0/15
-	BENCHMARK_v4 = &net.IPNet{
-		IP:   net.IPv4(198, 18, 0, 0),
-		Mask: net.CIDRMask(15, IPv4Bits),
-	}
-	// 2001:2::/48
-	BENCHMARK_v6 = &net.IPNet{
-		IP:   net.IP{0x20, 0x01, 0x00, 0x02, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
-		Mask: net.CIDRMask(48, IPv6Bits),
-	}
-	// 192.0.2.0/24
-	DOC_1 = &net.IPNet{
-		IP:   net.IPv4(192, 0, 2, 0),
-		Mask: net.CIDRMask(24, IPv4Bits),
-	}
-	// 198.51.100.0/24
-	DOC_2 = &net.IPNet{
-		IP:   net.IPv4(198, 51, 100, 0),
-		Mask: net.CIDRMask(24, IPv4Bits),
-	}
-	// 203.0.113.0/24
-	DOC_3 = &net.IPNet{
-		IP:   net.IPv4(203, 0, 113, 0),
-		Mask: net.CIDRMask(24, IPv4Bits),
-	}
-	// 240.0.0.0/4
-	RESERVED = &net.IPNet{
-		IP:   net.IPv4(240, 0, 0, 0),
-		Mask: net.CIDRMask(4, IPv4Bits),
-	}
-	// 0.0.0.0/8
-	THIS_NETWORK = &net.IPNet{
-		IP:   net.IPv4(0, 0, 0, 0),
-		Mask: net.CIDRMask(8, IPv4Bits),
-	}
-	// 224.0.0.0/4
-	MULTICAST_v4 = &net.IPNet{
-		IP:   net.IPv4(224, 0, 0, 0),
-		Mask: net.CIDRMask(4, IPv4Bits),
-	}
-	// ff00::/8
-	MULTICAST_v6 = &net.IPNet{
-		IP:   net.IP{0xff, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
-		Mask: net.CIDRMask(8, IPv6Bits),
-	}
-	// 127.0.0.0/8
-	LOOPBACK_v4 = &net.IPNet{
-		IP:   net.IPv4(127, 0, 0, 0),
-		Mask: net.CIDRMask(8, IPv4Bits),
-	}
-	// ::1/128
-	LOOPBACK_v6 = &net.IPNet{
-		IP:   net.IP{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1},
-		Mask: net.CIDRMask(IPv6Bits, IPv6Bits),
-	}
-	// 0.0.0.0/0
-	DEFAULT_v4 = &net.IPNet{
-		IP:   net.IPv4zero,
-		Mask: net.CIDRMask(0, IPv4Bits),
-	}
-	// ::/0
-	DEFAULT_v6 = &net.IPNet{
-		IP:   net.IPv6zero,
-		Mask: net.CIDRMask(0, IPv6Bits),
-	}
-	// fc00::/7
-	UNIQUE_LOCAL = &net.IPNet{
-		IP:   net.IP{0xfc, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
-		Mask: net.CIDRMask(7, IPv6Bits),
-	}
-	// 100::/64
-	DISCARD = &net.IPNet{
-		IP:   net.IP{0x01, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
-		Mask: net.CIDRMask(64, IPv6Bits),
-	}
-	// 2001::/32
-	TEREDO = &net.IPNet{
-		IP:   net.IP{0x20, 0x01, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
-		Mask: net.CIDRMask(32, IPv6Bits),
-	}
-	// 2001:10::/28
-	ORCHIDv1 = &net.IPNet{
-		IP:   net.IP{0x20, 0x01, 0x00, 0x10, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
-		Mask: net.CIDRMask(28, IPv6Bits),
-	}
-	// 2001:20::/28
-	ORCHIDv2 = &net.IPNet{
-		IP:   net.IP{0x20, 0x01, 0x00, 0x20, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
-		Mask: net.CIDRMask(28, IPv6Bits),
-	}
-	// 2001:30::/28
-	DETS = &net.IPNet{
-		IP:   net.IP{0x20, 0x01, 0x00, 0x30, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
-		Mask: net.CIDRMask(28, IPv6Bits),
-	}
-	// 2001:db8::/32
-	DOC_v6 = &net.IPNet{
-		IP:   net.IP{0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
-		Mask: net.CIDRMask(32, IPv6Bits),
-	}
-	// 64:ff9b::/96
-	EMBEDDED = &net.IPNet{
-		IP:   net.IP{0x00, 0x64, 0xff, 0x9b, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
-		Mask: net.CIDRMask(96, IPv6Bits),
-	}
+	PFX_LINK_LOCAL_v4   = netip.MustParsePrefix("169.254.0.0/16")
+	PFX_LINK_LOCAL_v6   = netip.MustParsePrefix("fe80::/10")
+	PFX_RFC1918_10      = netip.MustParsePrefix("10.0.0.0/8")
+	PFX_RFC1918_172     = netip.MustParsePrefix("172.16.0.0/12")
+	PFX_RFC1918_192     = netip.MustParsePrefix("192.168.0.0/16")
+	PFX_CGNAT           = netip.MustParsePrefix("100.64.0.0/10")
+	PFX_RFC6890_192     = netip.MustParsePrefix("192.0.0.0/24")
+	PFX_AS112_v4        = netip.MustParsePrefix("192.31.196.0/24")
+	PFX_AS112_v4_Direct = netip.MustParsePrefix("192.175.48.0/24")
+	PFX_AS112_v6        = netip.MustParsePrefix("2001:4:112::/48")
+	PFX_AS112_v6_Direct = netip.MustParsePrefix("2620:4f:8000::/48")
+	PFX_AMT_v4          = netip.MustParsePrefix("192.52.193.0/24")
+	PFX_AMT_v6          = netip.MustParsePrefix("2001:3::/32")
+	PFX_SIX_TO_FOUR_v4  = netip.MustParsePrefix("192.88.99.0/24")
+	PFX_SIX_TO_FOUR_v6  = netip.MustParsePrefix("2002::/16")
+	PFX_BENCHMARK_v4    = netip.MustParsePrefix("198.18.0.0/15")
+	PFX_BENCHMARK_v6    = netip.MustParsePrefix("2001:2::/48")
+	PFX_DOC_1           = netip.MustParsePrefix("192.0.2.0/24")
+	PFX_DOC_2           = netip.MustParsePrefix("198.51.100.0/24")
+	PFX_DOC_3           = netip.MustParsePrefix("203.0.113.0/24")
+	PFX_RESERVED        = netip.MustParsePrefix("240.0.0.0/4")
+	PFX_THIS_NETWORK    = netip.MustParsePrefix("0.0.0.0/8")
+	PFX_MULTICAST_v4    = netip.MustParsePrefix("224.0.0.0/4")
+	PFX_MULTICAST_v6    = netip.MustParsePrefix("ff00::/8")
+	PFX_LOOPBACK_v4     = netip.MustParsePrefix("127.0.0.0/8")
+	PFX_LOOPBACK_v6     = netip.MustParsePrefix("::1/128")
+	PFX_DEFAULT_v4      = netip.MustParsePrefix("0.0.0.0/0")
+	PFX_DEFAULT_v6      = netip.MustParsePrefix("::/0")
+	PFX_UNSPECIFIED_v4  = netip.MustParsePrefix("0.0.0.0/32")
+	PFX_UNSPECIFIED_v6  = netip.MustParsePrefix("::/128")
+	PFX_UNIQUE_LOCAL    = netip.MustParsePrefix("fc00::/7")
+	PFX_DISCARD         = netip.MustParsePrefix("100::/64")
+	PFX_TEREDO          = netip.MustParsePrefix("2001::/32")
+	PFX_ORCHIDv1        = netip.MustParsePrefix("2001:10::/28")
+	PFX_ORCHIDv2        = netip.MustParsePrefix("2001:20::/28")
+	PFX_DETS            = netip.MustParsePrefix("2001:30::/28")
+	PFX_DOC_v6          = netip.MustParsePrefix("2001:db8::/32")
+	PFX_EMBEDDED        = netip.MustParsePrefix("64:ff9b::/96")
+	PFX_LOCAL_XLAT      = netip.MustParsePrefix("64:ff9b:1::/48")
+	PFX_IPv4_MAPPED     = netip.MustParsePrefix("::ffff:0:0/96")
 )
+
+// net.IPNet forms of the above, for callers that haven't moved to net/netip.
+var (
+	LINK_LOCAL_v4   = IPNetFromPrefix(PFX_LINK_LOCAL_v4)
+	LINK_LOCAL_v6   = IPNetFromPrefix(PFX_LINK_LOCAL_v6)
+	RFC1918_10      = IPNetFromPrefix(PFX_RFC1918_10)
+	RFC1918_172     = IPNetFromPrefix(PFX_RFC1918_172)
+	RFC1918_192     = IPNetFromPrefix(PFX_RFC1918_192)
+	CGNAT           = IPNetFromPrefix(PFX_CGNAT)
+	RFC6890_192     = IPNetFromPrefix(PFX_RFC6890_192)
+	AS112_v4        = IPNetFromPrefix(PFX_AS112_v4)
+	AS112_v4_Direct = IPNetFromPrefix(PFX_AS112_v4_Direct)
+	AS112_v6        = IPNetFromPrefix(PFX_AS112_v6)
+	AS112_v6_Direct = IPNetFromPrefix(PFX_AS112_v6_Direct)
+	AMT_v4          = IPNetFromPrefix(PFX_AMT_v4)
+	AMT_v6          = IPNetFromPrefix(PFX_AMT_v6)
+	SIX_TO_FOUR_v4  = IPNetFromPrefix(PFX_SIX_TO_FOUR_v4)
+	SIX_TO_FOUR_v6  = IPNetFromPrefix(PFX_SIX_TO_FOUR_v6)
+	BENCHMARK_v4    = IPNetFromPrefix(PFX_BENCHMARK_v4)
+	BENCHMARK_v6    = IPNetFromPrefix(PFX_BENCHMARK_v6)
+	DOC_1           = IPNetFromPrefix(PFX_DOC_1)
+	DOC_2           = IPNetFromPrefix(PFX_DOC_2)
+	DOC_3           = IPNetFromPrefix(PFX_DOC_3)
+	RESERVED        = IPNetFromPrefix(PFX_RESERVED)
+	THIS_NETWORK    = IPNetFromPrefix(PFX_THIS_NETWORK)
+	MULTICAST_v4    = IPNetFromPrefix(PFX_MULTICAST_v4)
+	MULTICAST_v6    = IPNetFromPrefix(PFX_MULTICAST_v6)
+	LOOPBACK_v4     = IPNetFromPrefix(PFX_LOOPBACK_v4)
+	LOOPBACK_v6     = IPNetFromPrefix(PFX_LOOPBACK_v6)
+	DEFAULT_v4      = IPNetFromPrefix(PFX_DEFAULT_v4)
+	DEFAULT_v6      = IPNetFromPrefix(PFX_DEFAULT_v6)
+	UNSPECIFIED_v4  = IPNetFromPrefix(PFX_UNSPECIFIED_v4)
+	UNSPECIFIED_v6  = IPNetFromPrefix(PFX_UNSPECIFIED_v6)
+	UNIQUE_LOCAL    = IPNetFromPrefix(PFX_UNIQUE_LOCAL)
+	DISCARD         = IPNetFromPrefix(PFX_DISCARD)
+	TEREDO          = IPNetFromPrefix(PFX_TEREDO)
+	ORCHIDv1        = IPNetFromPrefix(PFX_ORCHIDv1)
+	ORCHIDv2        = IPNetFromPrefix(PFX_ORCHIDv2)
+	DETS            = IPNetFromPrefix(PFX_DETS)
+	DOC_v6          = IPNetFromPrefix(PFX_DOC_v6)
+	EMBEDDED        = IPNetFromPrefix(PFX_EMBEDDED)
+	LOCAL_XLAT      = IPNetFromPrefix(PFX_LOCAL_XLAT)
+	IPv4_MAPPED     = IPNetFromPrefix(PFX_IPv4_MAPPED)
+)
+
+// SPECIAL_LABELS are the names displayed for special-purpose blocks in place of the IANA
+// registry's, which are terse & sometimes truncated, e.g. 'IPv4-IPv6 Translat.'.
+var SPECIAL_LABELS = map[netip.Prefix]string{
+	PFX_LINK_LOCAL_v4:   TXT_LINK_LOCAL,
+	PFX_LINK_LOCAL_v6:   TXT_LINK_LOCAL,
+	PFX_RFC1918_10:      TXT_PRIVATE,
+	PFX_RFC1918_172:     TXT_PRIVATE,
+	PFX_RFC1918_192:     TXT_PRIVATE,
+	PFX_CGNAT:           TXT_CGNAT,
+	PFX_RFC6890_192:     TXT_PRIVATE,
+	PFX_AS112_v4:        TXT_AS112,
+	PFX_AS112_v4_Direct: TXT_AS112,
+	PFX_AS112_v6:        TXT_AS112,
+	PFX_AS112_v6_Direct: TXT_AS112,
+	PFX_AMT_v4:          TXT_AMT,
+	PFX_AMT_v6:          TXT_AMT,
+	PFX_SIX_TO_FOUR_v4:  TXT_6to4,
+	PFX_SIX_TO_FOUR_v6:  TXT_6to4,
+	PFX_BENCHMARK_v4:    TXT_BENCHMARK,
+	PFX_BENCHMARK_v6:    TXT_BENCHMARK,
+	PFX_DOC_1:           TXT_DOC,
+	PFX_DOC_2:           TXT_DOC,
+	PFX_DOC_3:           TXT_DOC,
+	PFX_DOC_v6:          TXT_DOC,
+	PFX_RESERVED:        TXT_RESERVED,
+	PFX_THIS_NETWORK:    TXT_THIS,
+	PFX_MULTICAST_v4:    TXT_MULTICAST,
+	PFX_MULTICAST_v6:    TXT_MULTICAST,
+	PFX_LOOPBACK_v4:     TXT_LOOPBACK,
+	PFX_LOOPBACK_v6:     TXT_LOOPBACK,
+	PFX_UNSPECIFIED_v4:  TXT_DEFAULT,
+	PFX_UNSPECIFIED_v6:  TXT_DEFAULT,
+	PFX_UNIQUE_LOCAL:    TXT_ULA,
+	PFX_DISCARD:         TXT_DISCARD,
+	PFX_TEREDO:          TXT_TEREDO,
+	PFX_ORCHIDv1:        TXT_ORCHIDv1,
+	PFX_ORCHIDv2:        TXT_ORCHIDv2,
+	PFX_DETS:            TXT_DETS,
+	PFX_EMBEDDED:        TXT_EMBEDDED,
+	PFX_LOCAL_XLAT:      TXT_LOCAL_XLAT,
+	PFX_IPv4_MAPPED:     TXT_MAPPED,
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"net"
+	"net/netip"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -63,6 +64,7 @@ func Test_Definitions(t *testing.T) {
 			es := hex.EncodeToString(e)
 			ps := hex.EncodeToString(p)
 			assert.Equal(t, expected, result, "expected bytes: %s, got %s", es, ps)
+			assert.Equal(t, netip.MustParsePrefix(expected), addr.PrefixFromIPNet(pfx))
 		})
 	}
 	for pfx, expected := range cases {
@@ -0,0 +1,551 @@
+package addr
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/biter777/countries"
+	goasn "github.com/thatmattlove/go-asn"
+)
+
+// DELEGATED_FILE is the local index written by ImportDelegated, in the delegated-extended format.
+const DELEGATED_FILE string = "delegated-extended.txt"
+
+const (
+	DELEGATED_ASN  string = "asn"
+	DELEGATED_IPV4 string = "ipv4"
+	DELEGATED_IPV6 string = "ipv6"
+)
+
+const (
+	STATUS_ALLOCATED string = "allocated"
+	STATUS_ASSIGNED  string = "assigned"
+	STATUS_AVAILABLE string = "available"
+	STATUS_RESERVED  string = "reserved"
+)
+
+// DELEGATED_URLS are the latest delegated-extended statistics of each RIR.
+var DELEGATED_URLS = []string{
+	"https://ftp.afrinic.net/stats/afrinic/delegated-afrinic-extended-latest",
+	"https://ftp.apnic.net/stats/apnic/delegated-apnic-extended-latest",
+	"https://ftp.arin.net/pub/stats/arin/delegated-arin-extended-latest",
+	"https://ftp.lacnic.net/pub/stats/lacnic/delegated-lacnic-extended-latest",
+	"https://ftp.ripe.net/pub/stats/ripencc/delegated-ripencc-extended-latest",
+}
+
+var ErrEmptyDelegated = errors.New("no delegations found")
+
+// Delegation is a single record from an RIR's delegated or delegated-extended statistics.
+type Delegation struct {
+	// Registry is the RIR, named as bgp.tools names it, e.g. 'RIPE' or 'ARIN'.
+	Registry string
+	Country  countries.CountryCode
+	// Type is one of DELEGATED_ASN, DELEGATED_IPV4 or DELEGATED_IPV6.
+	Type string
+	// Range is the delegated addresses of ipv4 & ipv6 delegations.
+	Range IPRange
+	// Low & High are the delegated ASNs of asn delegations.
+	Low  goasn.ASN
+	High goasn.ASN
+	// Date is the allocation or assignment date, which is zero if the registry doesn't have one.
+	Date time.Time
+	// Status is one of STATUS_ALLOCATED, STATUS_ASSIGNED, STATUS_AVAILABLE or STATUS_RESERVED.
+	Status string
+	// OpaqueID identifies the holder of a delegation. It's the same for all of the holder's
+	// delegations from one RIR, but means nothing else. Empty in non-extended statistics.
+	OpaqueID string
+}
+
+// Start returns the first delegated address or ASN.
+func (d *Delegation) Start() string {
+	if d.Type == DELEGATED_ASN {
+		return d.Low.ASPlain()
+	}
+	return d.Range.Start.String()
+}
+
+// Value returns the delegation's size as the statistics format counts it: the number of ASNs or
+// IPv4 addresses, or the IPv6 prefix length.
+func (d *Delegation) Value() string {
+	switch d.Type {
+	case DELEGATED_ASN:
+		return fmt.Sprint(uint64(d.High.Uint32()) - uint64(d.Low.Uint32()) + 1)
+	case DELEGATED_IPV6:
+		return fmt.Sprint(d.Range.Prefixes()[0].Bits())
+	default:
+		return fmt.Sprint(uint64(addr4(d.Range.End)) - uint64(addr4(d.Range.Start)) + 1)
+	}
+}
+
+// DelegatedTable looks up the delegations containing addresses & ASNs.
+type DelegatedTable struct {
+	v4   delegatedRanges
+	v6   delegatedRanges
+	asns delegatedRanges
+	// load, if set, fills the table on first use. See OpenDelegatedTable.
+	load    func() (*DelegatedTable, error)
+	once    sync.Once
+	loadErr error
+}
+
+// delegatedRanges are delegations ordered by their first address or ASN. Delegations from
+// different registries may overlap, so maxEnd[i] is the largest last address or ASN of the first
+// i+1 delegations, which bounds how far back a lookup has to look.
+type delegatedRanges struct {
+	entries []*Delegation
+	start   []netip.Addr
+	end     []netip.Addr
+	maxEnd  []netip.Addr
+}
+
+func NewDelegatedTable(entries ...[]*Delegation) *DelegatedTable {
+	v4, v6, asns := []*Delegation{}, []*Delegation{}, []*Delegation{}
+	for _, e := range entries {
+		for _, d := range e {
+			switch d.Type {
+			case DELEGATED_ASN:
+				asns = append(asns, d)
+			case DELEGATED_IPV4:
+				v4 = append(v4, d)
+			case DELEGATED_IPV6:
+				v6 = append(v6, d)
+			}
+		}
+	}
+	return &DelegatedTable{
+		v4:   newDelegatedRanges(v4),
+		v6:   newDelegatedRanges(v6),
+		asns: newDelegatedRanges(asns),
+	}
+}
+
+// newDelegatedRanges orders delegations for lookups. ASNs are stored as IPv4 addresses, so that
+// both can be compared the same way.
+func newDelegatedRanges(entries []*Delegation) delegatedRanges {
+	bounds := func(d *Delegation) (netip.Addr, netip.Addr) {
+		if d.Type == DELEGATED_ASN {
+			return asnAddr(d.Low), asnAddr(d.High)
+		}
+		return d.Range.Start, d.Range.End
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		si, _ := bounds(entries[i])
+		sj, _ := bounds(entries[j])
+		return si.Less(sj)
+	})
+	r := delegatedRanges{
+		entries: entries,
+		start:   make([]netip.Addr, len(entries)),
+		end:     make([]netip.Addr, len(entries)),
+		maxEnd:  make([]netip.Addr, len(entries)),
+	}
+	for i, d := range entries {
+		r.start[i], r.end[i] = bounds(d)
+		r.maxEnd[i] = r.end[i]
+		if i > 0 && r.end[i].Less(r.maxEnd[i-1]) {
+			r.maxEnd[i] = r.maxEnd[i-1]
+		}
+	}
+	return r
+}
+
+// lookup returns the smallest delegation containing a.
+func (r delegatedRanges) lookup(a netip.Addr) *Delegation {
+	i := sort.Search(len(r.start), func(i int) bool { return a.Less(r.start[i]) }) - 1
+	var match *Delegation
+	var size netip.Addr
+	for ; i >= 0 && !r.maxEnd[i].Less(a); i-- {
+		if r.end[i].Less(a) {
+			continue
+		}
+		if match == nil || rangeSize(r.start[i], r.end[i]).Less(size) {
+			match, size = r.entries[i], rangeSize(r.start[i], r.end[i])
+		}
+	}
+	return match
+}
+
+// ready loads a table opened with OpenDelegatedTable, if it hasn't been already.
+func (t *DelegatedTable) ready() {
+	if t.load == nil {
+		return
+	}
+	t.once.Do(func() {
+		loaded, err := t.load()
+		if err != nil {
+			t.loadErr = err
+			return
+		}
+		t.v4, t.v6, t.asns = loaded.v4, loaded.v6, loaded.asns
+	})
+}
+
+// Err returns the error from loading a table opened with OpenDelegatedTable, or nil if it loaded.
+// A table that failed to load is empty.
+func (t *DelegatedTable) Err() error {
+	t.ready()
+	return t.loadErr
+}
+
+// LookupAddr returns the smallest delegation containing a, or nil if there isn't one.
+func (t *DelegatedTable) LookupAddr(a netip.Addr) *Delegation {
+	t.ready()
+	a = a.Unmap().WithZone("")
+	if a.Is4() {
+		return t.v4.lookup(a)
+	}
+	return t.v6.lookup(a)
+}
+
+// LookupASN returns the smallest delegation containing asn, or nil if there isn't one.
+func (t *DelegatedTable) LookupASN(asn goasn.ASN) *Delegation {
+	t.ready()
+	return t.asns.lookup(asnAddr(asn))
+}
+
+// Entries returns every delegation, ASNs first, then IPv4 & IPv6.
+func (t *DelegatedTable) Entries() []*Delegation {
+	t.ready()
+	entries := make([]*Delegation, 0, t.Len())
+	entries = append(entries, t.asns.entries...)
+	entries = append(entries, t.v4.entries...)
+	return append(entries, t.v6.entries...)
+}
+
+func (t *DelegatedTable) Len() int {
+	t.ready()
+	return len(t.asns.entries) + len(t.v4.entries) + len(t.v6.entries)
+}
+
+// ParseDelegated parses an RIR's delegated or delegated-extended statistics, e.g.
+// delegated-ripencc-extended-latest. The version line, summary lines & comments are skipped.
+func ParseDelegated(r io.Reader) ([]*Delegation, error) {
+	scanner := bufio.NewScanner(r)
+	entries := []*Delegation{}
+	p := &delegatedParser{countries: map[string]countries.CountryCode{}, dates: map[string]time.Time{}}
+	n := 0
+	for scanner.Scan() {
+		n++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "|")
+		if fields[0] == "" {
+			return nil, fmt.Errorf("line %d: missing registry", n)
+		}
+		// The version line starts with the format version, e.g. '2|ripencc|20231018|...'.
+		if fields[0][0] >= '0' && fields[0][0] <= '9' {
+			continue
+		}
+		if len(fields) < 7 {
+			if len(fields) == 6 && fields[5] == "summary" {
+				continue
+			}
+			return nil, fmt.Errorf("line %d: expected at least 7 fields, got %d", n, len(fields))
+		}
+		d, err := p.parse(fields)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		if d != nil {
+			entries = append(entries, d)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// delegatedParser caches the countries & dates of parsed records, which repeat often and are slow
+// to parse.
+type delegatedParser struct {
+	countries map[string]countries.CountryCode
+	dates     map[string]time.Time
+}
+
+// parse parses the fields of a record: registry, country, type, start, value, date, status &
+// (in delegated-extended statistics) opaque ID. Records of unknown types are nil.
+func (p *delegatedParser) parse(fields []string) (*Delegation, error) {
+	d := &Delegation{
+		Registry: registryName(fields[0]),
+		Type:     strings.ToLower(fields[2]),
+		Status:   strings.ToLower(fields[6]),
+	}
+	cc, ok := p.countries[fields[1]]
+	if !ok {
+		cc = countries.Unknown
+		// The IANA pool & unassigned space have no country, marked 'ZZ' or left empty.
+		if code := strings.ToUpper(fields[1]); code != "" && code != "ZZ" {
+			cc = countries.ByName(code)
+		}
+		p.countries[fields[1]] = cc
+	}
+	d.Country = cc
+	if len(fields) > 7 {
+		d.OpaqueID = fields[7]
+	}
+	date, ok := p.dates[fields[5]]
+	if !ok {
+		date, _ = time.Parse("20060102", fields[5])
+		p.dates[fields[5]] = date
+	}
+	d.Date = date
+	value, err := strconv.ParseUint(fields[4], 10, 64)
+	if err != nil || value == 0 {
+		return nil, fmt.Errorf("invalid value '%s'", fields[4])
+	}
+	switch d.Type {
+	case DELEGATED_ASN:
+		low, err := strconv.ParseUint(fields[3], 10, 32)
+		if err != nil || low+value-1 > 0xFFFFFFFF {
+			return nil, fmt.Errorf("invalid ASN range '%s' (%d)", fields[3], value)
+		}
+		d.Low, d.High = goasn.FromUint32(uint32(low)), goasn.FromUint32(uint32(low+value-1))
+	case DELEGATED_IPV4:
+		start, err := netip.ParseAddr(fields[3])
+		if err != nil || !start.Is4() || uint64(addr4(start))+value-1 > 0xFFFFFFFF {
+			return nil, fmt.Errorf("invalid IPv4 range '%s' (%d)", fields[3], value)
+		}
+		d.Range = IPRange{Start: start, End: addrFrom4(addr4(start) + uint32(value-1))}
+	case DELEGATED_IPV6:
+		start, err := netip.ParseAddr(fields[3])
+		if err != nil || !start.Is6() {
+			return nil, fmt.Errorf("invalid IPv6 address '%s'", fields[3])
+		}
+		p, err := start.Prefix(int(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid IPv6 prefix length '%s'", fields[4])
+		}
+		d.Range = IPRange{Start: p.Addr(), End: LastAddr(p)}
+	default:
+		return nil, nil
+	}
+	return d, nil
+}
+
+// WriteDelegated writes delegations in the delegated-extended format, which ParseDelegated reads.
+func WriteDelegated(w io.Writer, entries []*Delegation) error {
+	bw := bufio.NewWriter(w)
+	for _, d := range entries {
+		cc := "ZZ"
+		if d.Country != countries.Unknown {
+			cc = d.Country.Alpha2()
+		}
+		date := ""
+		if !d.Date.IsZero() {
+			date = d.Date.Format("20060102")
+		}
+		_, err := fmt.Fprintf(bw, "%s|%s|%s|%s|%s|%s|%s|%s\n",
+			registryID(d.Registry), cc, d.Type, d.Start(), d.Value(), date, d.Status, d.OpaqueID,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// ImportDelegated reads delegated statistics from each source, a file path or an http(s) URL, and
+// writes them to dest as a single index. The number of delegations read from each source is
+// returned.
+func ImportDelegated(dest string, sources ...string) ([]int, error) {
+	counts := make([]int, 0, len(sources))
+	all := []*Delegation{}
+	for _, src := range sources {
+		entries, err := readDelegatedSource(src)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", src, err)
+		}
+		if len(entries) == 0 {
+			return nil, fmt.Errorf("%s: %w", src, ErrEmptyDelegated)
+		}
+		counts = append(counts, len(entries))
+		all = append(all, entries...)
+	}
+	err := os.MkdirAll(filepath.Dir(dest), 0o755)
+	if err != nil {
+		return nil, err
+	}
+	tmp := dest + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return nil, err
+	}
+	err = WriteDelegated(f, NewDelegatedTable(all).Entries())
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return nil, err
+	}
+	return counts, os.Rename(tmp, dest)
+}
+
+// OpenDelegatedTable returns a table that's loaded from path on first use, since loading every
+// RIR's delegations takes a moment and many commands never need them.
+func OpenDelegatedTable(path string) *DelegatedTable {
+	return &DelegatedTable{load: func() (*DelegatedTable, error) {
+		return LoadDelegatedTable(path)
+	}}
+}
+
+func LoadDelegatedTable(path string) (*DelegatedTable, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	entries, err := ParseDelegated(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return NewDelegatedTable(entries), nil
+}
+
+func readDelegatedSource(src string) ([]*Delegation, error) {
+	if !strings.HasPrefix(src, "http://") && !strings.HasPrefix(src, "https://") {
+		f, err := os.Open(src)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return ParseDelegated(f)
+	}
+	res, err := http.Get(src)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download '%s', status %s", src, res.Status)
+	}
+	return ParseDelegated(res.Body)
+}
+
+// delegate adds a delegation to a response, filling in the registry, country & allocation date if
+// they're unknown.
+func (r *Response) delegate(d *Delegation) {
+	if d == nil {
+		return
+	}
+	r.Delegation = d
+	if r.Registry == "" || r.Registry == REGISTRY_MMDB {
+		r.Registry = d.Registry
+	}
+	if r.Country == countries.Unknown {
+		r.Country = d.Country
+	}
+	if r.Allocated.IsZero() {
+		r.Allocated = d.Date
+	}
+}
+
+// offlineResponse answers an IP lookup from opts.MMDB & opts.Delegated.
+func offlineResponse(ipv *IPValidator, opts QueryOptions) (*Response, error) {
+	res, err := opts.mmdb().Response(ipv)
+	if err == nil {
+		return res, nil
+	}
+	d := opts.delegated().LookupAddr(ipv.Addr)
+	if d == nil {
+		return nil, fmt.Errorf("no MMDB or delegation data for %s", ipv.Addr)
+	}
+	res = &Response{
+		ASN:     goasn.FromUint32(0),
+		IP:      &ipv.IP,
+		Addr:    ipv.Addr,
+		Network: ipv.Network,
+		Country: countries.Unknown,
+	}
+	if !res.Network.IsValid() {
+		// IPv4 delegations aren't always a single prefix, so use the one containing the address.
+		for _, p := range d.Range.Prefixes() {
+			if p.Contains(ipv.Addr) {
+				res.Network = p
+				break
+			}
+		}
+	}
+	if res.Network.IsValid() {
+		res.Prefix = IPNetFromPrefix(res.Network)
+	}
+	return res, nil
+}
+
+// offlineASNResponse answers an ASN lookup from opts.Delegated & opts.PeeringDB.
+func offlineASNResponse(asn goasn.ASN, opts QueryOptions) (*Response, error) {
+	d := opts.delegated().LookupASN(asn)
+	n := opts.peeringDB().Network(asn.Uint32())
+	if d == nil && n == nil {
+		return nil, ErrOfflineASN
+	}
+	res := &Response{ASN: asn, Country: countries.Unknown}
+	res.delegate(d)
+	res.network(n)
+	return res, nil
+}
+
+// registryName returns the name bgp.tools uses for an RIR, e.g. 'RIPE' for 'ripencc'.
+func registryName(rir string) string {
+	rir = strings.ToLower(strings.TrimSpace(rir))
+	if rir == "ripencc" {
+		return "RIPE"
+	}
+	return strings.ToUpper(rir)
+}
+
+// registryID returns the name an RIR uses for itself in its statistics.
+func registryID(name string) string {
+	if name == "RIPE" {
+		return "ripencc"
+	}
+	return strings.ToLower(name)
+}
+
+func addr4(a netip.Addr) uint32 {
+	b := a.As4()
+	return binary.BigEndian.Uint32(b[:])
+}
+
+func addrFrom4(n uint32) netip.Addr {
+	b := [4]byte{}
+	binary.BigEndian.PutUint32(b[:], n)
+	return netip.AddrFrom4(b)
+}
+
+func asnAddr(asn goasn.ASN) netip.Addr {
+	return addrFrom4(asn.Uint32())
+}
+
+// rangeSize returns the number of addresses in a range less one, as an address so that sizes can
+// be compared.
+func rangeSize(start, end netip.Addr) netip.Addr {
+	s, e := start.As16(), end.As16()
+	out := [16]byte{}
+	borrow := 0
+	for i := 15; i >= 0; i-- {
+		v := int(e[i]) - int(s[i]) - borrow
+		borrow = 0
+		if v < 0 {
+			v += 256
+			borrow = 1
+		}
+		out[i] = byte(v)
+	}
+	return netip.AddrFrom16(out)
+}
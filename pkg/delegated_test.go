@@ -0,0 +1,271 @@
+package addr_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/biter777/countries"
+	"github.com/stretchr/testify/assert"
+	addr "github.com/thatmattlove/addr/pkg"
+	goasn "github.com/thatmattlove/go-asn"
+)
+
+const DELEGATED_RIPE string = `2.3|ripencc|1697580000|150|19830705|20231017|+0100
+ripencc|*|asn|*|3|summary
+ripencc|*|ipv4|*|3|summary
+ripencc|*|ipv6|*|2|summary
+ripencc|NL|asn|3333|1|19930901|allocated|d1f6cd5b-7c42-4e47-a2b1-2c83a0b4b4f1
+ripencc|EU|asn|7|2|20020923|assigned|a5e9b3c0-7c42-4e47-a2b1-2c83a0b4b4f1
+ripencc||asn|9|1||available|
+ripencc|NL|ipv4|193.0.0.0|2048|19930901|allocated|d1f6cd5b-7c42-4e47-a2b1-2c83a0b4b4f1
+ripencc|NL|ipv4|193.0.8.0|768|20110101|assigned|d1f6cd5b-7c42-4e47-a2b1-2c83a0b4b4f1
+ripencc|ZZ|ipv4|193.0.20.0|256||reserved|
+ripencc|NL|ipv6|2001:67c:2e8::|48|20021203|assigned|d1f6cd5b-7c42-4e47-a2b1-2c83a0b4b4f1
+ripencc|DE|ipv6|2a00:1000::|29|20081120|allocated|b8a6f0d4-7c42-4e47-a2b1-2c83a0b4b4f1
+`
+
+const DELEGATED_ARIN string = `2|arin|1697580000|2|19700101|20231017|-0400
+# comments are ignored
+arin|US|ipv4|8.8.8.0|256|20140303|allocated|fd3ff8bd5b0f1d8b1d9d2a4c
+arin|US|asn|15169|1|20000330|assigned|fd3ff8bd5b0f1d8b1d9d2a4c
+`
+
+func Test_ParseDelegated(t *testing.T) {
+	t.Run("extended", func(t *testing.T) {
+		t.Parallel()
+		entries, err := addr.ParseDelegated(strings.NewReader(DELEGATED_RIPE))
+		assert.NoError(t, err)
+		assert.Len(t, entries, 8)
+		asn := entries[0]
+		assert.Equal(t, "RIPE", asn.Registry)
+		assert.Equal(t, countries.NL, asn.Country)
+		assert.Equal(t, addr.DELEGATED_ASN, asn.Type)
+		assert.Equal(t, uint32(3333), asn.Low.Uint32())
+		assert.Equal(t, uint32(3333), asn.High.Uint32())
+		assert.Equal(t, time.Date(1993, 9, 1, 0, 0, 0, 0, time.UTC), asn.Date)
+		assert.Equal(t, addr.STATUS_ALLOCATED, asn.Status)
+		assert.Equal(t, "d1f6cd5b-7c42-4e47-a2b1-2c83a0b4b4f1", asn.OpaqueID)
+		assert.Equal(t, uint32(8), entries[1].High.Uint32())
+		assert.True(t, entries[2].Date.IsZero())
+		assert.Equal(t, countries.Unknown, entries[2].Country)
+		assert.Equal(t, "193.0.7.255", entries[3].Range.End.String())
+		assert.Equal(t, "193.0.10.255", entries[4].Range.End.String())
+		assert.Equal(t, countries.Unknown, entries[5].Country)
+		assert.Equal(t, "2001:67c:2e8:ffff:ffff:ffff:ffff:ffff", entries[6].Range.End.String())
+	})
+	t.Run("not extended", func(t *testing.T) {
+		t.Parallel()
+		entries, err := addr.ParseDelegated(strings.NewReader("2|apnic|20231017|1|19850701|20231016|+1000\napnic|AU|ipv4|1.1.1.0|256|20110811|assigned\n"))
+		assert.NoError(t, err)
+		assert.Len(t, entries, 1)
+		assert.Equal(t, "APNIC", entries[0].Registry)
+		assert.Equal(t, "", entries[0].OpaqueID)
+	})
+	t.Run("invalid", func(t *testing.T) {
+		t.Parallel()
+		cases := []string{
+			"ripencc|NL|ipv4|193.0.0.0",
+			"ripencc|NL|ipv4|nope|256|20110101|assigned",
+			"ripencc|NL|ipv4|255.255.255.0|512|20110101|assigned",
+			"ripencc|NL|ipv6|2001:db8::|129|20110101|assigned",
+			"ripencc|NL|asn|nope|1|20110101|assigned",
+			"ripencc|NL|asn|1|0|20110101|assigned",
+			"|NL|ipv4|193.0.0.0|256|20110101|assigned",
+			"|",
+		}
+		for _, c := range cases {
+			_, err := addr.ParseDelegated(strings.NewReader(c))
+			assert.Error(t, err, c)
+		}
+	})
+}
+
+func loadTestDelegated(t *testing.T) *addr.DelegatedTable {
+	ripe, err := addr.ParseDelegated(strings.NewReader(DELEGATED_RIPE))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	arin, err := addr.ParseDelegated(strings.NewReader(DELEGATED_ARIN))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return addr.NewDelegatedTable(ripe, arin)
+}
+
+func TestDelegatedTable_Lookup(t *testing.T) {
+	table := loadTestDelegated(t)
+	assert.Equal(t, 10, table.Len())
+	type casesT struct {
+		q      string
+		start  string
+		status string
+	}
+	cases := []casesT{
+		{"193.0.0.1", "193.0.0.0", addr.STATUS_ALLOCATED},
+		{"193.0.7.255", "193.0.0.0", addr.STATUS_ALLOCATED},
+		// 193.0.8.0/22 overlaps the end of 193.0.0.0 (2048), and is more specific.
+		{"193.0.8.1", "193.0.8.0", addr.STATUS_ASSIGNED},
+		{"193.0.10.255", "193.0.8.0", addr.STATUS_ASSIGNED},
+		{"193.0.11.0", "", ""},
+		{"193.0.20.20", "193.0.20.0", addr.STATUS_RESERVED},
+		{"8.8.8.8", "8.8.8.0", addr.STATUS_ALLOCATED},
+		{"::ffff:8.8.8.8", "8.8.8.0", addr.STATUS_ALLOCATED},
+		{"2001:67c:2e8:22::c100:68b", "2001:67c:2e8::", addr.STATUS_ASSIGNED},
+		{"2a00:1007::1", "2a00:1000::", addr.STATUS_ALLOCATED},
+		{"2a00:1008::1", "", ""},
+		{"1.1.1.1", "", ""},
+		{"AS3333", "3333", addr.STATUS_ALLOCATED},
+		{"AS8", "7", addr.STATUS_ASSIGNED},
+		{"AS9", "9", addr.STATUS_AVAILABLE},
+		{"AS15169", "15169", addr.STATUS_ASSIGNED},
+		{"AS10", "", ""},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.q, func(t *testing.T) {
+			t.Parallel()
+			var d *addr.Delegation
+			if strings.HasPrefix(c.q, "AS") {
+				asn, err := goasn.Parse(strings.TrimPrefix(c.q, "AS"))
+				assert.NoError(t, err)
+				d = table.LookupASN(asn)
+			} else {
+				d = table.LookupAddr(netip.MustParseAddr(c.q))
+			}
+			if c.start == "" {
+				assert.Nil(t, d)
+				return
+			}
+			if assert.NotNil(t, d) {
+				assert.Equal(t, c.start, d.Start())
+				assert.Equal(t, c.status, d.Status)
+			}
+		})
+	}
+}
+
+func Test_WriteDelegated(t *testing.T) {
+	t.Parallel()
+	table := loadTestDelegated(t)
+	b := &bytes.Buffer{}
+	err := addr.WriteDelegated(b, table.Entries())
+	assert.NoError(t, err)
+	assert.Contains(t, b.String(), "ripencc|NL|ipv4|193.0.0.0|2048|19930901|allocated|d1f6cd5b-7c42-4e47-a2b1-2c83a0b4b4f1\n")
+	assert.Contains(t, b.String(), "ripencc|ZZ|asn|9|1||available|\n")
+	assert.Contains(t, b.String(), "ripencc|DE|ipv6|2a00:1000::|29|20081120|allocated|")
+	entries, err := addr.ParseDelegated(b)
+	assert.NoError(t, err)
+	assert.Equal(t, table.Entries(), entries)
+}
+
+func Test_ImportDelegated(t *testing.T) {
+	t.Parallel()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, DELEGATED_ARIN)
+	}))
+	defer srv.Close()
+	dir := t.TempDir()
+	ripe := filepath.Join(dir, "delegated-ripencc-extended-latest")
+	err := os.WriteFile(ripe, []byte(DELEGATED_RIPE), 0o644)
+	assert.NoError(t, err)
+	dest := filepath.Join(dir, "index", addr.DELEGATED_FILE)
+
+	counts, err := addr.ImportDelegated(dest, ripe, srv.URL+"/delegated-arin-extended-latest")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{8, 2}, counts)
+	table, err := addr.LoadDelegatedTable(dest)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, table.Len())
+	assert.Equal(t, "ARIN", table.LookupAddr(netip.MustParseAddr("8.8.8.8")).Registry)
+
+	_, err = addr.ImportDelegated(dest, srv.URL+"/missing")
+	assert.ErrorContains(t, err, "404")
+	empty := filepath.Join(dir, "empty")
+	err = os.WriteFile(empty, []byte("2|arin|1697580000|0|19700101|20231017|-0400\n"), 0o644)
+	assert.NoError(t, err)
+	_, err = addr.ImportDelegated(dest, empty)
+	assert.ErrorIs(t, err, addr.ErrEmptyDelegated)
+	table, err = addr.LoadDelegatedTable(dest)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, table.Len(), "failed imports leave the index as it was")
+
+	lazy := addr.OpenDelegatedTable(dest)
+	assert.Equal(t, "RIPE", lazy.LookupASN(goasn.FromUint32(3333)).Registry)
+	assert.NoError(t, lazy.Err())
+	lazy = addr.OpenDelegatedTable(filepath.Join(dir, "missing"))
+	assert.Nil(t, lazy.LookupASN(goasn.FromUint32(3333)))
+	assert.Error(t, lazy.Err())
+}
+
+func TestResult_Delegation(t *testing.T) {
+	t.Parallel()
+	table := loadTestDelegated(t)
+	for _, d := range []*addr.Delegation{
+		table.LookupAddr(netip.MustParseAddr("193.0.8.1")),
+		table.LookupASN(goasn.FromUint32(7)),
+	} {
+		r := addr.NewIPResult("q", &addr.Response{ASN: goasn.FromUint32(0), Delegation: d}, nil)
+		assert.Equal(t, d.Status, r.Column("status"))
+		assert.Equal(t, d.OpaqueID, r.Column("holder_id"))
+		b, err := json.Marshal(r)
+		assert.NoError(t, err)
+		out := &addr.Result{}
+		err = json.Unmarshal(b, out)
+		assert.NoError(t, err)
+		assert.Equal(t, d, out.Delegation)
+	}
+}
+
+func Test_QueryWith_Delegated(t *testing.T) {
+	t.Parallel()
+	opts := addr.QueryOptions{Offline: true, Delegated: loadTestDelegated(t)}
+	r, err := addr.QueryIPPrefixWith(nil, "193.0.8.1", opts)
+	assert.NoError(t, err)
+	assert.Equal(t, "RIPE", r.Registry)
+	assert.Equal(t, countries.NL, r.Country)
+	assert.Equal(t, "2011-01-01", r.Allocated.Format(time.DateOnly))
+	assert.Equal(t, "193.0.8.0/23", r.Network.String())
+	assert.Equal(t, addr.STATUS_ASSIGNED, r.Delegation.Status)
+
+	r, err = addr.QueryASNWith(nil, "AS15169", opts)
+	assert.NoError(t, err)
+	assert.Equal(t, "ARIN", r.Registry)
+	assert.Equal(t, countries.US, r.Country)
+	assert.Equal(t, "fd3ff8bd5b0f1d8b1d9d2a4c", r.Delegation.OpaqueID)
+
+	_, err = addr.QueryIPPrefixWith(nil, "1.1.1.1", opts)
+	assert.ErrorContains(t, err, "no MMDB or delegation data")
+	_, err = addr.QueryASNWith(nil, "AS10", opts)
+	assert.ErrorIs(t, err, addr.ErrOfflineASN)
+
+	opts.Delegated = addr.OpenDelegatedTable(filepath.Join(t.TempDir(), "missing"))
+	_, err = addr.QueryIPPrefixWith(nil, "193.0.8.1", opts)
+	assert.ErrorIs(t, err, os.ErrNotExist, "load errors aren't hidden")
+	_, err = addr.QueryASNWith(nil, "AS15169", opts)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	opts.MMDB = openTestMMDB(t)
+	_, err = addr.QueryIPPrefixWith(nil, "1.1.1.1", opts)
+	assert.ErrorIs(t, err, os.ErrNotExist, "load errors aren't hidden by MMDB answers")
+	_, err = addr.QueryIPPrefixWith(nil, "10.0.0.1", opts)
+	assert.NoError(t, err, "special-purpose addresses don't need delegations")
+
+	online := addr.QueryOptions{Delegated: opts.Delegated}
+	_, err = addr.QueryIPPrefixWith(nil, "193.0.8.1", online)
+	assert.ErrorContains(t, err, "failed to load delegations")
+	_, err = addr.QueryASNWith(nil, "AS15169", online)
+	assert.ErrorContains(t, err, "failed to load delegations")
+}
@@ -3,8 +3,10 @@ package addr
 import (
 	"fmt"
 	"net"
+	"time"
 
 	"github.com/miekg/dns"
+	"github.com/thatmattlove/addr/pkg/metrics"
 )
 
 var DNS_SERVER string = "1.1.1.1:53"
@@ -20,7 +22,12 @@ func NewErrLookupAssertionFailure(rr dns.RR) ErrLookupAssertionFailure {
 	return fmt.Errorf("failed to assert response for target '%s' as %s record", rr.Header().Name, dns.Type(rr.Header().Rrtype).String())
 }
 
-func DNSLookup[T dns.RR](target string, lookupType uint16) ([]T, error) {
+func DNSLookup[T dns.RR](target string, lookupType uint16) (answers []T, err error) {
+	var received int
+	start := time.Now()
+	defer func() {
+		metrics.HOOK.Query(metrics.PROVIDER_DNS, dns.TypeToString[lookupType], err, time.Since(start), received)
+	}()
 	client := new(dns.Client)
 	msg := new(dns.Msg)
 	if target[len(target)-1] != '.' {
@@ -32,10 +39,11 @@ func DNSLookup[T dns.RR](target string, lookupType uint16) ([]T, error) {
 	if err != nil {
 		return nil, err
 	}
+	received = res.Len()
 	if res.Rcode != dns.RcodeSuccess {
 		return nil, NewErrLookupFailure(target, res.Rcode)
 	}
-	answers := []T{}
+	answers = []T{}
 	for _, a := range res.Answer {
 		rec, ok := a.(T)
 		if !ok {
@@ -80,7 +88,7 @@ func DNSReverseLookup(ip *net.IP) ([]string, error) {
 	if err != nil {
 		return nil, err
 	}
-	results := make([]string, len(answers)-1)
+	results := make([]string, 0, len(answers))
 	for _, a := range answers {
 		results = append(results, a.Ptr)
 	}
@@ -0,0 +1,125 @@
+package addr
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+)
+
+// Embedded describes an IPv4 address embedded in an IPv6 address.
+type Embedded struct {
+	// Type is the embedding mechanism, one of TXT_EMBEDDED, TXT_6to4, TXT_TEREDO or TXT_MAPPED.
+	Type string
+	// IPv4 is the embedded address. For 6to4, this is the gateway address. For Teredo, this is
+	// the client's public address.
+	IPv4 netip.Addr
+	// Prefix is the prefix the IPv4 address was extracted from.
+	Prefix netip.Prefix
+	// Server is the Teredo server address.
+	Server netip.Addr
+	// Port is the Teredo client's public UDP port.
+	Port uint16
+	// Origin is the lookup result for IPv4. Nil if it wasn't looked up, or the lookup failed.
+	Origin *Response
+}
+
+// NAT64_PREFIXES are the well-known IPv4/IPv6 translation prefixes IPv4 addresses are extracted
+// from. Network-specific prefixes are passed to ExtractEmbeddedIPv4 or QueryOptions instead.
+var NAT64_PREFIXES []netip.Prefix = []netip.Prefix{PFX_EMBEDDED}
+
+var NAT64_PREFIX_LENGTHS = []int{32, 40, 48, 56, 64, 96}
+
+type ErrNAT64PrefixLength error
+
+func NewErrNAT64PrefixLength(length int) ErrNAT64PrefixLength {
+	return fmt.Errorf("invalid NAT64 prefix length /%d, must be one of %v", length, NAT64_PREFIX_LENGTHS)
+}
+
+// ParseNAT64Prefix parses a network-specific NAT64 prefix and validates its length.
+func ParseNAT64Prefix(in string) (netip.Prefix, error) {
+	pfx, err := netip.ParsePrefix(in)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	if !pfx.Addr().Is6() || !validNAT64Length(pfx.Bits()) {
+		return netip.Prefix{}, NewErrNAT64PrefixLength(pfx.Bits())
+	}
+	return pfx.Masked(), nil
+}
+
+// ExtractNAT64 extracts an IPv4 address from an IPv4-embedded IPv6 address, per RFC6052 section
+// 2.2. Bits 64-71 (the 'u' octet) are skipped.
+func ExtractNAT64(a netip.Addr, length int) (netip.Addr, error) {
+	if !validNAT64Length(length) {
+		return netip.Addr{}, NewErrNAT64PrefixLength(length)
+	}
+	if !a.Is6() {
+		return netip.Addr{}, fmt.Errorf("invalid IPv6 address '%s'", a)
+	}
+	ip16 := a.As16()
+	v4 := [4]byte{}
+	n := 0
+	for i := length / 8; n < len(v4); i++ {
+		if i == 8 {
+			continue
+		}
+		v4[n] = ip16[i]
+		n++
+	}
+	return netip.AddrFrom4(v4), nil
+}
+
+// ExtractEmbeddedIPv4 returns the IPv4 address embedded in a, if a is an IPv4-mapped, NAT64,
+// 6to4 or Teredo address. nat64 are network-specific NAT64 prefixes checked after NAT64_PREFIXES,
+// which must be one of the lengths allowed by RFC6052.
+func ExtractEmbeddedIPv4(a netip.Addr, nat64 ...netip.Prefix) *Embedded {
+	if !a.Is6() {
+		return nil
+	}
+	ip16 := a.As16()
+	if a.Is4In6() {
+		return &Embedded{Type: TXT_MAPPED, IPv4: a.Unmap(), Prefix: PFX_IPv4_MAPPED}
+	}
+	prefixes := append(append([]netip.Prefix{}, NAT64_PREFIXES...), nat64...)
+	for _, pfx := range prefixes {
+		if !pfx.Contains(a) {
+			continue
+		}
+		v4, err := ExtractNAT64(a, pfx.Bits())
+		if err != nil {
+			continue
+		}
+		return &Embedded{Type: TXT_EMBEDDED, IPv4: v4, Prefix: pfx}
+	}
+	switch true {
+	case PFX_SIX_TO_FOUR_v6.Contains(a):
+		// 2002:WWXX:YYZZ::/48, where WWXX:YYZZ is the gateway's IPv4 address.
+		return &Embedded{
+			Type:   TXT_6to4,
+			IPv4:   netip.AddrFrom4([4]byte{ip16[2], ip16[3], ip16[4], ip16[5]}),
+			Prefix: PFX_SIX_TO_FOUR_v6,
+		}
+	case PFX_TEREDO.Contains(a):
+		// 2001:0:SSSS:SSSS:FFFF:PPPP:CCCC:CCCC, where the client port (P) and address (C) are
+		// obfuscated by inverting each bit. See RFC4380 section 4.
+		port := binary.BigEndian.Uint16(ip16[10:12]) ^ 0xffff
+		return &Embedded{
+			Type:   TXT_TEREDO,
+			IPv4:   netip.AddrFrom4([4]byte{^ip16[12], ^ip16[13], ^ip16[14], ^ip16[15]}),
+			Prefix: PFX_TEREDO,
+			Server: netip.AddrFrom4([4]byte{ip16[4], ip16[5], ip16[6], ip16[7]}),
+			Port:   port,
+		}
+	default:
+		return nil
+	}
+}
+
+func validNAT64Length(length int) bool {
+	for _, l := range NAT64_PREFIX_LENGTHS {
+		if l == length {
+			return true
+		}
+	}
+	return false
+}
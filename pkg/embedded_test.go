@@ -0,0 +1,175 @@
+package addr_test
+
+import (
+	"fmt"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	addr "github.com/thatmattlove/addr/pkg"
+)
+
+func Test_ExtractNAT64(t *testing.T) {
+	// RFC6052 section 2.4 examples, embedding 192.0.2.33.
+	cases := map[int]string{
+		32: "2001:db8:c000:221::",
+		40: "2001:db8:1c0:2:21::",
+		48: "2001:db8:122:c000:2:2100::",
+		56: "2001:db8:122:3c0:0:221::",
+		64: "2001:db8:122:344:c0:2:2100:0",
+		96: "2001:db8:122:344::192.0.2.33",
+	}
+	for length, ip := range cases {
+		length := length
+		ip := ip
+		t.Run(fmt.Sprintf("/%d", length), func(t *testing.T) {
+			t.Parallel()
+			v4, err := addr.ExtractNAT64(netip.MustParseAddr(ip), length)
+			assert.NoError(t, err)
+			assert.Equal(t, "192.0.2.33", v4.String())
+		})
+	}
+	t.Run("invalid length", func(t *testing.T) {
+		t.Parallel()
+		_, err := addr.ExtractNAT64(netip.MustParseAddr("2001:db8::1"), 80)
+		assert.Error(t, err)
+	})
+}
+
+func Test_ParseNAT64Prefix(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		t.Parallel()
+		pfx, err := addr.ParseNAT64Prefix("2001:db8:64::/64")
+		assert.NoError(t, err)
+		assert.Equal(t, "2001:db8:64::/64", pfx.String())
+	})
+	t.Run("invalid length", func(t *testing.T) {
+		t.Parallel()
+		_, err := addr.ParseNAT64Prefix("2001:db8:64::/63")
+		assert.EqualError(t, err, addr.NewErrNAT64PrefixLength(63).Error())
+	})
+	t.Run("ipv4", func(t *testing.T) {
+		t.Parallel()
+		_, err := addr.ParseNAT64Prefix("192.0.2.0/24")
+		assert.Error(t, err)
+	})
+	t.Run("invalid prefix", func(t *testing.T) {
+		t.Parallel()
+		_, err := addr.ParseNAT64Prefix("not a prefix")
+		assert.Error(t, err)
+	})
+}
+
+func Test_ExtractEmbeddedIPv4(t *testing.T) {
+	t.Run("nat64", func(t *testing.T) {
+		t.Parallel()
+		e := addr.ExtractEmbeddedIPv4(netip.MustParseAddr("64:ff9b::c000:221"))
+		assert.NotNil(t, e)
+		assert.Equal(t, addr.TXT_EMBEDDED, e.Type)
+		assert.Equal(t, "192.0.2.33", e.IPv4.String())
+		assert.Equal(t, addr.PFX_EMBEDDED, e.Prefix)
+	})
+	t.Run("6to4", func(t *testing.T) {
+		t.Parallel()
+		e := addr.ExtractEmbeddedIPv4(netip.MustParseAddr("2002:c000:0201::1"))
+		assert.NotNil(t, e)
+		assert.Equal(t, addr.TXT_6to4, e.Type)
+		assert.Equal(t, "192.0.2.1", e.IPv4.String())
+	})
+	t.Run("teredo", func(t *testing.T) {
+		t.Parallel()
+		// RFC4380 section 4 example.
+		e := addr.ExtractEmbeddedIPv4(netip.MustParseAddr("2001:0:4136:e378:8000:63bf:3fff:fdd2"))
+		assert.NotNil(t, e)
+		assert.Equal(t, addr.TXT_TEREDO, e.Type)
+		assert.Equal(t, "192.0.2.45", e.IPv4.String())
+		assert.Equal(t, "65.54.227.120", e.Server.String())
+		assert.Equal(t, uint16(40000), e.Port)
+	})
+	t.Run("mapped", func(t *testing.T) {
+		t.Parallel()
+		e := addr.ExtractEmbeddedIPv4(netip.MustParseAddr("::ffff:192.0.2.1"))
+		assert.NotNil(t, e)
+		assert.Equal(t, addr.TXT_MAPPED, e.Type)
+		assert.Equal(t, "192.0.2.1", e.IPv4.String())
+	})
+	t.Run("network-specific nat64", func(t *testing.T) {
+		t.Parallel()
+		a := netip.MustParseAddr("2001:db8:64::c000:221")
+		assert.Nil(t, addr.ExtractEmbeddedIPv4(a))
+		e := addr.ExtractEmbeddedIPv4(a, netip.MustParsePrefix("2001:db8:64::/96"))
+		assert.NotNil(t, e)
+		assert.Equal(t, "192.0.2.33", e.IPv4.String())
+		assert.Equal(t, "2001:db8:64::/96", e.Prefix.String())
+	})
+	t.Run("none", func(t *testing.T) {
+		t.Parallel()
+		assert.Nil(t, addr.ExtractEmbeddedIPv4(netip.MustParseAddr("2606:4700:4700::1111")))
+	})
+}
+
+func TestIPValidator_Embedded(t *testing.T) {
+	t.Run("ipv4 notation is not embedded", func(t *testing.T) {
+		t.Parallel()
+		v, _ := addr.NewIPValidator("192.0.2.1")
+		assert.False(t, v.IsMapped())
+		assert.Nil(t, v.Embedded())
+	})
+	t.Run("mapped", func(t *testing.T) {
+		t.Parallel()
+		v, _ := addr.NewIPValidator("::ffff:192.0.2.1")
+		assert.True(t, v.IsMapped())
+		shouldQuery, res := v.Validate()
+		assert.False(t, shouldQuery)
+		assert.Equal(t, addr.TXT_MAPPED, res.Name)
+		assert.Equal(t, addr.IPv4_MAPPED, res.Prefix)
+		assert.Equal(t, addr.PFX_IPv4_MAPPED, res.Network)
+	})
+	t.Run("6to4 prefix", func(t *testing.T) {
+		t.Parallel()
+		v, _ := addr.NewIPValidator("2002:c000:0201::/48")
+		e := v.Embedded()
+		assert.NotNil(t, e)
+		assert.Equal(t, "192.0.2.1", e.IPv4.String())
+	})
+}
+
+func Test_QueryIPPrefixEmbedded(t *testing.T) {
+	// Both the IPv6 & embedded IPv4 addresses are special-purpose, so no query is made.
+	res, err := addr.QueryIPPrefix("64:ff9b::10.0.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, addr.PFX_EMBEDDED, res.Network)
+	assert.NotNil(t, res.Embedded)
+	assert.NotNil(t, res.Embedded.Origin)
+	assert.Equal(t, addr.PFX_RFC1918_10, res.Embedded.Origin.Network)
+}
+
+func Test_QueryIPPrefixEmbedded_Failure(t *testing.T) {
+	t.Parallel()
+	rib := readTestRIB(t)
+	opts := addr.QueryOptions{
+		NAT64Prefixes: []netip.Prefix{netip.MustParsePrefix("2606:4700:64::/96")},
+		Offline:       true,
+		RIB:           rib,
+	}
+
+	res, err := addr.QueryIPPrefixWith(nil, "2606:4700:64::101:101", opts)
+	assert.NoError(t, err)
+	assert.Equal(t, "2606:4700::/32", res.Network.String())
+	if assert.NotNil(t, res.Embedded) && assert.NotNil(t, res.Embedded.Origin) {
+		assert.Equal(t, "1.1.1.0/24", res.Embedded.Origin.Network.String())
+	}
+
+	// 9.9.9.9 isn't in the RIB, so only the IPv6 address is answered.
+	res, err = addr.QueryIPPrefixWith(nil, "2606:4700:64::909:909", opts)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(13335), res.ASN.Uint32())
+	if assert.NotNil(t, res.Embedded) {
+		assert.Equal(t, "9.9.9.9", res.Embedded.IPv4.String())
+		assert.Nil(t, res.Embedded.Origin)
+	}
+
+	res, err = addr.QueryIPPrefixWith(nil, "2606:4700:64::909:909", addr.QueryOptions{Offline: true, RIB: rib})
+	assert.NoError(t, err)
+	assert.Nil(t, res.Embedded, "network-specific prefixes are only used when given")
+}
@@ -0,0 +1,454 @@
+package addr
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	FLOW_SRC string = "src"
+	FLOW_DST string = "dst"
+)
+
+// FLOW_ENRICHED_FIELDS are the fields added to each record by WriteFlows.
+var FLOW_ENRICHED_FIELDS = []string{"src_asn", "src_prefix", "src_as_name", "dst_asn", "dst_prefix", "dst_as_name"}
+
+// Field names used by nfdump, pmacct & goflow2 (and older goflow) exports, lower-cased.
+var (
+	flowSrcFields      = []string{"sa", "src4_addr", "src6_addr", "src_addr", "srcaddr", "src_ip", "ip_src", "srcip"}
+	flowDstFields      = []string{"da", "dst4_addr", "dst6_addr", "dst_addr", "dstaddr", "dst_ip", "ip_dst", "dstip"}
+	flowPacketsFields  = []string{"ipkt", "in_packets", "packets", "pkts"}
+	flowBytesFields    = []string{"ibyt", "in_bytes", "bytes", "octets"}
+	flowSamplingFields = []string{"sampling_rate", "samplingrate"}
+)
+
+var ErrEmptyFlows = errors.New("no flow records found, expected CSV or JSON with source & destination address fields")
+
+// FlowOrigin is the origin of a flow's source or destination address.
+type FlowOrigin struct {
+	ASN    uint32
+	Prefix netip.Prefix
+	Name   string
+}
+
+// FlowOriginFromResult returns the origin from a lookup result, or an empty origin if the lookup
+// failed.
+func FlowOriginFromResult(r *Result) FlowOrigin {
+	o := FlowOrigin{}
+	if r == nil || r.Error != "" {
+		return o
+	}
+	if r.ASN != nil {
+		o.ASN = r.ASN.Uint32()
+	}
+	o.Prefix, _ = netip.ParsePrefix(r.Prefix)
+	o.Name = r.Name
+	return o
+}
+
+// QueryFlowOrigins looks up the origin of each address with QueryAddrs, which makes every whois
+// query in bulk over one connection. Addresses that fail to look up have an empty origin.
+func QueryFlowOrigins(addrs []netip.Addr, opts QueryOptions) map[netip.Addr]FlowOrigin {
+	results := QueryAddrs(addrs, opts)
+	origins := make(map[netip.Addr]FlowOrigin, len(addrs))
+	for i, a := range addrs {
+		origins[a] = FlowOriginFromResult(results[i])
+	}
+	return origins
+}
+
+// FlowRecord is a flow exported by nfdump, pmacct or goflow2.
+type FlowRecord struct {
+	Src netip.Addr
+	Dst netip.Addr
+	// Packets & Bytes are multiplied by the record's sampling rate, if it has one.
+	Packets   uint64
+	Bytes     uint64
+	SrcOrigin FlowOrigin
+	DstOrigin FlowOrigin
+	// values are the record's original CSV values, and fields its original JSON object.
+	values []string
+	fields map[string]json.RawMessage
+}
+
+// Flows are flow records read from a CSV or JSON export.
+type Flows struct {
+	// Format is FORMAT_CSV or FORMAT_JSON.
+	Format string
+	// Header is the CSV header row.
+	Header  []string
+	Records []*FlowRecord
+	// Skipped counts rows or objects without a valid source & destination address, such as
+	// nfdump's summary lines.
+	Skipped int
+}
+
+// ReadFlows reads flow records from CSV with a header row, a JSON array, or JSON objects one per
+// line, as exported by nfdump ('-o csv', '-o json'), pmacct & goflow2.
+func ReadFlows(r io.Reader) (*Flows, error) {
+	br := bufio.NewReader(r)
+	first, err := firstNonSpace(br)
+	if err != nil {
+		return nil, err
+	}
+	var f *Flows
+	if first == '{' || first == '[' {
+		f, err = readFlowsJSON(br, first == '[')
+	} else {
+		f, err = readFlowsCSV(br)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(f.Records) == 0 {
+		return nil, ErrEmptyFlows
+	}
+	return f, nil
+}
+
+func firstNonSpace(br *bufio.Reader) (byte, error) {
+	for {
+		b, err := br.Peek(1)
+		if errors.Is(err, io.EOF) {
+			return 0, ErrEmptyFlows
+		}
+		if err != nil {
+			return 0, err
+		}
+		if !bytes.ContainsAny(b, " \t\r\n") {
+			return b[0], nil
+		}
+		br.ReadByte()
+	}
+}
+
+func readFlowsCSV(r io.Reader) (*Flows, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("invalid flow CSV: %w", err)
+	}
+	columns := map[string]int{}
+	for i, h := range header {
+		columns[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	column := func(names []string) int {
+		for _, n := range names {
+			if i, ok := columns[n]; ok {
+				return i
+			}
+		}
+		return -1
+	}
+	src, dst := column(flowSrcFields), column(flowDstFields)
+	if src < 0 || dst < 0 {
+		return nil, fmt.Errorf("flow CSV header has no source & destination address columns, expected one of %s and %s",
+			strings.Join(flowSrcFields, ", "), strings.Join(flowDstFields, ", "))
+	}
+	packets, byteCount, sampling := column(flowPacketsFields), column(flowBytesFields), column(flowSamplingFields)
+	f := &Flows{Format: FORMAT_CSV, Header: header, Records: []*FlowRecord{}}
+	value := func(row []string, i int) string {
+		if i < 0 || i >= len(row) {
+			return ""
+		}
+		return row[i]
+	}
+	for {
+		row, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid flow CSV: %w", err)
+		}
+		rec, ok := newFlowRecord(value(row, src), value(row, dst), value(row, packets), value(row, byteCount), value(row, sampling))
+		if !ok {
+			f.Skipped++
+			continue
+		}
+		rec.values = row
+		f.Records = append(f.Records, rec)
+	}
+	return f, nil
+}
+
+func readFlowsJSON(r io.Reader, array bool) (*Flows, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	if array {
+		if _, err := dec.Token(); err != nil {
+			return nil, fmt.Errorf("invalid flow JSON: %w", err)
+		}
+	}
+	f := &Flows{Format: FORMAT_JSON, Records: []*FlowRecord{}}
+	for dec.More() {
+		obj := map[string]json.RawMessage{}
+		if err := dec.Decode(&obj); err != nil {
+			return nil, fmt.Errorf("invalid flow JSON: %w", err)
+		}
+		lower := make(map[string]json.RawMessage, len(obj))
+		for k, v := range obj {
+			lower[strings.ToLower(k)] = v
+		}
+		value := func(names []string) string {
+			for _, n := range names {
+				if v, ok := lower[n]; ok {
+					return strings.Trim(string(v), `"`)
+				}
+			}
+			return ""
+		}
+		rec, ok := newFlowRecord(value(flowSrcFields), value(flowDstFields), value(flowPacketsFields), value(flowBytesFields), value(flowSamplingFields))
+		if !ok {
+			f.Skipped++
+			continue
+		}
+		rec.fields = obj
+		f.Records = append(f.Records, rec)
+	}
+	return f, nil
+}
+
+func newFlowRecord(src, dst, packets, byteCount, sampling string) (*FlowRecord, bool) {
+	s, err := netip.ParseAddr(strings.TrimSpace(src))
+	if err != nil {
+		return nil, false
+	}
+	d, err := netip.ParseAddr(strings.TrimSpace(dst))
+	if err != nil {
+		return nil, false
+	}
+	rec := &FlowRecord{Src: s.Unmap().WithZone(""), Dst: d.Unmap().WithZone("")}
+	rec.Packets = parseFlowCount(packets)
+	rec.Bytes = parseFlowCount(byteCount)
+	if rate := parseFlowCount(sampling); rate > 1 {
+		rec.Packets *= rate
+		rec.Bytes *= rate
+	}
+	return rec, true
+}
+
+// parseFlowCount parses a counter, which nfdump may write in scientific notation or with a unit
+// suffix such as '1.2 M'.
+func parseFlowCount(in string) uint64 {
+	in = strings.TrimSpace(in)
+	if n, err := strconv.ParseUint(in, 10, 64); err == nil {
+		return n
+	}
+	scale := float64(1)
+	for suffix, s := range map[string]float64{"K": 1e3, "M": 1e6, "G": 1e9, "T": 1e12} {
+		if strings.HasSuffix(in, suffix) {
+			in, scale = strings.TrimSpace(strings.TrimSuffix(in, suffix)), s
+		}
+	}
+	n, err := strconv.ParseFloat(in, 64)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return uint64(math.Round(n * scale))
+}
+
+// Addrs returns each unique source & destination address, in the order they first appear.
+func (f *Flows) Addrs() []netip.Addr {
+	seen := map[netip.Addr]bool{}
+	addrs := []netip.Addr{}
+	for _, r := range f.Records {
+		for _, a := range []netip.Addr{r.Src, r.Dst} {
+			if !seen[a] {
+				seen[a] = true
+				addrs = append(addrs, a)
+			}
+		}
+	}
+	return addrs
+}
+
+// Enrich sets the source & destination origin of each record.
+func (f *Flows) Enrich(origin func(netip.Addr) FlowOrigin) {
+	origins := map[netip.Addr]FlowOrigin{}
+	lookup := func(a netip.Addr) FlowOrigin {
+		o, ok := origins[a]
+		if !ok {
+			o = origin(a)
+			origins[a] = o
+		}
+		return o
+	}
+	for _, r := range f.Records {
+		r.SrcOrigin = lookup(r.Src)
+		r.DstOrigin = lookup(r.Dst)
+	}
+}
+
+func (o FlowOrigin) values() []string {
+	values := []string{"", "", o.Name}
+	if o.ASN != 0 {
+		values[0] = strconv.FormatUint(uint64(o.ASN), 10)
+	}
+	if o.Prefix.IsValid() {
+		values[1] = o.Prefix.String()
+	}
+	return values
+}
+
+// WriteFlows writes records in the format they were read, with FLOW_ENRICHED_FIELDS added. JSON
+// records are written one per line.
+func WriteFlows(w io.Writer, f *Flows) error {
+	if f.Format == FORMAT_JSON {
+		enc := json.NewEncoder(w)
+		for _, r := range f.Records {
+			obj := make(map[string]any, len(r.fields)+len(FLOW_ENRICHED_FIELDS))
+			for k, v := range r.fields {
+				obj[k] = v
+			}
+			for i, v := range append(r.SrcOrigin.values(), r.DstOrigin.values()...) {
+				obj[FLOW_ENRICHED_FIELDS[i]] = v
+			}
+			if err := enc.Encode(obj); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	cw := csv.NewWriter(w)
+	header := append(append([]string{}, f.Header...), FLOW_ENRICHED_FIELDS...)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, r := range f.Records {
+		row := make([]string, len(f.Header), len(header))
+		copy(row, r.values)
+		row = append(append(row, r.SrcOrigin.values()...), r.DstOrigin.values()...)
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// FlowGroup is the traffic of flows from or to an ASN.
+type FlowGroup struct {
+	// ASN is 0 if the origin is unknown.
+	ASN     uint32
+	Name    string
+	Flows   int
+	Packets uint64
+	Bytes   uint64
+	// Percent is the group's share of all bytes.
+	Percent float64
+}
+
+// FlowSummary is the top talkers of a set of flows by source or destination ASN.
+type FlowSummary struct {
+	// By is FLOW_SRC or FLOW_DST.
+	By    string
+	Flows int
+	Total TrafficCount
+	// Groups are ordered by bytes, largest first.
+	Groups []*FlowGroup
+	// Other counts the flows in the OtherGroups groups beyond the top N.
+	Other       FlowGroup
+	OtherGroups int
+}
+
+// SummarizeFlows groups flows by source or destination origin ASN. If top is greater than zero,
+// only the top groups are kept and the rest are counted in Other.
+func SummarizeFlows(f *Flows, by string, top int) (*FlowSummary, error) {
+	if by != FLOW_SRC && by != FLOW_DST {
+		return nil, fmt.Errorf("unknown flow direction '%s', must be %s or %s", by, FLOW_SRC, FLOW_DST)
+	}
+	s := &FlowSummary{By: by, Flows: len(f.Records), Groups: []*FlowGroup{}}
+	groups := map[uint32]*FlowGroup{}
+	for _, r := range f.Records {
+		o := r.SrcOrigin
+		if by == FLOW_DST {
+			o = r.DstOrigin
+		}
+		g, ok := groups[o.ASN]
+		if !ok {
+			g = &FlowGroup{ASN: o.ASN, Name: o.Name}
+			groups[o.ASN] = g
+			s.Groups = append(s.Groups, g)
+		}
+		if g.Name == "" {
+			g.Name = o.Name
+		}
+		g.add(r)
+		s.Total.add(TrafficCount{Packets: r.Packets, Bytes: r.Bytes})
+	}
+	for _, g := range s.Groups {
+		g.Percent = flowPercent(g, s.Total)
+	}
+	sort.SliceStable(s.Groups, func(i, j int) bool {
+		if s.Groups[i].Bytes != s.Groups[j].Bytes {
+			return s.Groups[i].Bytes > s.Groups[j].Bytes
+		}
+		return s.Groups[i].ASN < s.Groups[j].ASN
+	})
+	if top > 0 && len(s.Groups) > top {
+		for _, g := range s.Groups[top:] {
+			s.Other.Flows += g.Flows
+			s.Other.Packets += g.Packets
+			s.Other.Bytes += g.Bytes
+		}
+		s.Other.Percent = flowPercent(&s.Other, s.Total)
+		s.OtherGroups = len(s.Groups) - top
+		s.Groups = s.Groups[:top]
+	}
+	return s, nil
+}
+
+func (g *FlowGroup) add(r *FlowRecord) {
+	g.Flows++
+	g.Packets += r.Packets
+	g.Bytes += r.Bytes
+}
+
+func flowPercent(g *FlowGroup, total TrafficCount) float64 {
+	if total.Bytes == 0 {
+		return 0
+	}
+	return float64(g.Bytes) / float64(total.Bytes) * 100
+}
+
+type flowGroupJSON struct {
+	ASN     uint32  `json:"asn"`
+	Name    string  `json:"name,omitempty"`
+	Flows   int     `json:"flows"`
+	Packets uint64  `json:"packets"`
+	Bytes   uint64  `json:"bytes"`
+	Percent float64 `json:"percent"`
+}
+
+func newFlowGroupJSON(g *FlowGroup) flowGroupJSON {
+	return flowGroupJSON{g.ASN, g.Name, g.Flows, g.Packets, g.Bytes, math.Round(g.Percent*100) / 100}
+}
+
+// MarshalJSON encodes a flow summary with percentages rounded to two decimal places.
+func (s *FlowSummary) MarshalJSON() ([]byte, error) {
+	groups := make([]flowGroupJSON, 0, len(s.Groups))
+	for _, g := range s.Groups {
+		groups = append(groups, newFlowGroupJSON(g))
+	}
+	return json.Marshal(struct {
+		By          string          `json:"by"`
+		Flows       int             `json:"flows"`
+		Total       TrafficCount    `json:"total"`
+		Groups      []flowGroupJSON `json:"groups"`
+		Other       flowGroupJSON   `json:"other"`
+		OtherGroups int             `json:"other_groups"`
+	}{s.By, s.Flows, s.Total, groups, newFlowGroupJSON(&s.Other), s.OtherGroups})
+}
@@ -0,0 +1,193 @@
+package addr_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	addr "github.com/thatmattlove/addr/pkg"
+)
+
+const (
+	NFDUMP_CSV string = `ts,te,td,sa,da,sp,dp,pr,flg,fwd,stos,ipkt,ibyt,opkt,obyt
+2026-10-19 10:00:00,2026-10-19 10:00:01,1.000,1.1.1.1,192.0.2.10,443,50000,TCP,.AP.SF,0,0,10,15000,0,0
+2026-10-19 10:00:00,2026-10-19 10:00:02,2.000,8.8.8.8,192.0.2.10,53,50001,UDP,......,0,0,1,100,0,0
+2026-10-19 10:00:01,2026-10-19 10:00:03,2.000,1.0.0.1,192.0.2.11,443,50002,TCP,.AP.SF,0,0,5,5000,0,0
+Summary
+flows,bytes,packets,avg_bps,avg_pps,avg_bpp
+3,20100,16,80400,8,1256`
+
+	GOFLOW2_JSON string = `{"type":"SFLOW_5","sampling_rate":100,"src_addr":"2606:4700:4700::1111","dst_addr":"2001:db8::10","bytes":120,"packets":1}
+{"type":"SFLOW_5","sampling_rate":100,"src_addr":"8.8.8.8","dst_addr":"192.0.2.10","bytes":80,"packets":1}`
+
+	PMACCT_JSON string = `[{"ip_src": "1.1.1.1", "ip_dst": "192.0.2.10", "packets": "3", "bytes": "1.2 K"}]`
+)
+
+func fakeFlowOrigin(a netip.Addr) addr.FlowOrigin {
+	table := addr.NewPrefixTable()
+	table.Insert(netip.MustParsePrefix("1.0.0.0/23"), 13335)
+	table.Insert(netip.MustParsePrefix("1.1.1.0/24"), 13335)
+	table.Insert(netip.MustParsePrefix("8.8.8.0/24"), 15169)
+	table.Insert(netip.MustParsePrefix("2606:4700::/32"), 13335)
+	p, asn, _ := table.Lookup(a)
+	return addr.FlowOrigin{ASN: asn, Prefix: p}
+}
+
+func Test_ReadFlows(t *testing.T) {
+	t.Run("nfdump csv", func(t *testing.T) {
+		t.Parallel()
+		f, err := addr.ReadFlows(strings.NewReader(NFDUMP_CSV))
+		assert.NoError(t, err)
+		assert.Equal(t, addr.FORMAT_CSV, f.Format)
+		assert.Len(t, f.Records, 3)
+		assert.Equal(t, 3, f.Skipped, "the summary lines are skipped")
+		assert.Equal(t, "1.1.1.1", f.Records[0].Src.String())
+		assert.Equal(t, uint64(10), f.Records[0].Packets)
+		assert.Equal(t, uint64(15000), f.Records[0].Bytes)
+		assert.Equal(t, []netip.Addr{
+			netip.MustParseAddr("1.1.1.1"),
+			netip.MustParseAddr("192.0.2.10"),
+			netip.MustParseAddr("8.8.8.8"),
+			netip.MustParseAddr("1.0.0.1"),
+			netip.MustParseAddr("192.0.2.11"),
+		}, f.Addrs())
+	})
+	t.Run("goflow2 json", func(t *testing.T) {
+		t.Parallel()
+		f, err := addr.ReadFlows(strings.NewReader(GOFLOW2_JSON))
+		assert.NoError(t, err)
+		assert.Equal(t, addr.FORMAT_JSON, f.Format)
+		assert.Len(t, f.Records, 2)
+		assert.Equal(t, "2606:4700:4700::1111", f.Records[0].Src.String())
+		assert.Equal(t, uint64(12000), f.Records[0].Bytes, "counters are scaled by the sampling rate")
+		assert.Equal(t, uint64(100), f.Records[0].Packets)
+	})
+	t.Run("pmacct json array", func(t *testing.T) {
+		t.Parallel()
+		f, err := addr.ReadFlows(strings.NewReader(PMACCT_JSON))
+		assert.NoError(t, err)
+		assert.Len(t, f.Records, 1)
+		assert.Equal(t, uint64(3), f.Records[0].Packets)
+		assert.Equal(t, uint64(1200), f.Records[0].Bytes)
+	})
+	t.Run("pmacct csv", func(t *testing.T) {
+		t.Parallel()
+		f, err := addr.ReadFlows(strings.NewReader("SRC_IP,DST_IP,SRC_PORT,DST_PORT,PROTOCOL,PACKETS,BYTES\n1.1.1.1,192.0.2.10,443,50000,tcp,2,3000\n"))
+		assert.NoError(t, err)
+		assert.Len(t, f.Records, 1)
+		assert.Equal(t, uint64(3000), f.Records[0].Bytes)
+	})
+	t.Run("errors", func(t *testing.T) {
+		t.Parallel()
+		_, err := addr.ReadFlows(strings.NewReader(""))
+		assert.ErrorIs(t, err, addr.ErrEmptyFlows)
+		_, err = addr.ReadFlows(strings.NewReader("a,b,c\n1,2,3\n"))
+		assert.ErrorContains(t, err, "no source & destination address columns")
+		_, err = addr.ReadFlows(strings.NewReader(`{"src_addr": "1.1.1.1",`))
+		assert.ErrorContains(t, err, "invalid flow JSON")
+		_, err = addr.ReadFlows(strings.NewReader(`{"src_addr": "not an IP", "dst_addr": "1.1.1.1"}`))
+		assert.ErrorIs(t, err, addr.ErrEmptyFlows)
+	})
+}
+
+func Test_WriteFlows(t *testing.T) {
+	t.Run("csv", func(t *testing.T) {
+		t.Parallel()
+		f, err := addr.ReadFlows(strings.NewReader(NFDUMP_CSV))
+		assert.NoError(t, err)
+		f.Enrich(fakeFlowOrigin)
+		b := &bytes.Buffer{}
+		assert.NoError(t, addr.WriteFlows(b, f))
+		lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+		assert.Len(t, lines, 4)
+		assert.Equal(t, "ts,te,td,sa,da,sp,dp,pr,flg,fwd,stos,ipkt,ibyt,opkt,obyt,src_asn,src_prefix,src_as_name,dst_asn,dst_prefix,dst_as_name", lines[0])
+		assert.True(t, strings.HasSuffix(lines[1], ",0,0,13335,1.1.1.0/24,,,,"), lines[1])
+		assert.True(t, strings.HasSuffix(lines[3], ",0,0,13335,1.0.0.0/23,,,,"), lines[3])
+	})
+	t.Run("json", func(t *testing.T) {
+		t.Parallel()
+		f, err := addr.ReadFlows(strings.NewReader(GOFLOW2_JSON))
+		assert.NoError(t, err)
+		f.Enrich(fakeFlowOrigin)
+		b := &bytes.Buffer{}
+		assert.NoError(t, addr.WriteFlows(b, f))
+		lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+		assert.Len(t, lines, 2)
+		rec := map[string]any{}
+		assert.NoError(t, json.Unmarshal([]byte(lines[1]), &rec))
+		assert.Equal(t, "15169", rec["src_asn"])
+		assert.Equal(t, "8.8.8.0/24", rec["src_prefix"])
+		assert.Equal(t, "", rec["dst_asn"])
+		assert.Equal(t, float64(80), rec["bytes"], "original fields are kept as is")
+		assert.Equal(t, "SFLOW_5", rec["type"])
+	})
+}
+
+func Test_SummarizeFlows(t *testing.T) {
+	f, err := addr.ReadFlows(strings.NewReader(NFDUMP_CSV))
+	assert.NoError(t, err)
+	f.Enrich(fakeFlowOrigin)
+	t.Run("src", func(t *testing.T) {
+		t.Parallel()
+		s, err := addr.SummarizeFlows(f, addr.FLOW_SRC, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, addr.TrafficCount{Packets: 16, Bytes: 20100}, s.Total)
+		assert.Len(t, s.Groups, 2)
+		assert.Equal(t, uint32(13335), s.Groups[0].ASN)
+		assert.Equal(t, 2, s.Groups[0].Flows)
+		assert.Equal(t, uint64(20000), s.Groups[0].Bytes)
+		assert.InDelta(t, 99.5, s.Groups[0].Percent, 0.01)
+		assert.Equal(t, uint32(15169), s.Groups[1].ASN)
+	})
+	t.Run("dst", func(t *testing.T) {
+		t.Parallel()
+		s, err := addr.SummarizeFlows(f, addr.FLOW_DST, 0)
+		assert.NoError(t, err)
+		assert.Len(t, s.Groups, 1)
+		assert.Equal(t, uint32(0), s.Groups[0].ASN, "unknown origins are grouped together")
+		assert.Equal(t, float64(100), s.Groups[0].Percent)
+	})
+	t.Run("top", func(t *testing.T) {
+		t.Parallel()
+		s, err := addr.SummarizeFlows(f, addr.FLOW_SRC, 1)
+		assert.NoError(t, err)
+		assert.Len(t, s.Groups, 1)
+		assert.Equal(t, 1, s.OtherGroups)
+		assert.Equal(t, uint64(100), s.Other.Bytes)
+		b, err := json.Marshal(s)
+		assert.NoError(t, err)
+		assert.Contains(t, string(b), `"other":{"asn":0,"flows":1,"packets":1,"bytes":100,"percent":0.5},"other_groups":1`)
+	})
+	t.Run("invalid direction", func(t *testing.T) {
+		t.Parallel()
+		_, err := addr.SummarizeFlows(f, "both", 0)
+		assert.ErrorContains(t, err, "unknown flow direction 'both'")
+	})
+}
+
+func Test_FlowOriginFromResult(t *testing.T) {
+	t.Parallel()
+	o := addr.FlowOriginFromResult(&addr.Result{Prefix: "1.1.1.0/24", Name: "Cloudflare"})
+	assert.Equal(t, "1.1.1.0/24", o.Prefix.String())
+	assert.Equal(t, "Cloudflare", o.Name)
+	assert.Equal(t, addr.FlowOrigin{}, addr.FlowOriginFromResult(&addr.Result{Error: "failed"}))
+}
+
+func Test_QueryFlowOrigins(t *testing.T) {
+	requests := serveBulkWhois(t, `AS      | IP               | BGP Prefix          | CC | Registry | Allocated  | AS Name
+13335   | 1.1.1.1          | 1.1.1.0/24          | US | ARIN     | 2010-07-14 | Cloudflare, Inc.
+15169   | 8.8.8.8          | 8.8.8.0/24          | US | ARIN     | 1992-12-01 | Google LLC
+`)
+	f, err := addr.ReadFlows(strings.NewReader(NFDUMP_CSV))
+	assert.NoError(t, err)
+	origins := addr.QueryFlowOrigins(f.Addrs(), addr.QueryOptions{})
+	assert.Equal(t, "begin\nverbose\n1.1.1.1\n8.8.8.8\n1.0.0.1\nend\n", <-requests, "documentation addresses aren't queried")
+	assert.Len(t, requests, 0)
+	f.Enrich(func(a netip.Addr) addr.FlowOrigin { return origins[a] })
+	assert.Equal(t, addr.FlowOrigin{ASN: 13335, Prefix: netip.MustParsePrefix("1.1.1.0/24"), Name: "Cloudflare, Inc."}, f.Records[0].SrcOrigin)
+	assert.Equal(t, uint32(15169), f.Records[1].SrcOrigin.ASN)
+	assert.Equal(t, addr.FlowOrigin{}, f.Records[2].SrcOrigin, "failed lookups have an empty origin")
+}
@@ -0,0 +1,89 @@
+package addr
+
+import (
+	"fmt"
+	"net/netip"
+	"regexp"
+	"strings"
+)
+
+var rangeSeparator = regexp.MustCompile(`\s*-\s*`)
+
+// IPRange is an inclusive range of IP addresses, such as those found in firewall exports or RIR
+// inetnum objects.
+type IPRange struct {
+	Start netip.Addr
+	End   netip.Addr
+}
+
+// ParseIPRange parses a range in either '192.0.2.10-192.0.2.50' or '192.0.2.0 - 192.0.2.255'
+// format.
+func ParseIPRange(in string) (IPRange, error) {
+	parts := rangeSeparator.Split(strings.TrimSpace(in), -1)
+	if len(parts) != 2 {
+		return IPRange{}, fmt.Errorf("invalid IP range '%s'", in)
+	}
+	start, err := netip.ParseAddr(parts[0])
+	if err != nil {
+		return IPRange{}, fmt.Errorf("invalid IP range '%s': %w", in, err)
+	}
+	end, err := netip.ParseAddr(parts[1])
+	if err != nil {
+		return IPRange{}, fmt.Errorf("invalid IP range '%s': %w", in, err)
+	}
+	start, end = start.Unmap(), end.Unmap()
+	if start.Is4() != end.Is4() {
+		return IPRange{}, fmt.Errorf("invalid IP range '%s', addresses must be the same family", in)
+	}
+	if end.Less(start) {
+		return IPRange{}, fmt.Errorf("invalid IP range '%s', start is after end", in)
+	}
+	return IPRange{Start: start, End: end}, nil
+}
+
+func IsIPRange(in string) bool {
+	_, err := ParseIPRange(in)
+	return err == nil
+}
+
+func (r IPRange) String() string {
+	return fmt.Sprintf("%s-%s", r.Start, r.End)
+}
+
+// Prefixes returns the minimal set of prefixes covering the range.
+func (r IPRange) Prefixes() []netip.Prefix {
+	prefixes := []netip.Prefix{}
+	cur := r.Start
+	for {
+		var p netip.Prefix
+		for bits := 0; bits <= cur.BitLen(); bits++ {
+			p = netip.PrefixFrom(cur, bits)
+			if p.Masked().Addr() == cur && !r.End.Less(LastAddr(p)) {
+				break
+			}
+		}
+		prefixes = append(prefixes, p)
+		last := LastAddr(p)
+		if last == r.End {
+			return prefixes
+		}
+		cur = last.Next()
+	}
+}
+
+// QueryIPRange looks up the origin of each prefix covering an IP range.
+func QueryIPRange(in string) ([]*Response, error) {
+	r, err := ParseIPRange(in)
+	if err != nil {
+		return nil, err
+	}
+	responses := []*Response{}
+	for _, p := range r.Prefixes() {
+		res, err := QueryIPPrefix(p.String())
+		if err != nil {
+			return nil, err
+		}
+		responses = append(responses, res)
+	}
+	return responses, nil
+}
@@ -0,0 +1,97 @@
+package addr_test
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	addr "github.com/thatmattlove/addr/pkg"
+)
+
+func Test_ParseIPRange(t *testing.T) {
+	t.Run("firewall style", func(t *testing.T) {
+		t.Parallel()
+		r, err := addr.ParseIPRange("192.0.2.10-192.0.2.50")
+		assert.NoError(t, err)
+		assert.Equal(t, netip.MustParseAddr("192.0.2.10"), r.Start)
+		assert.Equal(t, netip.MustParseAddr("192.0.2.50"), r.End)
+		assert.Equal(t, "192.0.2.10-192.0.2.50", r.String())
+	})
+	t.Run("inetnum style", func(t *testing.T) {
+		t.Parallel()
+		r, err := addr.ParseIPRange("192.0.2.0 - 192.0.2.255")
+		assert.NoError(t, err)
+		assert.Equal(t, prefixes("192.0.2.0/24"), r.Prefixes())
+	})
+	t.Run("ipv6", func(t *testing.T) {
+		t.Parallel()
+		r, err := addr.ParseIPRange("2001:db8::-2001:db8::ffff")
+		assert.NoError(t, err)
+		assert.Equal(t, prefixes("2001:db8::/112"), r.Prefixes())
+	})
+	t.Run("reversed", func(t *testing.T) {
+		t.Parallel()
+		_, err := addr.ParseIPRange("192.0.2.50-192.0.2.10")
+		assert.Error(t, err)
+	})
+	t.Run("mixed families", func(t *testing.T) {
+		t.Parallel()
+		_, err := addr.ParseIPRange("192.0.2.0-2001:db8::")
+		assert.Error(t, err)
+	})
+	t.Run("not a range", func(t *testing.T) {
+		t.Parallel()
+		assert.False(t, addr.IsIPRange("192.0.2.0/24"))
+		assert.False(t, addr.IsIPRange("192.0.2.0-"))
+		assert.True(t, addr.IsIPRange("192.0.2.0-192.0.2.0"))
+	})
+}
+
+func TestIPRange_Prefixes(t *testing.T) {
+	t.Run("unaligned", func(t *testing.T) {
+		t.Parallel()
+		r, _ := addr.ParseIPRange("192.0.2.10-192.0.2.50")
+		assert.Equal(t, prefixes(
+			"192.0.2.10/31",
+			"192.0.2.12/30",
+			"192.0.2.16/28",
+			"192.0.2.32/28",
+			"192.0.2.48/31",
+			"192.0.2.50/32",
+		), r.Prefixes())
+	})
+	t.Run("single address", func(t *testing.T) {
+		t.Parallel()
+		r, _ := addr.ParseIPRange("192.0.2.1-192.0.2.1")
+		assert.Equal(t, prefixes("192.0.2.1/32"), r.Prefixes())
+	})
+	t.Run("entire address space", func(t *testing.T) {
+		t.Parallel()
+		r, _ := addr.ParseIPRange("0.0.0.0-255.255.255.255")
+		assert.Equal(t, prefixes("0.0.0.0/0"), r.Prefixes())
+		r, _ = addr.ParseIPRange("128.0.0.0-255.255.255.255")
+		assert.Equal(t, prefixes("128.0.0.0/1"), r.Prefixes())
+	})
+}
+
+func Test_ParsePrefixListRanges(t *testing.T) {
+	result, err := addr.ParsePrefixList(strings.NewReader("192.0.2.0 - 192.0.2.127\n198.51.100.0-198.51.100.1, 203.0.113.0/24\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, prefixes("192.0.2.0/25", "198.51.100.0/31", "203.0.113.0/24"), result)
+}
+
+func Test_QueryIPRange(t *testing.T) {
+	t.Run("special-purpose", func(t *testing.T) {
+		t.Parallel()
+		responses, err := addr.QueryIPRange("10.0.0.0 - 10.0.2.255")
+		assert.NoError(t, err)
+		assert.Len(t, responses, 2)
+		assert.Equal(t, "10.0.0.0/8", responses[1].Network.String())
+	})
+	t.Run("invalid", func(t *testing.T) {
+		t.Parallel()
+		_, err := addr.QueryIPRange("10.0.0.0/8")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,327 @@
+package addr
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	goasn "github.com/thatmattlove/go-asn"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	FORMAT_YAML string = "yaml"
+	FORMAT_JSON string = "json"
+	FORMAT_CSV  string = "csv"
+)
+
+const REGISTRY_LOCAL string = "Local"
+
+var (
+	ErrLocalDefinitionsHeader = errors.New("local definitions CSV is missing the 'target' column")
+	ErrLocalDefinitionTarget  = errors.New("local definition has neither a prefix nor an ASN")
+)
+
+// LocalDefinition is a user-defined annotation for a prefix or ASN. For prefix definitions, ASN
+// is the optional origin ASN.
+type LocalDefinition struct {
+	Prefix netip.Prefix
+	ASN    goasn.ASN
+	Name   string
+	Site   string
+	Owner  string
+	Tags   []string
+}
+
+type LocalDefinitions struct {
+	prefixes []*LocalDefinition
+	asns     map[uint32]*LocalDefinition
+}
+
+// LOCAL_DEFINITIONS are consulted before the special-purpose tables and before any query is made.
+var LOCAL_DEFINITIONS *LocalDefinitions = NewLocalDefinitions()
+
+type localDefinitionEntry struct {
+	Prefix string   `json:"prefix" yaml:"prefix"`
+	ASN    asnValue `json:"asn" yaml:"asn"`
+	Name   string   `json:"name" yaml:"name"`
+	Site   string   `json:"site" yaml:"site"`
+	Owner  string   `json:"owner" yaml:"owner"`
+	Tags   []string `json:"tags" yaml:"tags"`
+	// line is the entry's line in a CSV file, or zero.
+	line int
+}
+
+type localDefinitionsFile struct {
+	Prefixes []localDefinitionEntry `json:"prefixes" yaml:"prefixes"`
+	ASNs     []localDefinitionEntry `json:"asns" yaml:"asns"`
+}
+
+// asnValue accepts ASNs as either JSON strings or numbers.
+type asnValue string
+
+func (v *asnValue) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*v = asnValue(s)
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(b, &n); err != nil {
+		return fmt.Errorf("invalid ASN %s", string(b))
+	}
+	*v = asnValue(n.String())
+	return nil
+}
+
+// NewLocalDefinitions creates a set of definitions. Definitions without a prefix or ASN are
+// skipped.
+func NewLocalDefinitions(defs ...*LocalDefinition) *LocalDefinitions {
+	d := &LocalDefinitions{
+		prefixes: []*LocalDefinition{},
+		asns:     map[uint32]*LocalDefinition{},
+	}
+	for _, def := range defs {
+		d.Add(def)
+	}
+	return d
+}
+
+// Add adds a definition. Prefix definitions are kept ordered from most to least specific.
+// ErrLocalDefinitionTarget is returned if def has neither a prefix nor an ASN.
+func (d *LocalDefinitions) Add(def *LocalDefinition) error {
+	if !def.Prefix.IsValid() {
+		if len(def.ASN) == 0 {
+			return ErrLocalDefinitionTarget
+		}
+		d.asns[def.ASN.Uint32()] = def
+		return nil
+	}
+	d.prefixes = append(d.prefixes, def)
+	sort.SliceStable(d.prefixes, func(i, j int) bool {
+		return d.prefixes[i].Prefix.Bits() > d.prefixes[j].Prefix.Bits()
+	})
+	return nil
+}
+
+// LookupAddr returns the most specific prefix definition containing a.
+func (d *LocalDefinitions) LookupAddr(a netip.Addr) *LocalDefinition {
+	for _, def := range d.prefixes {
+		if def.Prefix.Contains(a) {
+			return def
+		}
+	}
+	return nil
+}
+
+// LookupPrefix returns the most specific prefix definition covering all of p.
+func (d *LocalDefinitions) LookupPrefix(p netip.Prefix) *LocalDefinition {
+	for _, def := range d.prefixes {
+		if def.Prefix.Bits() <= p.Bits() && def.Prefix.Contains(p.Addr()) {
+			return def
+		}
+	}
+	return nil
+}
+
+func (d *LocalDefinitions) LookupASN(asn goasn.ASN) *LocalDefinition {
+	return d.asns[asn.Uint32()]
+}
+
+func (d *LocalDefinitions) Len() int {
+	return len(d.prefixes) + len(d.asns)
+}
+
+// ParseLocalDefinitions parses local definitions in YAML, JSON or CSV format. YAML & JSON files
+// have top-level 'prefixes' and 'asns' lists. CSV files have a header row with a 'target' column
+// containing a prefix, IP address or ASN, and optional 'asn', 'name', 'site', 'owner' and 'tags'
+// columns. Tags are separated by ';'.
+func ParseLocalDefinitions(r io.Reader, format string) (*LocalDefinitions, error) {
+	var entries []localDefinitionEntry
+	var err error
+	switch format {
+	case FORMAT_YAML, FORMAT_JSON:
+		entries, err = parseLocalDefinitionsDocument(r, format)
+	case FORMAT_CSV:
+		entries, err = parseLocalDefinitionsCSV(r)
+	default:
+		err = fmt.Errorf("unsupported local definitions format '%s'", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defs := NewLocalDefinitions()
+	for _, e := range entries {
+		def, err := e.definition()
+		if err == nil {
+			err = defs.Add(def)
+		}
+		if err != nil && e.line != 0 {
+			return nil, fmt.Errorf("line %d: %w", e.line, err)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return defs, nil
+}
+
+// LoadLocalDefinitions loads local definitions from a file, using the file extension to
+// determine the format.
+func LoadLocalDefinitions(path string) (*LocalDefinitions, error) {
+	format, err := localDefinitionsFormat(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	defs, err := ParseLocalDefinitions(f, format)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse '%s': %w", path, err)
+	}
+	return defs, nil
+}
+
+func localDefinitionsFormat(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return FORMAT_YAML, nil
+	case ".json":
+		return FORMAT_JSON, nil
+	case ".csv":
+		return FORMAT_CSV, nil
+	default:
+		return "", fmt.Errorf("unable to determine local definitions format of '%s'", path)
+	}
+}
+
+func parseLocalDefinitionsDocument(r io.Reader, format string) ([]localDefinitionEntry, error) {
+	doc := localDefinitionsFile{}
+	var err error
+	if format == FORMAT_JSON {
+		err = json.NewDecoder(r).Decode(&doc)
+	} else {
+		err = yaml.NewDecoder(r).Decode(&doc)
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	for i, e := range doc.Prefixes {
+		if e.Prefix == "" {
+			return nil, fmt.Errorf("prefix definition %d is missing 'prefix'", i)
+		}
+	}
+	for i, e := range doc.ASNs {
+		if e.ASN == "" {
+			return nil, fmt.Errorf("ASN definition %d is missing 'asn'", i)
+		}
+		doc.ASNs[i].Prefix = ""
+	}
+	return append(doc.Prefixes, doc.ASNs...), nil
+}
+
+func parseLocalDefinitionsCSV(r io.Reader) ([]localDefinitionEntry, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+	reader.Comment = '#'
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := map[string]int{}
+	for i, h := range header {
+		columns[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	if _, ok := columns["target"]; !ok {
+		return nil, ErrLocalDefinitionsHeader
+	}
+	column := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+	entries := []localDefinitionEntry{}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		e := localDefinitionEntry{
+			line:  line,
+			ASN:   asnValue(column(record, "asn")),
+			Name:  column(record, "name"),
+			Site:  column(record, "site"),
+			Owner: column(record, "owner"),
+		}
+		for _, tag := range strings.Split(column(record, "tags"), ";") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				e.Tags = append(e.Tags, tag)
+			}
+		}
+		target := column(record, "target")
+		if strings.ContainsAny(target, ".:") && !isASDot(target) {
+			e.Prefix = target
+		} else {
+			e.ASN = asnValue(target)
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+func (e localDefinitionEntry) definition() (*LocalDefinition, error) {
+	def := &LocalDefinition{
+		Name:  e.Name,
+		Site:  e.Site,
+		Owner: e.Owner,
+		Tags:  e.Tags,
+	}
+	if e.Prefix != "" {
+		pfx, err := parsePrefixOrAddr(e.Prefix)
+		if err != nil {
+			return nil, err
+		}
+		def.Prefix = pfx
+	}
+	if e.ASN != "" {
+		asn, err := ParseASN(string(e.ASN))
+		if err != nil {
+			return nil, err
+		}
+		def.ASN = asn
+	}
+	return def, nil
+}
+
+func parsePrefixOrAddr(in string) (netip.Prefix, error) {
+	if a, err := netip.ParseAddr(in); err == nil {
+		return netip.PrefixFrom(a, a.BitLen()), nil
+	}
+	pfx, err := netip.ParsePrefix(in)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return pfx.Masked(), nil
+}
+
+// isASDot returns true if in looks like an asdot ASN, e.g. AS1.10, rather than an IP address.
+func isASDot(in string) bool {
+	return strings.Count(in, ".") == 1 && !strings.Contains(in, "/") && !strings.Contains(in, ":")
+}
@@ -0,0 +1,207 @@
+package addr_test
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	addr "github.com/thatmattlove/addr/pkg"
+	goasn "github.com/thatmattlove/go-asn"
+)
+
+const (
+	LOCAL_YAML string = `prefixes:
+  - prefix: 10.20.0.0/16
+    name: DC-East mgmt
+    site: dc-east
+    owner: netops
+    tags: [mgmt, oob]
+  - prefix: 10.20.30.0/24
+    name: DC-East OOB
+    asn: 4200001234
+  - prefix: 192.0.2.1
+    name: Jump host
+asns:
+  - asn: AS4200001234
+    name: Private fabric
+`
+	LOCAL_JSON string = `{
+  "prefixes": [{"prefix": "2001:db8::/48", "name": "Lab", "tags": ["lab"]}],
+  "asns": [{"asn": 64512, "name": "Lab fabric"}]
+}`
+	LOCAL_CSV string = `target,asn,name,site,owner,tags
+# Comments are ignored.
+10.20.0.0/16,,DC-East mgmt,dc-east,netops,mgmt;oob
+AS4200001234,,Private fabric,,netops,
+1.10,,ASDot fabric,,,
+`
+)
+
+func Test_ParseLocalDefinitions(t *testing.T) {
+	t.Run("yaml", func(t *testing.T) {
+		t.Parallel()
+		defs, err := addr.ParseLocalDefinitions(strings.NewReader(LOCAL_YAML), addr.FORMAT_YAML)
+		assert.NoError(t, err)
+		assert.Equal(t, 4, defs.Len())
+		d := defs.LookupAddr(netip.MustParseAddr("10.20.1.1"))
+		assert.NotNil(t, d)
+		assert.Equal(t, "DC-East mgmt", d.Name)
+		assert.Equal(t, "dc-east", d.Site)
+		assert.Equal(t, "netops", d.Owner)
+		assert.Equal(t, []string{"mgmt", "oob"}, d.Tags)
+		d = defs.LookupASN(goasn.MustParse("4200001234"))
+		assert.NotNil(t, d)
+		assert.Equal(t, "Private fabric", d.Name)
+		d = defs.LookupAddr(netip.MustParseAddr("192.0.2.1"))
+		assert.NotNil(t, d)
+		assert.Equal(t, 32, d.Prefix.Bits())
+	})
+	t.Run("json", func(t *testing.T) {
+		t.Parallel()
+		defs, err := addr.ParseLocalDefinitions(strings.NewReader(LOCAL_JSON), addr.FORMAT_JSON)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, defs.Len())
+		assert.NotNil(t, defs.LookupAddr(netip.MustParseAddr("2001:db8::1")))
+		assert.NotNil(t, defs.LookupASN(goasn.MustParse("64512")))
+	})
+	t.Run("csv", func(t *testing.T) {
+		t.Parallel()
+		defs, err := addr.ParseLocalDefinitions(strings.NewReader(LOCAL_CSV), addr.FORMAT_CSV)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, defs.Len())
+		d := defs.LookupAddr(netip.MustParseAddr("10.20.1.1"))
+		assert.NotNil(t, d)
+		assert.Equal(t, []string{"mgmt", "oob"}, d.Tags)
+		assert.NotNil(t, defs.LookupASN(goasn.MustParse("4200001234")))
+		assert.NotNil(t, defs.LookupASN(goasn.MustParse("65546")))
+	})
+	t.Run("csv missing target", func(t *testing.T) {
+		t.Parallel()
+		_, err := addr.ParseLocalDefinitions(strings.NewReader("prefix,name\n"), addr.FORMAT_CSV)
+		assert.ErrorIs(t, err, addr.ErrLocalDefinitionsHeader)
+	})
+	t.Run("csv empty target", func(t *testing.T) {
+		t.Parallel()
+		_, err := addr.ParseLocalDefinitions(strings.NewReader("target,name\n10.0.0.0/8,ok\n# skipped\n,Nameless\n"), addr.FORMAT_CSV)
+		assert.ErrorIs(t, err, addr.ErrLocalDefinitionTarget)
+		assert.ErrorContains(t, err, "line 4:")
+		_, err = addr.ParseLocalDefinitions(strings.NewReader("target,name\n10.0.0.0/33,bad\n"), addr.FORMAT_CSV)
+		assert.ErrorContains(t, err, "line 2:")
+	})
+	t.Run("invalid prefix", func(t *testing.T) {
+		t.Parallel()
+		_, err := addr.ParseLocalDefinitions(strings.NewReader("prefixes:\n  - prefix: nope\n"), addr.FORMAT_YAML)
+		assert.Error(t, err)
+	})
+	t.Run("invalid asn", func(t *testing.T) {
+		t.Parallel()
+		_, err := addr.ParseLocalDefinitions(strings.NewReader(`{"asns": [{"asn": "ASnope"}]}`), addr.FORMAT_JSON)
+		assert.Error(t, err)
+	})
+	t.Run("missing prefix", func(t *testing.T) {
+		t.Parallel()
+		_, err := addr.ParseLocalDefinitions(strings.NewReader("prefixes:\n  - name: nothing\n"), addr.FORMAT_YAML)
+		assert.Error(t, err)
+	})
+	t.Run("unsupported format", func(t *testing.T) {
+		t.Parallel()
+		_, err := addr.ParseLocalDefinitions(strings.NewReader(""), "toml")
+		assert.Error(t, err)
+	})
+	t.Run("empty", func(t *testing.T) {
+		t.Parallel()
+		defs, err := addr.ParseLocalDefinitions(strings.NewReader(""), addr.FORMAT_YAML)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, defs.Len())
+	})
+}
+
+func Test_NewLocalDefinitions(t *testing.T) {
+	t.Parallel()
+	defs := addr.NewLocalDefinitions(&addr.LocalDefinition{Name: "nowhere"}, &addr.LocalDefinition{ASN: goasn.MustParse("64512")})
+	assert.Equal(t, 1, defs.Len())
+	assert.ErrorIs(t, defs.Add(&addr.LocalDefinition{Name: "nowhere"}), addr.ErrLocalDefinitionTarget)
+}
+
+func TestLocalDefinitions_Lookup(t *testing.T) {
+	defs, _ := addr.ParseLocalDefinitions(strings.NewReader(LOCAL_YAML), addr.FORMAT_YAML)
+	t.Run("longest match", func(t *testing.T) {
+		t.Parallel()
+		d := defs.LookupAddr(netip.MustParseAddr("10.20.30.40"))
+		assert.NotNil(t, d)
+		assert.Equal(t, "DC-East OOB", d.Name)
+		assert.Equal(t, uint32(4200001234), d.ASN.Uint32())
+	})
+	t.Run("prefix covered", func(t *testing.T) {
+		t.Parallel()
+		d := defs.LookupPrefix(netip.MustParsePrefix("10.20.30.0/25"))
+		assert.Equal(t, "DC-East OOB", d.Name)
+		d = defs.LookupPrefix(netip.MustParsePrefix("10.20.0.0/17"))
+		assert.Equal(t, "DC-East mgmt", d.Name)
+	})
+	t.Run("prefix not covered", func(t *testing.T) {
+		t.Parallel()
+		assert.Nil(t, defs.LookupPrefix(netip.MustParsePrefix("10.0.0.0/8")))
+	})
+	t.Run("no match", func(t *testing.T) {
+		t.Parallel()
+		assert.Nil(t, defs.LookupAddr(netip.MustParseAddr("10.21.0.1")))
+		assert.Nil(t, defs.LookupASN(goasn.MustParse("14525")))
+	})
+}
+
+func Test_LoadLocalDefinitions(t *testing.T) {
+	t.Run("by extension", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		for name, data := range map[string]string{"defs.yml": LOCAL_YAML, "defs.json": LOCAL_JSON, "defs.csv": LOCAL_CSV} {
+			f := filepath.Join(dir, name)
+			assert.NoError(t, os.WriteFile(f, []byte(data), 0o644))
+			defs, err := addr.LoadLocalDefinitions(f)
+			assert.NoError(t, err, name)
+			assert.Greater(t, defs.Len(), 0, name)
+		}
+	})
+	t.Run("unknown extension", func(t *testing.T) {
+		t.Parallel()
+		_, err := addr.LoadLocalDefinitions(filepath.Join(t.TempDir(), "defs.txt"))
+		assert.Error(t, err)
+	})
+	t.Run("missing file", func(t *testing.T) {
+		t.Parallel()
+		_, err := addr.LoadLocalDefinitions(filepath.Join(t.TempDir(), "defs.yaml"))
+		assert.Error(t, err)
+	})
+}
+
+func Test_LocalDefinitionsPrecedence(t *testing.T) {
+	original := addr.LOCAL_DEFINITIONS
+	defer func() { addr.LOCAL_DEFINITIONS = original }()
+	defs, _ := addr.ParseLocalDefinitions(strings.NewReader(LOCAL_YAML), addr.FORMAT_YAML)
+	addr.LOCAL_DEFINITIONS = defs
+
+	v, _ := addr.NewIPValidator("10.20.1.1")
+	shouldQuery, res := v.Validate()
+	assert.False(t, shouldQuery)
+	assert.Equal(t, "DC-East mgmt", res.Name)
+	assert.Equal(t, addr.REGISTRY_LOCAL, res.Registry)
+	assert.Equal(t, netip.MustParsePrefix("10.20.0.0/16"), res.Network)
+	assert.NotNil(t, res.Local)
+
+	v, _ = addr.NewIPValidator("192.0.2.1")
+	_, res = v.Validate()
+	assert.Equal(t, "Jump host", res.Name)
+
+	asv, _ := addr.NewASNValidator("4200001234")
+	shouldQuery, res = asv.Validate()
+	assert.False(t, shouldQuery)
+	assert.Equal(t, "Private fabric", res.Name)
+	assert.Equal(t, addr.REGISTRY_LOCAL, res.Registry)
+
+	v, _ = addr.NewIPValidator("10.21.0.1")
+	_, res = v.Validate()
+	assert.Equal(t, addr.REGISTRY_IANA, res.Registry)
+}
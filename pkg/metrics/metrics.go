@@ -0,0 +1,45 @@
+// Package metrics lets library users observe lookups. Set HOOK to a Metrics implementation, such
+// as the Prometheus one in pkg/metrics/prom, before making any lookups.
+package metrics
+
+import "time"
+
+const (
+	PROVIDER_WHOIS string = "whois"
+	PROVIDER_DNS   string = "dns"
+)
+
+const (
+	RESULT_SUCCESS string = "success"
+	RESULT_ERROR   string = "error"
+)
+
+// Metrics receives lookup events. Implementations must be safe for concurrent use.
+type Metrics interface {
+	// Query is called after every upstream query. queryType is 'ip', 'asn' or 'bulk' for whois
+	// queries ('connect' if the connection fails), and the record type for DNS queries. received is the
+	// response size in bytes.
+	Query(provider, queryType string, err error, duration time.Duration, received int)
+	// ParseFailure is called when a provider's response can't be parsed.
+	ParseFailure(provider string, err error)
+	// Cache is called on every lookup in a caching layer, such as the one used by 'addr serve'.
+	Cache(name string, hit bool)
+}
+
+// HOOK receives metrics from every lookup. It does nothing by default.
+var HOOK Metrics = Noop{}
+
+// Noop is a Metrics implementation that discards everything.
+type Noop struct{}
+
+func (Noop) Query(string, string, error, time.Duration, int) {}
+func (Noop) ParseFailure(string, error)                      {}
+func (Noop) Cache(string, bool)                              {}
+
+// Result returns the result label for err.
+func Result(err error) string {
+	if err != nil {
+		return RESULT_ERROR
+	}
+	return RESULT_SUCCESS
+}
@@ -0,0 +1,85 @@
+// Package prom implements metrics.Metrics with Prometheus collectors.
+package prom
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/thatmattlove/addr/pkg/metrics"
+)
+
+const NAMESPACE string = "addr"
+
+var _ metrics.Metrics = (*Metrics)(nil)
+
+type Metrics struct {
+	queries       *prometheus.CounterVec
+	duration      *prometheus.HistogramVec
+	received      *prometheus.CounterVec
+	parseFailures *prometheus.CounterVec
+	cache         *prometheus.CounterVec
+}
+
+// New creates Prometheus metrics and registers them with reg.
+func New(reg prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		queries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: NAMESPACE,
+			Name:      "queries_total",
+			Help:      "Upstream queries by provider, query type & result.",
+		}, []string{"provider", "type", "result"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: NAMESPACE,
+			Name:      "query_duration_seconds",
+			Help:      "Upstream query latency by provider & query type.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"provider", "type"}),
+		received: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: NAMESPACE,
+			Name:      "received_bytes_total",
+			Help:      "Bytes received from upstream queries by provider.",
+		}, []string{"provider"}),
+		parseFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: NAMESPACE,
+			Name:      "parse_failures_total",
+			Help:      "Upstream responses that couldn't be parsed, by provider.",
+		}, []string{"provider"}),
+		cache: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: NAMESPACE,
+			Name:      "cache_requests_total",
+			Help:      "Cache lookups by cache & result (hit or miss).",
+		}, []string{"cache", "result"}),
+	}
+	reg.MustRegister(m.queries, m.duration, m.received, m.parseFailures, m.cache)
+	return m
+}
+
+func (m *Metrics) Query(provider, queryType string, err error, duration time.Duration, received int) {
+	m.queries.WithLabelValues(provider, queryType, metrics.Result(err)).Inc()
+	m.duration.WithLabelValues(provider, queryType).Observe(duration.Seconds())
+	m.received.WithLabelValues(provider).Add(float64(received))
+}
+
+func (m *Metrics) ParseFailure(provider string, err error) {
+	m.parseFailures.WithLabelValues(provider).Inc()
+}
+
+func (m *Metrics) Cache(name string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	m.cache.WithLabelValues(name, result).Inc()
+}
+
+// Handler creates a registry with addr's metrics plus the Go runtime & process collectors, and
+// returns the metrics along with an HTTP handler serving them.
+func Handler() (*Metrics, http.Handler) {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	m := New(reg)
+	return m, promhttp.HandlerFor(reg, promhttp.HandlerOpts{Registry: reg})
+}
@@ -0,0 +1,45 @@
+package prom_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thatmattlove/addr/pkg/metrics"
+	"github.com/thatmattlove/addr/pkg/metrics/prom"
+)
+
+func Test_Handler(t *testing.T) {
+	m, h := prom.Handler()
+	m.Query(metrics.PROVIDER_WHOIS, "ip", nil, time.Millisecond*50, 512)
+	m.Query(metrics.PROVIDER_WHOIS, "ip", errors.New("timeout"), time.Second, 0)
+	m.Query(metrics.PROVIDER_DNS, "PTR", nil, time.Millisecond, 100)
+	m.ParseFailure(metrics.PROVIDER_WHOIS, errors.New("empty response"))
+	m.Cache("server", true)
+	m.Cache("server", true)
+	m.Cache("server", false)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	b, _ := io.ReadAll(rec.Body)
+	body := string(b)
+	for _, line := range []string{
+		`addr_queries_total{provider="whois",result="success",type="ip"} 1`,
+		`addr_queries_total{provider="whois",result="error",type="ip"} 1`,
+		`addr_queries_total{provider="dns",result="success",type="PTR"} 1`,
+		`addr_query_duration_seconds_count{provider="whois",type="ip"} 2`,
+		`addr_received_bytes_total{provider="whois"} 512`,
+		`addr_received_bytes_total{provider="dns"} 100`,
+		`addr_parse_failures_total{provider="whois"} 1`,
+		`addr_cache_requests_total{cache="server",result="hit"} 2`,
+		`addr_cache_requests_total{cache="server",result="miss"} 1`,
+		`go_goroutines`,
+	} {
+		assert.Contains(t, body, line)
+	}
+}
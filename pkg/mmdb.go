@@ -0,0 +1,185 @@
+package addr
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+
+	"github.com/biter777/countries"
+	"github.com/oschwald/maxminddb-golang"
+	goasn "github.com/thatmattlove/go-asn"
+)
+
+const REGISTRY_MMDB string = "MMDB"
+
+var (
+	ErrOfflineASN = errors.New("ASN lookups aren't available offline")
+	ErrOfflineDNS = errors.New("DNS lookups aren't available offline")
+)
+
+// GeoLocation is the location of an IP address from an MMDB database such as GeoLite2-City.
+type GeoLocation struct {
+	City string
+	// Region is the largest subdivision, e.g. a state or province.
+	Region     string
+	RegionCode string
+	Country    countries.CountryCode
+	Latitude   float64
+	Longitude  float64
+	// AccuracyRadius is in kilometers.
+	AccuracyRadius uint16
+	TimeZone       string
+}
+
+// MMDBASN is the origin of an IP address from an MMDB database such as GeoLite2-ASN, which may
+// differ from what is currently advertised.
+type MMDBASN struct {
+	ASN     goasn.ASN
+	Name    string
+	Network netip.Prefix
+}
+
+// MMDB looks up IP addresses in one or more MMDB databases. Each database may have location
+// fields, ASN fields or both, in the GeoIP2/GeoLite2 schema.
+type MMDB struct {
+	readers []*maxminddb.Reader
+}
+
+type mmdbNames struct {
+	Names map[string]string `maxminddb:"names"`
+}
+
+// mmdbRecord is the union of the GeoIP2 City, Country & ASN schemas.
+type mmdbRecord struct {
+	City    mmdbNames `maxminddb:"city"`
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	Subdivisions []struct {
+		ISOCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"subdivisions"`
+	Location struct {
+		Latitude       *float64 `maxminddb:"latitude"`
+		Longitude      *float64 `maxminddb:"longitude"`
+		AccuracyRadius uint16   `maxminddb:"accuracy_radius"`
+		TimeZone       string   `maxminddb:"time_zone"`
+	} `maxminddb:"location"`
+	ASN          uint32 `maxminddb:"autonomous_system_number"`
+	Organization string `maxminddb:"autonomous_system_organization"`
+}
+
+func NewMMDB() *MMDB {
+	return &MMDB{readers: []*maxminddb.Reader{}}
+}
+
+// OpenMMDB opens MMDB databases. When databases have the same fields, the first to have a value
+// for an address is used.
+func OpenMMDB(paths ...string) (*MMDB, error) {
+	m := NewMMDB()
+	for _, p := range paths {
+		r, err := maxminddb.Open(p)
+		if err != nil {
+			m.Close()
+			return nil, fmt.Errorf("%s: %w", p, err)
+		}
+		m.readers = append(m.readers, r)
+	}
+	return m, nil
+}
+
+func (m *MMDB) Len() int {
+	return len(m.readers)
+}
+
+func (m *MMDB) Close() error {
+	var err error
+	for _, r := range m.readers {
+		if e := r.Close(); e != nil {
+			err = e
+		}
+	}
+	m.readers = []*maxminddb.Reader{}
+	return err
+}
+
+// Lookup returns the location & MMDB ASN of an address, either of which is nil if no database
+// has it.
+func (m *MMDB) Lookup(a netip.Addr) (*GeoLocation, *MMDBASN) {
+	var loc *GeoLocation
+	var asn *MMDBASN
+	ip := IPFromAddr(a.Unmap())
+	for _, r := range m.readers {
+		rec := mmdbRecord{}
+		network, ok, err := r.LookupNetwork(ip, &rec)
+		if err != nil || !ok {
+			continue
+		}
+		if loc == nil {
+			loc = rec.location()
+		}
+		if asn == nil && rec.ASN != 0 {
+			asn = &MMDBASN{ASN: goasn.FromUint32(rec.ASN), Name: rec.Organization}
+			if network != nil {
+				asn.Network = PrefixFromIPNet(network)
+			}
+		}
+	}
+	return loc, asn
+}
+
+// location returns nil if the record has no location fields.
+func (rec mmdbRecord) location() *GeoLocation {
+	loc := &GeoLocation{
+		City:           rec.City.Names["en"],
+		Country:        countries.Unknown,
+		AccuracyRadius: rec.Location.AccuracyRadius,
+		TimeZone:       rec.Location.TimeZone,
+	}
+	if rec.Country.ISOCode != "" {
+		loc.Country = countries.ByName(rec.Country.ISOCode)
+	}
+	if len(rec.Subdivisions) > 0 {
+		loc.Region = rec.Subdivisions[0].Names["en"]
+		loc.RegionCode = rec.Subdivisions[0].ISOCode
+	}
+	hasCoordinates := rec.Location.Latitude != nil && rec.Location.Longitude != nil
+	if hasCoordinates {
+		loc.Latitude, loc.Longitude = *rec.Location.Latitude, *rec.Location.Longitude
+	}
+	if loc.City == "" && loc.Region == "" && loc.Country == countries.Unknown && !hasCoordinates {
+		return nil
+	}
+	return loc
+}
+
+// Response builds a lookup response from MMDB data alone, for offline lookups.
+func (m *MMDB) Response(ipv *IPValidator) (*Response, error) {
+	loc, asn := m.Lookup(ipv.Addr)
+	if loc == nil && asn == nil {
+		return nil, fmt.Errorf("no MMDB data for %s", ipv.Addr)
+	}
+	res := &Response{
+		ASN:      goasn.FromUint32(0),
+		IP:       &ipv.IP,
+		Addr:     ipv.Addr,
+		Network:  ipv.Network,
+		Registry: REGISTRY_MMDB,
+		Country:  countries.Unknown,
+		Geo:      loc,
+		GeoASN:   asn,
+	}
+	if asn != nil {
+		res.ASN, res.Name = asn.ASN, asn.Name
+		if !res.Network.IsValid() {
+			res.Network = asn.Network
+		}
+	}
+	if loc != nil {
+		res.Country = loc.Country
+	}
+	if res.Network.IsValid() {
+		res.Prefix = IPNetFromPrefix(res.Network)
+	}
+	return res, nil
+}
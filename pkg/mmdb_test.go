@@ -0,0 +1,321 @@
+package addr_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/biter777/countries"
+	"github.com/stretchr/testify/assert"
+	addr "github.com/thatmattlove/addr/pkg"
+)
+
+// mmdbNode is a node of an MMDB search tree. Each record is either a child node, data, or empty.
+type mmdbNode struct {
+	children [2]*mmdbNode
+	data     [2]any
+}
+
+// writeMMDB writes an IPv6 MMDB database with 24 bit records, in the format described by
+// https://maxmind.github.io/MaxMind-DB/. IPv4 networks are stored in the IPv4-compatible ::/96
+// subtree.
+func writeMMDB(t *testing.T, dbType string, networks map[string]map[string]any) string {
+	root := &mmdbNode{}
+	for n, data := range networks {
+		p := netip.MustParsePrefix(n)
+		bits := p.Bits()
+		a := p.Addr().As16()
+		if p.Addr().Is4() {
+			a = [16]byte{}
+			copy(a[12:], p.Addr().AsSlice())
+			bits += 96
+		}
+		node := root
+		for i := 0; i < bits; i++ {
+			bit := (a[i/8] >> (7 - i%8)) & 1
+			if i == bits-1 {
+				node.data[bit] = data
+				break
+			}
+			if node.children[bit] == nil {
+				node.children[bit] = &mmdbNode{}
+			}
+			node = node.children[bit]
+		}
+	}
+	nodes := []*mmdbNode{}
+	var number func(n *mmdbNode)
+	number = func(n *mmdbNode) {
+		nodes = append(nodes, n)
+		for _, c := range n.children {
+			if c != nil {
+				number(c)
+			}
+		}
+	}
+	number(root)
+	index := map[*mmdbNode]int{}
+	for i, n := range nodes {
+		index[n] = i
+	}
+	data := &bytes.Buffer{}
+	tree := &bytes.Buffer{}
+	for _, n := range nodes {
+		for bit := 0; bit < 2; bit++ {
+			record := len(nodes)
+			if c := n.children[bit]; c != nil {
+				record = index[c]
+			} else if d := n.data[bit]; d != nil {
+				record = len(nodes) + 16 + data.Len()
+				encodeMMDB(data, d)
+			}
+			tree.Write([]byte{byte(record >> 16), byte(record >> 8), byte(record)})
+		}
+	}
+	out := &bytes.Buffer{}
+	out.Write(tree.Bytes())
+	out.Write(make([]byte, 16))
+	out.Write(data.Bytes())
+	out.WriteString("\xab\xcd\xefMaxMind.com")
+	encodeMMDB(out, map[string]any{
+		"node_count":                  uint32(len(nodes)),
+		"record_size":                 uint16(24),
+		"ip_version":                  uint16(6),
+		"database_type":               dbType,
+		"languages":                   []any{"en"},
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(1760868000),
+		"description":                 map[string]any{"en": "addr test database"},
+	})
+	path := filepath.Join(t.TempDir(), dbType+".mmdb")
+	assert.NoError(t, os.WriteFile(path, out.Bytes(), 0o644))
+	return path
+}
+
+// encodeMMDB encodes a value in the MMDB data section format.
+func encodeMMDB(b *bytes.Buffer, v any) {
+	control := func(typ, size int) {
+		ext := typ > 7
+		first := typ << 5
+		if ext {
+			first = 0
+		}
+		switch {
+		case size < 29:
+			b.WriteByte(byte(first | size))
+			if ext {
+				b.WriteByte(byte(typ - 7))
+			}
+		default:
+			b.WriteByte(byte(first | 29))
+			if ext {
+				b.WriteByte(byte(typ - 7))
+			}
+			b.WriteByte(byte(size - 29))
+		}
+	}
+	encodeUint := func(typ int, n uint64) {
+		buf := make([]byte, 8)
+		binary.BigEndian.PutUint64(buf, n)
+		buf = bytes.TrimLeft(buf, "\x00")
+		control(typ, len(buf))
+		b.Write(buf)
+	}
+	switch v := v.(type) {
+	case string:
+		control(2, len(v))
+		b.WriteString(v)
+	case float64:
+		control(3, 8)
+		binary.Write(b, binary.BigEndian, math.Float64bits(v))
+	case uint16:
+		encodeUint(5, uint64(v))
+	case uint32:
+		encodeUint(6, uint64(v))
+	case uint64:
+		encodeUint(9, v)
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		control(7, len(v))
+		for _, k := range keys {
+			encodeMMDB(b, k)
+			encodeMMDB(b, v[k])
+		}
+	case []any:
+		control(11, len(v))
+		for _, e := range v {
+			encodeMMDB(b, e)
+		}
+	default:
+		panic("unsupported MMDB type")
+	}
+}
+
+func mmdbCity(city, region, regionCode, country string, lat, long float64) map[string]any {
+	return map[string]any{
+		"city":         map[string]any{"names": map[string]any{"en": city}},
+		"country":      map[string]any{"iso_code": country},
+		"subdivisions": []any{map[string]any{"iso_code": regionCode, "names": map[string]any{"en": region}}},
+		"location": map[string]any{
+			"latitude":        lat,
+			"longitude":       long,
+			"accuracy_radius": uint16(1000),
+			"time_zone":       "America/Los_Angeles",
+		},
+	}
+}
+
+func mmdbASN(asn uint32, org string) map[string]any {
+	return map[string]any{"autonomous_system_number": asn, "autonomous_system_organization": org}
+}
+
+// openTestMMDB opens GeoLite2-City & GeoLite2-ASN style test databases.
+func openTestMMDB(t *testing.T) *addr.MMDB {
+	city := writeMMDB(t, "GeoLite2-City", map[string]map[string]any{
+		"1.1.1.0/24":     mmdbCity("San Francisco", "California", "CA", "US", 37.7749, -122.4194),
+		"2606:4700::/32": mmdbCity("San Francisco", "California", "CA", "US", 37.7749, -122.4194),
+		"9.9.9.0/24":     {"country": map[string]any{"iso_code": "CH"}},
+	})
+	asn := writeMMDB(t, "GeoLite2-ASN", map[string]map[string]any{
+		"1.1.1.0/24":     mmdbASN(13335, "CLOUDFLARENET"),
+		"2606:4700::/32": mmdbASN(13335, "CLOUDFLARENET"),
+		"8.8.8.0/24":     mmdbASN(15169, "GOOGLE"),
+	})
+	m, err := addr.OpenMMDB(city, asn)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(func() { m.Close() })
+	return m
+}
+
+func TestMMDB_Lookup(t *testing.T) {
+	m := openTestMMDB(t)
+	assert.Equal(t, 2, m.Len())
+	t.Run("city & asn", func(t *testing.T) {
+		t.Parallel()
+		loc, asn := m.Lookup(netip.MustParseAddr("1.1.1.1"))
+		assert.Equal(t, &addr.GeoLocation{
+			City:           "San Francisco",
+			Region:         "California",
+			RegionCode:     "CA",
+			Country:        countries.USA,
+			Latitude:       37.7749,
+			Longitude:      -122.4194,
+			AccuracyRadius: 1000,
+			TimeZone:       "America/Los_Angeles",
+		}, loc)
+		assert.Equal(t, "13335", asn.ASN.ASPlain())
+		assert.Equal(t, "CLOUDFLARENET", asn.Name)
+		assert.Equal(t, "1.1.1.0/24", asn.Network.String())
+	})
+	t.Run("ipv6", func(t *testing.T) {
+		t.Parallel()
+		loc, asn := m.Lookup(netip.MustParseAddr("2606:4700:4700::1111"))
+		assert.Equal(t, "San Francisco", loc.City)
+		assert.Equal(t, "2606:4700::/32", asn.Network.String())
+	})
+	t.Run("asn only", func(t *testing.T) {
+		t.Parallel()
+		loc, asn := m.Lookup(netip.MustParseAddr("8.8.8.8"))
+		assert.Nil(t, loc)
+		assert.Equal(t, "GOOGLE", asn.Name)
+	})
+	t.Run("country only", func(t *testing.T) {
+		t.Parallel()
+		loc, asn := m.Lookup(netip.MustParseAddr("9.9.9.9"))
+		assert.Equal(t, countries.Switzerland, loc.Country)
+		assert.Zero(t, loc.Latitude)
+		assert.Nil(t, asn)
+	})
+	t.Run("missing", func(t *testing.T) {
+		t.Parallel()
+		loc, asn := m.Lookup(netip.MustParseAddr("192.0.2.1"))
+		assert.Nil(t, loc)
+		assert.Nil(t, asn)
+	})
+}
+
+func TestMMDB_Response(t *testing.T) {
+	m := openTestMMDB(t)
+	t.Run("found", func(t *testing.T) {
+		t.Parallel()
+		v, _ := addr.NewIPValidator("1.1.1.1")
+		r, err := m.Response(v)
+		assert.NoError(t, err)
+		assert.Equal(t, "13335", r.ASN.ASPlain())
+		assert.Equal(t, "CLOUDFLARENET", r.Name)
+		assert.Equal(t, "1.1.1.0/24", r.Network.String())
+		assert.Equal(t, countries.USA, r.Country)
+		assert.Equal(t, addr.REGISTRY_MMDB, r.Registry)
+		assert.False(t, r.FromQuery)
+		res := addr.NewIPResult("1.1.1.1", r, nil)
+		assert.Equal(t, "San Francisco", res.Column("city"))
+		assert.Equal(t, "California", res.Column("region"))
+		assert.Equal(t, "37.7749,-122.4194", res.Column("coordinates"))
+		assert.Equal(t, "13335", res.Column("mmdb_asn"))
+		assert.Equal(t, "CLOUDFLARENET", res.Column("mmdb_name"))
+	})
+	t.Run("json", func(t *testing.T) {
+		t.Parallel()
+		v, _ := addr.NewIPValidator("1.1.1.1")
+		r, err := m.Response(v)
+		assert.NoError(t, err)
+		b, err := json.Marshal(addr.NewIPResult("1.1.1.1", r, nil))
+		assert.NoError(t, err)
+		assert.Contains(t, string(b), `"geo":{"city":"San Francisco","region":"California","region_code":"CA","country":"US","latitude":37.7749,"longitude":-122.4194,"accuracy_radius":1000,"time_zone":"America/Los_Angeles"},"mmdb_asn":{"asn":13335,"name":"CLOUDFLARENET","network":"1.1.1.0/24"}`)
+		res := &addr.Result{}
+		assert.NoError(t, json.Unmarshal(b, res))
+		assert.Equal(t, r.Geo, res.Geo)
+		assert.Equal(t, r.GeoASN, res.GeoASN)
+	})
+	t.Run("prefix", func(t *testing.T) {
+		t.Parallel()
+		v, _ := addr.NewIPValidator("8.8.8.0/25")
+		r, err := m.Response(v)
+		assert.NoError(t, err)
+		assert.Equal(t, "8.8.8.0/25", r.Network.String())
+	})
+	t.Run("missing", func(t *testing.T) {
+		t.Parallel()
+		v, _ := addr.NewIPValidator("203.0.114.1")
+		_, err := m.Response(v)
+		assert.ErrorContains(t, err, "no MMDB data for 203.0.114.1")
+	})
+}
+
+func Test_OpenMMDB_Error(t *testing.T) {
+	t.Parallel()
+	_, err := addr.OpenMMDB(filepath.Join(t.TempDir(), "missing.mmdb"))
+	assert.Error(t, err)
+}
+
+func Test_QueryIPPrefixWith_Offline(t *testing.T) {
+	t.Parallel()
+	opts := addr.QueryOptions{Offline: true, MMDB: openTestMMDB(t)}
+	r, err := addr.QueryIPPrefixWith(nil, "1.1.1.1", opts)
+	assert.NoError(t, err)
+	assert.Equal(t, "CLOUDFLARENET", r.Name)
+	assert.Equal(t, "San Francisco", r.Geo.City)
+
+	r, err = addr.QueryIPPrefixWith(nil, "10.0.0.1", opts)
+	assert.NoError(t, err)
+	assert.Equal(t, addr.TXT_PRIVATE, r.Name, "special-purpose prefixes are still answered")
+
+	_, err = addr.QueryIPPrefixWith(nil, "203.0.114.1", opts)
+	assert.ErrorContains(t, err, "no MMDB or delegation data")
+
+	_, err = addr.QueryASNWith(nil, "AS13335", opts)
+	assert.ErrorIs(t, err, addr.ErrOfflineASN)
+}
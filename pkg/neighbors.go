@@ -0,0 +1,315 @@
+package addr
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Relationships of a neighbor to the ASN looked up.
+const (
+	REL_UPSTREAM   string = "upstream"
+	REL_DOWNSTREAM string = "downstream"
+	REL_PEER       string = "peer"
+)
+
+// PEER_DEGREE_RATIO is the largest ratio between the degrees (number of neighbors) of two ASNs
+// for a link at the top of an AS path to be inferred as peering, rather than transit.
+var PEER_DEGREE_RATIO float64 = 10
+
+var ErrNoNeighborData = errors.New("no RIB dumps or AS relationships to find neighbors from")
+
+// ASNeighbor is an ASN linked to another in AS paths or AS relationship data.
+type ASNeighbor struct {
+	ASN uint32
+	// Paths is the number of distinct AS paths in the RIB through the link. Zero if the link is
+	// only in the AS relationships.
+	Paths int
+	// Inferred is true if the relationship was inferred from AS paths, as the AS relationships
+	// don't have the link.
+	Inferred bool
+}
+
+// ASNeighbors are the neighbors of an ASN by their relationship to it. Each is ordered by paths,
+// most first, then by ASN.
+type ASNeighbors struct {
+	Upstreams   []ASNeighbor
+	Downstreams []ASNeighbor
+	Peers       []ASNeighbor
+}
+
+// Len returns the number of neighbors.
+func (n *ASNeighbors) Len() int {
+	return len(n.Upstreams) + len(n.Downstreams) + len(n.Peers)
+}
+
+// ASRelationships are links between ASNs from CAIDA's AS relationship dataset.
+type ASRelationships struct {
+	// links maps each ASN to its neighbors' relationships to it.
+	links map[uint32]map[uint32]string
+	count int
+}
+
+func NewASRelationships() *ASRelationships {
+	return &ASRelationships{links: map[uint32]map[uint32]string{}}
+}
+
+// LoadASRelationships reads CAIDA AS relationship files, which may be compressed with gzip or
+// bzip2, into a single set of relationships.
+func LoadASRelationships(paths ...string) (*ASRelationships, error) {
+	rels := NewASRelationships()
+	for _, p := range paths {
+		f, err := os.Open(p)
+		if err != nil {
+			return nil, err
+		}
+		_, err = rels.Read(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p, err)
+		}
+	}
+	return rels, nil
+}
+
+// Read adds the links of a CAIDA AS relationship file, such as 20231001.as-rel2.txt.bz2, and
+// returns the number read. Links are '<provider>|<customer>|-1' or '<peer>|<peer>|0', optionally
+// followed by '|<source>'. Lines starting with '#' are skipped.
+func (rels *ASRelationships) Read(r io.Reader) (int, error) {
+	in, err := decompress(r)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+	scanner := bufio.NewScanner(in)
+	n := 0
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Split(text, "|")
+		if len(fields) < 3 {
+			return n, fmt.Errorf("line %d: expected '<as1>|<as2>|<relationship>'", line)
+		}
+		a, errA := strconv.ParseUint(fields[0], 10, 32)
+		b, errB := strconv.ParseUint(fields[1], 10, 32)
+		if errA != nil || errB != nil {
+			return n, fmt.Errorf("line %d: invalid ASN", line)
+		}
+		switch fields[2] {
+		case "-1":
+			rels.add(uint32(a), uint32(b), REL_DOWNSTREAM)
+			rels.add(uint32(b), uint32(a), REL_UPSTREAM)
+		case "0":
+			rels.add(uint32(a), uint32(b), REL_PEER)
+			rels.add(uint32(b), uint32(a), REL_PEER)
+		default:
+			return n, fmt.Errorf("line %d: unknown relationship '%s'", line, fields[2])
+		}
+		n++
+	}
+	if err := scanner.Err(); err != nil {
+		return n, err
+	}
+	rels.count += n
+	return n, nil
+}
+
+func (rels *ASRelationships) add(asn, neighbor uint32, rel string) {
+	if rels.links[asn] == nil {
+		rels.links[asn] = map[uint32]string{}
+	}
+	rels.links[asn][neighbor] = rel
+}
+
+// Len returns the number of links read.
+func (rels *ASRelationships) Len() int {
+	return rels.count
+}
+
+// Neighbors returns the relationship of each neighbor of asn to it.
+func (rels *ASRelationships) Neighbors(asn uint32) map[uint32]string {
+	return rels.links[asn]
+}
+
+// LookupNeighbors returns the upstreams, downstreams & peers of asn from rels and the AS paths in
+// rib, either of which may be nil. A relationship is taken from rels if it has the link, and
+// otherwise inferred from the paths.
+func LookupNeighbors(asn uint32, rib *RIB, relationships *ASRelationships) *ASNeighbors {
+	var rels map[uint32]string
+	if relationships != nil {
+		rels = relationships.Neighbors(asn)
+	}
+	var links map[uint32]*ribLink
+	if rib != nil {
+		links = rib.neighbors(asn)
+	}
+	n := &ASNeighbors{}
+	add := func(nb ASNeighbor, rel string) {
+		switch rel {
+		case REL_UPSTREAM:
+			n.Upstreams = append(n.Upstreams, nb)
+		case REL_DOWNSTREAM:
+			n.Downstreams = append(n.Downstreams, nb)
+		default:
+			n.Peers = append(n.Peers, nb)
+		}
+	}
+	for neighbor, rel := range rels {
+		nb := ASNeighbor{ASN: neighbor}
+		if l, ok := links[neighbor]; ok {
+			nb.Paths = l.paths
+		}
+		add(nb, rel)
+	}
+	for neighbor, l := range links {
+		if _, ok := rels[neighbor]; !ok {
+			add(ASNeighbor{ASN: neighbor, Paths: l.paths, Inferred: true}, l.relationship())
+		}
+	}
+	for _, list := range [][]ASNeighbor{n.Upstreams, n.Downstreams, n.Peers} {
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].Paths != list[j].Paths {
+				return list[i].Paths > list[j].Paths
+			}
+			return list[i].ASN < list[j].ASN
+		})
+	}
+	return n
+}
+
+// ribLink counts the AS paths through a link, and how many of them suggest each relationship.
+type ribLink struct {
+	paths, upstream, downstream, peer int
+}
+
+// relationship returns the relationship suggested by the most paths, preferring upstream, then
+// downstream, on a tie.
+func (l *ribLink) relationship() string {
+	switch {
+	case l.upstream >= l.downstream && l.upstream >= l.peer:
+		return REL_UPSTREAM
+	case l.downstream >= l.peer:
+		return REL_DOWNSTREAM
+	default:
+		return REL_PEER
+	}
+}
+
+// neighbors returns the links of asn in the RIB's AS paths.
+func (rib *RIB) neighbors(asn uint32) map[uint32]*ribLink {
+	return rib.adjacency()[asn]
+}
+
+// adjacency returns the links of each ASN in the RIB's AS paths, which are found on first use.
+// Relationships are inferred as in Gao's algorithm: each path is assumed to climb from the origin
+// through providers to its highest degree ASN, then descend through customers. A link with the
+// highest degree ASN is instead a peering if the degrees of its ASNs are within PEER_DEGREE_RATIO.
+func (rib *RIB) adjacency() map[uint32]map[uint32]*ribLink {
+	rib.linkOnce.Do(func() {
+		rib.links = map[uint32]map[uint32]*ribLink{}
+		degree := rib.degrees()
+		seen := map[string]bool{}
+		for _, raw := range rib.paths {
+			path := dedupePath(raw)
+			key := pathKey(path)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			top := 0
+			for i, a := range path {
+				if degree[a] > degree[path[top]] {
+					top = i
+				}
+			}
+			for i, a := range path {
+				links := rib.links[a]
+				if links == nil {
+					links = map[uint32]*ribLink{}
+					rib.links[a] = links
+				}
+				for _, j := range []int{i - 1, i + 1} {
+					if j < 0 || j >= len(path) {
+						continue
+					}
+					l := links[path[j]]
+					if l == nil {
+						l = &ribLink{}
+						links[path[j]] = l
+					}
+					l.paths++
+					if (i == top || j == top) && degreeRatio(degree[a], degree[path[j]]) <= PEER_DEGREE_RATIO {
+						l.peer++
+						continue
+					}
+					// Left of the top, each ASN is a customer of the next; right of it, each is
+					// the provider of the next.
+					left := i
+					if j < i {
+						left = j
+					}
+					if (left < top) == (j > i) {
+						l.upstream++
+					} else {
+						l.downstream++
+					}
+				}
+			}
+		}
+	})
+	return rib.links
+}
+
+// degrees returns the number of distinct neighbors of each ASN in the RIB's AS paths, which is
+// counted on first use.
+func (rib *RIB) degrees() map[uint32]int {
+	rib.degreeOnce.Do(func() {
+		links := map[uint64]struct{}{}
+		for _, path := range rib.paths {
+			for i := 1; i < len(path); i++ {
+				a, b := path[i-1], path[i]
+				if a == b {
+					continue
+				}
+				if a > b {
+					a, b = b, a
+				}
+				links[uint64(a)<<32|uint64(b)] = struct{}{}
+			}
+		}
+		rib.degree = make(map[uint32]int, len(links)/2)
+		for l := range links {
+			rib.degree[uint32(l>>32)]++
+			rib.degree[uint32(l)]++
+		}
+	})
+	return rib.degree
+}
+
+// dedupePath returns path without prepending.
+func dedupePath(path []uint32) []uint32 {
+	out := make([]uint32, 0, len(path))
+	for i, asn := range path {
+		if i == 0 || asn != path[i-1] {
+			out = append(out, asn)
+		}
+	}
+	return out
+}
+
+func degreeRatio(a, b int) float64 {
+	if a < b {
+		a, b = b, a
+	}
+	if b == 0 {
+		return 0
+	}
+	return float64(a) / float64(b)
+}
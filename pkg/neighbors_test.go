@@ -0,0 +1,155 @@
+package addr_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	addr "github.com/thatmattlove/addr/pkg"
+)
+
+// AS_REL_SAMPLE is in CAIDA's serial-2 format, where serial-1 files lack the source field.
+const AS_REL_SAMPLE = `# source:topology|BGP|20231001|routeviews|route-views2
+# input clique: 174 3356
+# <provider-as>|<customer-as>|-1
+# <peer-as>|<peer-as>|0|<source>
+174|3356|0|bgp
+174|64500|0|bgp
+64500|64999|-1|bgp
+3356|64502|-1
+`
+
+// neighborPrefixes are routes seen from peers AS174, AS3356 & AS64510 (a customer of AS3356)
+// between two transit networks with 40 stub customers each, AS64500 (a customer of both, with
+// its own customer AS65001), and single-homed customers AS64501 & AS64502.
+func neighborPrefixes() []mrtPrefix {
+	prefixes := []mrtPrefix{
+		{prefix: "10.0.0.0/24", routes: []mrtRoute{
+			{peer: 0, path: []uint32{174, 64500}},
+			{peer: 1, path: []uint32{3356, 64500}},
+			{peer: 2, path: []uint32{64510, 3356, 64500}},
+		}},
+		{prefix: "10.0.1.0/24", routes: []mrtRoute{
+			{peer: 0, path: []uint32{174, 64501}},
+			{peer: 1, path: []uint32{3356, 174, 64501}},
+			{peer: 2, path: []uint32{64510, 3356, 174, 64501}},
+		}},
+		{prefix: "10.0.2.0/24", routes: []mrtRoute{
+			{peer: 0, path: []uint32{174, 3356, 64502}},
+			{peer: 1, path: []uint32{3356, 64502}},
+			{peer: 2, path: []uint32{64510, 3356, 64502}},
+		}},
+		{prefix: "10.0.3.0/24", routes: []mrtRoute{
+			{peer: 0, path: []uint32{174, 64500, 65001}},
+			{peer: 1, path: []uint32{3356, 64500, 65001}},
+			{peer: 2, path: []uint32{64510, 3356, 64500, 64500, 65001, 65001}},
+		}},
+	}
+	for i := 0; i < 40; i++ {
+		stub := uint32(65100 + i)
+		prefixes = append(prefixes, mrtPrefix{prefix: fmt.Sprintf("10.1.%d.0/24", i), routes: []mrtRoute{
+			{peer: 0, path: []uint32{174, stub}},
+			{peer: 1, path: []uint32{3356, stub}},
+			{peer: 2, path: []uint32{64510, 3356, stub}},
+		}})
+	}
+	return prefixes
+}
+
+func TestASRelationships_Read(t *testing.T) {
+	t.Run("base", func(t *testing.T) {
+		t.Parallel()
+		rels := addr.NewASRelationships()
+		n, err := rels.Read(strings.NewReader(AS_REL_SAMPLE))
+		assert.NoError(t, err)
+		assert.Equal(t, 4, n)
+		assert.Equal(t, 4, rels.Len())
+		assert.Equal(t, map[uint32]string{3356: addr.REL_PEER, 64500: addr.REL_PEER}, rels.Neighbors(174))
+		assert.Equal(t, map[uint32]string{174: addr.REL_PEER, 64999: addr.REL_DOWNSTREAM}, rels.Neighbors(64500))
+		assert.Equal(t, map[uint32]string{64500: addr.REL_UPSTREAM}, rels.Neighbors(64999))
+		assert.Nil(t, rels.Neighbors(13335))
+	})
+	t.Run("gzip", func(t *testing.T) {
+		t.Parallel()
+		b := &bytes.Buffer{}
+		w := gzip.NewWriter(b)
+		_, err := w.Write([]byte(AS_REL_SAMPLE))
+		assert.NoError(t, err)
+		assert.NoError(t, w.Close())
+		path := filepath.Join(t.TempDir(), "20231001.as-rel2.txt.gz")
+		assert.NoError(t, os.WriteFile(path, b.Bytes(), 0o644))
+		rels, err := addr.LoadASRelationships(path)
+		assert.NoError(t, err)
+		assert.Equal(t, 4, rels.Len())
+		assert.Equal(t, addr.REL_UPSTREAM, rels.Neighbors(64502)[3356])
+	})
+	t.Run("errors", func(t *testing.T) {
+		t.Parallel()
+		for in, want := range map[string]string{
+			"174|3356\n":          "line 1: expected",
+			"# c\nAS174|3356|0\n": "line 2: invalid ASN",
+			"174|3356|1\n":        "line 1: unknown relationship '1'",
+		} {
+			_, err := addr.NewASRelationships().Read(strings.NewReader(in))
+			assert.ErrorContains(t, err, want, in)
+		}
+		_, err := addr.LoadASRelationships(filepath.Join(t.TempDir(), "missing"))
+		assert.Error(t, err)
+	})
+}
+
+func Test_LookupNeighbors(t *testing.T) {
+	rib := addr.NewRIB()
+	_, err := rib.Read(bytes.NewReader(encodeMRT(3, neighborPrefixes())))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	t.Run("inferred", func(t *testing.T) {
+		t.Parallel()
+		n := addr.LookupNeighbors(64500, rib, nil)
+		assert.Equal(t, []addr.ASNeighbor{{ASN: 3356, Paths: 4, Inferred: true}, {ASN: 174, Paths: 2, Inferred: true}}, n.Upstreams)
+		assert.Equal(t, []addr.ASNeighbor{{ASN: 65001, Paths: 3, Inferred: true}}, n.Downstreams)
+		assert.Empty(t, n.Peers)
+
+		n = addr.LookupNeighbors(174, rib, nil)
+		assert.Empty(t, n.Upstreams)
+		assert.Equal(t, []addr.ASNeighbor{{ASN: 3356, Paths: 3, Inferred: true}}, n.Peers, "links between the highest degree ASNs are peerings")
+		assert.Len(t, n.Downstreams, 42)
+
+		n = addr.LookupNeighbors(3356, rib, nil)
+		assert.Contains(t, n.Downstreams, addr.ASNeighbor{ASN: 64510, Paths: 44, Inferred: true})
+		assert.Equal(t, 0, addr.LookupNeighbors(13335, rib, nil).Len())
+	})
+	t.Run("as-rel", func(t *testing.T) {
+		t.Parallel()
+		rels := addr.NewASRelationships()
+		_, err := rels.Read(strings.NewReader(AS_REL_SAMPLE))
+		assert.NoError(t, err)
+		n := addr.LookupNeighbors(64500, rib, rels)
+		assert.Equal(t, []addr.ASNeighbor{{ASN: 3356, Paths: 4, Inferred: true}}, n.Upstreams)
+		assert.Equal(t, []addr.ASNeighbor{{ASN: 65001, Paths: 3, Inferred: true}, {ASN: 64999}}, n.Downstreams)
+		assert.Equal(t, []addr.ASNeighbor{{ASN: 174, Paths: 2}}, n.Peers)
+
+		r := addr.NewASNResult("AS64500", &addr.Response{Neighbors: n})
+		assert.Equal(t, "3356", r.Column("upstreams"))
+		assert.Equal(t, "65001 64999", r.Column("downstreams"))
+		assert.Equal(t, "174", r.Column("peer_asns"))
+		b, err := json.Marshal(r)
+		assert.NoError(t, err)
+		assert.Contains(t, string(b), `"peers":[{"asn":174,"paths":2,"inferred":false}]`)
+		out := &addr.Result{}
+		assert.NoError(t, json.Unmarshal(b, out))
+		assert.Equal(t, n, out.Neighbors)
+	})
+	t.Run("no data", func(t *testing.T) {
+		t.Parallel()
+		assert.Equal(t, 0, addr.LookupNeighbors(64500, nil, nil).Len())
+	})
+}
@@ -0,0 +1,299 @@
+package addr
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/netip"
+	"sort"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+)
+
+// pcapngMagic is the block type of a pcapng section header, which starts every pcapng file.
+var pcapngMagic = []byte{0x0a, 0x0d, 0x0d, 0x0a}
+
+var ErrEmptyCapture = errors.New("no IP packets found in capture")
+
+// TrafficCount is a count of packets and bytes. Bytes are the packets' original lengths, which
+// may be larger than what was captured.
+type TrafficCount struct {
+	Packets uint64 `json:"packets"`
+	Bytes   uint64 `json:"bytes"`
+}
+
+func (c *TrafficCount) add(other TrafficCount) {
+	c.Packets += other.Packets
+	c.Bytes += other.Bytes
+}
+
+// PcapHost is the traffic sent & received by an address in a capture.
+type PcapHost struct {
+	Addr     netip.Addr
+	Sent     TrafficCount
+	Received TrafficCount
+}
+
+// Capture is the IP traffic in a pcap or pcapng file.
+type Capture struct {
+	// Total counts every IP packet in the capture.
+	Total TrafficCount
+	// Skipped counts packets that weren't IP or couldn't be decoded.
+	Skipped uint64
+	// Hosts are ordered by bytes sent & received, largest first.
+	Hosts []*PcapHost
+}
+
+// Addrs returns the address of each host in the capture.
+func (c *Capture) Addrs() []netip.Addr {
+	addrs := make([]netip.Addr, 0, len(c.Hosts))
+	for _, h := range c.Hosts {
+		addrs = append(addrs, h.Addr)
+	}
+	return addrs
+}
+
+type packetReader interface {
+	ReadPacketData() ([]byte, gopacket.CaptureInfo, error)
+}
+
+// ReadCapture reads a pcap or pcapng file, counting the packets & bytes sent and received by each
+// source & destination address.
+func ReadCapture(r io.Reader) (*Capture, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(len(pcapngMagic))
+	if err != nil {
+		return nil, fmt.Errorf("invalid capture: %w", err)
+	}
+	var pr packetReader
+	var linkType func(gopacket.CaptureInfo) layers.LinkType
+	if bytes.Equal(magic, pcapngMagic) {
+		ng, err := pcapgo.NewNgReader(br, pcapgo.DefaultNgReaderOptions)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pcapng capture: %w", err)
+		}
+		pr = ng
+		linkType = func(ci gopacket.CaptureInfo) layers.LinkType {
+			if i, err := ng.Interface(ci.InterfaceIndex); err == nil {
+				return i.LinkType
+			}
+			return ng.LinkType()
+		}
+	} else {
+		p, err := pcapgo.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pcap capture: %w", err)
+		}
+		pr = p
+		linkType = func(gopacket.CaptureInfo) layers.LinkType {
+			return p.LinkType()
+		}
+	}
+	c := &Capture{Hosts: []*PcapHost{}}
+	hosts := map[netip.Addr]*PcapHost{}
+	host := func(a netip.Addr) *PcapHost {
+		h, ok := hosts[a]
+		if !ok {
+			h = &PcapHost{Addr: a}
+			hosts[a] = h
+			c.Hosts = append(c.Hosts, h)
+		}
+		return h
+	}
+	for {
+		data, ci, err := pr.ReadPacketData()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid capture: %w", err)
+		}
+		src, dst, ok := packetAddrs(data, linkType(ci))
+		if !ok {
+			c.Skipped++
+			continue
+		}
+		count := TrafficCount{Packets: 1, Bytes: uint64(ci.Length)}
+		c.Total.add(count)
+		host(src).Sent.add(count)
+		host(dst).Received.add(count)
+	}
+	if len(c.Hosts) == 0 {
+		return nil, ErrEmptyCapture
+	}
+	sort.SliceStable(c.Hosts, func(i, j int) bool {
+		bi := c.Hosts[i].Sent.Bytes + c.Hosts[i].Received.Bytes
+		bj := c.Hosts[j].Sent.Bytes + c.Hosts[j].Received.Bytes
+		if bi != bj {
+			return bi > bj
+		}
+		return c.Hosts[i].Addr.Less(c.Hosts[j].Addr)
+	})
+	return c, nil
+}
+
+// packetAddrs returns the source & destination addresses of an IPv4 or IPv6 packet.
+func packetAddrs(data []byte, linkType layers.LinkType) (netip.Addr, netip.Addr, bool) {
+	p := gopacket.NewPacket(data, linkType, gopacket.DecodeOptions{Lazy: true, NoCopy: true})
+	var src, dst netip.Addr
+	switch l := p.NetworkLayer().(type) {
+	case *layers.IPv4:
+		src, _ = netip.AddrFromSlice(l.SrcIP)
+		dst, _ = netip.AddrFromSlice(l.DstIP)
+	case *layers.IPv6:
+		src, _ = netip.AddrFromSlice(l.SrcIP)
+		dst, _ = netip.AddrFromSlice(l.DstIP)
+	default:
+		return src, dst, false
+	}
+	return src.Unmap(), dst.Unmap(), src.IsValid() && dst.IsValid()
+}
+
+// QueryAddrs looks up each address with QueryIPPrefixes, so that all whois queries are made in bulk
+// over one connection. Results are in the same order as addrs, and failed lookups have an error
+// result.
+func QueryAddrs(addrs []netip.Addr, opts QueryOptions) []*Result {
+	responses, errs := QueryIPPrefixes(addrs, opts)
+	results := make([]*Result, len(addrs))
+	for i, a := range addrs {
+		q := a.String()
+		if errs[i] != nil {
+			results[i] = NewErrorResult(q, RESULT_IP, errs[i])
+			continue
+		}
+		results[i] = NewIPResult(q, responses[i], nil)
+	}
+	return results
+}
+
+// TrafficGroup is the traffic sent & received by hosts sharing a value of the summary key.
+type TrafficGroup struct {
+	// Key is the shared value, e.g. '13335' or 'US', or empty if unknown.
+	Key  string
+	Name string
+	// Hosts is the number of addresses in the group.
+	Hosts    int
+	Sent     TrafficCount
+	Received TrafficCount
+	// Percent is the group's share of all bytes sent & received.
+	Percent float64
+}
+
+// TrafficSummary is a capture's traffic grouped by ASN, country, registry or prefix.
+type TrafficSummary struct {
+	By    string
+	Total TrafficCount
+	// Failed counts the traffic of hosts that failed to look up, which isn't grouped.
+	Failed TrafficCount
+	// Groups are ordered by bytes sent & received, largest first.
+	Groups []*TrafficGroup
+	// Other counts the traffic in the OtherGroups groups beyond the top N.
+	Other       TrafficGroup
+	OtherGroups int
+}
+
+// SummarizeTraffic groups a capture's traffic by one of SUMMARY_KEYS, using results in the same
+// order as the capture's hosts. If top is greater than zero, only the top groups are kept and the
+// rest are counted in Other.
+func SummarizeTraffic(by string, c *Capture, results []*Result, top int) (*TrafficSummary, error) {
+	err := ValidateSummaryKey(by)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) != len(c.Hosts) {
+		return nil, fmt.Errorf("expected %d results, got %d", len(c.Hosts), len(results))
+	}
+	s := &TrafficSummary{By: by, Total: c.Total, Groups: []*TrafficGroup{}}
+	groups := map[string]*TrafficGroup{}
+	for i, h := range c.Hosts {
+		r := results[i]
+		if r.Error != "" {
+			s.Failed.add(h.Sent)
+			s.Failed.add(h.Received)
+			continue
+		}
+		key := r.Column(by)
+		g, ok := groups[key]
+		if !ok {
+			g = &TrafficGroup{Key: key, Name: summaryName(by, r)}
+			groups[key] = g
+			s.Groups = append(s.Groups, g)
+		}
+		g.Hosts++
+		g.Sent.add(h.Sent)
+		g.Received.add(h.Received)
+	}
+	for _, g := range s.Groups {
+		g.Percent = trafficPercent(g, s.Total)
+	}
+	sort.SliceStable(s.Groups, func(i, j int) bool {
+		bi := s.Groups[i].Sent.Bytes + s.Groups[i].Received.Bytes
+		bj := s.Groups[j].Sent.Bytes + s.Groups[j].Received.Bytes
+		if bi != bj {
+			return bi > bj
+		}
+		return s.Groups[i].Key < s.Groups[j].Key
+	})
+	if top > 0 && len(s.Groups) > top {
+		for _, g := range s.Groups[top:] {
+			s.Other.Hosts += g.Hosts
+			s.Other.Sent.add(g.Sent)
+			s.Other.Received.add(g.Received)
+		}
+		s.Other.Percent = trafficPercent(&s.Other, s.Total)
+		s.OtherGroups = len(s.Groups) - top
+		s.Groups = s.Groups[:top]
+	}
+	return s, nil
+}
+
+// trafficPercent returns a group's share of all bytes sent & received. Each packet is sent by one
+// host and received by another, so the shares of all groups add up to 100.
+func trafficPercent(g *TrafficGroup, total TrafficCount) float64 {
+	if total.Bytes == 0 {
+		return 0
+	}
+	return float64(g.Sent.Bytes+g.Received.Bytes) / float64(total.Bytes*2) * 100
+}
+
+type trafficGroupJSON struct {
+	Key      string       `json:"key"`
+	Name     string       `json:"name,omitempty"`
+	Hosts    int          `json:"hosts"`
+	Sent     TrafficCount `json:"sent"`
+	Received TrafficCount `json:"received"`
+	Percent  float64      `json:"percent"`
+}
+
+func newTrafficGroupJSON(g *TrafficGroup) trafficGroupJSON {
+	return trafficGroupJSON{
+		Key:      g.Key,
+		Name:     g.Name,
+		Hosts:    g.Hosts,
+		Sent:     g.Sent,
+		Received: g.Received,
+		Percent:  math.Round(g.Percent*100) / 100,
+	}
+}
+
+// MarshalJSON encodes a traffic summary with percentages rounded to two decimal places.
+func (s *TrafficSummary) MarshalJSON() ([]byte, error) {
+	groups := make([]trafficGroupJSON, 0, len(s.Groups))
+	for _, g := range s.Groups {
+		groups = append(groups, newTrafficGroupJSON(g))
+	}
+	return json.Marshal(struct {
+		By          string             `json:"by"`
+		Total       TrafficCount       `json:"total"`
+		Failed      TrafficCount       `json:"failed"`
+		Groups      []trafficGroupJSON `json:"groups"`
+		Other       trafficGroupJSON   `json:"other"`
+		OtherGroups int                `json:"other_groups"`
+	}{s.By, s.Total, s.Failed, groups, newTrafficGroupJSON(&s.Other), s.OtherGroups})
+}
@@ -0,0 +1,166 @@
+package addr_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/netip"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	addr "github.com/thatmattlove/addr/pkg"
+	goasn "github.com/thatmattlove/go-asn"
+)
+
+func readCapture(t *testing.T, name string) *addr.Capture {
+	f, err := os.Open(name)
+	assert.NoError(t, err)
+	defer f.Close()
+	c, err := addr.ReadCapture(f)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return c
+}
+
+// fakePcapQuery answers lookups of 1.1.1.1 & 8.8.8.8, and of private addresses without a query.
+func fakePcapQuery(q string) (*addr.Response, error) {
+	origins := map[string]string{"1.1.1.1": "13335", "8.8.8.8": "15169"}
+	asn, ok := origins[q]
+	if !ok {
+		if strings.HasPrefix(q, "192.168.") {
+			return addr.QueryIPPrefix(q)
+		}
+		return nil, errors.New("upstream failure")
+	}
+	ip := net.ParseIP(q)
+	return &addr.Response{ASN: goasn.MustParse(asn), IP: &ip, Name: "AS" + asn, FromQuery: true}, nil
+}
+
+func Test_ReadCapture(t *testing.T) {
+	t.Run("pcap", func(t *testing.T) {
+		t.Parallel()
+		c := readCapture(t, "testdata/capture.pcap")
+		assert.Equal(t, addr.TrafficCount{Packets: 4, Bytes: 500}, c.Total)
+		assert.Equal(t, uint64(1), c.Skipped, "ARP isn't counted")
+		assert.Equal(t, []netip.Addr{
+			netip.MustParseAddr("192.168.1.10"),
+			netip.MustParseAddr("1.1.1.1"),
+			netip.MustParseAddr("8.8.8.8"),
+		}, c.Addrs())
+		assert.Equal(t, addr.TrafficCount{Packets: 3, Bytes: 300}, c.Hosts[0].Sent)
+		assert.Equal(t, addr.TrafficCount{Packets: 1, Bytes: 200}, c.Hosts[0].Received)
+		assert.Equal(t, addr.TrafficCount{Packets: 2, Bytes: 200}, c.Hosts[1].Received)
+	})
+	t.Run("pcapng", func(t *testing.T) {
+		t.Parallel()
+		c := readCapture(t, "testdata/capture.pcapng")
+		assert.Equal(t, addr.TrafficCount{Packets: 3, Bytes: 400}, c.Total)
+		assert.Equal(t, []netip.Addr{
+			netip.MustParseAddr("2001:db8::10"),
+			netip.MustParseAddr("2606:4700:4700::1111"),
+			netip.MustParseAddr("1.1.1.1"),
+			netip.MustParseAddr("192.168.1.10"),
+		}, c.Addrs())
+	})
+	t.Run("not a capture", func(t *testing.T) {
+		t.Parallel()
+		_, err := addr.ReadCapture(strings.NewReader("not a capture file"))
+		assert.ErrorContains(t, err, "invalid pcap capture")
+	})
+	t.Run("empty", func(t *testing.T) {
+		t.Parallel()
+		_, err := addr.ReadCapture(bytes.NewReader(nil))
+		assert.ErrorContains(t, err, "invalid capture")
+	})
+}
+
+// fakePcapResults looks up each address with query, like QueryAddrs.
+func fakePcapResults(addrs []netip.Addr, query func(string) (*addr.Response, error)) []*addr.Result {
+	results := make([]*addr.Result, len(addrs))
+	for i, a := range addrs {
+		r, err := query(a.String())
+		if err != nil {
+			results[i] = addr.NewErrorResult(a.String(), addr.RESULT_IP, err)
+			continue
+		}
+		results[i] = addr.NewIPResult(a.String(), r, nil)
+	}
+	return results
+}
+
+func Test_QueryAddrs(t *testing.T) {
+	requests := serveBulkWhois(t, `AS      | IP               | BGP Prefix          | CC | Registry | Allocated  | AS Name
+15169   | 8.8.8.8          | 8.8.8.0/24          | US | ARIN     | 1992-12-01 | Google LLC
+13335   | 1.1.1.1          | 1.1.1.0/24          | US | ARIN     | 2010-07-14 | Cloudflare, Inc.
+`)
+	addrs := []netip.Addr{netip.MustParseAddr("8.8.8.8"), netip.MustParseAddr("192.0.2.1"), netip.MustParseAddr("1.1.1.1"), netip.MustParseAddr("9.9.9.9")}
+	results := addr.QueryAddrs(addrs, addr.QueryOptions{})
+	assert.Equal(t, "begin\nverbose\n8.8.8.8\n1.1.1.1\n9.9.9.9\nend\n", <-requests)
+	assert.Equal(t, "15169", results[0].Column("asn"))
+	assert.Equal(t, addr.TXT_DOC, results[1].Name)
+	assert.Equal(t, "13335", results[2].Column("asn"))
+	assert.Equal(t, addr.ErrEmptyResponse.Error(), results[3].Error)
+}
+
+func Test_SummarizeTraffic(t *testing.T) {
+	c := readCapture(t, "testdata/capture.pcap")
+	results := fakePcapResults(c.Addrs(), fakePcapQuery)
+	t.Run("asn", func(t *testing.T) {
+		t.Parallel()
+		s, err := addr.SummarizeTraffic(addr.SUMMARY_ASN, c, results, 0)
+		assert.NoError(t, err)
+		assert.Len(t, s.Groups, 3)
+		assert.Equal(t, "", s.Groups[0].Key)
+		assert.Equal(t, float64(50), s.Groups[0].Percent)
+		assert.Equal(t, "13335", s.Groups[1].Key)
+		assert.Equal(t, "AS13335", s.Groups[1].Name)
+		assert.Equal(t, float64(40), s.Groups[1].Percent)
+		assert.Equal(t, addr.TrafficCount{Packets: 1, Bytes: 200}, s.Groups[1].Sent)
+		assert.Equal(t, "15169", s.Groups[2].Key)
+		assert.Equal(t, float64(10), s.Groups[2].Percent)
+	})
+	t.Run("top", func(t *testing.T) {
+		t.Parallel()
+		s, err := addr.SummarizeTraffic(addr.SUMMARY_ASN, c, results, 1)
+		assert.NoError(t, err)
+		assert.Len(t, s.Groups, 1)
+		assert.Equal(t, 2, s.OtherGroups)
+		assert.Equal(t, 2, s.Other.Hosts)
+		assert.Equal(t, float64(50), s.Other.Percent)
+	})
+	t.Run("failed", func(t *testing.T) {
+		t.Parallel()
+		failed := fakePcapResults(c.Addrs(), func(q string) (*addr.Response, error) {
+			if q == "8.8.8.8" {
+				return nil, errors.New("upstream failure")
+			}
+			return fakePcapQuery(q)
+		})
+		s, err := addr.SummarizeTraffic(addr.SUMMARY_COUNTRY, c, failed, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, addr.TrafficCount{Packets: 1, Bytes: 100}, s.Failed)
+		assert.Len(t, s.Groups, 2)
+	})
+	t.Run("json", func(t *testing.T) {
+		t.Parallel()
+		s, err := addr.SummarizeTraffic(addr.SUMMARY_ASN, c, results, 0)
+		assert.NoError(t, err)
+		b, err := json.Marshal(s)
+		assert.NoError(t, err)
+		assert.Contains(t, string(b), `{"key":"13335","name":"AS13335","hosts":1,"sent":{"packets":1,"bytes":200},"received":{"packets":2,"bytes":200},"percent":40}`)
+	})
+	t.Run("invalid key", func(t *testing.T) {
+		t.Parallel()
+		_, err := addr.SummarizeTraffic("city", c, results, 0)
+		assert.Error(t, err)
+	})
+	t.Run("mismatched results", func(t *testing.T) {
+		t.Parallel()
+		_, err := addr.SummarizeTraffic(addr.SUMMARY_ASN, c, results[1:], 0)
+		assert.ErrorContains(t, err, "expected 3 results, got 2")
+	})
+}
@@ -0,0 +1,510 @@
+package addr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/biter777/countries"
+)
+
+// PEERINGDB_FILE is the name of the PeeringDB dump used from the data directory, if present.
+const PEERINGDB_FILE = "peeringdb.json"
+
+// PEERINGDB_OBJECTS are the PeeringDB object types read from dumps. Others are ignored.
+var PEERINGDB_OBJECTS = []string{"net", "ix", "netixlan", "fac", "netfac"}
+
+var ErrEmptyPeeringDB = errors.New("no networks or IXPs found in PeeringDB dump")
+
+// PeeringDBNetwork is a PeeringDB network (net), with its IXP connections & facilities.
+type PeeringDBNetwork struct {
+	ID      int
+	ASN     uint32
+	Name    string
+	AKA     string
+	Website string
+	// IRRASSet is the AS-SET the network registers its prefixes under, e.g. 'RIPE::AS-EXAMPLE'.
+	IRRASSet string
+	// Policy is the general peering policy: 'Open', 'Selective', 'Restrictive' or 'No'.
+	Policy    string
+	PolicyURL string
+	// Traffic is the traffic level, e.g. '1-5Tbps'.
+	Traffic string
+	Ratio   string
+	Scope   string
+	Type    string
+	// Connections are the network's ports on IXP peering LANs, ordered by IXP name.
+	Connections []*PeeringDBConnection
+	// Facilities are ordered by name.
+	Facilities []*PeeringDBFacility
+}
+
+// IXPs returns the names of the IXPs the network is connected to, once each.
+func (n *PeeringDBNetwork) IXPs() []string {
+	names := []string{}
+	for i, c := range n.Connections {
+		if i == 0 || c.IXID != n.Connections[i-1].IXID {
+			names = append(names, c.IXName)
+		}
+	}
+	return names
+}
+
+// PeeringDBConnection is a network's port on an IXP's peering LAN (netixlan).
+type PeeringDBConnection struct {
+	IXID    int
+	IXName  string
+	ASN     uint32
+	NetName string
+	// Speed is the port speed in Mbps.
+	Speed int
+	// IPv4 & IPv6 are the network's peering LAN addresses, invalid if it has none.
+	IPv4 netip.Addr
+	IPv6 netip.Addr
+	// RouteServer is true if the network peers with the IXP's route servers.
+	RouteServer bool
+}
+
+// PeeringDBIX is a PeeringDB internet exchange (ix), with its participants' connections.
+type PeeringDBIX struct {
+	ID       int
+	Name     string
+	NameLong string
+	City     string
+	Country  countries.CountryCode
+	Website  string
+	// Participants are ordered by ASN, then by peering LAN address.
+	Participants []*PeeringDBConnection
+}
+
+// Networks returns the number of distinct networks connected to the IXP.
+func (ix *PeeringDBIX) Networks() int {
+	n := 0
+	for i, p := range ix.Participants {
+		if i == 0 || p.ASN != ix.Participants[i-1].ASN {
+			n++
+		}
+	}
+	return n
+}
+
+// MarshalJSON encodes an IXP with its participants' connections, in the format of a result's
+// PeeringDB IXPs.
+func (ix *PeeringDBIX) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		ID           int                       `json:"id"`
+		Name         string                    `json:"name"`
+		NameLong     string                    `json:"name_long"`
+		City         string                    `json:"city"`
+		Country      string                    `json:"country"`
+		Website      string                    `json:"website"`
+		Networks     int                       `json:"networks"`
+		Participants []peeringDBConnectionJSON `json:"participants"`
+	}{
+		ID:           ix.ID,
+		Name:         ix.Name,
+		NameLong:     ix.NameLong,
+		City:         ix.City,
+		Country:      countryFunc(countries.CountryCode.Alpha2)(ix.Country),
+		Website:      ix.Website,
+		Networks:     ix.Networks(),
+		Participants: peeringDBConnectionsJSON(ix.Participants),
+	})
+}
+
+// PeeringDBFacility is a PeeringDB facility (fac), such as a data center.
+type PeeringDBFacility struct {
+	ID      int
+	Name    string
+	City    string
+	Country countries.CountryCode
+}
+
+// PeeringDB holds the networks & IXPs of PeeringDB API dumps, with each network's connections
+// to IXPs (netixlan) & facilities (netfac) linked.
+type PeeringDB struct {
+	networks map[uint32]*PeeringDBNetwork
+	ixs      []*PeeringDBIX
+	// load, if set, fills the database on first use. See OpenPeeringDB.
+	load    func() (*PeeringDB, error)
+	once    sync.Once
+	loadErr error
+}
+
+func NewPeeringDB() *PeeringDB {
+	return &PeeringDB{networks: map[uint32]*PeeringDBNetwork{}}
+}
+
+// OpenPeeringDB returns a database that's loaded from paths on first use, since full dumps are
+// large and many commands never need them.
+func OpenPeeringDB(paths ...string) *PeeringDB {
+	return &PeeringDB{networks: map[uint32]*PeeringDBNetwork{}, load: func() (*PeeringDB, error) {
+		return LoadPeeringDB(paths...)
+	}}
+}
+
+// LoadPeeringDB reads PeeringDB dumps into a single database. Each file is either a full dump,
+// with an API response for each object type under its name ('{"net": {"data": [...]}, ...}'),
+// or a single API response named after its object type, e.g. net.json or netixlan.json.
+func LoadPeeringDB(paths ...string) (*PeeringDB, error) {
+	objs := &peeringDBObjects{}
+	for _, p := range paths {
+		f, err := os.Open(p)
+		if err != nil {
+			return nil, err
+		}
+		objectType := strings.SplitN(filepath.Base(p), ".", 2)[0]
+		err = objs.read(f, objectType)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p, err)
+		}
+	}
+	return objs.link()
+}
+
+// ReadPeeringDB reads a full PeeringDB dump.
+func ReadPeeringDB(r io.Reader) (*PeeringDB, error) {
+	objs := &peeringDBObjects{}
+	err := objs.read(r, "")
+	if err != nil {
+		return nil, err
+	}
+	return objs.link()
+}
+
+func (db *PeeringDB) ready() {
+	if db.load == nil {
+		return
+	}
+	db.once.Do(func() {
+		loaded, err := db.load()
+		if err != nil {
+			db.loadErr = err
+			return
+		}
+		db.networks, db.ixs = loaded.networks, loaded.ixs
+	})
+}
+
+// Err returns the error from loading a database opened with OpenPeeringDB, or nil if it loaded.
+func (db *PeeringDB) Err() error {
+	db.ready()
+	return db.loadErr
+}
+
+// Len returns the number of networks.
+func (db *PeeringDB) Len() int {
+	db.ready()
+	return len(db.networks)
+}
+
+// Network returns the network with an ASN, or nil if there isn't one.
+func (db *PeeringDB) Network(asn uint32) *PeeringDBNetwork {
+	db.ready()
+	return db.networks[asn]
+}
+
+// LookupIX returns the IXP with an ID, or whose name or long name is q, ignoring case. Failing
+// that, a name containing q matches if no other IXP's does.
+func (db *PeeringDB) LookupIX(q string) (*PeeringDBIX, error) {
+	db.ready()
+	q = strings.TrimSpace(q)
+	if id, err := strconv.Atoi(q); err == nil {
+		for _, ix := range db.ixs {
+			if ix.ID == id {
+				return ix, nil
+			}
+		}
+		return nil, fmt.Errorf("no IXP with ID %d", id)
+	}
+	lower := strings.ToLower(q)
+	var partial []*PeeringDBIX
+	for _, ix := range db.ixs {
+		if strings.EqualFold(ix.Name, q) || strings.EqualFold(ix.NameLong, q) {
+			return ix, nil
+		}
+		if strings.Contains(strings.ToLower(ix.Name), lower) || strings.Contains(strings.ToLower(ix.NameLong), lower) {
+			partial = append(partial, ix)
+		}
+	}
+	switch len(partial) {
+	case 0:
+		return nil, fmt.Errorf("no IXP matching '%s'", q)
+	case 1:
+		return partial[0], nil
+	}
+	names := []string{}
+	for i, ix := range partial {
+		if i == 10 {
+			names = append(names, fmt.Sprintf("%d more", len(partial)-i))
+			break
+		}
+		names = append(names, fmt.Sprintf("%s (%d)", ix.Name, ix.ID))
+	}
+	return nil, fmt.Errorf("'%s' matches %d IXPs: %s", q, len(partial), strings.Join(names, ", "))
+}
+
+// peeringDBObjects are the objects read from PeeringDB dumps, before they're linked.
+type peeringDBObjects struct {
+	Net      []peeringDBNet
+	IX       []peeringDBIX
+	NetIXLAN []peeringDBNetIXLAN
+	Fac      []peeringDBFac
+	NetFac   []peeringDBNetFac
+}
+
+type peeringDBNet struct {
+	ID       int    `json:"id"`
+	Status   string `json:"status"`
+	ASN      uint32 `json:"asn"`
+	Name     string `json:"name"`
+	AKA      string `json:"aka"`
+	Website  string `json:"website"`
+	IRRASSet string `json:"irr_as_set"`
+	Policy   string `json:"policy_general"`
+	URL      string `json:"policy_url"`
+	Traffic  string `json:"info_traffic"`
+	Ratio    string `json:"info_ratio"`
+	Scope    string `json:"info_scope"`
+	Type     string `json:"info_type"`
+}
+
+type peeringDBIX struct {
+	ID       int    `json:"id"`
+	Status   string `json:"status"`
+	Name     string `json:"name"`
+	NameLong string `json:"name_long"`
+	City     string `json:"city"`
+	Country  string `json:"country"`
+	Website  string `json:"website"`
+}
+
+type peeringDBNetIXLAN struct {
+	Status      string  `json:"status"`
+	NetID       int     `json:"net_id"`
+	IXID        int     `json:"ix_id"`
+	Speed       int     `json:"speed"`
+	IPv4        *string `json:"ipaddr4"`
+	IPv6        *string `json:"ipaddr6"`
+	RouteServer bool    `json:"is_rs_peer"`
+	// Operational is missing from older dumps, where every connection is operational.
+	Operational *bool `json:"operational"`
+}
+
+type peeringDBFac struct {
+	ID      int    `json:"id"`
+	Status  string `json:"status"`
+	Name    string `json:"name"`
+	City    string `json:"city"`
+	Country string `json:"country"`
+}
+
+type peeringDBNetFac struct {
+	Status string `json:"status"`
+	NetID  int    `json:"net_id"`
+	FacID  int    `json:"fac_id"`
+}
+
+// read adds the objects of a dump. If objectType is set and the dump is a single API response,
+// its objects are of that type.
+func (objs *peeringDBObjects) read(r io.Reader, objectType string) error {
+	var dump map[string]json.RawMessage
+	err := json.NewDecoder(r).Decode(&dump)
+	if err != nil {
+		return err
+	}
+	if data, ok := dump["data"]; ok {
+		if objectType == "" {
+			return errors.New("API response without an object type")
+		}
+		return objs.add(objectType, data)
+	}
+	for _, t := range PEERINGDB_OBJECTS {
+		res, ok := dump[t]
+		if !ok {
+			continue
+		}
+		var in struct {
+			Data json.RawMessage `json:"data"`
+		}
+		err = json.Unmarshal(res, &in)
+		if err == nil {
+			err = objs.add(t, in.Data)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", t, err)
+		}
+	}
+	return nil
+}
+
+// add adds objects of a type, from an API response's data. Types not in PEERINGDB_OBJECTS are
+// ignored.
+func (objs *peeringDBObjects) add(objectType string, data json.RawMessage) error {
+	switch objectType {
+	case "net":
+		return appendJSON(data, &objs.Net)
+	case "ix":
+		return appendJSON(data, &objs.IX)
+	case "netixlan":
+		return appendJSON(data, &objs.NetIXLAN)
+	case "fac":
+		return appendJSON(data, &objs.Fac)
+	case "netfac":
+		return appendJSON(data, &objs.NetFac)
+	}
+	return nil
+}
+
+func appendJSON[T any](data json.RawMessage, to *[]T) error {
+	var objs []T
+	err := json.Unmarshal(data, &objs)
+	if err != nil {
+		return err
+	}
+	*to = append(*to, objs...)
+	return nil
+}
+
+// link builds a database from the objects, skipping any that are deleted, and connections that
+// aren't operational or whose network or IXP is missing.
+func (objs *peeringDBObjects) link() (*PeeringDB, error) {
+	db := NewPeeringDB()
+	networks := map[int]*PeeringDBNetwork{}
+	for _, n := range objs.Net {
+		if !peeringDBActive(n.Status) {
+			continue
+		}
+		net := &PeeringDBNetwork{
+			ID:        n.ID,
+			ASN:       n.ASN,
+			Name:      n.Name,
+			AKA:       n.AKA,
+			Website:   n.Website,
+			IRRASSet:  n.IRRASSet,
+			Policy:    n.Policy,
+			PolicyURL: n.URL,
+			Traffic:   n.Traffic,
+			Ratio:     n.Ratio,
+			Scope:     n.Scope,
+			Type:      n.Type,
+		}
+		networks[n.ID] = net
+		db.networks[n.ASN] = net
+	}
+	ixs := map[int]*PeeringDBIX{}
+	for _, x := range objs.IX {
+		if !peeringDBActive(x.Status) {
+			continue
+		}
+		ix := &PeeringDBIX{
+			ID:       x.ID,
+			Name:     x.Name,
+			NameLong: x.NameLong,
+			City:     x.City,
+			Country:  peeringDBCountry(x.Country),
+			Website:  x.Website,
+		}
+		ixs[x.ID] = ix
+		db.ixs = append(db.ixs, ix)
+	}
+	if len(db.networks) == 0 && len(db.ixs) == 0 {
+		return nil, ErrEmptyPeeringDB
+	}
+	for _, l := range objs.NetIXLAN {
+		net, ix := networks[l.NetID], ixs[l.IXID]
+		if !peeringDBActive(l.Status) || (l.Operational != nil && !*l.Operational) || net == nil || ix == nil {
+			continue
+		}
+		c := &PeeringDBConnection{
+			IXID:        ix.ID,
+			IXName:      ix.Name,
+			ASN:         net.ASN,
+			NetName:     net.Name,
+			Speed:       l.Speed,
+			RouteServer: l.RouteServer,
+		}
+		if l.IPv4 != nil {
+			c.IPv4, _ = netip.ParseAddr(*l.IPv4)
+		}
+		if l.IPv6 != nil {
+			c.IPv6, _ = netip.ParseAddr(*l.IPv6)
+		}
+		net.Connections = append(net.Connections, c)
+		ix.Participants = append(ix.Participants, c)
+	}
+	facilities := map[int]*PeeringDBFacility{}
+	for _, f := range objs.Fac {
+		if peeringDBActive(f.Status) {
+			facilities[f.ID] = &PeeringDBFacility{ID: f.ID, Name: f.Name, City: f.City, Country: peeringDBCountry(f.Country)}
+		}
+	}
+	for _, nf := range objs.NetFac {
+		net, fac := networks[nf.NetID], facilities[nf.FacID]
+		if peeringDBActive(nf.Status) && net != nil && fac != nil {
+			net.Facilities = append(net.Facilities, fac)
+		}
+	}
+	for _, net := range networks {
+		sort.SliceStable(net.Connections, func(i, j int) bool {
+			a, b := net.Connections[i], net.Connections[j]
+			if a.IXName != b.IXName {
+				return a.IXName < b.IXName
+			}
+			if a.IXID != b.IXID {
+				return a.IXID < b.IXID
+			}
+			return a.IPv4.Less(b.IPv4)
+		})
+		sort.SliceStable(net.Facilities, func(i, j int) bool {
+			return net.Facilities[i].Name < net.Facilities[j].Name
+		})
+	}
+	sort.Slice(db.ixs, func(i, j int) bool { return db.ixs[i].ID < db.ixs[j].ID })
+	for _, ix := range db.ixs {
+		sort.SliceStable(ix.Participants, func(i, j int) bool {
+			a, b := ix.Participants[i], ix.Participants[j]
+			if a.ASN != b.ASN {
+				return a.ASN < b.ASN
+			}
+			if a.IPv4 != b.IPv4 {
+				return a.IPv4.Less(b.IPv4)
+			}
+			return a.IPv6.Less(b.IPv6)
+		})
+	}
+	return db, nil
+}
+
+// peeringDBActive returns true for objects that aren't deleted or pending. The API only returns
+// active objects, so dumps of it may not have a status.
+func peeringDBActive(status string) bool {
+	return status == "" || status == "ok"
+}
+
+func peeringDBCountry(code string) countries.CountryCode {
+	if code == "" {
+		return countries.Unknown
+	}
+	return countries.ByName(code)
+}
+
+// network adds a PeeringDB network to a response, filling in the name if it's unknown.
+func (r *Response) network(n *PeeringDBNetwork) {
+	if n == nil {
+		return
+	}
+	r.PeeringDB = n
+	if r.Name == "" {
+		r.Name = n.Name
+	}
+}
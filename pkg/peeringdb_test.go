@@ -0,0 +1,170 @@
+package addr_test
+
+import (
+	"encoding/json"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/biter777/countries"
+	"github.com/stretchr/testify/assert"
+	addr "github.com/thatmattlove/addr/pkg"
+	goasn "github.com/thatmattlove/go-asn"
+)
+
+func readTestPeeringDB(t *testing.T) *addr.PeeringDB {
+	f, err := os.Open("testdata/peeringdb.json")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer f.Close()
+	db, err := addr.ReadPeeringDB(f)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return db
+}
+
+func Test_ReadPeeringDB(t *testing.T) {
+	t.Run("networks", func(t *testing.T) {
+		t.Parallel()
+		db := readTestPeeringDB(t)
+		assert.Equal(t, 3, db.Len())
+		assert.Nil(t, db.Network(64502), "deleted networks are skipped")
+		n := db.Network(64500)
+		if !assert.NotNil(t, n) {
+			t.FailNow()
+		}
+		assert.Equal(t, "Example Transit", n.Name)
+		assert.Equal(t, "RIPE::AS-EXTRANSIT", n.IRRASSet)
+		assert.Equal(t, "Selective", n.Policy)
+		assert.Equal(t, "1-5Tbps", n.Traffic)
+		assert.Equal(t, []string{"EX-IX Amsterdam", "EX-IX Frankfurt"}, n.IXPs())
+		assert.Len(t, n.Connections, 3)
+		assert.Equal(t, netip.MustParseAddr("192.0.2.10"), n.Connections[1].IPv4)
+		assert.Equal(t, 100000, n.Connections[1].Speed)
+		assert.True(t, n.Connections[1].RouteServer)
+		if assert.Len(t, n.Facilities, 2) {
+			assert.Equal(t, "Example DC Amsterdam 2", n.Facilities[0].Name)
+			assert.Equal(t, countries.NL, n.Facilities[0].Country)
+		}
+		n = db.Network(64501)
+		if assert.Len(t, n.Connections, 1, "connections that aren't operational are skipped") {
+			assert.False(t, n.Connections[0].IPv6.IsValid())
+		}
+	})
+	t.Run("errors", func(t *testing.T) {
+		t.Parallel()
+		for in, want := range map[string]string{
+			`{"data": []}`:                     "without an object type",
+			`{"net": {"data": [{"id": "x"}]}}`: "net: json",
+			`{"poc": {"data": []}}`:            addr.ErrEmptyPeeringDB.Error(),
+			`[`:                                "unexpected EOF",
+		} {
+			_, err := addr.ReadPeeringDB(strings.NewReader(in))
+			assert.ErrorContains(t, err, want, in)
+		}
+	})
+}
+
+func Test_LoadPeeringDB(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		"net.json":      `{"data": [{"id": 1, "asn": 64500, "name": "Example Transit", "policy_general": "Open"}], "meta": {}}`,
+		"ix.json":       `{"data": [{"id": 2, "name": "EX-IX", "country": "DE"}], "meta": {}}`,
+		"netixlan.json": `{"data": [{"net_id": 1, "ix_id": 2, "speed": 10000, "ipaddr4": "192.0.2.1", "ipaddr6": null}], "meta": {}}`,
+		"poc.json":      `{"data": [{"id": 3}], "meta": {}}`,
+	} {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644))
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	assert.NoError(t, err)
+	db := addr.OpenPeeringDB(paths...)
+	assert.NoError(t, db.Err())
+	ix, err := db.LookupIX("2")
+	if assert.NoError(t, err) {
+		assert.Equal(t, countries.DE, ix.Country)
+		assert.Equal(t, []*addr.PeeringDBConnection{{
+			IXID: 2, IXName: "EX-IX", ASN: 64500, NetName: "Example Transit", Speed: 10000,
+			IPv4: netip.MustParseAddr("192.0.2.1"),
+		}}, ix.Participants)
+	}
+
+	db = addr.OpenPeeringDB(filepath.Join(dir, "missing.json"))
+	assert.Error(t, db.Err())
+	assert.Nil(t, db.Network(64500))
+}
+
+func TestPeeringDB_LookupIX(t *testing.T) {
+	t.Parallel()
+	db := readTestPeeringDB(t)
+	for q, want := range map[string]int{
+		"2":                                   2,
+		"ex-ix frankfurt":                     1,
+		"Example Internet Exchange Amsterdam": 2,
+		"sample":                              3,
+		"berlin":                              0,
+	} {
+		ix, err := db.LookupIX(q)
+		if want == 0 {
+			assert.ErrorContains(t, err, "no IXP matching 'berlin'")
+			continue
+		}
+		if assert.NoError(t, err, q) {
+			assert.Equal(t, want, ix.ID, q)
+		}
+	}
+	_, err := db.LookupIX("EX-IX")
+	assert.ErrorContains(t, err, "'EX-IX' matches 2 IXPs: EX-IX Frankfurt (1), EX-IX Amsterdam (2)")
+	_, err = db.LookupIX("9")
+	assert.ErrorContains(t, err, "no IXP with ID 9")
+
+	ix, err := db.LookupIX("1")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, ix.Networks())
+	asns := []uint32{}
+	for _, p := range ix.Participants {
+		asns = append(asns, p.ASN)
+	}
+	assert.Equal(t, []uint32{64500, 64500, 64501}, asns)
+	b, err := json.Marshal(ix)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `"country":"DE","website":"https://ex-ix.example","networks":2,"participants":[{"ix_id":1,"ix":"EX-IX Frankfurt","asn":64500,"name":"Example Transit","speed":100000,"ipv4":"192.0.2.10","ipv6":"2001:db8::10","route_server":true}`)
+}
+
+func TestResult_PeeringDB(t *testing.T) {
+	t.Parallel()
+	n := readTestPeeringDB(t).Network(64500)
+	r := addr.NewASNResult("AS64500", &addr.Response{ASN: goasn.FromUint32(64500), PeeringDB: n})
+	assert.Equal(t, "RIPE::AS-EXTRANSIT", r.Column("irr_as_set"))
+	assert.Equal(t, "Selective", r.Column("policy"))
+	assert.Equal(t, "1-5Tbps", r.Column("traffic"))
+	assert.Equal(t, "EX-IX Amsterdam; EX-IX Frankfurt", r.Column("ixps"))
+	assert.Equal(t, "Example DC Amsterdam 2; Example DC Frankfurt 1", r.Column("facilities"))
+	b, err := json.Marshal(r)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `"facilities":[{"id":21,"name":"Example DC Amsterdam 2","city":"Amsterdam","country":"NL"}`)
+	out := &addr.Result{}
+	assert.NoError(t, json.Unmarshal(b, out))
+	assert.Equal(t, n, out.PeeringDB)
+}
+
+func Test_QueryASNWith_PeeringDB(t *testing.T) {
+	t.Parallel()
+	opts := addr.QueryOptions{Offline: true, PeeringDB: readTestPeeringDB(t)}
+	r, err := addr.QueryASNWith(nil, "AS3333", opts)
+	assert.NoError(t, err)
+	assert.Equal(t, "RIPE NCC", r.Name)
+	assert.Equal(t, "Open", r.PeeringDB.Policy)
+	_, err = addr.QueryASNWith(nil, "AS10", opts)
+	assert.ErrorIs(t, err, addr.ErrOfflineASN)
+
+	malformed := filepath.Join(t.TempDir(), "net.json")
+	assert.NoError(t, os.WriteFile(malformed, []byte("{"), 0o644))
+	opts.PeeringDB = addr.OpenPeeringDB(malformed)
+	_, err = addr.QueryASNWith(nil, "AS3333", opts)
+	assert.ErrorContains(t, err, "failed to load PeeringDB: "+malformed)
+}
@@ -0,0 +1,153 @@
+package addr
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"strings"
+
+	goasn "github.com/thatmattlove/go-asn"
+)
+
+// prefixIndex maps prefixes to values, and finds the most specific prefix containing an address.
+type prefixIndex[T any] struct {
+	// lengths are the prefix lengths in the index, per address family, longest first.
+	lengths map[bool][]int
+	entries map[netip.Prefix]T
+}
+
+func newPrefixIndex[T any]() *prefixIndex[T] {
+	return &prefixIndex[T]{lengths: map[bool][]int{}, entries: map[netip.Prefix]T{}}
+}
+
+func (x *prefixIndex[T]) get(p netip.Prefix) (T, bool) {
+	v, ok := x.entries[p.Masked()]
+	return v, ok
+}
+
+// set adds or replaces the value of a prefix.
+func (x *prefixIndex[T]) set(p netip.Prefix, v T) {
+	p = p.Masked()
+	if _, ok := x.entries[p]; !ok {
+		v4 := p.Addr().Is4()
+		lengths := x.lengths[v4]
+		i := 0
+		for i < len(lengths) && lengths[i] > p.Bits() {
+			i++
+		}
+		if i == len(lengths) || lengths[i] != p.Bits() {
+			lengths = append(lengths, 0)
+			copy(lengths[i+1:], lengths[i:])
+			lengths[i] = p.Bits()
+			x.lengths[v4] = lengths
+		}
+	}
+	x.entries[p] = v
+}
+
+// lookup returns the most specific prefix containing a, and its value.
+func (x *prefixIndex[T]) lookup(a netip.Addr) (netip.Prefix, T, bool) {
+	a = a.Unmap().WithZone("")
+	for _, bits := range x.lengths[a.Is4()] {
+		p, err := a.Prefix(bits)
+		if err != nil {
+			continue
+		}
+		if v, ok := x.entries[p]; ok {
+			return p, v, true
+		}
+	}
+	var zero T
+	return netip.Prefix{}, zero, false
+}
+
+// PrefixTable maps prefixes to origin ASNs, for offline lookups. Lookups return the most specific
+// matching prefix.
+type PrefixTable struct {
+	index *prefixIndex[uint32]
+}
+
+func NewPrefixTable() *PrefixTable {
+	return &PrefixTable{index: newPrefixIndex[uint32]()}
+}
+
+// Insert adds or replaces the origin ASN of a prefix.
+func (t *PrefixTable) Insert(p netip.Prefix, asn uint32) {
+	t.index.set(p, asn)
+}
+
+// Lookup returns the most specific prefix containing a, and its origin ASN.
+func (t *PrefixTable) Lookup(a netip.Addr) (netip.Prefix, uint32, bool) {
+	return t.index.lookup(a)
+}
+
+func (t *PrefixTable) Len() int {
+	return len(t.index.entries)
+}
+
+type prefixTableJSON struct {
+	CIDR string `json:"CIDR"`
+	ASN  uint32 `json:"ASN"`
+}
+
+// ParsePrefixTable parses a table of prefixes & origin ASNs, in bgp.tools' table.txt format
+// ('<prefix> <asn>' per line, whitespace or comma separated) or table.jsonl format
+// ('{"CIDR": "<prefix>", "ASN": <asn>}' per line). Lines starting with '#' are ignored.
+func ParsePrefixTable(r io.Reader) (*PrefixTable, error) {
+	t := NewPrefixTable()
+	scanner := bufio.NewScanner(r)
+	n := 0
+	for scanner.Scan() {
+		n++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var prefix, asn string
+		if strings.HasPrefix(line, "{") {
+			e := prefixTableJSON{}
+			err := json.Unmarshal([]byte(line), &e)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			prefix, asn = e.CIDR, fmt.Sprint(e.ASN)
+		} else {
+			fields := strings.FieldsFunc(line, func(r rune) bool {
+				return r == ',' || r == ' ' || r == '\t'
+			})
+			if len(fields) < 2 {
+				return nil, fmt.Errorf("line %d: expected a prefix and an ASN", n)
+			}
+			prefix, asn = fields[0], fields[1]
+		}
+		p, err := netip.ParsePrefix(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		a, err := goasn.Parse(strings.TrimPrefix(strings.ToUpper(asn), "AS"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid ASN '%s'", n, asn)
+		}
+		t.Insert(p, a.Uint32())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func LoadPrefixTable(path string) (*PrefixTable, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	t, err := ParsePrefixTable(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return t, nil
+}
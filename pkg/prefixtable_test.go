@@ -0,0 +1,72 @@
+package addr_test
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	addr "github.com/thatmattlove/addr/pkg"
+)
+
+func TestPrefixTable_Lookup(t *testing.T) {
+	table := addr.NewPrefixTable()
+	table.Insert(netip.MustParsePrefix("1.0.0.0/8"), 64500)
+	table.Insert(netip.MustParsePrefix("1.1.1.0/24"), 13335)
+	table.Insert(netip.MustParsePrefix("1.1.0.0/16"), 64501)
+	table.Insert(netip.MustParsePrefix("2606:4700::/32"), 13335)
+	cases := []struct {
+		addr   string
+		prefix string
+		asn    uint32
+	}{
+		{"1.1.1.1", "1.1.1.0/24", 13335},
+		{"1.1.2.1", "1.1.0.0/16", 64501},
+		{"1.2.3.4", "1.0.0.0/8", 64500},
+		{"::ffff:1.1.1.1", "1.1.1.0/24", 13335},
+		{"2606:4700:4700::1111", "2606:4700::/32", 13335},
+		{"8.8.8.8", "", 0},
+		{"2001:db8::1", "", 0},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.addr, func(t *testing.T) {
+			t.Parallel()
+			p, asn, ok := table.Lookup(netip.MustParseAddr(c.addr))
+			assert.Equal(t, c.asn != 0, ok)
+			assert.Equal(t, c.asn, asn)
+			if ok {
+				assert.Equal(t, c.prefix, p.String())
+			}
+		})
+	}
+	assert.Equal(t, 4, table.Len())
+}
+
+func Test_ParsePrefixTable(t *testing.T) {
+	t.Run("text", func(t *testing.T) {
+		t.Parallel()
+		table, err := addr.ParsePrefixTable(strings.NewReader("# bgp.tools table.txt\n1.1.1.0/24 13335\n8.8.8.0/24,AS15169\n\n2001:4860::/32\t15169\n"))
+		assert.NoError(t, err)
+		assert.Equal(t, 3, table.Len())
+		_, asn, _ := table.Lookup(netip.MustParseAddr("8.8.8.8"))
+		assert.Equal(t, uint32(15169), asn)
+	})
+	t.Run("jsonl", func(t *testing.T) {
+		t.Parallel()
+		table, err := addr.ParsePrefixTable(strings.NewReader(`{"CIDR":"1.1.1.0/24","ASN":13335,"Hits":100}` + "\n" + `{"CIDR":"2606:4700::/32","ASN":13335,"Hits":50}`))
+		assert.NoError(t, err)
+		_, asn, ok := table.Lookup(netip.MustParseAddr("2606:4700::1"))
+		assert.True(t, ok)
+		assert.Equal(t, uint32(13335), asn)
+	})
+	t.Run("errors", func(t *testing.T) {
+		t.Parallel()
+		_, err := addr.ParsePrefixTable(strings.NewReader("1.1.1.0/24 13335\n1.1.1.0/33 13335\n"))
+		assert.ErrorContains(t, err, "line 2")
+		_, err = addr.ParsePrefixTable(strings.NewReader("1.1.1.0/24 cloudflare\n"))
+		assert.ErrorContains(t, err, "invalid ASN 'cloudflare'")
+		_, err = addr.ParsePrefixTable(strings.NewReader("1.1.1.0/24\n"))
+		assert.ErrorContains(t, err, "expected a prefix and an ASN")
+	})
+}
@@ -0,0 +1,896 @@
+package addr
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/biter777/countries"
+	goasn "github.com/thatmattlove/go-asn"
+)
+
+const (
+	RESULT_IP  string = "ip"
+	RESULT_ASN string = "asn"
+)
+
+// Result is the data model used to render lookup results with templates & other output formats.
+type Result struct {
+	// Query is the argument as given, e.g. '192.0.2.1' or 'AS14525'. For IP & ASN ranges, Query
+	// is the prefix or ASN within the range.
+	Query string
+	// Type is either RESULT_IP or RESULT_ASN, or empty if the argument wasn't recognized.
+	Type string
+	// IP is the address looked up. Empty for ASN results.
+	IP string
+	// Prefix is the advertised or special-purpose prefix containing IP. Empty for ASN results.
+	Prefix string
+	// ASN is the origin ASN for IP results, or the ASN looked up. Zero if unknown.
+	ASN       goasn.ASN
+	Name      string
+	Country   countries.CountryCode
+	Registry  string
+	Allocated time.Time
+	// Advertised is true if the result came from bgp.tools, rather than from a special-purpose
+	// registry or local definition.
+	Advertised bool
+	// PTRs are the reverse DNS names of IP.
+	PTRs []string
+	// Special is the special-purpose registry entry containing IP, if any.
+	Special *SpecialPrefix
+	// SpecialASN is the special-purpose registry entry containing ASN, if any.
+	SpecialASN *SpecialASN
+	// Embedded is the IPv4 address embedded in IP, if any.
+	Embedded *Embedded
+	// Local is the local definition matching IP or ASN, if any.
+	Local *LocalDefinition
+	// Geo is the location of IP from QueryOptions.MMDB, if any.
+	Geo *GeoLocation
+	// GeoASN is the origin of IP from QueryOptions.MMDB, if any.
+	GeoASN *MMDBASN
+	// Delegation is the RIR delegation containing IP or ASN from QueryOptions.Delegated, if any.
+	Delegation *Delegation
+	// Route is the routes to Prefix from QueryOptions.RIB, if any.
+	Route *RIBEntry
+	// Neighbors are the upstreams, downstreams & peers of ASN, if requested.
+	Neighbors *ASNeighbors
+	// PeeringDB is the PeeringDB network of ASN from QueryOptions.PeeringDB, if any.
+	PeeringDB *PeeringDBNetwork
+	// Error is set if the lookup failed, in which case only Query & Type are set.
+	Error string
+}
+
+// RESULT_COLUMNS are the columns available to tabular output formats.
+var RESULT_COLUMNS = []string{
+	"target", "ip", "prefix", "asn", "name", "country", "registry", "allocated", "ptr", "special", "error",
+	"city", "region", "coordinates", "mmdb_asn", "mmdb_name", "status", "holder_id",
+	"origins", "upstreams", "peers", "downstreams", "peer_asns",
+	"irr_as_set", "policy", "traffic", "ixps", "facilities",
+}
+
+var DEFAULT_COLUMNS = []string{"target", "ip", "prefix", "asn", "name", "country", "registry"}
+
+// TEMPLATE_FUNCS are the functions available to output templates.
+var TEMPLATE_FUNCS = template.FuncMap{
+	"asplain":      func(asn goasn.ASN) string { return asn.ASPlain() },
+	"asdot":        func(asn goasn.ASN) string { return asn.ASDot() },
+	"countryCode":  countryFunc(countries.CountryCode.Alpha2),
+	"countryName":  countryFunc(countries.CountryCode.String),
+	"countryEmoji": countryFunc(countries.CountryCode.Emoji),
+	"prefixLen":    prefixLen,
+	"join":         strings.Join,
+}
+
+func NewIPResult(query string, r *Response, ptrs []string) *Result {
+	res := newResult(query, RESULT_IP, r)
+	if r.Addr.IsValid() {
+		res.IP = r.Addr.String()
+	}
+	if r.Network.IsValid() {
+		res.Prefix = r.Network.String()
+	}
+	res.PTRs = ptrs
+	res.Embedded = r.Embedded
+	res.Geo, res.GeoASN = r.Geo, r.GeoASN
+	res.Route = r.Route
+	if !r.FromQuery && r.Local == nil && r.Addr.IsValid() {
+		res.Special = SPECIAL_TABLE.Lookup(r.Addr)
+	}
+	return res
+}
+
+func NewASNResult(query string, r *Response) *Result {
+	res := newResult(query, RESULT_ASN, r)
+	res.Neighbors = r.Neighbors
+	res.PeeringDB = r.PeeringDB
+	if !r.FromQuery && r.Local == nil {
+		res.SpecialASN = SPECIAL_ASN_TABLE.Lookup(res.ASN)
+	}
+	return res
+}
+
+func NewErrorResult(query, resultType string, err error) *Result {
+	return &Result{
+		Query: query,
+		Type:  resultType,
+		ASN:   goasn.FromUint32(0),
+		Error: err.Error(),
+	}
+}
+
+// ValidateColumns returns an error naming the first column that isn't one of RESULT_COLUMNS.
+func ValidateColumns(columns []string) error {
+	for _, c := range columns {
+		known := false
+		for _, k := range RESULT_COLUMNS {
+			if c == k {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("unknown column '%s', must be one of %s", c, strings.Join(RESULT_COLUMNS, ", "))
+		}
+	}
+	return nil
+}
+
+// Column returns the value of one of RESULT_COLUMNS as a string. Unknown values, such as the ASN
+// of a prefix that isn't advertised, are empty.
+func (r *Result) Column(name string) string {
+	switch name {
+	case "target":
+		return r.Query
+	case "ip":
+		return r.IP
+	case "prefix":
+		return r.Prefix
+	case "asn":
+		if r.ASN == nil || r.ASN.Uint32() == 0 {
+			return ""
+		}
+		return r.ASN.ASPlain()
+	case "name":
+		return r.Name
+	case "country":
+		return countryFunc(countries.CountryCode.Alpha2)(r.Country)
+	case "registry":
+		return r.Registry
+	case "allocated":
+		if r.Allocated.IsZero() {
+			return ""
+		}
+		return r.Allocated.Format(time.DateOnly)
+	case "ptr":
+		return strings.Join(r.PTRs, " ")
+	case "special":
+		if r.Special != nil {
+			return r.Special.Name
+		}
+		if r.SpecialASN != nil {
+			return r.SpecialASN.Name
+		}
+		return ""
+	case "error":
+		return r.Error
+	case "city":
+		if r.Geo != nil {
+			return r.Geo.City
+		}
+		return ""
+	case "region":
+		if r.Geo != nil {
+			return r.Geo.Region
+		}
+		return ""
+	case "coordinates":
+		if r.Geo != nil && (r.Geo.Latitude != 0 || r.Geo.Longitude != 0) {
+			return fmt.Sprintf("%g,%g", r.Geo.Latitude, r.Geo.Longitude)
+		}
+		return ""
+	case "mmdb_asn":
+		if r.GeoASN != nil {
+			return r.GeoASN.ASN.ASPlain()
+		}
+		return ""
+	case "mmdb_name":
+		if r.GeoASN != nil {
+			return r.GeoASN.Name
+		}
+		return ""
+	case "status":
+		if r.Delegation != nil {
+			return r.Delegation.Status
+		}
+		return ""
+	case "holder_id":
+		if r.Delegation != nil {
+			return r.Delegation.OpaqueID
+		}
+		return ""
+	case "origins":
+		if r.Route == nil {
+			return ""
+		}
+		origins := make([]uint32, 0, len(r.Route.Origins))
+		for _, o := range r.Route.Origins {
+			origins = append(origins, o.ASN)
+		}
+		return FormatASPath(origins)
+	case "upstreams":
+		if r.Route != nil {
+			return FormatASPath(r.Route.Upstreams)
+		}
+		if r.Neighbors != nil {
+			return formatNeighbors(r.Neighbors.Upstreams)
+		}
+		return ""
+	case "peers":
+		if r.Route != nil {
+			return fmt.Sprint(r.Route.Peers)
+		}
+		return ""
+	case "downstreams":
+		if r.Neighbors != nil {
+			return formatNeighbors(r.Neighbors.Downstreams)
+		}
+		return ""
+	case "peer_asns":
+		if r.Neighbors != nil {
+			return formatNeighbors(r.Neighbors.Peers)
+		}
+		return ""
+	case "irr_as_set":
+		if r.PeeringDB != nil {
+			return r.PeeringDB.IRRASSet
+		}
+		return ""
+	case "policy":
+		if r.PeeringDB != nil {
+			return r.PeeringDB.Policy
+		}
+		return ""
+	case "traffic":
+		if r.PeeringDB != nil {
+			return r.PeeringDB.Traffic
+		}
+		return ""
+	case "ixps":
+		if r.PeeringDB != nil {
+			return strings.Join(r.PeeringDB.IXPs(), "; ")
+		}
+		return ""
+	case "facilities":
+		if r.PeeringDB == nil {
+			return ""
+		}
+		names := make([]string, 0, len(r.PeeringDB.Facilities))
+		for _, f := range r.PeeringDB.Facilities {
+			names = append(names, f.Name)
+		}
+		return strings.Join(names, "; ")
+	default:
+		return ""
+	}
+}
+
+// ParseTemplate parses an output template, such as '{{.IP}} {{.ASN}} {{.Name}}', with
+// TEMPLATE_FUNCS available.
+func ParseTemplate(text string) (*template.Template, error) {
+	return template.New("format").Funcs(TEMPLATE_FUNCS).Parse(text)
+}
+
+// RenderTemplate renders a result with a template parsed by ParseTemplate.
+func RenderTemplate(t *template.Template, r *Result) (string, error) {
+	b := &strings.Builder{}
+	err := t.Execute(b, r)
+	if err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func newResult(query, resultType string, r *Response) *Result {
+	asn := r.ASN
+	if asn == nil {
+		asn = goasn.FromUint32(0)
+	}
+	return &Result{
+		Query:      query,
+		Type:       resultType,
+		ASN:        asn,
+		Name:       r.Name,
+		Country:    r.Country,
+		Registry:   r.Registry,
+		Allocated:  r.Allocated,
+		Advertised: r.FromQuery,
+		Local:      r.Local,
+		Delegation: r.Delegation,
+	}
+}
+
+// countryFunc wraps a country code method so that unknown countries render as an empty string.
+func countryFunc(f func(countries.CountryCode) string) func(countries.CountryCode) string {
+	return func(c countries.CountryCode) string {
+		if c == countries.Unknown {
+			return ""
+		}
+		return f(c)
+	}
+}
+
+// formatNeighbors formats the ASNs of neighbors as space separated ASNs, like FormatASPath.
+func formatNeighbors(neighbors []ASNeighbor) string {
+	asns := make([]uint32, 0, len(neighbors))
+	for _, n := range neighbors {
+		asns = append(asns, n.ASN)
+	}
+	return FormatASPath(asns)
+}
+
+// prefixLen returns the length of a prefix such as Result.Prefix, or -1 if it isn't valid.
+func prefixLen(prefix string) int {
+	p, err := netip.ParsePrefix(prefix)
+	if err != nil {
+		return -1
+	}
+	return p.Bits()
+}
+
+type resultJSON struct {
+	Query      string                `json:"query"`
+	Type       string                `json:"type"`
+	IP         string                `json:"ip,omitempty"`
+	Prefix     string                `json:"prefix,omitempty"`
+	ASN        uint32                `json:"asn"`
+	Name       string                `json:"name"`
+	Country    string                `json:"country"`
+	Registry   string                `json:"registry"`
+	Allocated  string                `json:"allocated"`
+	Advertised bool                  `json:"advertised"`
+	PTRs       []string              `json:"ptrs"`
+	Special    *specialPrefixJSON    `json:"special,omitempty"`
+	SpecialASN *specialASNJSON       `json:"special_asn,omitempty"`
+	Embedded   *embeddedJSON         `json:"embedded,omitempty"`
+	Local      *localDefinitionJSON  `json:"local,omitempty"`
+	Geo        *geoLocationJSON      `json:"geo,omitempty"`
+	GeoASN     *mmdbASNJSON          `json:"mmdb_asn,omitempty"`
+	Delegation *delegationJSON       `json:"delegation,omitempty"`
+	Route      *ribEntryJSON         `json:"route,omitempty"`
+	Neighbors  *asNeighborsJSON      `json:"neighbors,omitempty"`
+	PeeringDB  *peeringDBNetworkJSON `json:"peeringdb,omitempty"`
+	Error      string                `json:"error,omitempty"`
+}
+
+type geoLocationJSON struct {
+	City           string  `json:"city,omitempty"`
+	Region         string  `json:"region,omitempty"`
+	RegionCode     string  `json:"region_code,omitempty"`
+	Country        string  `json:"country,omitempty"`
+	Latitude       float64 `json:"latitude"`
+	Longitude      float64 `json:"longitude"`
+	AccuracyRadius uint16  `json:"accuracy_radius,omitempty"`
+	TimeZone       string  `json:"time_zone,omitempty"`
+}
+
+type mmdbASNJSON struct {
+	ASN     uint32 `json:"asn"`
+	Name    string `json:"name"`
+	Network string `json:"network,omitempty"`
+}
+
+type ribOriginJSON struct {
+	ASN   uint32 `json:"asn"`
+	Peers int    `json:"peers"`
+}
+
+type ribEntryJSON struct {
+	Prefix      string          `json:"prefix"`
+	Peers       int             `json:"peers"`
+	Origins     []ribOriginJSON `json:"origins"`
+	Upstreams   []uint32        `json:"upstreams"`
+	Paths       [][]uint32      `json:"paths"`
+	Communities []string        `json:"communities"`
+}
+
+type asNeighborJSON struct {
+	ASN      uint32 `json:"asn"`
+	Paths    int    `json:"paths"`
+	Inferred bool   `json:"inferred"`
+}
+
+type asNeighborsJSON struct {
+	Upstreams   []asNeighborJSON `json:"upstreams"`
+	Downstreams []asNeighborJSON `json:"downstreams"`
+	Peers       []asNeighborJSON `json:"peers"`
+}
+
+type peeringDBConnectionJSON struct {
+	IXID        int    `json:"ix_id"`
+	IX          string `json:"ix"`
+	ASN         uint32 `json:"asn"`
+	Name        string `json:"name"`
+	Speed       int    `json:"speed"`
+	IPv4        string `json:"ipv4"`
+	IPv6        string `json:"ipv6"`
+	RouteServer bool   `json:"route_server"`
+}
+
+type peeringDBFacilityJSON struct {
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	City    string `json:"city"`
+	Country string `json:"country"`
+}
+
+type peeringDBNetworkJSON struct {
+	ID         int                       `json:"id"`
+	Name       string                    `json:"name"`
+	AKA        string                    `json:"aka"`
+	Website    string                    `json:"website"`
+	IRRASSet   string                    `json:"irr_as_set"`
+	Policy     string                    `json:"policy"`
+	PolicyURL  string                    `json:"policy_url"`
+	Traffic    string                    `json:"traffic"`
+	Ratio      string                    `json:"ratio"`
+	Scope      string                    `json:"scope"`
+	Type       string                    `json:"type"`
+	IXPs       []peeringDBConnectionJSON `json:"ixps"`
+	Facilities []peeringDBFacilityJSON   `json:"facilities"`
+}
+
+type delegationJSON struct {
+	Registry string `json:"registry"`
+	Country  string `json:"country"`
+	Type     string `json:"type"`
+	Start    string `json:"start"`
+	End      string `json:"end"`
+	Date     string `json:"date"`
+	Status   string `json:"status"`
+	OpaqueID string `json:"opaque_id,omitempty"`
+}
+
+type specialPrefixJSON struct {
+	Prefix            string `json:"prefix"`
+	Name              string `json:"name"`
+	Label             string `json:"label"`
+	RFC               string `json:"rfc"`
+	GloballyReachable bool   `json:"globally_reachable"`
+}
+
+type specialASNJSON struct {
+	Low       uint32 `json:"low"`
+	High      uint32 `json:"high"`
+	Name      string `json:"name"`
+	Reference string `json:"reference"`
+}
+
+type embeddedJSON struct {
+	Type   string  `json:"type"`
+	IPv4   string  `json:"ipv4"`
+	Prefix string  `json:"prefix"`
+	Server string  `json:"server,omitempty"`
+	Port   uint16  `json:"port,omitempty"`
+	Origin *Result `json:"origin,omitempty"`
+}
+
+type localDefinitionJSON struct {
+	Prefix string   `json:"prefix,omitempty"`
+	ASN    uint32   `json:"asn,omitempty"`
+	Name   string   `json:"name"`
+	Site   string   `json:"site,omitempty"`
+	Owner  string   `json:"owner,omitempty"`
+	Tags   []string `json:"tags,omitempty"`
+}
+
+// MarshalJSON encodes a result with ASNs as numbers, countries as ISO 3166-1 alpha-2 codes and
+// dates as YYYY-MM-DD.
+func (r *Result) MarshalJSON() ([]byte, error) {
+	out := resultJSON{
+		Query:      r.Query,
+		Type:       r.Type,
+		IP:         r.IP,
+		Prefix:     r.Prefix,
+		Name:       r.Name,
+		Country:    r.Column("country"),
+		Registry:   r.Registry,
+		Allocated:  r.Column("allocated"),
+		Advertised: r.Advertised,
+		PTRs:       r.PTRs,
+		Error:      r.Error,
+	}
+	if out.PTRs == nil {
+		out.PTRs = []string{}
+	}
+	if r.ASN != nil {
+		out.ASN = r.ASN.Uint32()
+	}
+	if r.Special != nil {
+		out.Special = &specialPrefixJSON{
+			Prefix:            r.Special.Prefix.String(),
+			Name:              r.Special.Name,
+			Label:             r.Special.Label,
+			RFC:               r.Special.RFC,
+			GloballyReachable: r.Special.GloballyReachable,
+		}
+	}
+	if r.SpecialASN != nil {
+		out.SpecialASN = &specialASNJSON{
+			Low:       r.SpecialASN.Low.Uint32(),
+			High:      r.SpecialASN.High.Uint32(),
+			Name:      r.SpecialASN.Name,
+			Reference: r.SpecialASN.Reference,
+		}
+	}
+	if e := r.Embedded; e != nil {
+		out.Embedded = &embeddedJSON{
+			Type:   e.Type,
+			IPv4:   e.IPv4.String(),
+			Prefix: e.Prefix.String(),
+			Port:   e.Port,
+		}
+		if e.Server.IsValid() {
+			out.Embedded.Server = e.Server.String()
+		}
+		if e.Origin != nil {
+			out.Embedded.Origin = NewIPResult(e.IPv4.String(), e.Origin, nil)
+		}
+	}
+	if l := r.Local; l != nil {
+		out.Local = &localDefinitionJSON{
+			Name:  l.Name,
+			Site:  l.Site,
+			Owner: l.Owner,
+			Tags:  l.Tags,
+		}
+		if l.Prefix.IsValid() {
+			out.Local.Prefix = l.Prefix.String()
+		}
+		if l.ASN != nil {
+			out.Local.ASN = l.ASN.Uint32()
+		}
+	}
+	if g := r.Geo; g != nil {
+		out.Geo = &geoLocationJSON{
+			City:           g.City,
+			Region:         g.Region,
+			RegionCode:     g.RegionCode,
+			Country:        countryFunc(countries.CountryCode.Alpha2)(g.Country),
+			Latitude:       g.Latitude,
+			Longitude:      g.Longitude,
+			AccuracyRadius: g.AccuracyRadius,
+			TimeZone:       g.TimeZone,
+		}
+	}
+	if a := r.GeoASN; a != nil {
+		out.GeoASN = &mmdbASNJSON{ASN: a.ASN.Uint32(), Name: a.Name}
+		if a.Network.IsValid() {
+			out.GeoASN.Network = a.Network.String()
+		}
+	}
+	if e := r.Route; e != nil {
+		out.Route = &ribEntryJSON{
+			Prefix:      e.Prefix.String(),
+			Peers:       e.Peers,
+			Origins:     []ribOriginJSON{},
+			Upstreams:   e.Upstreams,
+			Paths:       e.Paths,
+			Communities: e.Communities,
+		}
+		for _, o := range e.Origins {
+			out.Route.Origins = append(out.Route.Origins, ribOriginJSON{ASN: o.ASN, Peers: o.Peers})
+		}
+		if out.Route.Upstreams == nil {
+			out.Route.Upstreams = []uint32{}
+		}
+		if out.Route.Paths == nil {
+			out.Route.Paths = [][]uint32{}
+		}
+		if out.Route.Communities == nil {
+			out.Route.Communities = []string{}
+		}
+	}
+	if n := r.Neighbors; n != nil {
+		out.Neighbors = &asNeighborsJSON{
+			Upstreams:   neighborsJSON(n.Upstreams),
+			Downstreams: neighborsJSON(n.Downstreams),
+			Peers:       neighborsJSON(n.Peers),
+		}
+	}
+	if n := r.PeeringDB; n != nil {
+		out.PeeringDB = &peeringDBNetworkJSON{
+			ID:         n.ID,
+			Name:       n.Name,
+			AKA:        n.AKA,
+			Website:    n.Website,
+			IRRASSet:   n.IRRASSet,
+			Policy:     n.Policy,
+			PolicyURL:  n.PolicyURL,
+			Traffic:    n.Traffic,
+			Ratio:      n.Ratio,
+			Scope:      n.Scope,
+			Type:       n.Type,
+			IXPs:       peeringDBConnectionsJSON(n.Connections),
+			Facilities: []peeringDBFacilityJSON{},
+		}
+		for _, f := range n.Facilities {
+			out.PeeringDB.Facilities = append(out.PeeringDB.Facilities, peeringDBFacilityJSON{
+				ID:      f.ID,
+				Name:    f.Name,
+				City:    f.City,
+				Country: countryFunc(countries.CountryCode.Alpha2)(f.Country),
+			})
+		}
+	}
+	if d := r.Delegation; d != nil {
+		out.Delegation = &delegationJSON{
+			Registry: d.Registry,
+			Country:  countryFunc(countries.CountryCode.Alpha2)(d.Country),
+			Type:     d.Type,
+			Start:    d.Start(),
+			End:      d.Range.End.String(),
+			Status:   d.Status,
+			OpaqueID: d.OpaqueID,
+		}
+		if d.Type == DELEGATED_ASN {
+			out.Delegation.End = d.High.ASPlain()
+		}
+		if !d.Date.IsZero() {
+			out.Delegation.Date = d.Date.Format(time.DateOnly)
+		}
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON decodes a result encoded by MarshalJSON. Embedded.Origin isn't restored.
+func (r *Result) UnmarshalJSON(b []byte) error {
+	in := resultJSON{}
+	err := json.Unmarshal(b, &in)
+	if err != nil {
+		return err
+	}
+	*r = Result{
+		Query:      in.Query,
+		Type:       in.Type,
+		IP:         in.IP,
+		Prefix:     in.Prefix,
+		ASN:        goasn.FromUint32(in.ASN),
+		Name:       in.Name,
+		Country:    countries.Unknown,
+		Registry:   in.Registry,
+		Advertised: in.Advertised,
+		PTRs:       in.PTRs,
+		Error:      in.Error,
+	}
+	if in.Country != "" {
+		r.Country = countries.ByName(in.Country)
+	}
+	if in.Allocated != "" {
+		r.Allocated, err = time.Parse(time.DateOnly, in.Allocated)
+		if err != nil {
+			return err
+		}
+	}
+	if s := in.Special; s != nil {
+		p, err := netip.ParsePrefix(s.Prefix)
+		if err != nil {
+			return err
+		}
+		r.Special = &SpecialPrefix{Prefix: p, Name: s.Name, Label: s.Label, RFC: s.RFC, GloballyReachable: s.GloballyReachable}
+	}
+	if s := in.SpecialASN; s != nil {
+		r.SpecialASN = &SpecialASN{
+			Low:       goasn.FromUint32(s.Low),
+			High:      goasn.FromUint32(s.High),
+			Name:      s.Name,
+			Reference: s.Reference,
+		}
+	}
+	if e := in.Embedded; e != nil {
+		r.Embedded = &Embedded{Type: e.Type, Port: e.Port}
+		r.Embedded.IPv4, err = netip.ParseAddr(e.IPv4)
+		if err != nil {
+			return err
+		}
+		r.Embedded.Prefix, err = netip.ParsePrefix(e.Prefix)
+		if err != nil {
+			return err
+		}
+		if e.Server != "" {
+			r.Embedded.Server, err = netip.ParseAddr(e.Server)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if l := in.Local; l != nil {
+		r.Local = &LocalDefinition{Name: l.Name, Site: l.Site, Owner: l.Owner, Tags: l.Tags}
+		if l.Prefix != "" {
+			r.Local.Prefix, err = netip.ParsePrefix(l.Prefix)
+			if err != nil {
+				return err
+			}
+		}
+		if l.ASN != 0 {
+			r.Local.ASN = goasn.FromUint32(l.ASN)
+		}
+	}
+	if g := in.Geo; g != nil {
+		r.Geo = &GeoLocation{
+			City:           g.City,
+			Region:         g.Region,
+			RegionCode:     g.RegionCode,
+			Country:        countries.Unknown,
+			Latitude:       g.Latitude,
+			Longitude:      g.Longitude,
+			AccuracyRadius: g.AccuracyRadius,
+			TimeZone:       g.TimeZone,
+		}
+		if g.Country != "" {
+			r.Geo.Country = countries.ByName(g.Country)
+		}
+	}
+	if a := in.GeoASN; a != nil {
+		r.GeoASN = &MMDBASN{ASN: goasn.FromUint32(a.ASN), Name: a.Name}
+		if a.Network != "" {
+			r.GeoASN.Network, err = netip.ParsePrefix(a.Network)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if e := in.Route; e != nil {
+		r.Route = &RIBEntry{
+			Peers:       e.Peers,
+			Upstreams:   e.Upstreams,
+			Paths:       e.Paths,
+			Communities: e.Communities,
+		}
+		r.Route.Prefix, err = netip.ParsePrefix(e.Prefix)
+		if err != nil {
+			return err
+		}
+		for _, o := range e.Origins {
+			r.Route.Origins = append(r.Route.Origins, RIBOrigin{ASN: o.ASN, Peers: o.Peers})
+		}
+	}
+	if n := in.Neighbors; n != nil {
+		r.Neighbors = &ASNeighbors{
+			Upstreams:   neighborsFromJSON(n.Upstreams),
+			Downstreams: neighborsFromJSON(n.Downstreams),
+			Peers:       neighborsFromJSON(n.Peers),
+		}
+	}
+	if n := in.PeeringDB; n != nil {
+		r.PeeringDB = &PeeringDBNetwork{
+			ID:        n.ID,
+			ASN:       in.ASN,
+			Name:      n.Name,
+			AKA:       n.AKA,
+			Website:   n.Website,
+			IRRASSet:  n.IRRASSet,
+			Policy:    n.Policy,
+			PolicyURL: n.PolicyURL,
+			Traffic:   n.Traffic,
+			Ratio:     n.Ratio,
+			Scope:     n.Scope,
+			Type:      n.Type,
+		}
+		r.PeeringDB.Connections, err = peeringDBConnectionsFromJSON(n.IXPs)
+		if err != nil {
+			return err
+		}
+		for _, f := range n.Facilities {
+			r.PeeringDB.Facilities = append(r.PeeringDB.Facilities, &PeeringDBFacility{
+				ID:      f.ID,
+				Name:    f.Name,
+				City:    f.City,
+				Country: peeringDBCountry(f.Country),
+			})
+		}
+	}
+	if d := in.Delegation; d != nil {
+		r.Delegation = &Delegation{
+			Registry: d.Registry,
+			Country:  countries.Unknown,
+			Type:     d.Type,
+			Status:   d.Status,
+			OpaqueID: d.OpaqueID,
+		}
+		if d.Country != "" {
+			r.Delegation.Country = countries.ByName(d.Country)
+		}
+		if d.Date != "" {
+			r.Delegation.Date, err = time.Parse(time.DateOnly, d.Date)
+			if err != nil {
+				return err
+			}
+		}
+		if d.Type == DELEGATED_ASN {
+			r.Delegation.Low, err = goasn.Parse(d.Start)
+			if err != nil {
+				return err
+			}
+			r.Delegation.High, err = goasn.Parse(d.End)
+			if err != nil {
+				return err
+			}
+		} else {
+			r.Delegation.Range, err = ParseIPRange(d.Start + "-" + d.End)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func neighborsJSON(neighbors []ASNeighbor) []asNeighborJSON {
+	out := []asNeighborJSON{}
+	for _, n := range neighbors {
+		out = append(out, asNeighborJSON{ASN: n.ASN, Paths: n.Paths, Inferred: n.Inferred})
+	}
+	return out
+}
+
+func neighborsFromJSON(neighbors []asNeighborJSON) []ASNeighbor {
+	var out []ASNeighbor
+	for _, n := range neighbors {
+		out = append(out, ASNeighbor{ASN: n.ASN, Paths: n.Paths, Inferred: n.Inferred})
+	}
+	return out
+}
+
+func peeringDBConnectionsJSON(connections []*PeeringDBConnection) []peeringDBConnectionJSON {
+	out := []peeringDBConnectionJSON{}
+	for _, c := range connections {
+		j := peeringDBConnectionJSON{
+			IXID:        c.IXID,
+			IX:          c.IXName,
+			ASN:         c.ASN,
+			Name:        c.NetName,
+			Speed:       c.Speed,
+			RouteServer: c.RouteServer,
+		}
+		if c.IPv4.IsValid() {
+			j.IPv4 = c.IPv4.String()
+		}
+		if c.IPv6.IsValid() {
+			j.IPv6 = c.IPv6.String()
+		}
+		out = append(out, j)
+	}
+	return out
+}
+
+func peeringDBConnectionsFromJSON(connections []peeringDBConnectionJSON) ([]*PeeringDBConnection, error) {
+	var out []*PeeringDBConnection
+	for _, j := range connections {
+		c := &PeeringDBConnection{
+			IXID:        j.IXID,
+			IXName:      j.IX,
+			ASN:         j.ASN,
+			NetName:     j.Name,
+			Speed:       j.Speed,
+			RouteServer: j.RouteServer,
+		}
+		var err error
+		if j.IPv4 != "" {
+			c.IPv4, err = netip.ParseAddr(j.IPv4)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if j.IPv6 != "" {
+			c.IPv6, err = netip.ParseAddr(j.IPv6)
+			if err != nil {
+				return nil, err
+			}
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
@@ -0,0 +1,254 @@
+package addr_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/biter777/countries"
+	"github.com/stretchr/testify/assert"
+	addr "github.com/thatmattlove/addr/pkg"
+	goasn "github.com/thatmattlove/go-asn"
+)
+
+func Test_NewIPResult(t *testing.T) {
+	t.Run("advertised", func(t *testing.T) {
+		t.Parallel()
+		r := &addr.Response{
+			ASN:       goasn.MustParse("13335"),
+			Addr:      netip.MustParseAddr("1.1.1.1"),
+			Network:   netip.MustParsePrefix("1.1.1.0/24"),
+			Name:      "Cloudflare, Inc.",
+			Country:   countries.USA,
+			Registry:  "ARIN",
+			FromQuery: true,
+		}
+		res := addr.NewIPResult("1.1.1.1", r, []string{"one.one.one.one."})
+		assert.Equal(t, addr.RESULT_IP, res.Type)
+		assert.Equal(t, "1.1.1.1", res.IP)
+		assert.Equal(t, "1.1.1.0/24", res.Prefix)
+		assert.True(t, res.Advertised)
+		assert.Nil(t, res.Special)
+		assert.Equal(t, []string{"one.one.one.one."}, res.PTRs)
+	})
+	t.Run("special-purpose", func(t *testing.T) {
+		t.Parallel()
+		r, err := addr.QueryIPPrefix("10.1.2.3")
+		assert.NoError(t, err)
+		res := addr.NewIPResult("10.1.2.3", r, nil)
+		assert.False(t, res.Advertised)
+		assert.NotNil(t, res.Special)
+		assert.Equal(t, "10.0.0.0/8", res.Prefix)
+		assert.Equal(t, uint32(0), res.ASN.Uint32())
+	})
+}
+
+func Test_NewASNResult(t *testing.T) {
+	r, err := addr.QueryASN("AS64512")
+	assert.NoError(t, err)
+	res := addr.NewASNResult("AS64512", r)
+	assert.Equal(t, addr.RESULT_ASN, res.Type)
+	assert.Empty(t, res.IP)
+	assert.NotNil(t, res.SpecialASN)
+}
+
+func Test_RenderTemplate(t *testing.T) {
+	res := &addr.Result{
+		Query:    "1.1.1.1",
+		Type:     addr.RESULT_IP,
+		IP:       "1.1.1.1",
+		Prefix:   "1.1.1.0/24",
+		ASN:      goasn.MustParse("65546"),
+		Name:     "Example",
+		Country:  countries.USA,
+		PTRs:     []string{"a.example.", "b.example."},
+		Registry: "ARIN",
+	}
+	type CaseT struct {
+		template string
+		expected string
+	}
+	cases := []CaseT{
+		{"{{.IP}} {{.ASN}} {{.Name}}", "1.1.1.1 65546 Example"},
+		{"{{asplain .ASN}} {{asdot .ASN}}", "65546 1.10"},
+		{"{{countryCode .Country}} {{countryName .Country}} {{countryEmoji .Country}}", "US United States 🇺🇸"},
+		{"{{prefixLen .Prefix}}", "24"},
+		{`{{join .PTRs ","}}`, "a.example.,b.example."},
+		{"{{if .Error}}error{{else}}ok{{end}}", "ok"},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.template, func(t *testing.T) {
+			t.Parallel()
+			tmpl, err := addr.ParseTemplate(c.template)
+			assert.NoError(t, err)
+			out, err := addr.RenderTemplate(tmpl, res)
+			assert.NoError(t, err)
+			assert.Equal(t, c.expected, out)
+		})
+	}
+	t.Run("error result", func(t *testing.T) {
+		t.Parallel()
+		tmpl, _ := addr.ParseTemplate("{{.Query}} {{asplain .ASN}} {{countryCode .Country}}{{.Error}}")
+		out, err := addr.RenderTemplate(tmpl, addr.NewErrorResult("nope", addr.RESULT_IP, errors.New("failed")))
+		assert.NoError(t, err)
+		assert.Equal(t, "nope 0 failed", out)
+	})
+	t.Run("invalid template", func(t *testing.T) {
+		t.Parallel()
+		_, err := addr.ParseTemplate("{{.IP")
+		assert.Error(t, err)
+	})
+	t.Run("unknown field", func(t *testing.T) {
+		t.Parallel()
+		tmpl, _ := addr.ParseTemplate("{{.Nope}}")
+		_, err := addr.RenderTemplate(tmpl, res)
+		assert.Error(t, err)
+	})
+}
+
+func TestResult_Column(t *testing.T) {
+	res := &addr.Result{
+		Query:     "1.1.1.1",
+		IP:        "1.1.1.1",
+		Prefix:    "1.1.1.0/24",
+		ASN:       goasn.MustParse("13335"),
+		Name:      "Cloudflare, Inc.",
+		Country:   countries.USA,
+		Registry:  "ARIN",
+		Allocated: time.Date(2010, 7, 14, 0, 0, 0, 0, time.UTC),
+		PTRs:      []string{"one.one.one.one."},
+	}
+	type CaseT struct {
+		column   string
+		expected string
+	}
+	cases := []CaseT{
+		{"target", "1.1.1.1"},
+		{"ip", "1.1.1.1"},
+		{"prefix", "1.1.1.0/24"},
+		{"asn", "13335"},
+		{"name", "Cloudflare, Inc."},
+		{"country", "US"},
+		{"registry", "ARIN"},
+		{"allocated", "2010-07-14"},
+		{"ptr", "one.one.one.one."},
+		{"special", ""},
+		{"error", ""},
+		{"nope", ""},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.column, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, c.expected, res.Column(c.column))
+		})
+	}
+	t.Run("unknown values are empty", func(t *testing.T) {
+		t.Parallel()
+		r, _ := addr.QueryIPPrefix("10.1.2.3")
+		res := addr.NewIPResult("10.1.2.3", r, nil)
+		assert.Equal(t, "", res.Column("asn"))
+		assert.Equal(t, addr.TXT_PRIVATE, res.Column("name"))
+		assert.Equal(t, "Private-Use", res.Column("special"), "the registry's name is kept")
+		res = addr.NewErrorResult("nope", "", errors.New("failed"))
+		assert.Equal(t, "", res.Column("country"))
+		assert.Equal(t, "failed", res.Column("error"))
+	})
+}
+
+func Test_ValidateColumns(t *testing.T) {
+	assert.NoError(t, addr.ValidateColumns(addr.RESULT_COLUMNS))
+	assert.NoError(t, addr.ValidateColumns(nil))
+	assert.ErrorContains(t, addr.ValidateColumns([]string{"ip", "bogus"}), "unknown column 'bogus'")
+}
+
+func TestResult_MarshalJSON(t *testing.T) {
+	t.Run("ip", func(t *testing.T) {
+		t.Parallel()
+		res := &addr.Result{
+			Query:      "1.1.1.1",
+			Type:       addr.RESULT_IP,
+			IP:         "1.1.1.1",
+			Prefix:     "1.1.1.0/24",
+			ASN:        goasn.MustParse("13335"),
+			Name:       "Cloudflare, Inc.",
+			Country:    countries.USA,
+			Registry:   "ARIN",
+			Allocated:  time.Date(2010, 7, 14, 0, 0, 0, 0, time.UTC),
+			Advertised: true,
+		}
+		b, err := json.Marshal(res)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{
+			"query": "1.1.1.1",
+			"type": "ip",
+			"ip": "1.1.1.1",
+			"prefix": "1.1.1.0/24",
+			"asn": 13335,
+			"name": "Cloudflare, Inc.",
+			"country": "US",
+			"registry": "ARIN",
+			"allocated": "2010-07-14",
+			"advertised": true,
+			"ptrs": []
+		}`, string(b))
+	})
+	t.Run("nested", func(t *testing.T) {
+		t.Parallel()
+		r, err := addr.QueryIPPrefix("64:ff9b::a01:101")
+		assert.NoError(t, err)
+		b, err := json.Marshal(addr.NewIPResult("64:ff9b::a01:101", r, nil))
+		assert.NoError(t, err)
+		out := map[string]any{}
+		assert.NoError(t, json.Unmarshal(b, &out))
+		embedded := out["embedded"].(map[string]any)
+		assert.Equal(t, "10.1.1.1", embedded["ipv4"])
+		assert.Equal(t, "10.0.0.0/8", embedded["origin"].(map[string]any)["prefix"])
+		assert.Equal(t, "64:ff9b::/96", out["special"].(map[string]any)["prefix"])
+	})
+	t.Run("error", func(t *testing.T) {
+		t.Parallel()
+		b, err := json.Marshal(addr.NewErrorResult("nope", "", errors.New("failed")))
+		assert.NoError(t, err)
+		assert.Contains(t, string(b), `"error":"failed"`)
+		assert.Contains(t, string(b), `"country":""`)
+	})
+}
+
+func TestResult_UnmarshalJSON(t *testing.T) {
+	t.Run("round trip", func(t *testing.T) {
+		t.Parallel()
+		r, err := addr.QueryIPPrefix("64:ff9b::a01:101")
+		assert.NoError(t, err)
+		in := addr.NewIPResult("64:ff9b::a01:101", r, []string{"host.example."})
+		b, err := json.Marshal(in)
+		assert.NoError(t, err)
+		out := &addr.Result{}
+		assert.NoError(t, json.Unmarshal(b, out))
+		for _, c := range addr.RESULT_COLUMNS {
+			assert.Equal(t, in.Column(c), out.Column(c), c)
+		}
+		assert.Equal(t, in.Special.Prefix, out.Special.Prefix)
+		assert.Equal(t, in.Embedded.IPv4, out.Embedded.IPv4)
+		b2, err := json.Marshal(out)
+		assert.NoError(t, err)
+		assert.NotContains(t, string(b2), "origin")
+	})
+	t.Run("country & allocated", func(t *testing.T) {
+		t.Parallel()
+		out := &addr.Result{}
+		err := json.Unmarshal([]byte(`{"query":"AS13335","type":"asn","asn":13335,"country":"US","allocated":"2010-07-14"}`), out)
+		assert.NoError(t, err)
+		assert.Equal(t, countries.USA, out.Country)
+		assert.Equal(t, "2010-07-14", out.Column("allocated"))
+		assert.Equal(t, "13335", out.Column("asn"))
+	})
+	t.Run("invalid", func(t *testing.T) {
+		t.Parallel()
+		err := json.Unmarshal([]byte(`{"query":"AS13335","allocated":"yesterday"}`), &addr.Result{})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,604 @@
+package addr
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/maphash"
+	"io"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/biter777/countries"
+	goasn "github.com/thatmattlove/go-asn"
+)
+
+// MRT types & TABLE_DUMP_V2 subtypes, from RFC 6396 & RFC 8050.
+const (
+	mrtTableDumpV2 uint16 = 13
+
+	mrtPeerIndexTable        uint16 = 1
+	mrtRIBIPv4Unicast        uint16 = 2
+	mrtRIBIPv6Unicast        uint16 = 4
+	mrtRIBIPv4UnicastAddPath uint16 = 8
+	mrtRIBIPv6UnicastAddPath uint16 = 10
+)
+
+// BGP path attribute flags & types, from RFC 4271, RFC 1997 & RFC 8092.
+const (
+	bgpAttrExtendedLength byte = 0x10
+
+	bgpAttrASPath           byte = 2
+	bgpAttrCommunities      byte = 8
+	bgpAttrLargeCommunities byte = 32
+)
+
+var (
+	ErrEmptyRIB       = errors.New("no routes found in RIB dump")
+	ErrMissingPeerIdx = errors.New("RIB entry before PEER_INDEX_TABLE")
+)
+
+// RIBOrigin is an origin ASN of a prefix, and the number of peers with a route from it.
+type RIBOrigin struct {
+	ASN   uint32
+	Peers int
+}
+
+// RIBEntry is a prefix's routes in MRT RIB dumps, combined across all peers.
+type RIBEntry struct {
+	Prefix netip.Prefix
+	// Peers is the number of peers with a route to the prefix.
+	Peers int
+	// Origins are ordered by peers, most first. More than one origin means the prefix is MOAS
+	// (multiple origin AS).
+	Origins []RIBOrigin
+	// Upstreams are the ASNs seen immediately before an origin in AS paths, in ascending order.
+	Upstreams []uint32
+	// Paths are the distinct AS paths of the prefix, in the order they were first seen.
+	Paths [][]uint32
+	// Communities are the distinct standard ('65000:100') & large ('65000:1:2') communities of
+	// the prefix's routes, in ascending order.
+	Communities []string
+}
+
+// Origin returns the origin ASN seen by the most peers.
+func (e *RIBEntry) Origin() uint32 {
+	if len(e.Origins) == 0 {
+		return 0
+	}
+	return e.Origins[0].ASN
+}
+
+// MOAS returns true if the prefix has more than one origin ASN.
+func (e *RIBEntry) MOAS() bool {
+	return len(e.Origins) > 1
+}
+
+// RIB is a routing table read from MRT TABLE_DUMP_V2 RIB dumps, such as RouteViews' rib.*.bz2
+// or RIPE RIS' bview.*.gz. Lookups return the most specific matching prefix.
+type RIB struct {
+	index *prefixIndex[*RIBEntry]
+	// Peers is the number of peers in each dump's peer index table, summed across dumps.
+	Peers int
+	// paths are the distinct AS paths of each dump, for finding the neighbors of an ASN.
+	paths      [][]uint32
+	degreeOnce sync.Once
+	degree     map[uint32]int
+	linkOnce   sync.Once
+	links      map[uint32]map[uint32]*ribLink
+}
+
+func NewRIB() *RIB {
+	return &RIB{index: newPrefixIndex[*RIBEntry]()}
+}
+
+// LoadRIB reads MRT dumps, which may be compressed with gzip or bzip2, into a single RIB.
+func LoadRIB(paths ...string) (*RIB, error) {
+	rib := NewRIB()
+	for _, p := range paths {
+		f, err := os.Open(p)
+		if err != nil {
+			return nil, err
+		}
+		_, err = rib.Read(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p, err)
+		}
+	}
+	return rib, nil
+}
+
+func (rib *RIB) Len() int {
+	return len(rib.index.entries)
+}
+
+// Lookup returns the routes of the most specific prefix containing a, or nil if there isn't one.
+func (rib *RIB) Lookup(a netip.Addr) *RIBEntry {
+	_, e, ok := rib.index.lookup(a)
+	if !ok {
+		return nil
+	}
+	return e
+}
+
+// decompress returns a reader of r's content, decompressing it if it starts with a gzip or bzip2
+// header. Closing it closes the decompressor, but not r.
+func decompress(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(3)
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		return decompressReader{Reader: bufio.NewReader(gz), Closer: gz}, nil
+	case bytes.Equal(magic, []byte("BZh")):
+		return io.NopCloser(bufio.NewReader(bzip2.NewReader(br))), nil
+	}
+	return io.NopCloser(br), nil
+}
+
+// decompressReader reads buffered decompressed content, and closes the decompressor.
+type decompressReader struct {
+	io.Reader
+	io.Closer
+}
+
+// ribReader holds the state of reading one dump. AS paths & communities are interned, as most
+// are shared by many prefixes, and identified by their index in pathList or communityList.
+type ribReader struct {
+	rib           *RIB
+	peers         int
+	gen           uint32
+	seed          maphash.Seed
+	paths         map[uint64]int
+	pathNext      []int
+	pathList      [][]uint32
+	chunk         []uint32
+	communities   map[string]int
+	communityList []string
+	peerSeen      []uint32
+	pathSeen      []uint32
+	communitySeen []uint32
+	comms         []int
+	origins       []ribPeerOrigin
+}
+
+type ribPeerOrigin struct {
+	asn  uint32
+	peer uint16
+}
+
+// Read adds the IPv4 & IPv6 unicast routes of an MRT TABLE_DUMP_V2 dump, which may be compressed
+// with gzip or bzip2, to the RIB. Other MRT records are skipped. The number of prefixes read is
+// returned.
+func (rib *RIB) Read(r io.Reader) (int, error) {
+	in, err := decompress(r)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+	rr := &ribReader{rib: rib, peers: -1, seed: maphash.MakeSeed(), paths: map[uint64]int{}, communities: map[string]int{}}
+	header := make([]byte, 12)
+	body := []byte{}
+	n := 0
+	for {
+		_, err = io.ReadFull(in, header)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return n, fmt.Errorf("invalid MRT header: %w", err)
+		}
+		mrtType := binary.BigEndian.Uint16(header[4:6])
+		subtype := binary.BigEndian.Uint16(header[6:8])
+		length := binary.BigEndian.Uint32(header[8:12])
+		if uint32(cap(body)) < length {
+			body = make([]byte, length)
+		}
+		body = body[:length]
+		_, err = io.ReadFull(in, body)
+		if err != nil {
+			return n, fmt.Errorf("invalid MRT record: %w", err)
+		}
+		if mrtType != mrtTableDumpV2 {
+			continue
+		}
+		switch subtype {
+		case mrtPeerIndexTable:
+			err = rr.readPeerIndex(body)
+		case mrtRIBIPv4Unicast, mrtRIBIPv6Unicast, mrtRIBIPv4UnicastAddPath, mrtRIBIPv6UnicastAddPath:
+			if rr.peers < 0 {
+				return n, ErrMissingPeerIdx
+			}
+			err = rr.readRIBEntry(body, subtype)
+			n++
+		}
+		if err != nil {
+			return n, err
+		}
+	}
+	if n == 0 {
+		return 0, ErrEmptyRIB
+	}
+	return n, nil
+}
+
+// readPeerIndex reads a PEER_INDEX_TABLE record, which only needs its peer count.
+func (rr *ribReader) readPeerIndex(b []byte) error {
+	if len(b) < 6 {
+		return errors.New("invalid PEER_INDEX_TABLE")
+	}
+	viewLen := int(binary.BigEndian.Uint16(b[4:6]))
+	if len(b) < 8+viewLen {
+		return errors.New("invalid PEER_INDEX_TABLE")
+	}
+	if rr.peers >= 0 {
+		rr.rib.Peers -= rr.peers
+	}
+	rr.peers = int(binary.BigEndian.Uint16(b[6+viewLen : 8+viewLen]))
+	rr.rib.Peers += rr.peers
+	return nil
+}
+
+// readRIBEntry reads a RIB_IPV4_UNICAST or RIB_IPV6_UNICAST record, or their ADDPATH variants:
+// a prefix, and the route of each peer that has one.
+func (rr *ribReader) readRIBEntry(b []byte, subtype uint16) error {
+	invalid := errors.New("invalid RIB entry")
+	if len(b) < 5 {
+		return invalid
+	}
+	bits := int(b[4])
+	size := 4
+	if subtype == mrtRIBIPv6Unicast || subtype == mrtRIBIPv6UnicastAddPath {
+		size = 16
+	}
+	n := (bits + 7) / 8
+	if bits > size*8 || len(b) < 5+n+2 {
+		return invalid
+	}
+	raw := make([]byte, size)
+	copy(raw, b[5:5+n])
+	a, _ := netip.AddrFromSlice(raw)
+	prefix := netip.PrefixFrom(a, bits).Masked()
+	b = b[5+n:]
+	count := int(binary.BigEndian.Uint16(b[:2]))
+	b = b[2:]
+
+	// Peers, paths & communities already seen in this entry are marked with the entry's
+	// generation, rather than collected in maps, as a full RIB has around a million entries.
+	rr.gen++
+	rr.origins = rr.origins[:0]
+	e := &RIBEntry{Prefix: prefix}
+	addPath := subtype == mrtRIBIPv4UnicastAddPath || subtype == mrtRIBIPv6UnicastAddPath
+	for i := 0; i < count; i++ {
+		// Peer index (2), originated time (4), path identifier (4, ADDPATH only) & attribute
+		// length (2).
+		fixed := 8
+		if addPath {
+			fixed += 4
+		}
+		if len(b) < fixed {
+			return invalid
+		}
+		peer := binary.BigEndian.Uint16(b[:2])
+		attrLen := int(binary.BigEndian.Uint16(b[fixed-2 : fixed]))
+		if len(b) < fixed+attrLen {
+			return invalid
+		}
+		rr.comms = rr.comms[:0]
+		pathID, err := rr.readAttributes(b[fixed : fixed+attrLen])
+		if err != nil {
+			return err
+		}
+		b = b[fixed+attrLen:]
+		if rr.mark(&rr.peerSeen, int(peer)) {
+			e.Peers++
+		}
+		for _, id := range rr.comms {
+			if rr.mark(&rr.communitySeen, id) {
+				e.Communities = append(e.Communities, rr.communityList[id])
+			}
+		}
+		if pathID < 0 || len(rr.pathList[pathID]) == 0 {
+			continue
+		}
+		path := rr.pathList[pathID]
+		if rr.mark(&rr.pathSeen, pathID) {
+			e.Paths = append(e.Paths, path)
+		}
+		origin, upstream := pathOrigin(path)
+		rr.origins = append(rr.origins, ribPeerOrigin{asn: origin, peer: peer})
+		if upstream != 0 && !containsASN(e.Upstreams, upstream) {
+			e.Upstreams = append(e.Upstreams, upstream)
+		}
+	}
+	// Count each origin's distinct peers, as an ADDPATH peer may have several paths to it.
+	sort.Slice(rr.origins, func(i, j int) bool {
+		if rr.origins[i].asn != rr.origins[j].asn {
+			return rr.origins[i].asn < rr.origins[j].asn
+		}
+		return rr.origins[i].peer < rr.origins[j].peer
+	})
+	for i, o := range rr.origins {
+		switch {
+		case i == 0 || o.asn != rr.origins[i-1].asn:
+			e.Origins = append(e.Origins, RIBOrigin{ASN: o.asn, Peers: 1})
+		case o.peer != rr.origins[i-1].peer:
+			e.Origins[len(e.Origins)-1].Peers++
+		}
+	}
+	rr.rib.add(e)
+	return nil
+}
+
+// mark marks id as seen in the current entry, returning false if it already was.
+func (rr *ribReader) mark(seen *[]uint32, id int) bool {
+	for len(*seen) <= id {
+		*seen = append(*seen, 0)
+	}
+	if (*seen)[id] == rr.gen {
+		return false
+	}
+	(*seen)[id] = rr.gen
+	return true
+}
+
+// readAttributes reads the AS path & communities from BGP path attributes, returning the ID of
+// the interned AS path, or -1 if there isn't one, and adding the IDs of interned communities to
+// rr.comms. TABLE_DUMP_V2 always encodes AS paths with 4-byte ASNs. AS_SET members are included
+// in the path in order.
+func (rr *ribReader) readAttributes(b []byte) (int, error) {
+	pathID := -1
+	for len(b) > 0 {
+		if len(b) < 3 {
+			return -1, errors.New("invalid BGP path attribute")
+		}
+		flags, attrType := b[0], b[1]
+		var length, offset int
+		if flags&bgpAttrExtendedLength != 0 {
+			if len(b) < 4 {
+				return -1, errors.New("invalid BGP path attribute")
+			}
+			length, offset = int(binary.BigEndian.Uint16(b[2:4])), 4
+		} else {
+			length, offset = int(b[2]), 3
+		}
+		if len(b) < offset+length {
+			return -1, errors.New("invalid BGP path attribute")
+		}
+		value := b[offset : offset+length]
+		b = b[offset+length:]
+		switch attrType {
+		case bgpAttrASPath:
+			id, err := rr.path(value)
+			if err != nil {
+				return -1, err
+			}
+			pathID = id
+		case bgpAttrCommunities:
+			for i := 0; i+4 <= len(value); i += 4 {
+				rr.comms = append(rr.comms, rr.community(value[i:i+4]))
+			}
+		case bgpAttrLargeCommunities:
+			for i := 0; i+12 <= len(value); i += 12 {
+				rr.comms = append(rr.comms, rr.community(value[i:i+12]))
+			}
+		}
+	}
+	return pathID, nil
+}
+
+// path returns the ID of the interned AS path encoded by an AS_PATH attribute, flattening its
+// segments. Paths are keyed by a hash of their encoding, chaining any collisions, and stored in
+// shared chunks to keep the number of allocations down.
+func (rr *ribReader) path(value []byte) (int, error) {
+	h := maphash.Bytes(rr.seed, value)
+	n := 0
+	for v := value; len(v) > 0; {
+		if len(v) < 2 || len(v) < 2+int(v[1])*4 {
+			return -1, errors.New("invalid AS_PATH")
+		}
+		n += int(v[1])
+		v = v[2+int(v[1])*4:]
+	}
+	id, ok := rr.paths[h]
+	for ok {
+		if equalPath(rr.pathList[id], value, n) {
+			return id, nil
+		}
+		id, ok = rr.pathNext[id], rr.pathNext[id] >= 0
+	}
+	if cap(rr.chunk)-len(rr.chunk) < n {
+		size := 1 << 16
+		if n > size {
+			size = n
+		}
+		rr.chunk = make([]uint32, 0, size)
+	}
+	start := len(rr.chunk)
+	for len(value) > 0 {
+		count := int(value[1])
+		for i := 0; i < count; i++ {
+			rr.chunk = append(rr.chunk, binary.BigEndian.Uint32(value[2+i*4:]))
+		}
+		value = value[2+count*4:]
+	}
+	id = len(rr.pathList)
+	prev, ok := rr.paths[h]
+	if !ok {
+		prev = -1
+	}
+	rr.paths[h] = id
+	rr.pathNext = append(rr.pathNext, prev)
+	rr.pathList = append(rr.pathList, rr.chunk[start:len(rr.chunk):len(rr.chunk)])
+	rr.rib.paths = append(rr.rib.paths, rr.pathList[id])
+	return id, nil
+}
+
+// equalPath reports whether path is the flattened AS_PATH value, which has n ASNs.
+func equalPath(path []uint32, value []byte, n int) bool {
+	if len(path) != n {
+		return false
+	}
+	i := 0
+	for len(value) > 0 {
+		count := int(value[1])
+		for j := 0; j < count; j++ {
+			if path[i] != binary.BigEndian.Uint32(value[2+j*4:]) {
+				return false
+			}
+			i++
+		}
+		value = value[2+count*4:]
+	}
+	return true
+}
+
+// community returns the ID of an interned community, formatted as 'a:b', or large community,
+// formatted as 'a:b:c', from its encoded value.
+func (rr *ribReader) community(value []byte) int {
+	if id, ok := rr.communities[string(value)]; ok {
+		return id
+	}
+	var c []byte
+	if len(value) == 4 {
+		c = strconv.AppendUint(c, uint64(binary.BigEndian.Uint16(value)), 10)
+		c = append(c, ':')
+		c = strconv.AppendUint(c, uint64(binary.BigEndian.Uint16(value[2:])), 10)
+	} else {
+		for i := 0; i < 12; i += 4 {
+			if i > 0 {
+				c = append(c, ':')
+			}
+			c = strconv.AppendUint(c, uint64(binary.BigEndian.Uint32(value[i:])), 10)
+		}
+	}
+	id := len(rr.communityList)
+	rr.communities[string(value)] = id
+	rr.communityList = append(rr.communityList, string(c))
+	return id
+}
+
+func containsASN(asns []uint32, asn uint32) bool {
+	for _, a := range asns {
+		if a == asn {
+			return true
+		}
+	}
+	return false
+}
+
+// add adds an entry to the RIB, combining it with any entry for the same prefix from another
+// dump.
+func (rib *RIB) add(e *RIBEntry) {
+	if prev, ok := rib.index.get(e.Prefix); ok {
+		e = mergeRIBEntries(prev, e)
+	}
+	sort.SliceStable(e.Origins, func(i, j int) bool {
+		if e.Origins[i].Peers != e.Origins[j].Peers {
+			return e.Origins[i].Peers > e.Origins[j].Peers
+		}
+		return e.Origins[i].ASN < e.Origins[j].ASN
+	})
+	sort.Slice(e.Upstreams, func(i, j int) bool { return e.Upstreams[i] < e.Upstreams[j] })
+	sort.Strings(e.Communities)
+	rib.index.set(e.Prefix, e)
+}
+
+func mergeRIBEntries(a, b *RIBEntry) *RIBEntry {
+	out := &RIBEntry{Prefix: a.Prefix, Peers: a.Peers + b.Peers, Paths: append([][]uint32{}, a.Paths...)}
+	origins := map[uint32]int{}
+	for _, o := range append(append([]RIBOrigin{}, a.Origins...), b.Origins...) {
+		if _, ok := origins[o.ASN]; !ok {
+			out.Origins = append(out.Origins, RIBOrigin{ASN: o.ASN})
+		}
+		origins[o.ASN] += o.Peers
+	}
+	for i := range out.Origins {
+		out.Origins[i].Peers = origins[out.Origins[i].ASN]
+	}
+	upstreams := map[uint32]bool{}
+	for _, u := range append(append([]uint32{}, a.Upstreams...), b.Upstreams...) {
+		if !upstreams[u] {
+			upstreams[u] = true
+			out.Upstreams = append(out.Upstreams, u)
+		}
+	}
+	paths := map[string]bool{}
+	for _, p := range a.Paths {
+		paths[pathKey(p)] = true
+	}
+	for _, p := range b.Paths {
+		if !paths[pathKey(p)] {
+			paths[pathKey(p)] = true
+			out.Paths = append(out.Paths, p)
+		}
+	}
+	communities := map[string]bool{}
+	for _, c := range append(append([]string{}, a.Communities...), b.Communities...) {
+		if !communities[c] {
+			communities[c] = true
+			out.Communities = append(out.Communities, c)
+		}
+	}
+	return out
+}
+
+// pathOrigin returns the origin of an AS path, and the upstream ASN before it, ignoring
+// prepending. The upstream is zero if the origin is the only ASN in the path.
+func pathOrigin(path []uint32) (uint32, uint32) {
+	origin := path[len(path)-1]
+	for i := len(path) - 2; i >= 0; i-- {
+		if path[i] != origin {
+			return origin, path[i]
+		}
+	}
+	return origin, 0
+}
+
+func pathKey(path []uint32) string {
+	b := make([]byte, len(path)*4)
+	for i, asn := range path {
+		binary.BigEndian.PutUint32(b[i*4:], asn)
+	}
+	return string(b)
+}
+
+// FormatASPath formats an AS path as space separated ASNs, e.g. '3356 13335'.
+func FormatASPath(path []uint32) string {
+	parts := make([]string, 0, len(path))
+	for _, asn := range path {
+		parts = append(parts, fmt.Sprint(asn))
+	}
+	return strings.Join(parts, " ")
+}
+
+// Response builds a lookup response from the routes of the most specific prefix containing the
+// address, or returns nil if the RIB doesn't have one.
+func (rib *RIB) Response(ipv *IPValidator) *Response {
+	e := rib.Lookup(ipv.Addr)
+	if e == nil {
+		return nil
+	}
+	return &Response{
+		ASN:       goasn.FromUint32(e.Origin()),
+		IP:        &ipv.IP,
+		Prefix:    IPNetFromPrefix(e.Prefix),
+		Addr:      ipv.Addr,
+		Network:   e.Prefix,
+		Country:   countries.Unknown,
+		FromQuery: true,
+		Route:     e,
+	}
+}
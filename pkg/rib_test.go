@@ -0,0 +1,321 @@
+package addr_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	addr "github.com/thatmattlove/addr/pkg"
+)
+
+type mrtRoute struct {
+	peer        uint16
+	path        []uint32
+	communities [][2]uint16
+	large       [][3]uint32
+}
+
+type mrtPrefix struct {
+	prefix string
+	routes []mrtRoute
+	// addPath encodes the entry as RIB_IPV4_UNICAST_ADDPATH or RIB_IPV6_UNICAST_ADDPATH.
+	addPath bool
+}
+
+// encodeMRT encodes a TABLE_DUMP_V2 RIB dump, in the format described by RFC 6396, with a peer
+// index table of peers followed by a RIB entry for each prefix.
+func encodeMRT(peers int, prefixes []mrtPrefix) []byte {
+	b := &bytes.Buffer{}
+	record := func(subtype uint16, body []byte) {
+		header := make([]byte, 12)
+		binary.BigEndian.PutUint32(header[0:], 1697587200)
+		binary.BigEndian.PutUint16(header[4:], 13)
+		binary.BigEndian.PutUint16(header[6:], subtype)
+		binary.BigEndian.PutUint32(header[8:], uint32(len(body)))
+		b.Write(header)
+		b.Write(body)
+	}
+	be16 := func(v uint16) []byte { return binary.BigEndian.AppendUint16(nil, v) }
+	be32 := func(v uint32) []byte { return binary.BigEndian.AppendUint32(nil, v) }
+
+	index := append(be32(0xC0000201), be16(4)...)
+	index = append(index, "test"...)
+	index = append(index, be16(uint16(peers))...)
+	for i := 0; i < peers; i++ {
+		// Peer type 0x02 is an IPv4 peer with a 4-byte ASN.
+		index = append(index, 0x02)
+		index = append(index, be32(uint32(i+1))...)
+		index = append(index, 192, 0, 2, byte(i+1))
+		index = append(index, be32(uint32(64500+i))...)
+	}
+	record(1, index)
+
+	for seq, p := range prefixes {
+		pfx := netip.MustParsePrefix(p.prefix)
+		subtype := uint16(2)
+		if pfx.Addr().Is6() {
+			subtype = 4
+		}
+		if p.addPath {
+			subtype += 6
+		}
+		body := be32(uint32(seq))
+		body = append(body, byte(pfx.Bits()))
+		body = append(body, pfx.Addr().AsSlice()[:(pfx.Bits()+7)/8]...)
+		body = append(body, be16(uint16(len(p.routes)))...)
+		for i, r := range p.routes {
+			attrs := []byte{0x40, 1, 1, 0}
+			segment := []byte{2, byte(len(r.path))}
+			for _, asn := range r.path {
+				segment = append(segment, be32(asn)...)
+			}
+			if len(segment) > 255 {
+				attrs = append(attrs, 0x50, 2)
+				attrs = append(attrs, be16(uint16(len(segment)))...)
+			} else {
+				attrs = append(attrs, 0x40, 2, byte(len(segment)))
+			}
+			attrs = append(attrs, segment...)
+			if len(r.communities) > 0 {
+				attrs = append(attrs, 0xC0, 8, byte(len(r.communities)*4))
+				for _, c := range r.communities {
+					attrs = append(attrs, be16(c[0])...)
+					attrs = append(attrs, be16(c[1])...)
+				}
+			}
+			if len(r.large) > 0 {
+				attrs = append(attrs, 0xC0, 32, byte(len(r.large)*12))
+				for _, c := range r.large {
+					attrs = append(attrs, be32(c[0])...)
+					attrs = append(attrs, be32(c[1])...)
+					attrs = append(attrs, be32(c[2])...)
+				}
+			}
+			body = append(body, be16(r.peer)...)
+			body = append(body, be32(1697500000)...)
+			if p.addPath {
+				body = append(body, be32(uint32(i+1))...)
+			}
+			body = append(body, be16(uint16(len(attrs)))...)
+			body = append(body, attrs...)
+		}
+		record(subtype, body)
+	}
+	return b.Bytes()
+}
+
+func longPath() []uint32 {
+	path := []uint32{3356}
+	for i := 0; i < 70; i++ {
+		path = append(path, 64496)
+	}
+	return append(path, 15169)
+}
+
+var testMRTPrefixes = []mrtPrefix{
+	{prefix: "1.1.1.0/24", routes: []mrtRoute{
+		{peer: 0, path: []uint32{64500, 174, 13335}, communities: [][2]uint16{{174, 21000}}},
+		{peer: 1, path: []uint32{64501, 3356, 13335, 13335, 13335}, communities: [][2]uint16{{3356, 3}, {174, 21000}}},
+		{peer: 2, path: []uint32{64502, 174, 13335}},
+	}},
+	{prefix: "1.0.0.0/8", routes: []mrtRoute{
+		{peer: 0, path: []uint32{64500, 4826, 64496}},
+	}},
+	// MOAS: two peers see AS64511 & one sees AS64510.
+	{prefix: "192.0.2.0/24", routes: []mrtRoute{
+		{peer: 0, path: []uint32{64500, 64510}},
+		{peer: 1, path: []uint32{64501, 3356, 64511}, large: [][3]uint32{{64511, 1, 2}}},
+		{peer: 2, path: []uint32{64502, 174, 64511}},
+	}},
+	{prefix: "2606:4700::/32", routes: []mrtRoute{
+		{peer: 0, path: []uint32{64500, 13335}},
+		{peer: 1, path: []uint32{64501, 13335}},
+	}},
+	{prefix: "8.8.8.0/24", addPath: true, routes: []mrtRoute{
+		{peer: 0, path: longPath()},
+		{peer: 0, path: []uint32{64500, 15169}},
+	}},
+}
+
+func readTestRIB(t *testing.T) *addr.RIB {
+	rib := addr.NewRIB()
+	n, err := rib.Read(bytes.NewReader(encodeMRT(3, testMRTPrefixes)))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, len(testMRTPrefixes), n)
+	return rib
+}
+
+func TestRIB_Read(t *testing.T) {
+	rib := readTestRIB(t)
+	assert.Equal(t, 5, rib.Len())
+	assert.Equal(t, 3, rib.Peers)
+
+	t.Run("longest match", func(t *testing.T) {
+		t.Parallel()
+		e := rib.Lookup(netip.MustParseAddr("1.1.1.1"))
+		if !assert.NotNil(t, e) {
+			return
+		}
+		assert.Equal(t, "1.1.1.0/24", e.Prefix.String())
+		assert.Equal(t, 3, e.Peers)
+		assert.Equal(t, uint32(13335), e.Origin())
+		assert.False(t, e.MOAS())
+		assert.Equal(t, []uint32{174, 3356}, e.Upstreams, "prepending is ignored")
+		assert.Len(t, e.Paths, 3)
+		assert.Equal(t, []string{"174:21000", "3356:3"}, e.Communities)
+
+		e = rib.Lookup(netip.MustParseAddr("1.2.3.4"))
+		if assert.NotNil(t, e) {
+			assert.Equal(t, "1.0.0.0/8", e.Prefix.String())
+		}
+		assert.Nil(t, rib.Lookup(netip.MustParseAddr("9.9.9.9")))
+	})
+	t.Run("moas", func(t *testing.T) {
+		t.Parallel()
+		e := rib.Lookup(netip.MustParseAddr("192.0.2.1"))
+		if !assert.NotNil(t, e) {
+			return
+		}
+		assert.True(t, e.MOAS())
+		assert.Equal(t, []addr.RIBOrigin{{ASN: 64511, Peers: 2}, {ASN: 64510, Peers: 1}}, e.Origins)
+		assert.Equal(t, []uint32{174, 3356, 64500}, e.Upstreams)
+		assert.Equal(t, []string{"64511:1:2"}, e.Communities)
+	})
+	t.Run("ipv6", func(t *testing.T) {
+		t.Parallel()
+		e := rib.Lookup(netip.MustParseAddr("2606:4700:4700::1111"))
+		if assert.NotNil(t, e) {
+			assert.Equal(t, "2606:4700::/32", e.Prefix.String())
+			assert.Equal(t, 2, e.Peers)
+		}
+	})
+	t.Run("addpath", func(t *testing.T) {
+		t.Parallel()
+		e := rib.Lookup(netip.MustParseAddr("8.8.8.8"))
+		if !assert.NotNil(t, e) {
+			return
+		}
+		assert.Equal(t, 1, e.Peers)
+		assert.Equal(t, []addr.RIBOrigin{{ASN: 15169, Peers: 1}}, e.Origins)
+		assert.Equal(t, []uint32{64496, 64500}, e.Upstreams)
+		assert.Equal(t, longPath(), e.Paths[0])
+	})
+}
+
+func TestRIB_Read_Errors(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		t.Parallel()
+		_, err := addr.NewRIB().Read(bytes.NewReader(encodeMRT(1, nil)))
+		assert.ErrorIs(t, err, addr.ErrEmptyRIB)
+	})
+	t.Run("no peer index", func(t *testing.T) {
+		t.Parallel()
+		b := encodeMRT(1, testMRTPrefixes[:1])
+		// Skip the header & body of the peer index table.
+		length := binary.BigEndian.Uint32(b[8:12])
+		_, err := addr.NewRIB().Read(bytes.NewReader(b[12+length:]))
+		assert.ErrorIs(t, err, addr.ErrMissingPeerIdx)
+	})
+	t.Run("truncated", func(t *testing.T) {
+		t.Parallel()
+		b := encodeMRT(3, testMRTPrefixes)
+		_, err := addr.NewRIB().Read(bytes.NewReader(b[:len(b)-10]))
+		assert.Error(t, err)
+	})
+}
+
+func Test_LoadRIB(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	gz := &bytes.Buffer{}
+	w := gzip.NewWriter(gz)
+	_, err := w.Write(encodeMRT(3, testMRTPrefixes))
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+	first := filepath.Join(dir, "bview.20231018.0000.gz")
+	assert.NoError(t, os.WriteFile(first, gz.Bytes(), 0o644))
+	second := filepath.Join(dir, "rib.20231018.0000")
+	err = os.WriteFile(second, encodeMRT(2, []mrtPrefix{{prefix: "192.0.2.0/24", routes: []mrtRoute{
+		{peer: 0, path: []uint32{65000, 6939, 64510}},
+		{peer: 1, path: []uint32{65001, 64512}},
+	}}}), 0o644)
+	assert.NoError(t, err)
+
+	rib, err := addr.LoadRIB(first, second)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, rib.Len())
+	assert.Equal(t, 5, rib.Peers)
+	e := rib.Lookup(netip.MustParseAddr("192.0.2.1"))
+	if assert.NotNil(t, e) {
+		assert.Equal(t, 5, e.Peers)
+		assert.Equal(t, []addr.RIBOrigin{{ASN: 64510, Peers: 2}, {ASN: 64511, Peers: 2}, {ASN: 64512, Peers: 1}}, e.Origins)
+		assert.Equal(t, []uint32{174, 3356, 6939, 64500, 65001}, e.Upstreams)
+		assert.Len(t, e.Paths, 5)
+	}
+
+	_, err = addr.LoadRIB(filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+}
+
+func TestResult_Route(t *testing.T) {
+	t.Parallel()
+	rib := readTestRIB(t)
+	e := rib.Lookup(netip.MustParseAddr("192.0.2.1"))
+	r := addr.NewIPResult("192.0.2.1", &addr.Response{ASN: nil, Network: e.Prefix, Route: e}, nil)
+	assert.Equal(t, "64511 64510", r.Column("origins"))
+	assert.Equal(t, "174 3356 64500", r.Column("upstreams"))
+	assert.Equal(t, "3", r.Column("peers"))
+	b, err := json.Marshal(r)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `"origins":[{"asn":64511,"peers":2},{"asn":64510,"peers":1}]`)
+	out := &addr.Result{}
+	err = json.Unmarshal(b, out)
+	assert.NoError(t, err)
+	assert.Equal(t, e, out.Route)
+}
+
+func Test_QueryIPPrefixWith_RIB(t *testing.T) {
+	t.Parallel()
+	opts := addr.QueryOptions{Offline: true, RIB: readTestRIB(t)}
+	r, err := addr.QueryIPPrefixWith(nil, "1.1.1.1", opts)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(13335), r.ASN.Uint32())
+	assert.Equal(t, "1.1.1.0/24", r.Network.String())
+	assert.True(t, r.FromQuery)
+	assert.Equal(t, []uint32{174, 3356}, r.Route.Upstreams)
+
+	_, err = addr.QueryIPPrefixWith(nil, "9.9.9.9", opts)
+	assert.ErrorContains(t, err, "no MMDB or delegation data", "addresses without a route fall back to other lookups")
+
+	t.Run("names from MMDB", func(t *testing.T) {
+		t.Parallel()
+		opts := addr.QueryOptions{Offline: true, RIB: opts.RIB, MMDB: openTestMMDB(t)}
+		r, err := addr.QueryIPPrefixWith(nil, "1.1.1.1", opts)
+		assert.NoError(t, err)
+		assert.Equal(t, "CLOUDFLARENET", r.Name)
+		r, err = addr.QueryIPPrefixWith(nil, "1.0.0.1", opts)
+		assert.NoError(t, err)
+		assert.Equal(t, "", r.Name, "the MMDB has no origin for the route")
+	})
+	t.Run("names from PeeringDB", func(t *testing.T) {
+		t.Parallel()
+		rib := addr.NewRIB()
+		_, err := rib.Read(bytes.NewReader(encodeMRT(1, []mrtPrefix{
+			{prefix: "5.5.5.0/24", routes: []mrtRoute{{peer: 0, path: []uint32{64501, 64500}}}},
+		})))
+		assert.NoError(t, err)
+		opts := addr.QueryOptions{Offline: true, RIB: rib, PeeringDB: readTestPeeringDB(t)}
+		r, err := addr.QueryIPPrefixWith(nil, "5.5.5.5", opts)
+		assert.NoError(t, err)
+		assert.Equal(t, "Example Transit", r.Name)
+		assert.Equal(t, 10, r.PeeringDB.ID)
+	})
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	addr "github.com/thatmattlove/addr/pkg"
+	"github.com/thatmattlove/addr/pkg/metrics"
+)
+
+// MAX_CACHE_ENTRIES is the number of results kept before expired entries are evicted.
+var MAX_CACHE_ENTRIES int = 10000
+
+// CACHE_NAME identifies the server's cache in metrics.
+const CACHE_NAME string = "server"
+
+type cacheEntry struct {
+	result  *addr.Result
+	expires time.Time
+}
+
+// cache is a TTL cache of successful lookups, shared by all clients.
+type cache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]cacheEntry
+}
+
+func newCache(ttl time.Duration) *cache {
+	return &cache{ttl: ttl, entries: map[string]cacheEntry{}}
+}
+
+func (c *cache) get(key string) *addr.Result {
+	if c.ttl <= 0 {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if ok && time.Now().After(e.expires) {
+		delete(c.entries, key)
+		ok = false
+	}
+	metrics.HOOK.Cache(CACHE_NAME, ok)
+	if !ok {
+		return nil
+	}
+	return e.result
+}
+
+func (c *cache) set(key string, r *addr.Result) {
+	if c.ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if len(c.entries) >= MAX_CACHE_ENTRIES {
+		for k, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		// If nothing has expired, drop arbitrary entries rather than growing without bound.
+		for k := range c.entries {
+			if len(c.entries) < MAX_CACHE_ENTRIES {
+				break
+			}
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = cacheEntry{result: r, expires: now.Add(c.ttl)}
+}
+
+// errLookupPanic is returned to callers sharing a lookup that panicked.
+var errLookupPanic = errors.New("lookup failed unexpectedly")
+
+type call struct {
+	wg     sync.WaitGroup
+	result *addr.Result
+	err    error
+}
+
+// group coalesces identical in-flight lookups, so only one query is made per key at a time.
+type group struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+func newGroup() *group {
+	return &group{calls: map[string]*call{}}
+}
+
+func (g *group) do(key string, fn func() (*addr.Result, error)) (*addr.Result, error) {
+	g.mu.Lock()
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		c.wg.Wait()
+		return c.result, c.err
+	}
+	c := &call{err: errLookupPanic}
+	c.wg.Add(1)
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	// Complete the call even if fn panics, so waiters aren't blocked forever.
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		c.wg.Done()
+	}()
+	c.result, c.err = fn()
+	return c.result, c.err
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+// IDLE_CLIENT_TIMEOUT is how long a client's rate limit state is kept after its last request.
+var IDLE_CLIENT_TIMEOUT time.Duration = time.Minute * 10
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// limiter is a per-client token bucket rate limiter.
+type limiter struct {
+	mu      sync.Mutex
+	rate    float64
+	burst   float64
+	clients map[string]*bucket
+	swept   time.Time
+}
+
+func newLimiter(rate float64, burst int) *limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &limiter{
+		rate:    rate,
+		burst:   float64(burst),
+		clients: map[string]*bucket{},
+		swept:   time.Now(),
+	}
+}
+
+// allow returns true if client may make a request. If not, the time until the next request is
+// allowed is returned.
+func (l *limiter) allow(client string) (bool, time.Duration) {
+	if l.rate <= 0 {
+		return true, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b := l.bucket(client)
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+		return false, wait
+	}
+	b.tokens--
+	return true, 0
+}
+
+// charge takes n more tokens from client's bucket for a request that was already allowed. The
+// bucket may go below zero, which delays the client's next request until it's paid back.
+func (l *limiter) charge(client string, n int) {
+	if l.rate <= 0 || n <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.bucket(client).tokens -= float64(n)
+}
+
+// bucket returns client's bucket, refilled for the time since its last request.
+func (l *limiter) bucket(client string) *bucket {
+	now := time.Now()
+	l.sweep(now)
+	b, ok := l.clients[client]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.clients[client] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * l.rate
+	if b.tokens > l.burst {
+		b.tokens = l.burst
+	}
+	b.last = now
+	return b
+}
+
+func (l *limiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < IDLE_CLIENT_TIMEOUT {
+		return
+	}
+	for k, b := range l.clients {
+		if now.Sub(b.last) > IDLE_CLIENT_TIMEOUT {
+			delete(l.clients, k)
+		}
+	}
+	l.swept = now
+}
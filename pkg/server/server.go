@@ -0,0 +1,378 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/thatmattlove/addr/internal/util"
+	addr "github.com/thatmattlove/addr/pkg"
+)
+
+var (
+	DEFAULT_MAX_BULK int = 100
+	BULK_WORKERS     int = 8
+	// MAX_BODY_SIZE is the maximum size of a /bulk request body, in bytes.
+	MAX_BODY_SIZE int64 = 1 << 20
+)
+
+type Options struct {
+	// CacheTTL is how long successful lookups are cached. Caching is disabled if zero.
+	CacheTTL time.Duration
+	// RateLimit is the number of requests per second allowed per client IP address, with bursts
+	// of up to Burst requests. Rate limiting is disabled if zero.
+	RateLimit float64
+	Burst     int
+	// MaxBulk is the maximum number of targets in a /bulk request or range, after ranges are split
+	// into prefixes & ASNs. Defaults to DEFAULT_MAX_BULK.
+	MaxBulk int
+	// QueryIP, QueryASN & ReverseLookup default to addr.QueryIPPrefix, addr.QueryASN &
+	// addr.DNSReverseLookup.
+	QueryIP       func(string) (*addr.Response, error)
+	QueryASN      func(string) (*addr.Response, error)
+	ReverseLookup func(*net.IP) ([]string, error)
+}
+
+// Server serves lookups over HTTP:
+//
+//	GET  /ip/{ip, prefix or range}
+//	GET  /asn/{asn or range}
+//	POST /bulk     {"targets": ["192.0.2.1", "AS14525"]}
+//	GET  /healthz
+//
+// Results use the same JSON schema as 'addr --output json'. Ranges, e.g. '192.0.2.10-192.0.2.50'
+// or 'AS64512-AS64520', are split into the prefixes or ASNs they cover, which are each looked up,
+// and counted against the rate limit, like the targets of a /bulk request.
+type Server struct {
+	opts    Options
+	cache   *cache
+	group   *group
+	limiter *limiter
+	mux     *http.ServeMux
+}
+
+type errInvalidTarget struct{ error }
+
+type bulkRequest struct {
+	Targets []string `json:"targets"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func New(opts Options) *Server {
+	if opts.QueryIP == nil {
+		opts.QueryIP = addr.QueryIPPrefix
+	}
+	if opts.QueryASN == nil {
+		opts.QueryASN = addr.QueryASN
+	}
+	if opts.ReverseLookup == nil {
+		opts.ReverseLookup = addr.DNSReverseLookup
+	}
+	if opts.MaxBulk <= 0 {
+		opts.MaxBulk = DEFAULT_MAX_BULK
+	}
+	s := &Server{
+		opts:    opts,
+		cache:   newCache(opts.CacheTTL),
+		group:   newGroup(),
+		limiter: newLimiter(opts.RateLimit, opts.Burst),
+		mux:     http.NewServeMux(),
+	}
+	s.mux.HandleFunc("/ip/", s.handleIP)
+	s.mux.HandleFunc("/asn/", s.handleASN)
+	s.mux.HandleFunc("/bulk", s.handleBulk)
+	s.mux.HandleFunc("/healthz", s.handleHealth)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/healthz" {
+		ok, wait := s.limiter.allow(clientAddr(r))
+		if !ok {
+			w.Header().Set("Retry-After", fmt.Sprint(int(math.Ceil(wait.Seconds()))))
+			writeJSON(w, http.StatusTooManyRequests, errorResponse{"rate limit exceeded"})
+			return
+		}
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// ListenAndServe serves lookups on listen, e.g. ':8080'.
+func (s *Server) ListenAndServe(listen string) error {
+	srv := &http.Server{
+		Addr:              listen,
+		Handler:           s,
+		ReadHeaderTimeout: time.Second * 10,
+	}
+	return srv.ListenAndServe()
+}
+
+// Lookup looks up an IP address, prefix or ASN, or each prefix or ASN in an IP or ASN range.
+// Results are cached, and identical lookups made at the same time share a single query.
+func (s *Server) Lookup(target string) ([]*addr.Result, error) {
+	targets, isRange, err := expand(target)
+	if err != nil {
+		return nil, err
+	}
+	if isRange {
+		return s.lookupAll(targets, make([]*addr.Result, len(targets))), nil
+	}
+	res, err := s.lookup(targets[0])
+	if err != nil {
+		return nil, err
+	}
+	return []*addr.Result{res}, nil
+}
+
+// expand splits IP & ASN ranges into the prefixes & ASNs they cover. IP addresses, prefixes &
+// ASNs are returned as is.
+func expand(target string) ([]string, bool, error) {
+	target = strings.TrimSpace(target)
+	if util.IsIP(target) || addr.IsASN(target) {
+		return []string{target}, false, nil
+	}
+	if rng, err := addr.ParseIPRange(target); err == nil {
+		targets := []string{}
+		for _, p := range rng.Prefixes() {
+			targets = append(targets, p.String())
+		}
+		return targets, true, nil
+	}
+	r, err := addr.ParseASNRange(target)
+	if err != nil {
+		if low, _, isRange := strings.Cut(target, "-"); isRange && addr.IsASN(low) {
+			return nil, false, errInvalidTarget{err}
+		}
+		return nil, false, errInvalidTarget{fmt.Errorf("invalid target '%s', must be an IP address, prefix, range or ASN", target)}
+	}
+	targets := []string{}
+	for _, asn := range r.ASNs() {
+		targets = append(targets, "AS"+asn.ASPlain())
+	}
+	return targets, true, nil
+}
+
+func (s *Server) lookup(target string) (*addr.Result, error) {
+	if util.IsIP(target) {
+		return s.lookupIP(target)
+	}
+	asn, err := addr.ParseASN(target)
+	if err != nil {
+		return nil, errInvalidTarget{err}
+	}
+	return s.lookupASN(target, asn.ASPlain())
+}
+
+// lookupAll concurrently looks up each target that doesn't have a result yet, with an error
+// result for each failed lookup.
+func (s *Server) lookupAll(targets []string, results []*addr.Result) []*addr.Result {
+	sem := make(chan struct{}, BULK_WORKERS)
+	wg := sync.WaitGroup{}
+	for i, t := range targets {
+		if results[i] != nil {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, t string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			res, err := s.lookup(t)
+			if err != nil {
+				res = addr.NewErrorResult(t, resultType(t), err)
+			}
+			results[i] = res
+		}(i, t)
+	}
+	wg.Wait()
+	return results
+}
+
+func (s *Server) lookupIP(query string) (*addr.Result, error) {
+	v, err := addr.NewIPValidator(query)
+	if err != nil {
+		return nil, errInvalidTarget{err}
+	}
+	key := v.Addr.String()
+	if v.Net != nil {
+		key = v.Network.String()
+	}
+	return s.cached("ip:"+key, query, func() (*addr.Result, error) {
+		r, err := s.opts.QueryIP(key)
+		if err != nil {
+			return nil, err
+		}
+		var ptrs []string
+		if r.IP != nil && v.Net == nil {
+			ptrs, _ = s.opts.ReverseLookup(r.IP)
+		}
+		return addr.NewIPResult(key, r, ptrs), nil
+	})
+}
+
+func (s *Server) lookupASN(query, asn string) (*addr.Result, error) {
+	return s.cached("asn:"+asn, query, func() (*addr.Result, error) {
+		r, err := s.opts.QueryASN(asn)
+		if err != nil {
+			return nil, err
+		}
+		return addr.NewASNResult("AS"+asn, r), nil
+	})
+}
+
+// cached returns a cached result for key, or runs fn. The returned result is a copy with Query
+// set to query.
+func (s *Server) cached(key, query string, fn func() (*addr.Result, error)) (*addr.Result, error) {
+	res := s.cache.get(key)
+	if res == nil {
+		var err error
+		res, err = s.group.do(key, func() (*addr.Result, error) {
+			r, err := fn()
+			if err == nil {
+				s.cache.set(key, r)
+			}
+			return r, err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	out := *res
+	out.Query = query
+	return &out, nil
+}
+
+func (s *Server) handleIP(w http.ResponseWriter, r *http.Request) {
+	target := strings.TrimPrefix(r.URL.Path, "/ip/")
+	if !util.IsIP(target) && !addr.IsIPRange(target) {
+		writeJSON(w, http.StatusBadRequest, errorResponse{fmt.Sprintf("invalid IP address, prefix or range '%s'", target)})
+		return
+	}
+	s.handleLookup(w, r, target)
+}
+
+func (s *Server) handleASN(w http.ResponseWriter, r *http.Request) {
+	target := strings.TrimPrefix(r.URL.Path, "/asn/")
+	if _, err := addr.ParseASNRange(target); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{err.Error()})
+		return
+	}
+	s.handleLookup(w, r, target)
+}
+
+func (s *Server) handleLookup(w http.ResponseWriter, r *http.Request, target string) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	targets, isRange, err := expand(target)
+	if err != nil {
+		writeJSON(w, errorStatus(err), errorResponse{err.Error()})
+		return
+	}
+	if !s.allowTargets(w, r, len(targets)) {
+		return
+	}
+	if isRange {
+		writeJSON(w, http.StatusOK, s.lookupAll(targets, make([]*addr.Result, len(targets))))
+		return
+	}
+	res, err := s.lookup(targets[0])
+	if err != nil {
+		writeJSON(w, errorStatus(err), errorResponse{err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (s *Server) handleBulk(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+	req := bulkRequest{}
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, MAX_BODY_SIZE)).Decode(&req)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{fmt.Sprintf("invalid request body: %s", err)})
+		return
+	}
+	targets, results := []string{}, []*addr.Result{}
+	for _, t := range req.Targets {
+		expanded, _, err := expand(t)
+		if err != nil {
+			targets = append(targets, t)
+			results = append(results, addr.NewErrorResult(t, resultType(t), err))
+			continue
+		}
+		targets = append(targets, expanded...)
+		results = append(results, make([]*addr.Result, len(expanded))...)
+	}
+	if !s.allowTargets(w, r, len(targets)) {
+		return
+	}
+	writeJSON(w, http.StatusOK, s.lookupAll(targets, results))
+}
+
+// allowTargets refuses requests for more than MaxBulk targets, and charges the rate limit for
+// each target after the first, which ServeHTTP has already charged for.
+func (s *Server) allowTargets(w http.ResponseWriter, r *http.Request, n int) bool {
+	if n > s.opts.MaxBulk {
+		writeJSON(w, http.StatusRequestEntityTooLarge, errorResponse{fmt.Sprintf("%d targets exceeds the limit of %d", n, s.opts.MaxBulk)})
+		return false
+	}
+	s.limiter.charge(clientAddr(r), n-1)
+	return true
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	writeJSON(w, http.StatusMethodNotAllowed, errorResponse{fmt.Sprintf("method %s not allowed", r.Method)})
+	return false
+}
+
+// resultType returns the type of result for a target, or an empty string if it's neither an IP
+// address, prefix or range, nor an ASN or ASN range.
+func resultType(target string) string {
+	if util.IsIP(target) || addr.IsIPRange(target) {
+		return addr.RESULT_IP
+	}
+	if low, _, _ := strings.Cut(target, "-"); addr.IsASN(low) {
+		return addr.RESULT_ASN
+	}
+	return ""
+}
+
+func errorStatus(err error) int {
+	if errors.As(err, &errInvalidTarget{}) {
+		return http.StatusBadRequest
+	}
+	return http.StatusBadGateway
+}
+
+func clientAddr(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
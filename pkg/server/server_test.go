@@ -0,0 +1,389 @@
+package server_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/biter777/countries"
+	"github.com/stretchr/testify/assert"
+	addr "github.com/thatmattlove/addr/pkg"
+	"github.com/thatmattlove/addr/pkg/metrics"
+	"github.com/thatmattlove/addr/pkg/server"
+	goasn "github.com/thatmattlove/go-asn"
+)
+
+type fakeQueries struct {
+	ip    atomic.Int32
+	asn   atomic.Int32
+	block chan struct{}
+}
+
+func (f *fakeQueries) options() server.Options {
+	return server.Options{
+		CacheTTL: time.Minute,
+		QueryIP: func(q string) (*addr.Response, error) {
+			f.ip.Add(1)
+			if f.block != nil {
+				<-f.block
+			}
+			if q == "192.0.2.99" {
+				return nil, errors.New("upstream failure")
+			}
+			a, _, _ := strings.Cut(q, "/")
+			ip := net.ParseIP(a)
+			return &addr.Response{
+				ASN:       goasn.MustParse("65000"),
+				IP:        &ip,
+				Addr:      netip.MustParseAddr(a),
+				Network:   netip.MustParsePrefix("192.0.2.0/24"),
+				Name:      "Example",
+				Country:   countries.USA,
+				Registry:  "ARIN",
+				FromQuery: true,
+			}, nil
+		},
+		QueryASN: func(q string) (*addr.Response, error) {
+			f.asn.Add(1)
+			return &addr.Response{ASN: goasn.MustParse(q), Name: "Example", FromQuery: true}, nil
+		},
+		ReverseLookup: func(ip *net.IP) ([]string, error) {
+			return []string{"host.example."}, nil
+		},
+	}
+}
+
+func get(t *testing.T, h http.Handler, path string) (*httptest.ResponseRecorder, map[string]any) {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	body := map[string]any{}
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	return rec, body
+}
+
+func getAll(t *testing.T, h http.Handler, path string) (*httptest.ResponseRecorder, []map[string]any) {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	body := []map[string]any{}
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	return rec, body
+}
+
+func Test_Server(t *testing.T) {
+	t.Run("healthz", func(t *testing.T) {
+		t.Parallel()
+		rec, body := get(t, server.New((&fakeQueries{}).options()), "/healthz")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "ok", body["status"])
+	})
+	t.Run("ip", func(t *testing.T) {
+		t.Parallel()
+		rec, body := get(t, server.New((&fakeQueries{}).options()), "/ip/192.0.2.1")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+		assert.Equal(t, "192.0.2.1", body["query"])
+		assert.Equal(t, "192.0.2.0/24", body["prefix"])
+		assert.Equal(t, float64(65000), body["asn"])
+		assert.Equal(t, "US", body["country"])
+		assert.Equal(t, []any{"host.example."}, body["ptrs"])
+	})
+	t.Run("prefix", func(t *testing.T) {
+		t.Parallel()
+		rec, body := get(t, server.New((&fakeQueries{}).options()), "/ip/10.0.0.0/8")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "10.0.0.0/8", body["query"])
+	})
+	t.Run("invalid ip", func(t *testing.T) {
+		t.Parallel()
+		rec, body := get(t, server.New((&fakeQueries{}).options()), "/ip/nope")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, body["error"], "invalid IP address")
+	})
+	t.Run("ip range", func(t *testing.T) {
+		t.Parallel()
+		rec, body := getAll(t, server.New((&fakeQueries{}).options()), "/ip/192.0.2.10-192.0.2.17")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Len(t, body, 3)
+		assert.Equal(t, "192.0.2.10/31", body[0]["query"])
+		assert.Equal(t, "192.0.2.12/30", body[1]["query"])
+		assert.Equal(t, "192.0.2.16/31", body[2]["query"])
+	})
+	t.Run("inetnum range", func(t *testing.T) {
+		t.Parallel()
+		rec, body := getAll(t, server.New((&fakeQueries{}).options()), "/ip/192.0.2.0%20-%20192.0.2.255")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Len(t, body, 1)
+		assert.Equal(t, "192.0.2.0/24", body[0]["query"])
+	})
+	t.Run("range too large", func(t *testing.T) {
+		t.Parallel()
+		opts := (&fakeQueries{}).options()
+		opts.MaxBulk = 2
+		rec, body := get(t, server.New(opts), "/ip/192.0.2.10-192.0.2.17")
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+		assert.Equal(t, "3 targets exceeds the limit of 2", body["error"])
+	})
+	t.Run("upstream error", func(t *testing.T) {
+		t.Parallel()
+		rec, body := get(t, server.New((&fakeQueries{}).options()), "/ip/192.0.2.99")
+		assert.Equal(t, http.StatusBadGateway, rec.Code)
+		assert.Equal(t, "upstream failure", body["error"])
+	})
+	t.Run("asn", func(t *testing.T) {
+		t.Parallel()
+		rec, body := get(t, server.New((&fakeQueries{}).options()), "/asn/as1.10")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "as1.10", body["query"])
+		assert.Equal(t, float64(65546), body["asn"])
+	})
+	t.Run("asn range", func(t *testing.T) {
+		t.Parallel()
+		f := &fakeQueries{}
+		rec, body := getAll(t, server.New(f.options()), "/asn/AS65000-AS65002")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Len(t, body, 3)
+		for i, asn := range []float64{65000, 65001, 65002} {
+			assert.Equal(t, asn, body[i]["asn"])
+		}
+		assert.Equal(t, int32(3), f.asn.Load())
+	})
+	t.Run("invalid asn range", func(t *testing.T) {
+		t.Parallel()
+		rec, body := get(t, server.New((&fakeQueries{}).options()), "/asn/AS65002-AS65000")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, body["error"], "is lower than")
+	})
+	t.Run("invalid asn", func(t *testing.T) {
+		t.Parallel()
+		rec, body := get(t, server.New((&fakeQueries{}).options()), "/asn/foo1")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, body["error"], "unexpected character 'f'")
+	})
+	t.Run("method not allowed", func(t *testing.T) {
+		t.Parallel()
+		s := server.New((&fakeQueries{}).options())
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/ip/192.0.2.1", nil))
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+		assert.Equal(t, http.MethodGet, rec.Header().Get("Allow"))
+	})
+}
+
+func TestServer_Cache(t *testing.T) {
+	f := &fakeQueries{}
+	s := server.New(f.options())
+	get(t, s, "/ip/192.0.2.1")
+	_, body := get(t, s, "/ip/192.0.2.1/32")
+	get(t, s, "/asn/AS65000")
+	get(t, s, "/asn/65000")
+	assert.Equal(t, int32(2), f.ip.Load(), "/32 prefix is cached separately from the address")
+	assert.Equal(t, int32(1), f.asn.Load())
+	assert.Equal(t, "192.0.2.1/32", body["query"])
+
+	f = &fakeQueries{}
+	opts := f.options()
+	opts.CacheTTL = 0
+	s = server.New(opts)
+	get(t, s, "/asn/AS65000")
+	get(t, s, "/asn/AS65000")
+	assert.Equal(t, int32(2), f.asn.Load())
+}
+
+type cacheMetrics struct {
+	metrics.Noop
+	hits   int
+	misses int
+}
+
+func (c *cacheMetrics) Cache(name string, hit bool) {
+	if hit {
+		c.hits++
+	} else {
+		c.misses++
+	}
+}
+
+func TestServer_CacheMetrics(t *testing.T) {
+	m := &cacheMetrics{}
+	prev := metrics.HOOK
+	metrics.HOOK = m
+	t.Cleanup(func() { metrics.HOOK = prev })
+	f := &fakeQueries{}
+	s := server.New(f.options())
+	get(t, s, "/asn/AS65000")
+	get(t, s, "/asn/AS65000")
+	get(t, s, "/asn/AS65000")
+	assert.Equal(t, 2, m.hits)
+	assert.Equal(t, 1, m.misses)
+}
+
+func TestServer_Coalescing(t *testing.T) {
+	f := &fakeQueries{block: make(chan struct{})}
+	s := server.New(f.options())
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := s.Lookup("192.0.2.1")
+			assert.NoError(t, err)
+			assert.Equal(t, "Example", res[0].Name)
+		}()
+	}
+	time.Sleep(time.Millisecond * 50)
+	close(f.block)
+	wg.Wait()
+	assert.Equal(t, int32(1), f.ip.Load())
+}
+
+func TestServer_CoalescingPanic(t *testing.T) {
+	f := &fakeQueries{block: make(chan struct{})}
+	opts := f.options()
+	queryIP := opts.QueryIP
+	opts.QueryIP = func(q string) (*addr.Response, error) {
+		res, err := queryIP(q)
+		if q == "192.0.2.98" {
+			panic("query failed")
+		}
+		return res, err
+	}
+	s := server.New(opts)
+	wg := sync.WaitGroup{}
+	panics := atomic.Int32{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if recover() != nil {
+					panics.Add(1)
+				}
+			}()
+			_, err := s.Lookup("192.0.2.98")
+			assert.Error(t, err)
+		}()
+	}
+	time.Sleep(time.Millisecond * 50)
+	close(f.block)
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second * 5):
+		t.Fatal("lookups waiting on a panicked lookup never returned")
+	}
+	assert.Equal(t, int32(1), panics.Load())
+	assert.Equal(t, int32(1), f.ip.Load())
+
+	_, err := s.Lookup("192.0.2.1")
+	assert.NoError(t, err)
+}
+
+func TestServer_RateLimit(t *testing.T) {
+	opts := (&fakeQueries{}).options()
+	opts.RateLimit = 0.5
+	opts.Burst = 2
+	s := server.New(opts)
+	request := func(path, remote string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = remote
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec
+	}
+	assert.Equal(t, http.StatusOK, request("/ip/192.0.2.1", "198.51.100.1:1000").Code)
+	assert.Equal(t, http.StatusOK, request("/ip/192.0.2.1", "198.51.100.1:1001").Code)
+	rec := request("/ip/192.0.2.1", "198.51.100.1:1002")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get("Retry-After"))
+	assert.Equal(t, http.StatusOK, request("/ip/192.0.2.1", "198.51.100.2:1000").Code, "other clients are not limited")
+	assert.Equal(t, http.StatusOK, request("/healthz", "198.51.100.1:1003").Code, "health checks are not limited")
+
+	t.Run("per target", func(t *testing.T) {
+		t.Parallel()
+		s := server.New(opts)
+		req := httptest.NewRequest(http.MethodPost, "/bulk", strings.NewReader(`{"targets": ["192.0.2.1", "192.0.2.2", "AS65000-AS65002"]}`))
+		req.RemoteAddr = "198.51.100.1:1000"
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code, "requests are allowed while the client has tokens")
+		req = httptest.NewRequest(http.MethodGet, "/ip/192.0.2.1", nil)
+		req.RemoteAddr = "198.51.100.1:1001"
+		rec = httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+		assert.Equal(t, "8", rec.Header().Get("Retry-After"), "each of the 5 targets costs a token")
+	})
+}
+
+func TestServer_Bulk(t *testing.T) {
+	post := func(s http.Handler, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/bulk", strings.NewReader(body)))
+		return rec
+	}
+	t.Run("basic", func(t *testing.T) {
+		t.Parallel()
+		rec := post(server.New((&fakeQueries{}).options()), `{"targets": ["192.0.2.1", "AS65000", "nope", "192.0.2.99"]}`)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		results := []map[string]any{}
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &results))
+		assert.Len(t, results, 4)
+		assert.Equal(t, "ip", results[0]["type"])
+		assert.Equal(t, "asn", results[1]["type"])
+		assert.Equal(t, "nope", results[2]["query"])
+		assert.Contains(t, results[2]["error"], "invalid target")
+		assert.Equal(t, "upstream failure", results[3]["error"])
+	})
+	t.Run("ranges", func(t *testing.T) {
+		t.Parallel()
+		rec := post(server.New((&fakeQueries{}).options()), `{"targets": ["192.0.2.0 - 192.0.2.3", "AS65000-AS65001", "AS2-AS1", "192.0.2.1"]}`)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		results := []map[string]any{}
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &results))
+		assert.Len(t, results, 5)
+		assert.Equal(t, "192.0.2.0/30", results[0]["query"])
+		assert.Equal(t, "AS65000", results[1]["query"])
+		assert.Equal(t, "AS65001", results[2]["query"])
+		assert.Equal(t, "asn", results[3]["type"])
+		assert.Contains(t, results[3]["error"], "is lower than")
+		assert.Equal(t, "192.0.2.1", results[4]["query"])
+	})
+	t.Run("too many targets after ranges", func(t *testing.T) {
+		t.Parallel()
+		opts := (&fakeQueries{}).options()
+		opts.MaxBulk = 2
+		rec := post(server.New(opts), `{"targets": ["AS65000-AS65002"]}`)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	})
+	t.Run("too many targets", func(t *testing.T) {
+		t.Parallel()
+		opts := (&fakeQueries{}).options()
+		opts.MaxBulk = 1
+		rec := post(server.New(opts), `{"targets": ["192.0.2.1", "192.0.2.2"]}`)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	})
+	t.Run("invalid body", func(t *testing.T) {
+		t.Parallel()
+		rec := post(server.New((&fakeQueries{}).options()), `["192.0.2.1"`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+	t.Run("get", func(t *testing.T) {
+		t.Parallel()
+		rec, _ := get(t, server.New((&fakeQueries{}).options()), "/bulk")
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	})
+}
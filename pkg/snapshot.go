@@ -0,0 +1,115 @@
+package addr
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// SNAPSHOT_FIELDS are the result columns compared by DiffSnapshots, plus 'advertised'.
+var SNAPSHOT_FIELDS = []string{"asn", "prefix", "advertised", "name", "country", "registry", "ptr", "error"}
+
+// WriteSnapshot writes results as an indented JSON array, the same format as '--output json'.
+func WriteSnapshot(w io.Writer, results []*Result) error {
+	if results == nil {
+		results = []*Result{}
+	}
+	b, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(b))
+	return err
+}
+
+// ReadSnapshot reads results written by WriteSnapshot or '--output json'.
+func ReadSnapshot(r io.Reader) ([]*Result, error) {
+	results := []*Result{}
+	err := json.NewDecoder(r).Decode(&results)
+	if err != nil {
+		return nil, fmt.Errorf("invalid snapshot: %w", err)
+	}
+	for i, res := range results {
+		if res == nil {
+			return nil, fmt.Errorf("invalid snapshot: result %d is null", i)
+		}
+	}
+	return results, nil
+}
+
+// FieldChange is a change to one field of a result between two snapshots.
+type FieldChange struct {
+	Field string
+	Old   string
+	New   string
+}
+
+// ResultChange lists the fields that changed for a target between two snapshots.
+type ResultChange struct {
+	Target  string
+	Changes []FieldChange
+}
+
+// SnapshotDiff is the difference between two snapshots. Targets are in the order of the new
+// snapshot, followed by any only in the old snapshot.
+type SnapshotDiff struct {
+	Added   []*Result
+	Removed []*Result
+	Changed []*ResultChange
+}
+
+func (d *SnapshotDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// DiffSnapshots compares two snapshots by target, reporting changes to SNAPSHOT_FIELDS.
+func DiffSnapshots(before, after []*Result) *SnapshotDiff {
+	diff := &SnapshotDiff{Added: []*Result{}, Removed: []*Result{}, Changed: []*ResultChange{}}
+	previous := make(map[string]*Result, len(before))
+	for _, r := range before {
+		previous[r.Query] = r
+	}
+	current := make(map[string]bool, len(after))
+	for _, r := range after {
+		current[r.Query] = true
+		p, ok := previous[r.Query]
+		if !ok {
+			diff.Added = append(diff.Added, r)
+			continue
+		}
+		changes := []FieldChange{}
+		for _, f := range SNAPSHOT_FIELDS {
+			o, n := snapshotField(p, f), snapshotField(r, f)
+			if o != n {
+				changes = append(changes, FieldChange{Field: f, Old: o, New: n})
+			}
+		}
+		if len(changes) != 0 {
+			diff.Changed = append(diff.Changed, &ResultChange{Target: r.Query, Changes: changes})
+		}
+	}
+	for _, r := range before {
+		if !current[r.Query] {
+			diff.Removed = append(diff.Removed, r)
+		}
+	}
+	return diff
+}
+
+// snapshotField returns a result's value for a field, with PTRs sorted so that reordered DNS
+// answers aren't reported as changes.
+func snapshotField(r *Result, field string) string {
+	switch field {
+	case "advertised":
+		return fmt.Sprint(r.Advertised)
+	case "ptr":
+		ptrs := make([]string, len(r.PTRs))
+		copy(ptrs, r.PTRs)
+		sort.Strings(ptrs)
+		return strings.Join(ptrs, " ")
+	default:
+		return r.Column(field)
+	}
+}
@@ -0,0 +1,76 @@
+package addr_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/biter777/countries"
+	"github.com/stretchr/testify/assert"
+	addr "github.com/thatmattlove/addr/pkg"
+	goasn "github.com/thatmattlove/go-asn"
+)
+
+func snapshotResult(query, asn, prefix, name string, ptrs ...string) *addr.Result {
+	return &addr.Result{
+		Query:      query,
+		Type:       addr.RESULT_IP,
+		IP:         query,
+		Prefix:     prefix,
+		ASN:        goasn.MustParse(asn),
+		Name:       name,
+		Country:    countries.USA,
+		Registry:   "ARIN",
+		Advertised: true,
+		PTRs:       ptrs,
+	}
+}
+
+func Test_Snapshot(t *testing.T) {
+	results := []*addr.Result{
+		snapshotResult("192.0.2.1", "64500", "192.0.2.0/24", "Example", "a.example.", "b.example."),
+		addr.NewErrorResult("192.0.2.99", addr.RESULT_IP, assert.AnError),
+	}
+	buf := &bytes.Buffer{}
+	assert.NoError(t, addr.WriteSnapshot(buf, results))
+	read, err := addr.ReadSnapshot(buf)
+	assert.NoError(t, err)
+	assert.Len(t, read, 2)
+	assert.Empty(t, addr.DiffSnapshots(results, read).Changed)
+
+	_, err = addr.ReadSnapshot(strings.NewReader(`{"query": "192.0.2.1"}`))
+	assert.ErrorContains(t, err, "invalid snapshot")
+	_, err = addr.ReadSnapshot(strings.NewReader(`[{"query": "192.0.2.1"}, null]`))
+	assert.ErrorContains(t, err, "invalid snapshot: result 1 is null")
+}
+
+func Test_DiffSnapshots(t *testing.T) {
+	old := []*addr.Result{
+		snapshotResult("192.0.2.1", "64500", "192.0.2.0/24", "Example", "a.example.", "b.example."),
+		snapshotResult("198.51.100.1", "64500", "198.51.100.0/24", "Example"),
+		snapshotResult("203.0.113.1", "64500", "203.0.113.0/24", "Example"),
+	}
+	current := []*addr.Result{
+		snapshotResult("2001:db8::1", "64500", "2001:db8::/32", "Example"),
+		snapshotResult("192.0.2.1", "64500", "192.0.2.0/24", "Example", "b.example.", "a.example."),
+		snapshotResult("198.51.100.1", "64501", "198.51.100.0/23", "Example Migrated", "c.example."),
+	}
+	diff := addr.DiffSnapshots(old, current)
+	assert.False(t, diff.Empty())
+	if assert.Len(t, diff.Added, 1) {
+		assert.Equal(t, "2001:db8::1", diff.Added[0].Query)
+	}
+	if assert.Len(t, diff.Removed, 1) {
+		assert.Equal(t, "203.0.113.1", diff.Removed[0].Query)
+	}
+	if assert.Len(t, diff.Changed, 1, "reordered PTRs aren't a change") {
+		assert.Equal(t, "198.51.100.1", diff.Changed[0].Target)
+		assert.Equal(t, []addr.FieldChange{
+			{Field: "asn", Old: "64500", New: "64501"},
+			{Field: "prefix", Old: "198.51.100.0/24", New: "198.51.100.0/23"},
+			{Field: "name", Old: "Example", New: "Example Migrated"},
+			{Field: "ptr", Old: "", New: "c.example."},
+		}, diff.Changed[0].Changes)
+	}
+	assert.True(t, addr.DiffSnapshots(old, old).Empty())
+}
@@ -0,0 +1,271 @@
+package addr
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	SPECIAL_V4_FILE string = "iana-ipv4-special-registry.csv"
+	SPECIAL_V6_FILE string = "iana-ipv6-special-registry.csv"
+)
+
+var (
+	IANA_SPECIAL_V4_URL string = "https://www.iana.org/assignments/iana-ipv4-special-registry/iana-ipv4-special-registry-1.csv"
+	IANA_SPECIAL_V6_URL string = "https://www.iana.org/assignments/iana-ipv6-special-registry/iana-ipv6-special-registry-1.csv"
+)
+
+//go:embed data/iana-ipv4-special-registry.csv
+var specialV4CSV []byte
+
+//go:embed data/iana-ipv6-special-registry.csv
+var specialV6CSV []byte
+
+var ErrSpecialRegistryHeader = errors.New("special registry is missing the 'Address Block' or 'Name' column")
+
+// SpecialPrefix is a single entry from an IANA special-purpose address registry.
+type SpecialPrefix struct {
+	Prefix             netip.Prefix
+	Name               string
+	RFC                string
+	Allocated          time.Time
+	Terminated         time.Time
+	Source             bool
+	Destination        bool
+	Forwardable        bool
+	GloballyReachable  bool
+	ReservedByProtocol bool
+	// Label is displayed as the name of results in the block in place of Name, from SPECIAL_LABELS.
+	Label string
+}
+
+// SpecialTable is the lookup table used by GetNonGlobalPrefix. Entries are kept ordered from most
+// to least specific, so the first match is always the longest match.
+type SpecialTable struct {
+	entries []*SpecialPrefix
+}
+
+// SUPPLEMENTAL_PREFIXES are non-global blocks that aren't part of either IANA special-purpose
+// registry, but that addr has always treated as such.
+var SUPPLEMENTAL_PREFIXES = []*SpecialPrefix{
+	{Prefix: PFX_MULTICAST_v4, Name: TXT_MULTICAST, Label: TXT_MULTICAST, RFC: "[RFC5771]"},
+	{Prefix: PFX_MULTICAST_v6, Name: TXT_MULTICAST, Label: TXT_MULTICAST, RFC: "[RFC4291]"},
+}
+
+var SPECIAL_TABLE *SpecialTable = mustDefaultSpecialTable()
+
+func NewSpecialTable(entries ...[]*SpecialPrefix) *SpecialTable {
+	table := &SpecialTable{entries: []*SpecialPrefix{}}
+	for _, e := range entries {
+		table.entries = append(table.entries, e...)
+	}
+	sort.SliceStable(table.entries, func(i, j int) bool {
+		return table.entries[i].Prefix.Bits() > table.entries[j].Prefix.Bits()
+	})
+	return table
+}
+
+// Lookup returns the most specific entry containing a, or nil if a isn't special-purpose.
+func (t *SpecialTable) Lookup(a netip.Addr) *SpecialPrefix {
+	for _, e := range t.entries {
+		if e.Prefix.Contains(a) {
+			return e
+		}
+	}
+	return nil
+}
+
+func (t *SpecialTable) Entries() []*SpecialPrefix {
+	return t.entries
+}
+
+func (t *SpecialTable) Len() int {
+	return len(t.entries)
+}
+
+// ParseSpecialRegistry parses an IANA special-purpose address registry in CSV format, such as
+// iana-ipv4-special-registry.csv or iana-ipv6-special-registry.csv.
+func ParseSpecialRegistry(r io.Reader) ([]*SpecialPrefix, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := map[string]int{}
+	for i, h := range header {
+		columns[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	if _, ok := columns["address block"]; !ok {
+		return nil, ErrSpecialRegistryHeader
+	}
+	if _, ok := columns["name"]; !ok {
+		return nil, ErrSpecialRegistryHeader
+	}
+	column := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return record[i]
+	}
+
+	entries := []*SpecialPrefix{}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := strings.Trim(column(record, "name"), `" `)
+		rfc := strings.Join(strings.Fields(column(record, "rfc")), " ")
+		allocated := parseRegistryDate(column(record, "allocation date"))
+		terminated := parseRegistryDate(column(record, "termination date"))
+		for _, block := range strings.Split(column(record, "address block"), ",") {
+			pfx, err := parseRegistryPrefix(block)
+			if err != nil {
+				return nil, err
+			}
+			label, ok := SPECIAL_LABELS[pfx]
+			if !ok {
+				label = name
+			}
+			entries = append(entries, &SpecialPrefix{
+				Prefix:             pfx,
+				Name:               name,
+				Label:              label,
+				RFC:                rfc,
+				Allocated:          allocated,
+				Terminated:         terminated,
+				Source:             parseRegistryBool(column(record, "source")),
+				Destination:        parseRegistryBool(column(record, "destination")),
+				Forwardable:        parseRegistryBool(column(record, "forwardable")),
+				GloballyReachable:  parseRegistryBool(column(record, "globally reachable")),
+				ReservedByProtocol: parseRegistryBool(column(record, "reserved-by-protocol")),
+			})
+		}
+	}
+	return entries, nil
+}
+
+// LoadSpecialTable builds a lookup table from IANA special-purpose registry files. If either path
+// is empty, the registry embedded at build time is used for that address family.
+func LoadSpecialTable(v4Path, v6Path string) (*SpecialTable, error) {
+	v4, err := readSpecialRegistry(v4Path, specialV4CSV)
+	if err != nil {
+		return nil, err
+	}
+	v6, err := readSpecialRegistry(v6Path, specialV6CSV)
+	if err != nil {
+		return nil, err
+	}
+	return NewSpecialTable(v4, v6, SUPPLEMENTAL_PREFIXES), nil
+}
+
+// DownloadSpecialRegistry fetches an IANA special-purpose registry from url, validates it, and
+// writes it to dest. The number of entries in the registry is returned.
+func DownloadSpecialRegistry(url, dest string) (int, error) {
+	return downloadRegistry(url, dest, func(r io.Reader) (int, error) {
+		entries, err := ParseSpecialRegistry(r)
+		return len(entries), err
+	})
+}
+
+func downloadRegistry(url, dest string, validate func(io.Reader) (int, error)) (int, error) {
+	res, err := http.Get(url)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("failed to download '%s', status %s", url, res.Status)
+	}
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return 0, err
+	}
+	n, err := validate(bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("invalid registry from '%s': %w", url, err)
+	}
+	err = os.MkdirAll(filepath.Dir(dest), 0o755)
+	if err != nil {
+		return 0, err
+	}
+	tmp := dest + ".tmp"
+	err = os.WriteFile(tmp, body, 0o644)
+	if err != nil {
+		return 0, err
+	}
+	err = os.Rename(tmp, dest)
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func readSpecialRegistry(path string, fallback []byte) ([]*SpecialPrefix, error) {
+	if path == "" {
+		return ParseSpecialRegistry(bytes.NewReader(fallback))
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	entries, err := ParseSpecialRegistry(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse '%s': %w", path, err)
+	}
+	return entries, nil
+}
+
+func mustDefaultSpecialTable() *SpecialTable {
+	table, err := LoadSpecialTable("", "")
+	if err != nil {
+		panic(err)
+	}
+	return table
+}
+
+func parseRegistryPrefix(in string) (netip.Prefix, error) {
+	// Strip footnote references, e.g. '192.0.0.0/24 [2]'.
+	if i := strings.Index(in, "["); i != -1 {
+		in = in[:i]
+	}
+	pfx, err := netip.ParsePrefix(strings.TrimSpace(in))
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return pfx.Masked(), nil
+}
+
+func parseRegistryBool(in string) bool {
+	fields := strings.Fields(in)
+	if len(fields) == 0 {
+		return false
+	}
+	return strings.EqualFold(fields[0], "true")
+}
+
+func parseRegistryDate(in string) time.Time {
+	d, err := time.Parse("2006-01", strings.TrimSpace(in))
+	if err != nil {
+		return time.Time{}
+	}
+	return d
+}
@@ -0,0 +1,223 @@
+package addr
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	goasn "github.com/thatmattlove/go-asn"
+)
+
+const SPECIAL_ASN_FILE string = "iana-as-numbers-special-registry.csv"
+
+var IANA_SPECIAL_ASN_URL string = "https://www.iana.org/assignments/iana-as-numbers-special-registry/special-purpose-as-numbers.csv"
+
+//go:embed data/iana-as-numbers-special-registry.csv
+var specialASNCSV []byte
+
+var ErrSpecialASNRegistryHeader = errors.New("special ASN registry is missing the 'AS Number' column")
+
+var rfcPattern = regexp.MustCompile(`RFC\s*(\d+)`)
+
+// SpecialASN is a single entry or range from the IANA special-purpose AS numbers registry.
+type SpecialASN struct {
+	Low       goasn.ASN
+	High      goasn.ASN
+	Name      string
+	Reason    string
+	Reference string
+	// Global is true for special-purpose ASNs that are still expected to appear in the global
+	// routing table, such as AS112.
+	Global bool
+}
+
+type SpecialASNTable struct {
+	entries []*SpecialASN
+}
+
+// SUPPLEMENTAL_ASNS are reserved ranges from the main IANA AS numbers registry that aren't part
+// of the special-purpose registry.
+var SUPPLEMENTAL_ASNS = []*SpecialASN{
+	{
+		Low:       goasn.FromUint32(65552),
+		High:      goasn.FromUint32(131071),
+		Name:      TXT_ASN_RESERVED + " (IANA)",
+		Reason:    "Reserved",
+		Reference: "[IANA]",
+	},
+}
+
+var SPECIAL_ASN_TABLE *SpecialASNTable = mustDefaultSpecialASNTable()
+
+func NewSpecialASNTable(entries ...[]*SpecialASN) *SpecialASNTable {
+	table := &SpecialASNTable{entries: []*SpecialASN{}}
+	for _, e := range entries {
+		table.entries = append(table.entries, e...)
+	}
+	return table
+}
+
+// Lookup returns the entry containing asn, or nil if asn isn't special-purpose.
+func (t *SpecialASNTable) Lookup(asn goasn.ASN) *SpecialASN {
+	for _, e := range t.entries {
+		if asn.GEqual(e.Low) && asn.LEqual(e.High) {
+			return e
+		}
+	}
+	return nil
+}
+
+func (t *SpecialASNTable) Entries() []*SpecialASN {
+	return t.entries
+}
+
+func (t *SpecialASNTable) Len() int {
+	return len(t.entries)
+}
+
+// ParseSpecialASNRegistry parses the IANA special-purpose AS numbers registry in CSV format.
+func ParseSpecialASNRegistry(r io.Reader) ([]*SpecialASN, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := map[string]int{}
+	for i, h := range header {
+		columns[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	if _, ok := columns["as number"]; !ok {
+		return nil, ErrSpecialASNRegistryHeader
+	}
+	column := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.Join(strings.Fields(record[i]), " ")
+	}
+
+	entries := []*SpecialASN{}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		low, high, err := parseASNRange(column(record, "as number"))
+		if err != nil {
+			return nil, err
+		}
+		reason := column(record, "reason for reservation")
+		reference := column(record, "reference")
+		name, global := classifySpecialASN(reason, reference)
+		entries = append(entries, &SpecialASN{
+			Low:       low,
+			High:      high,
+			Name:      name,
+			Reason:    reason,
+			Reference: reference,
+			Global:    global,
+		})
+	}
+	return entries, nil
+}
+
+// LoadSpecialASNTable builds a lookup table from an IANA special-purpose AS numbers registry
+// file. If path is empty, the registry embedded at build time is used.
+func LoadSpecialASNTable(path string) (*SpecialASNTable, error) {
+	entries, err := readSpecialASNRegistry(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewSpecialASNTable(entries, SUPPLEMENTAL_ASNS), nil
+}
+
+// DownloadSpecialASNRegistry fetches the IANA special-purpose AS numbers registry from url,
+// validates it, and writes it to dest. The number of entries in the registry is returned.
+func DownloadSpecialASNRegistry(url, dest string) (int, error) {
+	return downloadRegistry(url, dest, func(r io.Reader) (int, error) {
+		entries, err := ParseSpecialASNRegistry(r)
+		return len(entries), err
+	})
+}
+
+func readSpecialASNRegistry(path string) ([]*SpecialASN, error) {
+	if path == "" {
+		return ParseSpecialASNRegistry(bytes.NewReader(specialASNCSV))
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	entries, err := ParseSpecialASNRegistry(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse '%s': %w", path, err)
+	}
+	return entries, nil
+}
+
+func mustDefaultSpecialASNTable() *SpecialASNTable {
+	table, err := LoadSpecialASNTable("")
+	if err != nil {
+		panic(err)
+	}
+	return table
+}
+
+func parseASNRange(in string) (goasn.ASN, goasn.ASN, error) {
+	lowStr, highStr, isRange := strings.Cut(in, "-")
+	low, err := strconv.ParseUint(strings.TrimSpace(lowStr), 10, 32)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid AS number '%s'", in)
+	}
+	high := low
+	if isRange {
+		high, err = strconv.ParseUint(strings.TrimSpace(highStr), 10, 32)
+		if err != nil || high < low {
+			return nil, nil, fmt.Errorf("invalid AS number range '%s'", in)
+		}
+	}
+	return goasn.FromUint32(uint32(low)), goasn.FromUint32(uint32(high)), nil
+}
+
+// classifySpecialASN builds a display name such as 'Private Use ASN (RFC6996)' from a registry
+// entry's reason & reference.
+func classifySpecialASN(reason, reference string) (string, bool) {
+	var name string
+	global := false
+	lower := strings.ToLower(reason)
+	switch true {
+	case strings.Contains(lower, "private use"):
+		name = TXT_ASN_PRIVATE
+	case strings.Contains(lower, "documentation"):
+		name = TXT_ASN_DOC
+	case strings.Contains(lower, "as_trans"):
+		name = TXT_ASN_TRANS
+	case strings.Contains(lower, "as112"):
+		name = TXT_AS112
+		global = true
+	default:
+		name = TXT_ASN_RESERVED
+	}
+	rfcs := []string{}
+	for _, m := range rfcPattern.FindAllStringSubmatch(reference, -1) {
+		rfcs = append(rfcs, "RFC"+m[1])
+	}
+	if len(rfcs) > 0 {
+		name = fmt.Sprintf("%s (%s)", name, strings.Join(rfcs, ", "))
+	}
+	return name, global
+}
@@ -0,0 +1,121 @@
+package addr_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	addr "github.com/thatmattlove/addr/pkg"
+	goasn "github.com/thatmattlove/go-asn"
+)
+
+const SPECIAL_ASN string = `AS Number,Reason for Reservation,Reference
+0,Reserved by [RFC7607],[RFC7607]
+112,Used by the AS112 project to sink misdirected DNS queries; see [RFC7534],[RFC7534]
+23456,AS_TRANS; reserved by [RFC6793],[RFC6793]
+64496-64511,For documentation and sample code; reserved by [RFC5398],[RFC5398]
+64512-65534,For private use; reserved by [RFC6996],[RFC6996]
+`
+
+func Test_ParseSpecialASNRegistry(t *testing.T) {
+	t.Run("basic", func(t *testing.T) {
+		t.Parallel()
+		entries, err := addr.ParseSpecialASNRegistry(strings.NewReader(SPECIAL_ASN))
+		assert.NoError(t, err)
+		assert.Len(t, entries, 5)
+		assert.Equal(t, "Reserved ASN (RFC7607)", entries[0].Name)
+		assert.True(t, entries[1].Global)
+		assert.Equal(t, "AS_TRANS (RFC6793)", entries[2].Name)
+		assert.Equal(t, "Documentation ASN (RFC5398)", entries[3].Name)
+		assert.Equal(t, "Private Use ASN (RFC6996)", entries[4].Name)
+		assert.Equal(t, uint32(64512), entries[4].Low.Uint32())
+		assert.Equal(t, uint32(65534), entries[4].High.Uint32())
+	})
+	t.Run("missing columns", func(t *testing.T) {
+		t.Parallel()
+		_, err := addr.ParseSpecialASNRegistry(strings.NewReader("ASN,Reason\n0,Reserved\n"))
+		assert.ErrorIs(t, err, addr.ErrSpecialASNRegistryHeader)
+	})
+	t.Run("invalid range", func(t *testing.T) {
+		t.Parallel()
+		_, err := addr.ParseSpecialASNRegistry(strings.NewReader("AS Number,Reason for Reservation\n10-5,Backwards\n"))
+		assert.Error(t, err)
+	})
+	t.Run("invalid number", func(t *testing.T) {
+		t.Parallel()
+		_, err := addr.ParseSpecialASNRegistry(strings.NewReader("AS Number,Reason for Reservation\nnope,Broken\n"))
+		assert.Error(t, err)
+	})
+}
+
+func TestSpecialASNTable_Lookup(t *testing.T) {
+	table, err := addr.LoadSpecialASNTable("")
+	assert.NoError(t, err)
+	type casesT struct {
+		asn  uint32
+		name string
+	}
+	cases := []casesT{
+		{0, "Reserved ASN (RFC7607)"},
+		{112, "AS112 (RFC7534)"},
+		{23456, "AS_TRANS (RFC6793)"},
+		{64496, "Documentation ASN (RFC5398)"},
+		{64512, "Private Use ASN (RFC6996)"},
+		{65534, "Private Use ASN (RFC6996)"},
+		{65535, "Reserved ASN (RFC7300)"},
+		{65551, "Documentation ASN (RFC5398)"},
+		{100000, "Reserved ASN (IANA)"},
+		{4200000000, "Private Use ASN (RFC6996)"},
+		{4294967295, "Reserved ASN (RFC7300)"},
+		{14525, ""},
+		{131072, ""},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(fmt.Sprint(c.asn), func(t *testing.T) {
+			t.Parallel()
+			e := table.Lookup(goasn.FromUint32(c.asn))
+			if c.name == "" {
+				assert.Nil(t, e)
+			} else {
+				assert.NotNil(t, e)
+				assert.Equal(t, c.name, e.Name)
+			}
+		})
+	}
+}
+
+func Test_LoadSpecialASNTable(t *testing.T) {
+	t.Run("from file", func(t *testing.T) {
+		t.Parallel()
+		f := filepath.Join(t.TempDir(), addr.SPECIAL_ASN_FILE)
+		err := os.WriteFile(f, []byte(SPECIAL_ASN), 0o644)
+		assert.NoError(t, err)
+		table, err := addr.LoadSpecialASNTable(f)
+		assert.NoError(t, err)
+		assert.Equal(t, 5+len(addr.SUPPLEMENTAL_ASNS), table.Len())
+		assert.Nil(t, table.Lookup(goasn.FromUint32(4200000000)))
+	})
+	t.Run("missing file", func(t *testing.T) {
+		t.Parallel()
+		_, err := addr.LoadSpecialASNTable(filepath.Join(t.TempDir(), "nope.csv"))
+		assert.Error(t, err)
+	})
+}
+
+func Test_DownloadSpecialASNRegistry(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(SPECIAL_ASN))
+	}))
+	t.Cleanup(srv.Close)
+	dest := filepath.Join(t.TempDir(), addr.SPECIAL_ASN_FILE)
+	n, err := addr.DownloadSpecialASNRegistry(srv.URL, dest)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, n)
+	assert.FileExists(t, dest)
+}
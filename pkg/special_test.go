@@ -0,0 +1,167 @@
+package addr_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	addr "github.com/thatmattlove/addr/pkg"
+)
+
+const (
+	SPECIAL_V4 string = `Address Block,Name,RFC,Allocation Date,Termination Date,Source,Destination,Forwardable,Globally Reachable,Reserved-by-Protocol
+0.0.0.0/8,"""This network""","[RFC791], Section 3.2",1981-09,N/A,True,False,False,False,True
+192.0.0.0/24 [2],IETF Protocol Assignments,"[RFC6890], Section 2.1",2010-01,N/A,False,False,False,False,False
+"192.0.0.170/32, 192.0.0.171/32",NAT64/DNS64 Discovery,"[RFC8880][RFC7050], Section 2.2",2013-02,N/A,False,False,False,False,True
+192.88.99.0/24,Deprecated (6to4 Relay Anycast),[RFC7526],2001-06,2015-03,,,,,
+255.255.255.255/32,Limited Broadcast,"[RFC8190]
+[RFC919], Section 7",1984-10,N/A,False,True,False,False,True
+`
+	SPECIAL_V6 string = `Address Block,Name,RFC,Allocation Date,Termination Date,Source,Destination,Forwardable,Globally Reachable,Reserved-by-Protocol
+::ffff:0:0/96,IPv4-mapped Address,[RFC4291],2006-02,N/A,False,False,False,False,True
+2001::/23,IETF Protocol Assignments,[RFC2928],2000-09,N/A,False [1],False [1],False [1],False [1],False
+2001::/32,TEREDO,"[RFC4380]
+[RFC8190]",2006-01,N/A,True,True,True,N/A [2],False
+`
+)
+
+func Test_ParseSpecialRegistry(t *testing.T) {
+	t.Run("ipv4", func(t *testing.T) {
+		t.Parallel()
+		entries, err := addr.ParseSpecialRegistry(strings.NewReader(SPECIAL_V4))
+		assert.NoError(t, err)
+		assert.Len(t, entries, 6)
+		assert.Equal(t, "0.0.0.0/8", entries[0].Prefix.String())
+		assert.Equal(t, `This network`, entries[0].Name)
+		assert.Equal(t, addr.TXT_THIS, entries[0].Label)
+		assert.Equal(t, "[RFC791], Section 3.2", entries[0].RFC)
+		assert.Equal(t, time.Date(1981, 9, 1, 0, 0, 0, 0, time.UTC), entries[0].Allocated)
+		assert.True(t, entries[0].Terminated.IsZero())
+		assert.True(t, entries[0].Source)
+		assert.False(t, entries[0].Destination)
+		assert.True(t, entries[0].ReservedByProtocol)
+		assert.Equal(t, "192.0.0.0/24", entries[1].Prefix.String())
+		assert.Equal(t, "192.0.0.170/32", entries[2].Prefix.String())
+		assert.Equal(t, "192.0.0.171/32", entries[3].Prefix.String())
+		assert.Equal(t, "NAT64/DNS64 Discovery", entries[3].Label, "blocks without a label use their name")
+		assert.Equal(t, time.Date(2015, 3, 1, 0, 0, 0, 0, time.UTC), entries[4].Terminated)
+		assert.Equal(t, "[RFC8190] [RFC919], Section 7", entries[5].RFC)
+	})
+	t.Run("ipv6", func(t *testing.T) {
+		t.Parallel()
+		entries, err := addr.ParseSpecialRegistry(strings.NewReader(SPECIAL_V6))
+		assert.NoError(t, err)
+		assert.Len(t, entries, 3)
+		assert.Equal(t, "::ffff:0.0.0.0/96", entries[0].Prefix.String())
+		assert.False(t, entries[1].Source)
+		assert.False(t, entries[2].GloballyReachable)
+	})
+	t.Run("missing columns", func(t *testing.T) {
+		t.Parallel()
+		_, err := addr.ParseSpecialRegistry(strings.NewReader("Prefix,Description\n10.0.0.0/8,Private\n"))
+		assert.ErrorIs(t, err, addr.ErrSpecialRegistryHeader)
+	})
+	t.Run("invalid prefix", func(t *testing.T) {
+		t.Parallel()
+		_, err := addr.ParseSpecialRegistry(strings.NewReader("Address Block,Name\nnot-a-prefix,Broken\n"))
+		assert.Error(t, err)
+	})
+	t.Run("empty", func(t *testing.T) {
+		t.Parallel()
+		_, err := addr.ParseSpecialRegistry(strings.NewReader(""))
+		assert.Error(t, err)
+	})
+}
+
+func TestSpecialTable_Lookup(t *testing.T) {
+	v4, _ := addr.ParseSpecialRegistry(strings.NewReader(SPECIAL_V4))
+	v6, _ := addr.ParseSpecialRegistry(strings.NewReader(SPECIAL_V6))
+	table := addr.NewSpecialTable(v4, v6)
+	t.Run("longest match", func(t *testing.T) {
+		t.Parallel()
+		e := table.Lookup(netip.MustParseAddr("2001::1"))
+		assert.NotNil(t, e)
+		assert.Equal(t, "TEREDO", e.Name)
+		assert.Equal(t, addr.TXT_TEREDO, e.Label)
+		e = table.Lookup(netip.MustParseAddr("2001:1::1"))
+		assert.NotNil(t, e)
+		assert.Equal(t, "IETF Protocol Assignments", e.Name)
+	})
+	t.Run("ipv4 is not matched by ipv4-mapped prefix", func(t *testing.T) {
+		t.Parallel()
+		assert.Nil(t, table.Lookup(netip.MustParseAddr("1.1.1.1")))
+		e := table.Lookup(netip.MustParseAddr("192.0.0.170"))
+		assert.NotNil(t, e)
+		assert.Equal(t, "NAT64/DNS64 Discovery", e.Name)
+	})
+	t.Run("no match", func(t *testing.T) {
+		t.Parallel()
+		assert.Nil(t, table.Lookup(netip.MustParseAddr("2606:4700:4700::1111")))
+	})
+}
+
+func Test_LoadSpecialTable(t *testing.T) {
+	t.Run("embedded", func(t *testing.T) {
+		t.Parallel()
+		table, err := addr.LoadSpecialTable("", "")
+		assert.NoError(t, err)
+		assert.Greater(t, table.Len(), len(addr.SUPPLEMENTAL_PREFIXES))
+	})
+	t.Run("from file", func(t *testing.T) {
+		t.Parallel()
+		f := filepath.Join(t.TempDir(), addr.SPECIAL_V4_FILE)
+		err := os.WriteFile(f, []byte(SPECIAL_V4), 0o644)
+		assert.NoError(t, err)
+		table, err := addr.LoadSpecialTable(f, "")
+		assert.NoError(t, err)
+		assert.Nil(t, table.Lookup(netip.MustParseAddr("10.0.0.1")))
+		assert.NotNil(t, table.Lookup(netip.MustParseAddr("fe80::1")))
+	})
+	t.Run("missing file", func(t *testing.T) {
+		t.Parallel()
+		_, err := addr.LoadSpecialTable("", filepath.Join(t.TempDir(), "nope.csv"))
+		assert.Error(t, err)
+	})
+}
+
+func Test_DownloadSpecialRegistry(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v4.csv":
+			w.Write([]byte(SPECIAL_V4))
+		case "/bad.csv":
+			w.Write([]byte("not,a,registry\n"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	t.Run("basic", func(t *testing.T) {
+		t.Parallel()
+		dest := filepath.Join(t.TempDir(), "nested", addr.SPECIAL_V4_FILE)
+		n, err := addr.DownloadSpecialRegistry(srv.URL+"/v4.csv", dest)
+		assert.NoError(t, err)
+		assert.Equal(t, 6, n)
+		data, err := os.ReadFile(dest)
+		assert.NoError(t, err)
+		assert.Equal(t, SPECIAL_V4, string(data))
+	})
+	t.Run("not found", func(t *testing.T) {
+		t.Parallel()
+		_, err := addr.DownloadSpecialRegistry(srv.URL+"/missing.csv", filepath.Join(t.TempDir(), "x.csv"))
+		assert.Error(t, err)
+	})
+	t.Run("invalid registry is not written", func(t *testing.T) {
+		t.Parallel()
+		dest := filepath.Join(t.TempDir(), "x.csv")
+		_, err := addr.DownloadSpecialRegistry(srv.URL+"/bad.csv", dest)
+		assert.Error(t, err)
+		assert.NoFileExists(t, dest)
+	})
+}
@@ -0,0 +1,144 @@
+package addr
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/biter777/countries"
+)
+
+const (
+	SUMMARY_ASN      string = "asn"
+	SUMMARY_COUNTRY  string = "country"
+	SUMMARY_REGISTRY string = "registry"
+	SUMMARY_PREFIX   string = "prefix"
+)
+
+var SUMMARY_KEYS = []string{SUMMARY_ASN, SUMMARY_COUNTRY, SUMMARY_REGISTRY, SUMMARY_PREFIX}
+
+// SummaryGroup is the set of results sharing a value of the summary key.
+type SummaryGroup struct {
+	// Key is the shared value, e.g. '13335' or 'US', or empty if unknown.
+	Key string
+	// Name describes Key, e.g. the AS or country name.
+	Name  string
+	Count int
+	// Percent is Count as a percentage of the summary's successful results.
+	Percent float64
+	// Targets are the queries in the group, in the order they were looked up.
+	Targets []string
+}
+
+// Summary counts lookup results grouped by ASN, country, registry or prefix.
+type Summary struct {
+	By string
+	// Total is the number of results summarized, including failed lookups.
+	Total  int
+	Failed int
+	// Groups are ordered by count, largest first.
+	Groups []*SummaryGroup
+	// Other counts the results in the OtherGroups groups beyond the top N.
+	Other       int
+	OtherGroups int
+}
+
+func ValidateSummaryKey(by string) error {
+	for _, k := range SUMMARY_KEYS {
+		if by == k {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown summary key '%s', must be one of %s", by, strings.Join(SUMMARY_KEYS, ", "))
+}
+
+// Summarize groups results by one of SUMMARY_KEYS. If top is greater than zero, only the top
+// groups are kept and the rest are counted in Other. Failed lookups are counted but not grouped.
+func Summarize(by string, results []*Result, top int) (*Summary, error) {
+	err := ValidateSummaryKey(by)
+	if err != nil {
+		return nil, err
+	}
+	s := &Summary{By: by, Total: len(results), Groups: []*SummaryGroup{}}
+	groups := map[string]*SummaryGroup{}
+	for _, r := range results {
+		if r.Error != "" {
+			s.Failed++
+			continue
+		}
+		key := r.Column(by)
+		g, ok := groups[key]
+		if !ok {
+			g = &SummaryGroup{Key: key, Name: summaryName(by, r), Targets: []string{}}
+			groups[key] = g
+			s.Groups = append(s.Groups, g)
+		}
+		g.Count++
+		g.Targets = append(g.Targets, r.Query)
+	}
+	succeeded := s.Total - s.Failed
+	for _, g := range s.Groups {
+		g.Percent = float64(g.Count) / float64(succeeded) * 100
+	}
+	sort.SliceStable(s.Groups, func(i, j int) bool {
+		if s.Groups[i].Count != s.Groups[j].Count {
+			return s.Groups[i].Count > s.Groups[j].Count
+		}
+		return s.Groups[i].Key < s.Groups[j].Key
+	})
+	if top > 0 && len(s.Groups) > top {
+		for _, g := range s.Groups[top:] {
+			s.Other += g.Count
+		}
+		s.OtherGroups = len(s.Groups) - top
+		s.Groups = s.Groups[:top]
+	}
+	return s, nil
+}
+
+func summaryName(by string, r *Result) string {
+	switch by {
+	case SUMMARY_ASN, SUMMARY_PREFIX:
+		return r.Name
+	case SUMMARY_COUNTRY:
+		if r.Country == countries.Unknown {
+			return ""
+		}
+		return r.Country.String()
+	default:
+		return ""
+	}
+}
+
+type summaryGroupJSON struct {
+	Key     string   `json:"key"`
+	Name    string   `json:"name,omitempty"`
+	Count   int      `json:"count"`
+	Percent float64  `json:"percent"`
+	Targets []string `json:"targets,omitempty"`
+}
+
+// MarshalJSON encodes a summary with percentages rounded to two decimal places. Group targets are
+// left out if empty.
+func (s *Summary) MarshalJSON() ([]byte, error) {
+	groups := make([]summaryGroupJSON, 0, len(s.Groups))
+	for _, g := range s.Groups {
+		groups = append(groups, summaryGroupJSON{
+			Key:     g.Key,
+			Name:    g.Name,
+			Count:   g.Count,
+			Percent: math.Round(g.Percent*100) / 100,
+			Targets: g.Targets,
+		})
+	}
+	return json.Marshal(struct {
+		By          string             `json:"by"`
+		Total       int                `json:"total"`
+		Failed      int                `json:"failed"`
+		Groups      []summaryGroupJSON `json:"groups"`
+		Other       int                `json:"other"`
+		OtherGroups int                `json:"other_groups"`
+	}{s.By, s.Total, s.Failed, groups, s.Other, s.OtherGroups})
+}
@@ -0,0 +1,92 @@
+package addr_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/biter777/countries"
+	"github.com/stretchr/testify/assert"
+	addr "github.com/thatmattlove/addr/pkg"
+)
+
+func summaryResults() []*addr.Result {
+	gb := snapshotResult("198.51.100.1", "64501", "198.51.100.0/24", "Example GB")
+	gb.Country = countries.GBR
+	gb.Registry = "RIPE"
+	return []*addr.Result{
+		snapshotResult("192.0.2.1", "64500", "192.0.2.0/24", "Example"),
+		gb,
+		snapshotResult("192.0.2.2", "64500", "192.0.2.0/24", "Example"),
+		snapshotResult("203.0.113.1", "64502", "203.0.113.0/24", "Example Too"),
+		addr.NewErrorResult("192.0.2.99", addr.RESULT_IP, errors.New("failed")),
+	}
+}
+
+func Test_Summarize(t *testing.T) {
+	t.Run("asn", func(t *testing.T) {
+		t.Parallel()
+		s, err := addr.Summarize(addr.SUMMARY_ASN, summaryResults(), 0)
+		assert.NoError(t, err)
+		assert.Equal(t, 5, s.Total)
+		assert.Equal(t, 1, s.Failed)
+		if assert.Len(t, s.Groups, 3) {
+			assert.Equal(t, "64500", s.Groups[0].Key)
+			assert.Equal(t, "Example", s.Groups[0].Name)
+			assert.Equal(t, 2, s.Groups[0].Count)
+			assert.Equal(t, float64(50), s.Groups[0].Percent)
+			assert.Equal(t, []string{"192.0.2.1", "192.0.2.2"}, s.Groups[0].Targets)
+			assert.Equal(t, "64501", s.Groups[1].Key, "ties are ordered by key")
+		}
+	})
+	t.Run("country", func(t *testing.T) {
+		t.Parallel()
+		s, err := addr.Summarize(addr.SUMMARY_COUNTRY, summaryResults(), 0)
+		assert.NoError(t, err)
+		if assert.Len(t, s.Groups, 2) {
+			assert.Equal(t, "US", s.Groups[0].Key)
+			assert.Equal(t, "United States", s.Groups[0].Name)
+			assert.Equal(t, 3, s.Groups[0].Count)
+			assert.Equal(t, "GB", s.Groups[1].Key)
+		}
+	})
+	t.Run("top", func(t *testing.T) {
+		t.Parallel()
+		s, err := addr.Summarize(addr.SUMMARY_PREFIX, summaryResults(), 1)
+		assert.NoError(t, err)
+		assert.Len(t, s.Groups, 1)
+		assert.Equal(t, 2, s.Other)
+		assert.Equal(t, 2, s.OtherGroups)
+	})
+	t.Run("invalid key", func(t *testing.T) {
+		t.Parallel()
+		_, err := addr.Summarize("city", summaryResults(), 0)
+		assert.ErrorContains(t, err, "unknown summary key 'city'")
+	})
+	t.Run("all failed", func(t *testing.T) {
+		t.Parallel()
+		s, err := addr.Summarize(addr.SUMMARY_REGISTRY, summaryResults()[4:], 0)
+		assert.NoError(t, err)
+		assert.Empty(t, s.Groups)
+		assert.Equal(t, 1, s.Failed)
+	})
+}
+
+func TestSummary_MarshalJSON(t *testing.T) {
+	s, err := addr.Summarize(addr.SUMMARY_REGISTRY, summaryResults()[:3], 0)
+	assert.NoError(t, err)
+	s.Groups[1].Targets = nil
+	b, err := json.Marshal(s)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"by": "registry",
+		"total": 3,
+		"failed": 0,
+		"groups": [
+			{"key": "ARIN", "count": 2, "percent": 66.67, "targets": ["192.0.2.1", "192.0.2.2"]},
+			{"key": "RIPE", "count": 1, "percent": 33.33}
+		],
+		"other": 0,
+		"other_groups": 0
+	}`, string(b))
+}
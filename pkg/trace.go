@@ -0,0 +1,353 @@
+package addr
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	traceNoReplyHost   string = "???"
+	traceNoReplyMarker string = "*"
+)
+
+var (
+	traceHopPattern = regexp.MustCompile(`^\s*(\d+)\s+(.*)$`)
+	mtrHopPattern   = regexp.MustCompile(`^\s*(\d+)\.\s*(?:\|--|AS\S+)\s+(.*)$`)
+	mtrMultipath    = regexp.MustCompile("^\\s*\\|\\s+`--\\s+(\\S+)")
+	mtrStatsPattern = regexp.MustCompile(`^(\S+)(?:\s+\((\S+)\))?\s+([\d.]+)%?\s+(\d+)\s+[\d.]+\s+([\d.]+)`)
+)
+
+var ErrEmptyTrace = errors.New("no hops found, expected traceroute, traceroute6, 'mtr --report' or 'mtr --json' output")
+
+// TraceHop is a reply to a traceroute or mtr probe. Hops with replies from more than one address
+// have a TraceHop for each address.
+type TraceHop struct {
+	Hop int
+	// Host is the hostname reported by traceroute or mtr, if any.
+	Host string
+	// IP is invalid if the hop didn't reply.
+	IP netip.Addr
+	// RTT is the average round trip time of the hop's replies.
+	RTT time.Duration
+	// Loss & Sent are only set for mtr hops. Loss is a percentage.
+	Loss float64
+	Sent int
+}
+
+// ParseTrace parses the output of traceroute, traceroute6, 'mtr --report' or 'mtr --json'.
+func ParseTrace(r io.Reader) ([]*TraceHop, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var hops []*TraceHop
+	if t := bytes.TrimSpace(b); len(t) > 0 && t[0] == '{' {
+		hops, err = parseMTRJSON(t)
+	} else {
+		hops, err = parseTraceLines(b)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(hops) == 0 {
+		return nil, ErrEmptyTrace
+	}
+	return hops, nil
+}
+
+func parseTraceLines(b []byte) ([]*TraceHop, error) {
+	hops := []*TraceHop{}
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if m := mtrHopPattern.FindStringSubmatch(line); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			hops = append(hops, parseMTRHop(n, m[2]))
+			continue
+		}
+		if m := mtrMultipath.FindStringSubmatch(line); m != nil && len(hops) != 0 {
+			host, ip := splitTraceHost(m[1], "")
+			hops = append(hops, &TraceHop{Hop: hops[len(hops)-1].Hop, Host: host, IP: ip})
+			continue
+		}
+		if m := traceHopPattern.FindStringSubmatch(line); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			hops = append(hops, parseTracerouteHop(n, strings.Fields(m[2]))...)
+		}
+	}
+	return hops, scanner.Err()
+}
+
+// parseTracerouteHop parses the fields of a traceroute hop, e.g.
+// 'host.example (192.0.2.1)  0.420 ms  0.380 ms  192.0.2.2  0.370 ms'.
+func parseTracerouteHop(n int, fields []string) []*TraceHop {
+	hops := []*TraceHop{}
+	rtts := map[*TraceHop][]float64{}
+	var current *TraceHop
+	for i := 0; i < len(fields); i++ {
+		f := fields[i]
+		if f == traceNoReplyMarker {
+			continue
+		}
+		if i+1 < len(fields) && fields[i+1] == "ms" {
+			if ms, err := strconv.ParseFloat(f, 64); err == nil && current != nil {
+				rtts[current] = append(rtts[current], ms)
+			}
+			i++
+			continue
+		}
+		paren := ""
+		if i+1 < len(fields) && strings.HasPrefix(fields[i+1], "(") {
+			paren = fields[i+1]
+			i++
+		}
+		host, ip := splitTraceHost(f, paren)
+		if !ip.IsValid() {
+			// Annotations such as '!H' or '!X'.
+			continue
+		}
+		current = nil
+		for _, h := range hops {
+			if h.IP == ip {
+				current = h
+			}
+		}
+		if current == nil {
+			current = &TraceHop{Hop: n, Host: host, IP: ip}
+			hops = append(hops, current)
+		}
+	}
+	for h, r := range rtts {
+		h.RTT = averageRTT(r)
+	}
+	if len(hops) == 0 {
+		hops = append(hops, &TraceHop{Hop: n})
+	}
+	return hops
+}
+
+// parseMTRHop parses the rest of an 'mtr --report' hop line after the hop number, e.g.
+// 'host.example (192.0.2.1)   0.0%    10    0.4   0.4   0.3   0.5   0.0'.
+func parseMTRHop(n int, rest string) *TraceHop {
+	m := mtrStatsPattern.FindStringSubmatch(rest)
+	if m == nil {
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			return &TraceHop{Hop: n}
+		}
+		host, ip := splitTraceHost(fields[0], "")
+		return &TraceHop{Hop: n, Host: host, IP: ip}
+	}
+	paren := ""
+	if m[2] != "" {
+		paren = "(" + m[2] + ")"
+	}
+	host, ip := splitTraceHost(m[1], paren)
+	h := &TraceHop{Hop: n, Host: host, IP: ip}
+	h.Loss, _ = strconv.ParseFloat(m[3], 64)
+	h.Sent, _ = strconv.Atoi(m[4])
+	if avg, err := strconv.ParseFloat(m[5], 64); err == nil {
+		h.RTT = averageRTT([]float64{avg})
+	}
+	return h
+}
+
+type mtrJSON struct {
+	Report struct {
+		Hubs []struct {
+			Count json.RawMessage `json:"count"`
+			Host  string          `json:"host"`
+			Loss  float64         `json:"Loss%"`
+			Sent  int             `json:"Snt"`
+			Avg   float64         `json:"Avg"`
+		} `json:"hubs"`
+	} `json:"report"`
+}
+
+func parseMTRJSON(b []byte) ([]*TraceHop, error) {
+	in := mtrJSON{}
+	err := json.Unmarshal(b, &in)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mtr JSON: %w", err)
+	}
+	hops := make([]*TraceHop, 0, len(in.Report.Hubs))
+	for i, hub := range in.Report.Hubs {
+		// Older versions of mtr encode the hop count as a string.
+		n, err := strconv.Atoi(strings.Trim(string(hub.Count), `"`))
+		if err != nil {
+			n = i + 1
+		}
+		fields := strings.Fields(hub.Host)
+		host, ip := "", netip.Addr{}
+		if len(fields) > 1 {
+			host, ip = splitTraceHost(fields[0], fields[1])
+		} else if len(fields) == 1 {
+			host, ip = splitTraceHost(fields[0], "")
+		}
+		hops = append(hops, &TraceHop{
+			Hop:  n,
+			Host: host,
+			IP:   ip,
+			RTT:  averageRTT([]float64{hub.Avg}),
+			Loss: hub.Loss,
+			Sent: hub.Sent,
+		})
+	}
+	return hops, nil
+}
+
+// splitTraceHost returns the hostname & address from a host field and an optional
+// parenthesized address. Hostnames that are just the address are dropped.
+func splitTraceHost(host, paren string) (string, netip.Addr) {
+	if host == traceNoReplyHost {
+		return "", netip.Addr{}
+	}
+	if paren != "" {
+		if ip, err := netip.ParseAddr(strings.Trim(paren, "()")); err == nil {
+			ip = ip.WithZone("")
+			if host == ip.String() {
+				host = ""
+			}
+			return host, ip
+		}
+	}
+	if ip, err := netip.ParseAddr(host); err == nil {
+		return "", ip.WithZone("")
+	}
+	if !strings.Contains(host, ".") {
+		return "", netip.Addr{}
+	}
+	return host, netip.Addr{}
+}
+
+func averageRTT(ms []float64) time.Duration {
+	if len(ms) == 0 {
+		return 0
+	}
+	sum := float64(0)
+	for _, m := range ms {
+		sum += m
+	}
+	return time.Duration(sum / float64(len(ms)) * float64(time.Millisecond))
+}
+
+// AnnotatedHop is a trace hop with the lookup result for its address.
+type AnnotatedHop struct {
+	*TraceHop
+	// Result is nil if the hop didn't reply.
+	Result *Result
+	// Boundary is true if the hop's origin ASN differs from that of the last hop with a known ASN.
+	// The first hop with a known ASN isn't a boundary.
+	Boundary bool
+}
+
+type AnnotateOptions struct {
+	// QueryIP, ReverseLookup & ForwardLookup default to QueryIPPrefix, DNSReverseLookup &
+	// DNSForwardLookup.
+	QueryIP       func(string) (*Response, error)
+	ReverseLookup func(*net.IP) ([]string, error)
+	// ForwardLookup resolves hops reported by hostname only, e.g. by mtr without '-n' or '-b'.
+	ForwardLookup func(string) ([]net.IP, []net.IP, error)
+}
+
+// AnnotateTrace looks up the origin of each hop. Non-global hops, such as private addresses, are
+// never looked up, and each address is only looked up once. Hops that fail to look up have an
+// error result.
+func AnnotateTrace(hops []*TraceHop, opts AnnotateOptions) []*AnnotatedHop {
+	if opts.QueryIP == nil {
+		opts.QueryIP = QueryIPPrefix
+	}
+	if opts.ReverseLookup == nil {
+		opts.ReverseLookup = DNSReverseLookup
+	}
+	if opts.ForwardLookup == nil {
+		opts.ForwardLookup = DNSForwardLookup
+	}
+	results := map[netip.Addr]*Result{}
+	annotated := make([]*AnnotatedHop, 0, len(hops))
+	var lastASN uint32
+	for _, h := range hops {
+		if !h.IP.IsValid() && h.Host != "" {
+			if a, aaaa, err := opts.ForwardLookup(h.Host); err == nil && len(append(a, aaaa...)) != 0 {
+				h.IP = AddrFromIP(append(a, aaaa...)[0])
+			}
+		}
+		ah := &AnnotatedHop{TraceHop: h}
+		annotated = append(annotated, ah)
+		if !h.IP.IsValid() {
+			continue
+		}
+		r, ok := results[h.IP]
+		if !ok {
+			r = annotateAddr(h, opts)
+			results[h.IP] = r
+		}
+		ah.Result = r
+		if r.ASN != nil && r.ASN.Uint32() != 0 {
+			ah.Boundary = lastASN != 0 && r.ASN.Uint32() != lastASN
+			lastASN = r.ASN.Uint32()
+		}
+	}
+	return annotated
+}
+
+func annotateAddr(h *TraceHop, opts AnnotateOptions) *Result {
+	query := h.IP.String()
+	ptrs := []string{}
+	if h.Host != "" {
+		ptrs = append(ptrs, h.Host)
+	}
+	if pfx, _ := GetNonGlobalAddrPrefix(h.IP); pfx.IsValid() {
+		// Validate answers from local definitions & the special-purpose tables without a query.
+		v, err := NewIPValidator(query)
+		if err == nil {
+			if _, r := v.Validate(); r != nil {
+				return NewIPResult(query, r, ptrs)
+			}
+		}
+	}
+	r, err := opts.QueryIP(query)
+	if err != nil {
+		return NewErrorResult(query, RESULT_IP, err)
+	}
+	if len(ptrs) == 0 && r.IP != nil {
+		ptrs, _ = opts.ReverseLookup(r.IP)
+	}
+	return NewIPResult(query, r, ptrs)
+}
+
+// MarshalJSON encodes a hop with its result under 'result', and RTT in milliseconds.
+func (h *AnnotatedHop) MarshalJSON() ([]byte, error) {
+	out := struct {
+		Hop      int     `json:"hop"`
+		Host     string  `json:"host,omitempty"`
+		IP       string  `json:"ip,omitempty"`
+		RTT      float64 `json:"rtt_ms"`
+		Loss     float64 `json:"loss,omitempty"`
+		Sent     int     `json:"sent,omitempty"`
+		Boundary bool    `json:"boundary"`
+		Result   *Result `json:"result,omitempty"`
+	}{
+		Hop:      h.Hop,
+		Host:     h.Host,
+		RTT:      float64(h.RTT) / float64(time.Millisecond),
+		Loss:     h.Loss,
+		Sent:     h.Sent,
+		Boundary: h.Boundary,
+		Result:   h.Result,
+	}
+	if h.IP.IsValid() {
+		out.IP = h.IP.String()
+	}
+	return json.Marshal(out)
+}
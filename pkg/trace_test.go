@@ -0,0 +1,190 @@
+package addr_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	addr "github.com/thatmattlove/addr/pkg"
+	goasn "github.com/thatmattlove/go-asn"
+)
+
+const (
+	TRACEROUTE string = `traceroute to one.one.one.one (1.1.1.1), 30 hops max, 60 byte packets
+ 1  _gateway (192.168.1.1)  0.420 ms  0.380 ms  0.370 ms
+ 2  * * *
+ 3  ae1.example.net (203.0.113.1)  5.000 ms  6.000 ms ae2.example.net (203.0.113.5)  7.000 ms
+ 4  one.one.one.one (1.1.1.1)  10.000 ms !H  11.000 ms  12.000 ms`
+
+	TRACEROUTE6 string = `traceroute6 to 2606:4700:4700::1111 (2606:4700:4700::1111), 64 hops max, 12 byte packets
+ 1  fe80::1%en0  1.201 ms  0.899 ms  0.870 ms
+ 2  2001:db8::1  5.105 ms  4.899 ms  4.811 ms
+ 3  2606:4700:4700::1111  10.000 ms  10.000 ms  10.000 ms`
+
+	MTR_REPORT string = `Start: 2026-10-19T10:00:00+0000
+HOST: example                     Loss%   Snt   Last   Avg  Best  Wrst StDev
+  1.|-- 192.168.1.1                0.0%    10    0.4   0.5   0.3   0.6   0.1
+  2.|-- ???                       100.0    10    0.0   0.0   0.0   0.0   0.0
+  3.|-- ae1.example.net (203.0.113.1)  10.0%    10    5.1   5.2   5.0   5.5   0.1
+    |  ` + "`" + `-- 203.0.113.5
+  4. AS13335  1.1.1.1              0.0%    10   10.1  10.0   9.9  10.2   0.1`
+
+	MTR_JSON string = `{"report": {"mtr": {"src": "example", "dst": "1.1.1.1"}, "hubs": [
+		{"count": 1, "host": "192.168.1.1", "Loss%": 0.0, "Snt": 10, "Last": 0.4, "Avg": 0.5},
+		{"count": "2", "host": "???", "Loss%": 100.0, "Snt": 10, "Last": 0.0, "Avg": 0.0},
+		{"count": 3, "host": "one.one.one.one (1.1.1.1)", "Loss%": 0.0, "Snt": 10, "Last": 10.1, "Avg": 10.0}
+	]}}`
+)
+
+type traceHop struct {
+	hop  int
+	host string
+	ip   string
+	rtt  time.Duration
+}
+
+func assertHops(t *testing.T, expected []traceHop, hops []*addr.TraceHop) {
+	if !assert.Len(t, hops, len(expected)) {
+		return
+	}
+	for i, e := range expected {
+		assert.Equal(t, e.hop, hops[i].Hop, i)
+		assert.Equal(t, e.host, hops[i].Host, i)
+		ip := ""
+		if hops[i].IP.IsValid() {
+			ip = hops[i].IP.String()
+		}
+		assert.Equal(t, e.ip, ip, i)
+		assert.Equal(t, e.rtt, hops[i].RTT.Round(time.Microsecond), i)
+	}
+}
+
+func Test_ParseTrace(t *testing.T) {
+	t.Run("traceroute", func(t *testing.T) {
+		t.Parallel()
+		hops, err := addr.ParseTrace(strings.NewReader(TRACEROUTE))
+		assert.NoError(t, err)
+		assertHops(t, []traceHop{
+			{1, "_gateway", "192.168.1.1", time.Microsecond * 390},
+			{2, "", "", 0},
+			{3, "ae1.example.net", "203.0.113.1", time.Microsecond * 5500},
+			{3, "ae2.example.net", "203.0.113.5", time.Millisecond * 7},
+			{4, "one.one.one.one", "1.1.1.1", time.Millisecond * 11},
+		}, hops)
+	})
+	t.Run("traceroute6", func(t *testing.T) {
+		t.Parallel()
+		hops, err := addr.ParseTrace(strings.NewReader(TRACEROUTE6))
+		assert.NoError(t, err)
+		assertHops(t, []traceHop{
+			{1, "", "fe80::1", time.Microsecond * 990},
+			{2, "", "2001:db8::1", time.Microsecond * 4938},
+			{3, "", "2606:4700:4700::1111", time.Millisecond * 10},
+		}, hops)
+	})
+	t.Run("mtr report", func(t *testing.T) {
+		t.Parallel()
+		hops, err := addr.ParseTrace(strings.NewReader(MTR_REPORT))
+		assert.NoError(t, err)
+		assertHops(t, []traceHop{
+			{1, "", "192.168.1.1", time.Microsecond * 500},
+			{2, "", "", 0},
+			{3, "ae1.example.net", "203.0.113.1", time.Microsecond * 5200},
+			{3, "", "203.0.113.5", 0},
+			{4, "", "1.1.1.1", time.Millisecond * 10},
+		}, hops)
+		assert.Equal(t, float64(10), hops[2].Loss)
+		assert.Equal(t, 10, hops[2].Sent)
+	})
+	t.Run("mtr json", func(t *testing.T) {
+		t.Parallel()
+		hops, err := addr.ParseTrace(strings.NewReader(MTR_JSON))
+		assert.NoError(t, err)
+		assertHops(t, []traceHop{
+			{1, "", "192.168.1.1", time.Microsecond * 500},
+			{2, "", "", 0},
+			{3, "one.one.one.one", "1.1.1.1", time.Millisecond * 10},
+		}, hops)
+		assert.Equal(t, float64(100), hops[1].Loss)
+	})
+	t.Run("mtr no host", func(t *testing.T) {
+		t.Parallel()
+		hops, err := addr.ParseTrace(strings.NewReader("  1. AS???  \n  2.|-- \n"))
+		assert.NoError(t, err)
+		assertHops(t, []traceHop{
+			{1, "", "", 0},
+			{2, "", "", 0},
+		}, hops)
+	})
+	t.Run("empty", func(t *testing.T) {
+		t.Parallel()
+		_, err := addr.ParseTrace(strings.NewReader("not a trace\n"))
+		assert.ErrorIs(t, err, addr.ErrEmptyTrace)
+	})
+	t.Run("invalid json", func(t *testing.T) {
+		t.Parallel()
+		_, err := addr.ParseTrace(strings.NewReader(`{"report": [}`))
+		assert.ErrorContains(t, err, "invalid mtr JSON")
+	})
+}
+
+func Test_AnnotateTrace(t *testing.T) {
+	origins := map[string]string{"1.1.1.1": "64500", "1.0.0.1": "64500", "9.9.9.9": "64501"}
+	queried := map[string]int{}
+	opts := addr.AnnotateOptions{
+		QueryIP: func(q string) (*addr.Response, error) {
+			queried[q]++
+			asn, ok := origins[q]
+			if !ok {
+				return nil, errors.New("upstream failure")
+			}
+			ip := net.ParseIP(q)
+			return &addr.Response{ASN: goasn.MustParse(asn), IP: &ip, Name: "AS" + asn, FromQuery: true}, nil
+		},
+		ReverseLookup: func(ip *net.IP) ([]string, error) {
+			return []string{"rdns.example."}, nil
+		},
+		ForwardLookup: func(host string) ([]net.IP, []net.IP, error) {
+			return []net.IP{net.ParseIP("9.9.9.9")}, nil, nil
+		},
+	}
+	hops := []*addr.TraceHop{
+		{Hop: 1, IP: netip.MustParseAddr("192.168.1.1")},
+		{Hop: 2},
+		{Hop: 3, Host: "ae1.example.net", IP: netip.MustParseAddr("1.1.1.1")},
+		{Hop: 4, IP: netip.MustParseAddr("1.0.0.1")},
+		{Hop: 5, IP: netip.MustParseAddr("1.1.1.1")},
+		{Hop: 6, Host: "edge.example.org"},
+		{Hop: 7, IP: netip.MustParseAddr("192.0.2.200")},
+	}
+	annotated := addr.AnnotateTrace(hops, opts)
+	assert.Len(t, annotated, 7)
+
+	assert.Equal(t, addr.TXT_PRIVATE, annotated[0].Result.Name)
+	assert.False(t, annotated[0].Boundary)
+	assert.Zero(t, queried["192.168.1.1"], "private hops aren't looked up")
+
+	assert.Nil(t, annotated[1].Result)
+
+	assert.Equal(t, "64500", annotated[2].Result.Column("asn"))
+	assert.Equal(t, []string{"ae1.example.net"}, annotated[2].Result.PTRs, "the traced hostname is used as the PTR")
+	assert.False(t, annotated[2].Boundary, "the first known ASN isn't a boundary")
+	assert.False(t, annotated[3].Boundary)
+	assert.Equal(t, []string{"rdns.example."}, annotated[3].Result.PTRs)
+	assert.Equal(t, 1, queried["1.1.1.1"], "addresses are only looked up once")
+
+	assert.Equal(t, "9.9.9.9", annotated[5].IP.String(), "hostnames are resolved")
+	assert.True(t, annotated[5].Boundary)
+
+	assert.Equal(t, addr.TXT_DOC, annotated[6].Result.Name)
+	assert.Zero(t, queried["192.0.2.200"])
+
+	b, err := json.Marshal(annotated[2])
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `"hop":3,"host":"ae1.example.net","ip":"1.1.1.1","rtt_ms":0,"boundary":false,"result":{`)
+}
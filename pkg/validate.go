@@ -2,6 +2,7 @@ package addr
 
 import (
 	"net"
+	"net/netip"
 
 	"github.com/biter777/countries"
 	goasn "github.com/thatmattlove/go-asn"
@@ -11,6 +12,13 @@ type IPValidator struct {
 	InitialValue string
 	IP           net.IP
 	Net          *net.IPNet
+	Addr         netip.Addr
+	Network      netip.Prefix
+}
+
+type ASNValidator struct {
+	InitialValue string
+	ASN          goasn.ASN
 }
 
 func IsIPv6(ip net.IP) bool {
@@ -18,99 +26,68 @@ func IsIPv6(ip net.IP) bool {
 }
 
 func GetNonGlobalPrefix(ip net.IP) (*net.IPNet, string) {
-	if IsIPv6(ip) {
-		switch true {
-		case MULTICAST_v6.Contains(ip):
-			return MULTICAST_v6, TXT_MULTICAST
-		case LINK_LOCAL_v6.Contains(ip):
-			return LINK_LOCAL_v6, TXT_LINK_LOCAL
-		case UNIQUE_LOCAL.Contains(ip):
-			return UNIQUE_LOCAL, TXT_ULA
-		case LOOPBACK_v6.IP.Equal(ip):
-			return LOOPBACK_v6, TXT_LOOPBACK
-		case DEFAULT_v6.IP.Equal(ip):
-			return DEFAULT_v6, TXT_DEFAULT
-		case DOC_v6.Contains(ip):
-			return DOC_v6, TXT_DOC
-		case EMBEDDED.Contains(ip):
-			return EMBEDDED, TXT_EMBEDDED
-		case ORCHIDv1.Contains(ip):
-			return ORCHIDv1, TXT_ORCHIDv1
-		case ORCHIDv2.Contains(ip):
-			return ORCHIDv2, TXT_ORCHIDv2
-		case DETS.Contains(ip):
-			return DETS, TXT_DETS
-		case TEREDO.Contains(ip):
-			return TEREDO, TXT_TEREDO
-		case DISCARD.Contains(ip):
-			return DISCARD, TXT_DISCARD
-		case BENCHMARK_v6.Contains(ip):
-			return BENCHMARK_v6, TXT_BENCHMARK
-		case SIX_TO_FOUR_v6.Contains(ip):
-			return SIX_TO_FOUR_v6, TXT_6to4
-		case AS112_v6.Contains(ip):
-			return AS112_v6, TXT_AS112
-		case AS112_v6_Direct.Contains(ip):
-			return AS112_v6_Direct, TXT_AS112
-		case AMT_v6.Contains(ip):
-			return AMT_v6, TXT_AMT
-		default:
-			return nil, ""
-		}
-	}
-	switch true {
-	case LINK_LOCAL_v4.Contains(ip):
-		return LINK_LOCAL_v4, TXT_LINK_LOCAL
-	case RFC1918_10.Contains(ip):
-		return RFC1918_10, TXT_PRIVATE
-	case RFC1918_172.Contains(ip):
-		return RFC1918_172, TXT_PRIVATE
-	case RFC1918_192.Contains(ip):
-		return RFC1918_192, TXT_PRIVATE
-	case CGNAT.Contains(ip):
-		return CGNAT, TXT_CGNAT
-	case RFC6890_192.Contains(ip):
-		return RFC6890_192, TXT_PRIVATE
-	case AS112_v4.Contains(ip):
-		return AS112_v4, TXT_AS112
-	case AS112_v4_Direct.Contains(ip):
-		return AS112_v4_Direct, TXT_AS112
-	case AMT_v4.Contains(ip):
-		return AMT_v4, TXT_AMT
-	case SIX_TO_FOUR_v4.Contains(ip):
-		return SIX_TO_FOUR_v4, TXT_6to4
-	case BENCHMARK_v4.Contains(ip):
-		return BENCHMARK_v4, TXT_BENCHMARK
-	case DOC_1.Contains(ip):
-		return DOC_1, TXT_DOC
-	case DOC_2.Contains(ip):
-		return DOC_2, TXT_DOC
-	case DOC_3.Contains(ip):
-		return DOC_3, TXT_DOC
-	case RESERVED.Contains(ip):
-		return RESERVED, TXT_RESERVED
-	case THIS_NETWORK.Contains(ip):
-		return THIS_NETWORK, TXT_THIS
-	case MULTICAST_v4.Contains(ip):
-		return MULTICAST_v4, TXT_MULTICAST
-	case LOOPBACK_v4.Contains(ip):
-		return LOOPBACK_v4, TXT_LOOPBACK
-	case DEFAULT_v4.IP.Equal(ip):
-		return DEFAULT_v4, TXT_DEFAULT
-	default:
+	pfx, txt := GetNonGlobalAddrPrefix(AddrFromIP(ip))
+	if !pfx.IsValid() {
 		return nil, ""
 	}
+	return IPNetFromPrefix(pfx), txt
+}
+
+func GetNonGlobalAddrPrefix(a netip.Addr) (netip.Prefix, string) {
+	e := SPECIAL_TABLE.Lookup(a)
+	if e == nil {
+		return netip.Prefix{}, ""
+	}
+	return e.Prefix, e.Label
+}
+
+// IsMapped returns true if the input was an IPv4-mapped IPv6 address, e.g. ::ffff:192.0.2.1.
+func (ipv *IPValidator) IsMapped() bool {
+	return ipv.Addr.Is4In6()
+}
+
+// Embedded returns the IPv4 address embedded in the input, if it's an IPv6 address with one.
+// nat64 are network-specific NAT64 prefixes, as in ExtractEmbeddedIPv4.
+func (ipv *IPValidator) Embedded(nat64 ...netip.Prefix) *Embedded {
+	return ExtractEmbeddedIPv4(ipv.Addr, nat64...)
+}
+
+// Local returns the most specific local definition covering the input, if any.
+func (ipv *IPValidator) Local() *LocalDefinition {
+	if ipv.Network.IsValid() {
+		return LOCAL_DEFINITIONS.LookupPrefix(ipv.Network)
+	}
+	return LOCAL_DEFINITIONS.LookupAddr(ipv.Addr)
 }
 
 func (ipv *IPValidator) Validate() (bool, *Response) {
-	pfx, txt := GetNonGlobalPrefix(ipv.IP)
-	if pfx == nil {
+	if local := ipv.Local(); local != nil {
+		asn := local.ASN
+		if asn == nil {
+			asn = goasn.ASN{0, 0, 0, 0}
+		}
+		response := &Response{
+			ASN:      asn,
+			IP:       &ipv.IP,
+			Prefix:   IPNetFromPrefix(local.Prefix),
+			Addr:     ipv.Addr,
+			Network:  local.Prefix,
+			Name:     local.Name,
+			Registry: REGISTRY_LOCAL,
+			Local:    local,
+		}
+		return false, response
+	}
+	pfx, txt := GetNonGlobalAddrPrefix(ipv.Addr)
+	if !pfx.IsValid() {
 		return true, nil
 	}
 	response := &Response{
 		ASN:       goasn.ASN{0, 0, 0, 0},
 		IP:        &ipv.IP,
-		Prefix:    pfx,
+		Prefix:    IPNetFromPrefix(pfx),
+		Addr:      ipv.Addr,
+		Network:   pfx,
 		Name:      txt,
 		Country:   countries.USA,
 		Allocated: DEFAULT_ALLOCATED_DATE,
@@ -121,17 +98,63 @@ func (ipv *IPValidator) Validate() (bool, *Response) {
 
 func NewIPValidator(in string) (ipv *IPValidator, err error) {
 	var prefix *net.IPNet
+	var network netip.Prefix
 	ip := net.ParseIP(in)
-	if ip == nil {
+	addr, err := netip.ParseAddr(in)
+	if ip == nil || err != nil {
 		ip, prefix, err = net.ParseCIDR(in)
 		if err != nil {
 			return nil, err
 		}
+		network, err = netip.ParsePrefix(in)
+		if err != nil {
+			return nil, err
+		}
+		addr = network.Addr()
+		network = network.Masked()
 	}
 	ipv = &IPValidator{
 		InitialValue: in,
 		IP:           ip,
 		Net:          prefix,
+		Addr:         addr,
+		Network:      network,
 	}
 	return ipv, nil
 }
+
+func (asv *ASNValidator) Validate() (bool, *Response) {
+	if local := LOCAL_DEFINITIONS.LookupASN(asv.ASN); local != nil {
+		response := &Response{
+			ASN:      asv.ASN,
+			Name:     local.Name,
+			Registry: REGISTRY_LOCAL,
+			Local:    local,
+		}
+		return false, response
+	}
+	e := SPECIAL_ASN_TABLE.Lookup(asv.ASN)
+	if e == nil || e.Global {
+		return true, nil
+	}
+	response := &Response{
+		ASN:       asv.ASN,
+		Name:      e.Name,
+		Country:   countries.USA,
+		Allocated: DEFAULT_ALLOCATED_DATE,
+		Registry:  REGISTRY_IANA,
+	}
+	return false, response
+}
+
+func NewASNValidator(in string) (*ASNValidator, error) {
+	asn, err := ParseASN(in)
+	if err != nil {
+		return nil, err
+	}
+	asv := &ASNValidator{
+		InitialValue: in,
+		ASN:          asn,
+	}
+	return asv, nil
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"net/netip"
 	"testing"
 
 	"github.com/biter777/countries"
@@ -27,6 +28,13 @@ func TestIPValidator_NewIPValidator(t *testing.T) {
 		assert.NoError(t, err)
 		assert.NotNil(t, validator)
 	})
+	t.Run("netip fields", func(t *testing.T) {
+		t.Parallel()
+		validator, err := addr.NewIPValidator("192.0.2.1/24")
+		assert.NoError(t, err)
+		assert.Equal(t, netip.MustParseAddr("192.0.2.1"), validator.Addr)
+		assert.Equal(t, netip.MustParsePrefix("192.0.2.0/24"), validator.Network)
+	})
 	t.Run("error from invalid input", func(t *testing.T) {
 		t.Parallel()
 		ipStr := "this is not an IP"
@@ -114,6 +122,41 @@ func Test_GetNonGlobalPrefix(t *testing.T) {
 	}
 }
 
+func Test_GetNonGlobalAddrPrefix(t *testing.T) {
+	t.Run("non-global", func(t *testing.T) {
+		t.Parallel()
+		pfx, txt := addr.GetNonGlobalAddrPrefix(netip.MustParseAddr("10.1.2.3"))
+		assert.Equal(t, addr.PFX_RFC1918_10, pfx)
+		assert.Equal(t, addr.TXT_PRIVATE, txt)
+	})
+	t.Run("labels", func(t *testing.T) {
+		t.Parallel()
+		for a, want := range map[string]string{
+			"64:ff9b::c000:201": addr.TXT_EMBEDDED,
+			"64:ff9b:1::1":      addr.TXT_LOCAL_XLAT,
+			"fc00::1":           addr.TXT_ULA,
+			"100.64.0.1":        addr.TXT_CGNAT,
+			"::":                addr.TXT_DEFAULT,
+			"3fff::1":           "Documentation",
+			"192.0.0.9":         "Port Control Protocol Anycast",
+		} {
+			_, txt := addr.GetNonGlobalAddrPrefix(netip.MustParseAddr(a))
+			assert.Equal(t, want, txt, a)
+		}
+	})
+	t.Run("ipv4-mapped", func(t *testing.T) {
+		t.Parallel()
+		pfx, _ := addr.GetNonGlobalAddrPrefix(netip.MustParseAddr("::ffff:1.1.1.1"))
+		assert.Equal(t, addr.PFX_IPv4_MAPPED, pfx)
+	})
+	t.Run("global", func(t *testing.T) {
+		t.Parallel()
+		pfx, txt := addr.GetNonGlobalAddrPrefix(netip.MustParseAddr("1.1.1.1"))
+		assert.False(t, pfx.IsValid())
+		assert.Empty(t, txt)
+	})
+}
+
 func TestIPValidator_Validate(t *testing.T) {
 	t.Run("ip4 non-global has fallback response", func(t *testing.T) {
 		t.Parallel()
@@ -162,3 +205,46 @@ func TestIPValidator_Validate(t *testing.T) {
 		assert.Nil(t, response)
 	})
 }
+
+func TestASNValidator_NewASNValidator(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		t.Parallel()
+		v, err := addr.NewASNValidator("AS64512")
+		assert.NoError(t, err)
+		assert.Equal(t, uint32(64512), v.ASN.Uint32())
+	})
+	t.Run("invalid", func(t *testing.T) {
+		t.Parallel()
+		v, err := addr.NewASNValidator("this is not an ASN")
+		assert.Error(t, err)
+		assert.Nil(t, v)
+	})
+}
+
+func TestASNValidator_Validate(t *testing.T) {
+	t.Run("private has fallback response", func(t *testing.T) {
+		t.Parallel()
+		v, _ := addr.NewASNValidator("4200000000")
+		shouldQuery, response := v.Validate()
+		assert.False(t, shouldQuery)
+		assert.IsType(t, &addr.Response{}, response)
+		assert.True(t, v.ASN.Equal(response.ASN))
+		assert.Equal(t, "Private Use ASN (RFC6996)", response.Name)
+		assert.Equal(t, addr.REGISTRY_IANA, response.Registry)
+		assert.False(t, response.FromQuery)
+	})
+	t.Run("as112 should query", func(t *testing.T) {
+		t.Parallel()
+		v, _ := addr.NewASNValidator("112")
+		shouldQuery, response := v.Validate()
+		assert.True(t, shouldQuery)
+		assert.Nil(t, response)
+	})
+	t.Run("global should query", func(t *testing.T) {
+		t.Parallel()
+		v, _ := addr.NewASNValidator("14525")
+		shouldQuery, response := v.Validate()
+		assert.True(t, shouldQuery)
+		assert.Nil(t, response)
+	})
+}
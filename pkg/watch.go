@@ -0,0 +1,197 @@
+package addr
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+
+	goasn "github.com/thatmattlove/go-asn"
+)
+
+// WEBHOOK_TIMEOUT is how long PostWebhook waits for a response.
+var WEBHOOK_TIMEOUT time.Duration = time.Second * 10
+
+// Origin is the routing state of a watched IP address or prefix.
+type Origin struct {
+	ASN    goasn.ASN
+	Prefix netip.Prefix
+	// Advertised is true if the target is covered by a prefix seen in BGP.
+	Advertised bool
+}
+
+func OriginFromResponse(r *Response) Origin {
+	return Origin{ASN: r.ASN, Prefix: r.Network, Advertised: r.FromQuery}
+}
+
+func (o Origin) asn() uint32 {
+	if o.ASN == nil {
+		return 0
+	}
+	return o.ASN.Uint32()
+}
+
+func (o Origin) Equal(other Origin) bool {
+	return o.asn() == other.asn() && o.Prefix == other.Prefix && o.Advertised == other.Advertised
+}
+
+func (o Origin) String() string {
+	if !o.Advertised && o.Prefix.IsValid() {
+		return fmt.Sprintf("%s, not advertised", o.Prefix)
+	}
+	if !o.Advertised {
+		return "not advertised"
+	}
+	return fmt.Sprintf("AS%d %s", o.asn(), o.Prefix)
+}
+
+// OriginChange is a change in a watched target's origin between two checks.
+type OriginChange struct {
+	Target string
+	Old    Origin
+	New    Origin
+	Time   time.Time
+}
+
+// Diff describes each part of the origin that changed.
+func (c *OriginChange) Diff() []string {
+	diff := []string{}
+	if c.Old.Advertised != c.New.Advertised {
+		if c.New.Advertised {
+			diff = append(diff, fmt.Sprintf("advertised by AS%d as %s", c.New.asn(), c.New.Prefix))
+		} else {
+			diff = append(diff, fmt.Sprintf("withdrawn, was advertised by AS%d as %s", c.Old.asn(), c.Old.Prefix))
+		}
+		return diff
+	}
+	if c.Old.asn() != c.New.asn() {
+		diff = append(diff, fmt.Sprintf("origin AS%d → AS%d", c.Old.asn(), c.New.asn()))
+	}
+	if c.Old.Prefix != c.New.Prefix {
+		diff = append(diff, fmt.Sprintf("prefix %s → %s", c.Old.Prefix, c.New.Prefix))
+	}
+	return diff
+}
+
+type originJSON struct {
+	ASN        uint32 `json:"asn"`
+	Prefix     string `json:"prefix"`
+	Advertised bool   `json:"advertised"`
+}
+
+func newOriginJSON(o Origin) originJSON {
+	out := originJSON{ASN: o.asn(), Advertised: o.Advertised}
+	if o.Prefix.IsValid() {
+		out.Prefix = o.Prefix.String()
+	}
+	return out
+}
+
+func (c *OriginChange) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Target string     `json:"target"`
+		Time   string     `json:"time"`
+		Old    originJSON `json:"old"`
+		New    originJSON `json:"new"`
+		Diff   []string   `json:"diff"`
+	}{
+		Target: c.Target,
+		Time:   c.Time.UTC().Format(time.RFC3339),
+		Old:    newOriginJSON(c.Old),
+		New:    newOriginJSON(c.New),
+		Diff:   c.Diff(),
+	})
+}
+
+// Watcher tracks the origins of IP addresses & prefixes between checks.
+type Watcher struct {
+	query   func(string) (*Response, error)
+	origins map[string]Origin
+}
+
+// NewWatcher creates a Watcher that looks up origins with query, or QueryIPPrefix if nil.
+func NewWatcher(query func(string) (*Response, error)) *Watcher {
+	if query == nil {
+		query = QueryIPPrefix
+	}
+	return &Watcher{query: query, origins: map[string]Origin{}}
+}
+
+// Check looks up the current origin of target. If it differs from the previous check, the change
+// is returned. The first check of a target only records its origin. Failed lookups leave the
+// previous origin in place.
+func (w *Watcher) Check(target string) (Origin, *OriginChange, error) {
+	r, err := w.query(target)
+	if err != nil {
+		return Origin{}, nil, err
+	}
+	current := OriginFromResponse(r)
+	previous, seen := w.origins[target]
+	w.origins[target] = current
+	if !seen || previous.Equal(current) {
+		return current, nil, nil
+	}
+	return current, &OriginChange{Target: target, Old: previous, New: current, Time: time.Now()}, nil
+}
+
+// Seen returns true if target's origin has been recorded by a successful check.
+func (w *Watcher) Seen(target string) bool {
+	_, ok := w.origins[target]
+	return ok
+}
+
+// PostWebhook POSTs c to url as JSON, returning an error unless the response status is 2xx.
+func PostWebhook(url string, c *OriginChange) error {
+	body, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: WEBHOOK_TIMEOUT}
+	res, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("webhook '%s' returned status %d", url, res.StatusCode)
+	}
+	return nil
+}
+
+// RunHook runs command with the system shell, passing c as JSON on stdin and in ADDR_ environment
+// variables.
+func RunHook(command string, c *OriginChange) error {
+	body, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	prev, next := newOriginJSON(c.Old), newOriginJSON(c.New)
+	cmd.Env = append(os.Environ(),
+		"ADDR_TARGET="+c.Target,
+		fmt.Sprintf("ADDR_OLD_ASN=%d", prev.ASN),
+		"ADDR_OLD_PREFIX="+prev.Prefix,
+		fmt.Sprintf("ADDR_OLD_ADVERTISED=%t", prev.Advertised),
+		fmt.Sprintf("ADDR_NEW_ASN=%d", next.ASN),
+		"ADDR_NEW_PREFIX="+next.Prefix,
+		fmt.Sprintf("ADDR_NEW_ADVERTISED=%t", next.Advertised),
+	)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err = cmd.Run()
+	if err != nil {
+		return fmt.Errorf("hook '%s' failed: %w", command, err)
+	}
+	return nil
+}
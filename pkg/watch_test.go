@@ -0,0 +1,172 @@
+package addr_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	addr "github.com/thatmattlove/addr/pkg"
+	goasn "github.com/thatmattlove/go-asn"
+)
+
+func origin(asn string, prefix string) addr.Origin {
+	return addr.Origin{ASN: goasn.MustParse(asn), Prefix: netip.MustParsePrefix(prefix), Advertised: true}
+}
+
+func Test_Watcher(t *testing.T) {
+	responses := []*addr.Response{
+		{ASN: goasn.MustParse("64500"), Network: netip.MustParsePrefix("192.0.2.0/24"), FromQuery: true},
+		{ASN: goasn.MustParse("64500"), Network: netip.MustParsePrefix("192.0.2.0/24"), FromQuery: true},
+		nil,
+		{ASN: goasn.MustParse("64666"), Network: netip.MustParsePrefix("192.0.2.0/25"), FromQuery: true},
+		{ASN: goasn.FromUint32(0), FromQuery: false},
+	}
+	i := 0
+	w := addr.NewWatcher(func(q string) (*addr.Response, error) {
+		r := responses[i]
+		i++
+		if r == nil {
+			return nil, errors.New("upstream failure")
+		}
+		return r, nil
+	})
+
+	o, change, err := w.Check("192.0.2.1")
+	assert.NoError(t, err)
+	assert.Nil(t, change, "first check only records the origin")
+	assert.Equal(t, "AS64500 192.0.2.0/24", o.String())
+
+	_, change, err = w.Check("192.0.2.1")
+	assert.NoError(t, err)
+	assert.Nil(t, change)
+
+	_, change, err = w.Check("192.0.2.1")
+	assert.Error(t, err)
+	assert.Nil(t, change)
+
+	_, change, err = w.Check("192.0.2.1")
+	assert.NoError(t, err)
+	if assert.NotNil(t, change) {
+		assert.Equal(t, "192.0.2.1", change.Target)
+		assert.Equal(t, []string{"origin AS64500 → AS64666", "prefix 192.0.2.0/24 → 192.0.2.0/25"}, change.Diff())
+	}
+
+	o, change, err = w.Check("192.0.2.1")
+	assert.NoError(t, err)
+	assert.Equal(t, "not advertised", o.String())
+	if assert.NotNil(t, change) {
+		assert.Equal(t, []string{"withdrawn, was advertised by AS64666 as 192.0.2.0/25"}, change.Diff())
+	}
+}
+
+func TestWatcher_Seen(t *testing.T) {
+	t.Parallel()
+	fail := true
+	w := addr.NewWatcher(func(q string) (*addr.Response, error) {
+		if fail {
+			return nil, errors.New("upstream failure")
+		}
+		return &addr.Response{ASN: goasn.MustParse("64500"), Network: netip.MustParsePrefix("192.0.2.0/24"), FromQuery: true}, nil
+	})
+	_, _, err := w.Check("192.0.2.1")
+	assert.Error(t, err)
+	assert.False(t, w.Seen("192.0.2.1"), "failed checks don't record an origin")
+	fail = false
+	_, change, err := w.Check("192.0.2.1")
+	assert.NoError(t, err)
+	assert.Nil(t, change)
+	assert.True(t, w.Seen("192.0.2.1"))
+}
+
+func TestOriginChange_Diff(t *testing.T) {
+	t.Run("advertised", func(t *testing.T) {
+		t.Parallel()
+		c := &addr.OriginChange{New: origin("64500", "192.0.2.0/24")}
+		assert.Equal(t, []string{"advertised by AS64500 as 192.0.2.0/24"}, c.Diff())
+	})
+	t.Run("origin only", func(t *testing.T) {
+		t.Parallel()
+		c := &addr.OriginChange{Old: origin("64500", "192.0.2.0/24"), New: origin("64501", "192.0.2.0/24")}
+		assert.Equal(t, []string{"origin AS64500 → AS64501"}, c.Diff())
+	})
+}
+
+func TestOriginChange_MarshalJSON(t *testing.T) {
+	c := &addr.OriginChange{
+		Target: "192.0.2.1",
+		Old:    origin("64500", "192.0.2.0/24"),
+		New:    origin("64666", "192.0.2.0/24"),
+		Time:   time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	b, err := json.Marshal(c)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"target": "192.0.2.1",
+		"time": "2026-01-02T03:04:05Z",
+		"old": {"asn": 64500, "prefix": "192.0.2.0/24", "advertised": true},
+		"new": {"asn": 64666, "prefix": "192.0.2.0/24", "advertised": true},
+		"diff": ["origin AS64500 → AS64666"]
+	}`, string(b))
+}
+
+func Test_PostWebhook(t *testing.T) {
+	c := &addr.OriginChange{Target: "192.0.2.1", Old: origin("64500", "192.0.2.0/24"), New: addr.Origin{}}
+	t.Run("ok", func(t *testing.T) {
+		t.Parallel()
+		received := make(chan map[string]any, 1)
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body := map[string]any{}
+			json.NewDecoder(r.Body).Decode(&body)
+			assert.Equal(t, http.MethodPost, r.Method)
+			assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+			received <- body
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		t.Cleanup(srv.Close)
+		err := addr.PostWebhook(srv.URL, c)
+		assert.NoError(t, err)
+		body := <-received
+		assert.Equal(t, "192.0.2.1", body["target"])
+		assert.Equal(t, false, body["new"].(map[string]any)["advertised"])
+	})
+	t.Run("error status", func(t *testing.T) {
+		t.Parallel()
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		t.Cleanup(srv.Close)
+		err := addr.PostWebhook(srv.URL, c)
+		assert.ErrorContains(t, err, "returned status 500")
+	})
+}
+
+func Test_RunHook(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook test uses a POSIX shell")
+	}
+	c := &addr.OriginChange{Target: "192.0.2.1", Old: origin("64500", "192.0.2.0/24"), New: origin("64666", "192.0.2.0/24")}
+	t.Run("environment", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		out := filepath.Join(dir, "out")
+		err := addr.RunHook(`echo "$ADDR_TARGET $ADDR_OLD_ASN $ADDR_NEW_ASN $ADDR_NEW_PREFIX" > '`+out+`' && cat >> '`+out+`'`, c)
+		assert.NoError(t, err)
+		b, err := os.ReadFile(out)
+		assert.NoError(t, err)
+		assert.Contains(t, string(b), "192.0.2.1 64500 64666 192.0.2.0/24\n")
+		assert.Contains(t, string(b), `"diff":["origin AS64500 → AS64666"]`)
+	})
+	t.Run("failure", func(t *testing.T) {
+		t.Parallel()
+		err := addr.RunHook("exit 3", c)
+		assert.ErrorContains(t, err, "hook 'exit 3' failed")
+	})
+}
@@ -7,10 +7,17 @@ import (
 	"net"
 	"strings"
 	"time"
+
+	"github.com/thatmattlove/addr/pkg/metrics"
 )
 
 const (
 	TCP string = "tcp"
+	// QUERY_TYPE_CONNECT is the metrics query type for connections that fail before a query
+	// can be made.
+	QUERY_TYPE_CONNECT string = "connect"
+	// QUERY_TYPE_BULK is the metrics query type for bulk queries.
+	QUERY_TYPE_BULK string = "bulk"
 )
 
 type Whois struct {
@@ -20,10 +27,16 @@ type Whois struct {
 	Connection *net.TCPConn
 }
 
-func (w *Whois) Query(q string) (string, error) {
-	err := w.Open()
+func (w *Whois) Query(q string) (res string, err error) {
+	start := time.Now()
+	qt := queryType(q)
+	defer func() {
+		metrics.HOOK.Query(metrics.PROVIDER_WHOIS, qt, err, time.Since(start), len(res))
+	}()
+	err = w.Open()
 	defer w.Close()
 	if err != nil {
+		qt = QUERY_TYPE_CONNECT
 		return "", err
 	}
 	q = strings.Trim(q, "\r\n")
@@ -42,6 +55,56 @@ func (w *Whois) Query(q string) (string, error) {
 	return string(rx), nil
 }
 
+// BulkQuery sends every query in qs over one connection, using bgp.tools' bulk mode, and returns
+// the verbose response to all of them. Since a large bulk query can take a while to answer, the
+// read deadline is extended whenever part of the response is received.
+func (w *Whois) BulkQuery(qs []string) (res string, err error) {
+	start := time.Now()
+	qt := QUERY_TYPE_BULK
+	defer func() {
+		metrics.HOOK.Query(metrics.PROVIDER_WHOIS, qt, err, time.Since(start), len(res))
+	}()
+	err = w.Open()
+	defer w.Close()
+	if err != nil {
+		qt = QUERY_TYPE_CONNECT
+		return "", err
+	}
+	b := strings.Builder{}
+	b.WriteString("begin\nverbose\n")
+	for _, q := range qs {
+		b.WriteString(strings.Trim(q, "\r\n") + "\n")
+	}
+	b.WriteString("end\n")
+	_, err = w.Connection.Write([]byte(b.String()))
+	if err != nil {
+		return "", err
+	}
+	rx := bytes.Buffer{}
+	buf := make([]byte, 32*1024)
+	for {
+		w.Connection.SetReadDeadline(time.Now().Add(time.Second * 10))
+		n, rerr := w.Connection.Read(buf)
+		rx.Write(buf[:n])
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			return "", rerr
+		}
+	}
+	return string(bytes.Trim(rx.Bytes(), "\x00")), nil
+}
+
+// queryType returns 'asn' for AS number queries and 'ip' for everything else.
+func queryType(q string) string {
+	q = strings.ToLower(strings.TrimSpace(q))
+	if strings.HasPrefix(q, "as") {
+		return "asn"
+	}
+	return "ip"
+}
+
 func (w *Whois) Open() error {
 	conn, err := net.DialTCP(TCP, nil, w.TCPAddr)
 	if err != nil {
@@ -66,7 +129,13 @@ func (w *Whois) Close() error {
 	return nil
 }
 
-func New(host string, port uint) (*Whois, error) {
+func New(host string, port uint) (w *Whois, err error) {
+	start := time.Now()
+	defer func() {
+		if err != nil {
+			metrics.HOOK.Query(metrics.PROVIDER_WHOIS, QUERY_TYPE_CONNECT, err, time.Since(start), 0)
+		}
+	}()
 	server, err := net.ResolveTCPAddr(TCP, fmt.Sprintf("%s:%d", host, port))
 	if err != nil {
 		return nil, err
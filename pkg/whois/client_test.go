@@ -1,12 +1,68 @@
 package whois_test
 
 import (
+	"bufio"
+	"net"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/thatmattlove/addr/pkg/metrics"
 	"github.com/thatmattlove/addr/pkg/whois"
 )
 
+type queryMetrics struct {
+	metrics.Noop
+	mu    sync.Mutex
+	types []string
+}
+
+func (m *queryMetrics) Query(provider, queryType string, err error, duration time.Duration, received int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.types = append(m.types, queryType)
+}
+
+// serveWhois answers each connection to a local listener with reply, and returns its address.
+func serveWhois(t *testing.T, reply string) *net.TCPAddr {
+	return serveWhoisRequest(t, reply, nil)
+}
+
+// serveWhoisRequest is serveWhois, sending the request, read until a line that's a single query or
+// 'end', to requests if it isn't nil.
+func serveWhoisRequest(t *testing.T, reply string, requests chan<- string) *net.TCPAddr {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			r := bufio.NewReader(conn)
+			req := ""
+			for {
+				line, err := r.ReadString('\n')
+				req += line
+				if err != nil || !strings.HasPrefix(req, "begin") || line == "end\n" {
+					break
+				}
+			}
+			if requests != nil {
+				requests <- req
+			}
+			conn.Write([]byte(reply))
+			conn.Close()
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr)
+}
+
 func Test_WhoisClient(t *testing.T) {
 	w, err := whois.New("bgp.tools", 43)
 	assert.NoError(t, err)
@@ -14,3 +70,39 @@ func Test_WhoisClient(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Contains(t, res, "Stellar")
 }
+
+// TestWhois_QueryMetrics swaps metrics.HOOK, so it can't run in parallel with tests that make
+// queries.
+func TestWhois_QueryMetrics(t *testing.T) {
+	m := &queryMetrics{}
+	prev := metrics.HOOK
+	metrics.HOOK = m
+	t.Cleanup(func() { metrics.HOOK = prev })
+
+	w := &whois.Whois{TCPAddr: serveWhois(t, "AS | Name\n14525 | Stellar\n")}
+	res, err := w.Query("as14525")
+	assert.NoError(t, err)
+	assert.Contains(t, res, "Stellar")
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	closed := ln.Addr().(*net.TCPAddr)
+	ln.Close()
+	_, err = (&whois.Whois{TCPAddr: closed}).Query("192.0.2.1")
+	assert.Error(t, err)
+
+	assert.Equal(t, []string{"asn", whois.QUERY_TYPE_CONNECT}, m.types)
+}
+
+func TestWhois_BulkQuery(t *testing.T) {
+	t.Parallel()
+	reply := "AS | IP | BGP Prefix | CC | Registry | Allocated | AS Name\n" +
+		"13335 | 1.1.1.1 | 1.1.1.0/24 | US | ARIN | 2010-07-14 | Cloudflare, Inc.\n" +
+		"15169 | 8.8.8.8 | 8.8.8.0/24 | US | ARIN | 1992-12-01 | Google LLC\n"
+	requests := make(chan string, 1)
+	w := &whois.Whois{TCPAddr: serveWhoisRequest(t, reply, requests)}
+	res, err := w.BulkQuery([]string{"1.1.1.1", "8.8.8.8\r\n"})
+	assert.NoError(t, err)
+	assert.Equal(t, reply, res)
+	assert.Equal(t, "begin\nverbose\n1.1.1.1\n8.8.8.8\nend\n", <-requests)
+}
@@ -0,0 +1,51 @@
+package addr_test
+
+import (
+	"bufio"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	addr "github.com/thatmattlove/addr/pkg"
+)
+
+// serveBulkWhois points WHOIS_HOST & WHOIS_PORT at a local listener that answers bulk queries with
+// reply, and returns a channel of the queries it receives. Tests using it can't run in parallel with
+// other tests that make lookups.
+func serveBulkWhois(t *testing.T, reply string) <-chan string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	host, port := addr.WHOIS_HOST, addr.WHOIS_PORT
+	addr.WHOIS_HOST, addr.WHOIS_PORT = "127.0.0.1", uint(ln.Addr().(*net.TCPAddr).Port)
+	t.Cleanup(func() {
+		ln.Close()
+		addr.WHOIS_HOST, addr.WHOIS_PORT = host, port
+	})
+	requests := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			r := bufio.NewReader(conn)
+			req := ""
+			for {
+				line, err := r.ReadString('\n')
+				req += line
+				if err != nil || line == "end\n" {
+					break
+				}
+			}
+			// whois.New connects once without sending anything.
+			if req != "" {
+				requests <- req
+				conn.Write([]byte(reply))
+			}
+			conn.Close()
+		}
+	}()
+	return requests
+}